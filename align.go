@@ -0,0 +1,56 @@
+package spf
+
+import (
+	"golang.org/x/net/publicsuffix"
+)
+
+// AlignmentMode is the DMARC identifier alignment mode.
+type AlignmentMode string
+
+const (
+	StrictAlignment  AlignmentMode = "s"
+	RelaxedAlignment AlignmentMode = "r"
+)
+
+// OrganizationalDomain returns the organizational domain of the domain, the
+// registered domain directly below its public suffix according to the Public
+// Suffix List, e.g. "mail.example.com" becomes "example.com" and
+// "mail.example.co.uk" "example.co.uk". A domain that is itself a public
+// suffix is returned as is.
+func OrganizationalDomain(domain string) string {
+	domain = canonicalName(domain)
+
+	org, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return domain
+	}
+
+	return org
+}
+
+// Aligned reports whether the domain authenticated by SPF, the domain of the
+// MAIL FROM identity or of the HELO identity, is aligned with the domain of
+// the RFC5322.From header as defined in RFC 7489 section 3.1.2. Strict
+// alignment requires both domains to be identical, relaxed alignment only
+// requires them to share the same organizational domain.
+func Aligned(spfDomain, fromDomain string, mode AlignmentMode) bool {
+	spfDomain = canonicalName(spfDomain)
+	fromDomain = canonicalName(fromDomain)
+
+	if spfDomain == "" || fromDomain == "" {
+		return false
+	}
+
+	if mode == StrictAlignment {
+		return spfDomain == fromDomain
+	}
+
+	return OrganizationalDomain(spfDomain) == OrganizationalDomain(fromDomain)
+}
+
+// SPFAligned reports whether the result authenticates the spfDomain and that
+// domain is aligned with the fromDomain. Only a Pass result can produce an
+// aligned SPF identifier for DMARC.
+func SPFAligned(result Result, spfDomain, fromDomain string, mode AlignmentMode) bool {
+	return result == Pass && Aligned(spfDomain, fromDomain, mode)
+}
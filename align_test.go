@@ -0,0 +1,58 @@
+package spf
+
+import (
+	"testing"
+)
+
+type aligntest struct {
+	spfDomain  string
+	fromDomain string
+	mode       AlignmentMode
+	aligned    bool
+}
+
+func TestAligned(t *testing.T) {
+	tests := []aligntest{
+		aligntest{"example.com", "example.com", StrictAlignment, true},
+		aligntest{"Example.COM.", "example.com", StrictAlignment, true},
+		aligntest{"bounce.example.com", "example.com", StrictAlignment, false},
+		aligntest{"bounce.example.com", "example.com", RelaxedAlignment, true},
+		aligntest{"bounce.example.com", "news.example.com", RelaxedAlignment, true},
+		aligntest{"example.net", "example.com", RelaxedAlignment, false},
+		aligntest{"", "example.com", RelaxedAlignment, false},
+	}
+
+	for _, tcase := range tests {
+		if Aligned(tcase.spfDomain, tcase.fromDomain, tcase.mode) != tcase.aligned {
+			t.Error("For", tcase.spfDomain, tcase.fromDomain, tcase.mode, "Expected", tcase.aligned)
+		}
+	}
+
+	if SPFAligned(SoftFail, "example.com", "example.com", StrictAlignment) {
+		t.Error("Expected SoftFail to not be aligned")
+	}
+}
+
+func TestOrganizationalDomain(t *testing.T) {
+	tests := map[string]string{
+		"mail.example.com":    "example.com",
+		"Example.COM.":        "example.com",
+		"mail.example.co.uk":  "example.co.uk",
+		"a.b.example.com.au":  "example.com.au",
+		"bounce.example.test": "example.test",
+		"co.uk":               "co.uk",
+		"com":                 "com",
+	}
+
+	for domain, org := range tests {
+		t.Log("Analyzing", domain)
+
+		if got := OrganizationalDomain(domain); got != org {
+			t.Error("Expected", org, "got", got)
+		}
+	}
+
+	if Aligned("bounce.example.co.uk", "other.co.uk", RelaxedAlignment) {
+		t.Error("Expected", false, "got", true)
+	}
+}
@@ -0,0 +1,56 @@
+package spf
+
+import (
+	"strings"
+)
+
+// ARCResult is the SPF result an intermediary recorded in the
+// ARC-Authentication-Results header of an ARC set.
+type ARCResult struct {
+	// Instance is the instance number (i=) of the ARC set.
+	Instance int
+
+	// Result is the spf= result recorded by the intermediary.
+	Result Result
+
+	// Sender is the smtp.mailfrom property of the result, if any.
+	Sender string
+
+	// Trusted reports that the ARC chain validated and the intermediary
+	// that sealed the set is trusted by local policy.
+	Trusted bool
+}
+
+// applyARC replaces a Fail or SoftFail result, the typical outcome of a check
+// on a forwarded message, by Pass when a trusted intermediary reported a
+// Pass for the same sender domain.
+func (r *CheckResult) applyARC(req Request, domain string) {
+	if r.Result != Fail && r.Result != SoftFail {
+		return
+	}
+
+	trusted := req.PriorPass
+	for _, arc := range req.ARC {
+		if !arc.Trusted || arc.Result != Pass {
+			continue
+		}
+
+		if arc.Sender != "" && !strings.EqualFold(senderDomain(arc.Sender), domain) {
+			continue
+		}
+
+		trusted = true
+		break
+	}
+
+	if trusted {
+		r.DirectResult = r.Result
+		r.Result = Pass
+		r.Override = "arc"
+	}
+}
+
+// senderDomain returns the domain of an email address.
+func senderDomain(sender string) string {
+	return macroContext{sender: sender}.senderDomain()
+}
@@ -0,0 +1,167 @@
+package spf
+
+// Span is the byte range [Start, End) of a part of a record. The zero Span
+// marks a part absent from the term.
+type Span struct {
+	Start int
+	End   int
+}
+
+// IsZero reports whether the span is absent.
+func (s Span) IsZero() bool {
+	return s == Span{}
+}
+
+// TermKind is the kind of a term of a record.
+type TermKind int
+
+const (
+	VersionTerm TermKind = iota
+	MechanismTerm
+	ModifierTerm
+	InvalidTerm
+)
+
+// Return a TermKind as a string.
+func (k TermKind) String() string {
+	switch k {
+	case VersionTerm:
+		return "version"
+	case MechanismTerm:
+		return "mechanism"
+	case ModifierTerm:
+		return "modifier"
+	}
+
+	return "invalid"
+}
+
+// Term is a term of a record located in the record text.
+type Term struct {
+	Kind TermKind
+	Text string
+
+	// Span is the location of the whole term. Qualifier, Name, Value and
+	// Prefix locate its parts: the qualifier character, the mechanism or
+	// modifier name, the domain-spec, address or modifier value, and the
+	// cidr-length without its leading "/".
+	Span      Span
+	Qualifier Span
+	Name      Span
+	Value     Span
+	Prefix    Span
+
+	// Mechanism and Modifier hold the parsed term, both are nil for the
+	// version and invalid terms. Err describes why an invalid term was
+	// rejected.
+	Mechanism *Mechanism
+	Modifier  *Modifier
+	Err       error
+}
+
+// AST is a record split into located terms, for tools highlighting or
+// fixing records in place.
+type AST struct {
+	Raw   string
+	Terms []Term
+}
+
+// ParseAST splits the record of the domain into terms with their location.
+// Unlike NewSPF, invalid terms do not stop the parse, they are returned as
+// InvalidTerm with the error NewSPFLenient would warn about.
+func ParseAST(domain, record string) *AST {
+	ast := &AST{Raw: record}
+
+	for start := 0; start < len(record); {
+		if record[start] == ' ' || record[start] == '\t' {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(record) && record[end] != ' ' && record[end] != '\t' {
+			end++
+		}
+
+		ast.Terms = append(ast.Terms, parseTerm(domain, record, start, end, len(ast.Terms) == 0))
+		start = end
+	}
+
+	return ast
+}
+
+// parseTerm locates the parts of the term at record[start:end].
+func parseTerm(domain, record string, start, end int, first bool) Term {
+	text := record[start:end]
+	t := Term{Text: text, Span: Span{start, end}}
+
+	if first && len(text) > 2 && text[:2] == "v=" {
+		t.Kind = VersionTerm
+		t.Name = Span{start, start + 1}
+		t.Value = Span{start + 2, end}
+		return t
+	}
+
+	i := start
+	if i < end && (record[i] == '+' || record[i] == '-' || record[i] == '~' || record[i] == '?') {
+		t.Qualifier = Span{i, i + 1}
+		i++
+	}
+
+	name := i
+	for i < end && isNameChar(record[i], i == name) {
+		i++
+	}
+	if i > name {
+		t.Name = Span{name, i}
+	}
+
+	t.Kind = MechanismTerm
+	switch {
+	case i < end && record[i] == '=':
+		t.Kind = ModifierTerm
+		t.Value = Span{i + 1, end}
+		i = end
+	case i < end && record[i] == ':':
+		spec, _ := splitDomainSpec(record[i+1 : end])
+		t.Value = Span{i + 1, i + 1 + len(spec)}
+		i = t.Value.End
+	}
+
+	if i < end && record[i] == '/' {
+		t.Prefix = Span{i + 1, end}
+	}
+
+	if modifier, ok, err := parseModifier(text); ok {
+		if err != nil {
+			t.Kind = InvalidTerm
+			t.Err = termError(text, Mechanism{}, err)
+			return t
+		}
+
+		t.Modifier = &modifier
+		return t
+	}
+
+	m, err := NewMechanism(text, domain)
+	if err == nil {
+		err = m.validate()
+	}
+
+	if err != nil {
+		t.Kind = InvalidTerm
+		t.Err = termError(text, m, err)
+		return t
+	}
+
+	// redirect:domain is taken for redirect=domain.
+	if isModifier(m.Name) {
+		t.Kind = ModifierTerm
+		t.Modifier = &Modifier{Name: m.Name, Value: m.Domain}
+		return t
+	}
+
+	t.Mechanism = &m
+
+	return t
+}
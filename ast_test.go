@@ -0,0 +1,52 @@
+package spf
+
+import (
+	"testing"
+)
+
+func TestParseAST(t *testing.T) {
+	record := "v=spf1  -ip4:192.0.2.0/24 include:example..com\tredirect=%{d}._spf.example.com mx/24//64 foo:bar"
+
+	ast := ParseAST("example.com", record)
+	if len(ast.Terms) != 6 {
+		t.Fatal("Expected 6 terms got", ast.Terms)
+	}
+
+	part := func(s Span) string {
+		return record[s.Start:s.End]
+	}
+
+	expected := []struct {
+		kind      TermKind
+		text      string
+		qualifier string
+		name      string
+		value     string
+		prefix    string
+	}{
+		{VersionTerm, "v=spf1", "", "v", "spf1", ""},
+		{MechanismTerm, "-ip4:192.0.2.0/24", "-", "ip4", "192.0.2.0", "24"},
+		{InvalidTerm, "include:example..com", "", "include", "example..com", ""},
+		{ModifierTerm, "redirect=%{d}._spf.example.com", "", "redirect", "%{d}._spf.example.com", ""},
+		{MechanismTerm, "mx/24//64", "", "mx", "", "24//64"},
+		{InvalidTerm, "foo:bar", "", "foo", "bar", ""},
+	}
+
+	for i, term := range ast.Terms {
+		e := expected[i]
+		t.Log("Analyzing", term.Text)
+
+		if term.Kind != e.kind || part(term.Span) != e.text || part(term.Qualifier) != e.qualifier ||
+			part(term.Name) != e.name || part(term.Value) != e.value || part(term.Prefix) != e.prefix {
+			t.Error("Expected", e, "got", term.Kind, part(term.Span), part(term.Qualifier), part(term.Name), part(term.Value), part(term.Prefix))
+		}
+	}
+
+	if ast.Terms[2].Err != ErrInvalidDomain || ast.Terms[5].Err != ErrUnknownMechanism {
+		t.Error("Expected term errors got", ast.Terms[2].Err, ast.Terms[5].Err)
+	}
+
+	if ast.Terms[1].Mechanism == nil || ast.Terms[1].Mechanism.Result != Fail {
+		t.Error("Expected parsed mechanism got", ast.Terms[1].Mechanism)
+	}
+}
@@ -0,0 +1,285 @@
+package spf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AuthResult is an spf= result of an Authentication-Results header field as
+// defined in RFC 8601. Properties holds the result properties keyed by
+// "ptype.property", e.g. "smtp.mailfrom". Instance is set for results taken
+// from ARC-Authentication-Results header fields.
+type AuthResult struct {
+	AuthServID string
+	Instance   int
+	Result     Result
+	Reason     string
+	Comment    string
+	Properties map[string]string
+}
+
+// Return the result as an Authentication-Results result clause, e.g.
+// "spf=pass smtp.mailfrom=example.com".
+func (r AuthResult) String() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("spf=%s", strings.ToLower(string(r.Result))))
+
+	if r.Comment != "" {
+		buf.WriteString(fmt.Sprintf(" (%s)", r.Comment))
+	}
+
+	if r.Reason != "" {
+		buf.WriteString(fmt.Sprintf(" reason=%s", strconv.Quote(r.Reason)))
+	}
+
+	keys := make([]string, 0, len(r.Properties))
+	for k := range r.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		buf.WriteString(fmt.Sprintf(" %s=%s", k, r.Properties[k]))
+	}
+
+	return buf.String()
+}
+
+// ARC converts a result taken from an ARC-Authentication-Results header
+// field into an ARCResult. Trusted must only be set when the ARC chain
+// validated and the sealer is trusted.
+func (r AuthResult) ARC(trusted bool) ARCResult {
+	return ARCResult{
+		Instance: r.Instance,
+		Result:   r.Result,
+		Sender:   r.Properties["smtp.mailfrom"],
+		Trusted:  trusted,
+	}
+}
+
+// ParseAuthenticationResults extracts the spf= results of an
+// Authentication-Results or ARC-Authentication-Results header field. The
+// field name may be included. Results of other methods are ignored.
+func ParseAuthenticationResults(header string) ([]AuthResult, error) {
+	var results []AuthResult
+
+	value := unfold(header)
+	if i := strings.Index(value, ":"); i != -1 {
+		name := strings.TrimSpace(value[:i])
+		if strings.EqualFold(name, "Authentication-Results") || strings.EqualFold(name, "ARC-Authentication-Results") {
+			value = value[i+1:]
+		}
+	}
+
+	segments, err := splitOutside(value, ';')
+	if err != nil {
+		return nil, err
+	}
+
+	instance := 0
+	if len(segments) > 0 {
+		first := strings.TrimSpace(segments[0])
+		if strings.HasPrefix(strings.ToLower(first), "i=") {
+			instance, err = strconv.Atoi(strings.TrimSpace(first[2:]))
+			if err != nil {
+				return nil, ErrInvalidHeader
+			}
+			segments = segments[1:]
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, ErrInvalidHeader
+	}
+
+	// The authserv-id may be followed by a version number.
+	id := strings.Fields(stripComments(segments[0]))
+	if len(id) == 0 {
+		return nil, ErrInvalidHeader
+	}
+
+	for _, segment := range segments[1:] {
+		tokens, comment, err := resinfoTokens(segment)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tokens) == 0 || strings.EqualFold(tokens[0], "none") {
+			continue
+		}
+
+		method, value, ok := cutToken(tokens[0])
+		if !ok {
+			return nil, ErrInvalidHeader
+		}
+
+		if i := strings.Index(method, "/"); i != -1 {
+			method = method[:i]
+		}
+
+		if !strings.EqualFold(method, "spf") {
+			continue
+		}
+
+		result, err := ParseResult(value)
+		if err != nil {
+			return nil, ErrInvalidHeader
+		}
+
+		r := AuthResult{
+			AuthServID: id[0],
+			Instance:   instance,
+			Result:     result,
+			Comment:    comment,
+			Properties: make(map[string]string),
+		}
+
+		for _, token := range tokens[1:] {
+			key, value, ok := cutToken(token)
+			if !ok {
+				return nil, ErrInvalidHeader
+			}
+
+			if strings.EqualFold(key, "reason") {
+				r.Reason = value
+			} else {
+				r.Properties[strings.ToLower(key)] = value
+			}
+		}
+
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+// cutToken splits a key=value token.
+func cutToken(token string) (string, string, bool) {
+	i := strings.Index(token, "=")
+	if i <= 0 {
+		return "", "", false
+	}
+
+	return token[:i], token[i+1:], true
+}
+
+// splitOutside splits s at sep outside of quoted strings and comments.
+func splitOutside(s string, sep byte) ([]string, error) {
+	var parts []string
+	var quoted, escaped bool
+	var depth, start int
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case quoted:
+			quoted = s[i] != '"'
+		case s[i] == '"':
+			quoted = true
+		case s[i] == '(':
+			depth++
+		case s[i] == ')':
+			depth--
+		case s[i] == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	if quoted || depth != 0 {
+		return nil, ErrInvalidHeader
+	}
+
+	return append(parts, s[start:]), nil
+}
+
+// stripComments removes the comments from s.
+func stripComments(s string) string {
+	var buf strings.Builder
+	depth := 0
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '(':
+			depth++
+		case s[i] == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			buf.WriteByte(s[i])
+		}
+	}
+
+	return buf.String()
+}
+
+// resinfoTokens splits a result clause into whitespace separated tokens,
+// unquoting values, and returns the first comment found.
+func resinfoTokens(s string) ([]string, string, error) {
+	var tokens []string
+	var comment string
+	var buf strings.Builder
+
+	flush := func() {
+		if buf.Len() > 0 {
+			tokens = append(tokens, buf.String())
+			buf.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '(':
+			flush()
+			text, n, err := readComment(s[i:])
+			if err != nil {
+				return nil, "", err
+			}
+			if comment == "" {
+				comment = strings.TrimSpace(text)
+			}
+			i += n - 1
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				buf.WriteByte(s[j])
+			}
+			if j == len(s) {
+				return nil, "", ErrInvalidHeader
+			}
+			i = j
+		case c == ' ' || c == '\t':
+			flush()
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	flush()
+
+	// Allow whitespace around "=", e.g. "spf = pass".
+	var joined []string
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if i+1 < len(tokens) && (strings.HasSuffix(t, "=") || strings.HasPrefix(tokens[i+1], "=")) {
+			t += tokens[i+1]
+			i++
+			if strings.HasSuffix(t, "=") && i+1 < len(tokens) {
+				t += tokens[i+1]
+				i++
+			}
+		}
+		joined = append(joined, t)
+	}
+
+	return joined, comment, nil
+}
@@ -0,0 +1,64 @@
+package spf
+
+import (
+	"testing"
+)
+
+func TestParseAuthenticationResults(t *testing.T) {
+	header := "Authentication-Results: mx.example.org 1;\r\n" +
+		"\tdkim=pass header.d=example.com;\r\n" +
+		"\tspf=softfail (sender IP is 192.0.2.1) smtp.mailfrom=example.com reason=\"not listed\";\r\n" +
+		"\tspf = pass smtp.helo=mail.example.com"
+
+	results, err := ParseAuthenticationResults(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 {
+		t.Fatal("Expected 2 results got", results)
+	}
+
+	r := results[0]
+	if r.AuthServID != "mx.example.org" || r.Result != SoftFail || r.Comment != "sender IP is 192.0.2.1" ||
+		r.Reason != "not listed" || r.Properties["smtp.mailfrom"] != "example.com" {
+		t.Error("Unexpected result", r)
+	}
+
+	expected := `spf=softfail (sender IP is 192.0.2.1) reason="not listed" smtp.mailfrom=example.com`
+	if r.String() != expected {
+		t.Error("Expected", expected, "got", r.String())
+	}
+
+	if results[1].Result != Pass || results[1].Properties["smtp.helo"] != "mail.example.com" {
+		t.Error("Unexpected result", results[1])
+	}
+
+	arc, err := ParseAuthenticationResults("ARC-Authentication-Results: i=2; lists.example.org; spf=pass smtp.mailfrom=info@example.com")
+	if err != nil || len(arc) != 1 {
+		t.Fatal("Unexpected results", arc, err)
+	}
+
+	a := arc[0].ARC(true)
+	if a.Instance != 2 || a.Result != Pass || a.Sender != "info@example.com" || !a.Trusted {
+		t.Error("Unexpected ARC result", a)
+	}
+
+	none, err := ParseAuthenticationResults("Authentication-Results: mx.example.org; none")
+	if err != nil || len(none) != 0 {
+		t.Error("Unexpected results", none, err)
+	}
+
+	invalid := []string{
+		"Authentication-Results: ",
+		"Authentication-Results: mx.example.org; spf=maybe",
+		"Authentication-Results: mx.example.org; spf=pass (open",
+		"Authentication-Results: mx.example.org; spf=pass smtp.mailfrom",
+	}
+
+	for _, header := range invalid {
+		if _, err := ParseAuthenticationResults(header); err != ErrInvalidHeader {
+			t.Error("For", header, "Expected", ErrInvalidHeader, "got", err)
+		}
+	}
+}
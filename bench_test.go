@@ -0,0 +1,202 @@
+package spf
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// largeRecord returns a flattened record with n ip4 and n ip6 mechanisms.
+func largeRecord(n int) string {
+	terms := []string{"v=spf1"}
+	for i := 0; i < n; i++ {
+		terms = append(terms, fmt.Sprintf("ip4:198.51.%d.%d/32", i/256, i%256), fmt.Sprintf("ip6:2001:db8:%x::/48", i))
+	}
+
+	return strings.Join(append(terms, "-all"), " ")
+}
+
+// chainZone returns a zone where example.com includes a chain of depth
+// records, the last one authorizing 192.0.2.0/24.
+func chainZone(depth int) *Zone {
+	z := NewZone()
+	for i := 0; i < depth; i++ {
+		record := fmt.Sprintf("v=spf1 ip4:198.51.100.%d include:_spf%d.example.com -all", i, i+1)
+		if i == depth-1 {
+			record = "v=spf1 ip4:192.0.2.0/24 -all"
+		}
+
+		name := fmt.Sprintf("_spf%d.example.com", i)
+		if i == 0 {
+			name = "example.com"
+		}
+
+		z.Add(ZoneRecord{Name: name, Type: "TXT", Data: record})
+	}
+
+	return z
+}
+
+func BenchmarkParseFlattened(b *testing.B) {
+	record := largeRecord(200)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(record)))
+	for i := 0; i < b.N; i++ {
+		if _, err := NewSPF("example.com", record, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSPFString(b *testing.B) {
+	s, err := NewSPF("example.com", largeRecord(200), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.SPFString()
+	}
+}
+
+func BenchmarkEvaluateFlattened(b *testing.B) {
+	c := &Checker{Source: MapSource{"example.com": largeRecord(200)}}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if result, _ := c.SPFTest(ctx, "203.0.113.1", "info@example.com"); result != Fail {
+			b.Fatal("Expected", Fail, "got", result)
+		}
+	}
+}
+
+func BenchmarkEvaluateIncludeChain(b *testing.B) {
+	c := &Checker{Resolver: chainZone(9)}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if result, _ := c.SPFTest(ctx, "192.0.2.1", "info@example.com"); result != Pass {
+			b.Fatal("Expected", Pass, "got", result)
+		}
+	}
+}
+
+func BenchmarkEvaluateCacheHit(b *testing.B) {
+	c := &Checker{Resolver: &CachingResolver{Resolver: chainZone(9)}}
+	ctx := context.Background()
+	c.SPFTest(ctx, "192.0.2.1", "info@example.com")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if result, _ := c.SPFTest(ctx, "192.0.2.1", "info@example.com"); result != Pass {
+			b.Fatal("Expected", Pass, "got", result)
+		}
+	}
+}
+
+func BenchmarkMemoryCacheGet(b *testing.B) {
+	c := &MemoryCache{}
+	ctx := context.Background()
+	c.Set(ctx, "TXT example.com", []byte(`{"v":["v=spf1 -all"]}`), DefaultTTL)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Get(ctx, "TXT example.com"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTestLiterals(b *testing.B) {
+	s, err := NewSPF("example.com", largeRecord(200), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if s.Test("203.0.113.1") != Fail {
+			b.Fatal("Expected", Fail)
+		}
+	}
+}
+
+func TestLiteralsAllocations(t *testing.T) {
+	s, err := NewSPF("example.com", "v=spf1 ip4:192.0.2.0/24 -ip6:2001:db8::/32 ip6:2001:db8:1::1 ~all", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []spftest{
+		spftest{"192.0.2.1", "", Pass},
+		spftest{"::ffff:192.0.2.1", "", Pass},
+		spftest{"2001:db8::1", "", Fail},
+		spftest{"203.0.113.1", "", SoftFail},
+	}
+
+	for _, expected := range tests {
+		var result Result
+		allocs := testing.AllocsPerRun(100, func() {
+			result = s.Test(expected.server)
+		})
+
+		if result != expected.result {
+			t.Error("For", expected.server, "Expected", expected.result, "got", result)
+		}
+
+		if allocs != 0 {
+			t.Error("For", expected.server, "Expected no allocations got", allocs)
+		}
+	}
+}
+
+func BenchmarkEvaluateMX(b *testing.B) {
+	z := NewZone()
+	z.Add(ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 a mx/24 -all"})
+	z.Add(ZoneRecord{Name: "example.com", Type: "A", Data: "198.51.100.1"})
+	z.Add(ZoneRecord{Name: "example.com", Type: "MX", Data: "10 mx1.example.com"})
+	z.Add(ZoneRecord{Name: "example.com", Type: "MX", Data: "20 mx2.example.com"})
+	z.Add(ZoneRecord{Name: "mx1.example.com", Type: "A", Data: "198.51.100.25"})
+	z.Add(ZoneRecord{Name: "mx2.example.com", Type: "A", Data: "192.0.2.25"})
+
+	c := &Checker{Resolver: z}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if result, _ := c.SPFTest(ctx, "192.0.2.1", "info@example.com"); result != Pass {
+			b.Fatal("Expected", Pass, "got", result)
+		}
+	}
+}
+
+func TestSPFStringAllocations(t *testing.T) {
+	s, err := NewSPF("example.com", "v=spf1 a mx:example.net/24 ip4:192.0.2.0/24 ip6:2001:DB8::/32 include:_spf.example.net -all", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "v=spf1 a mx:example.net/24 ip4:192.0.2.0/24 ip6:2001:db8::/32 include:_spf.example.net -all"
+
+	var str string
+	allocs := testing.AllocsPerRun(100, func() {
+		str = s.SPFString()
+	})
+
+	if str != expected {
+		t.Error("Expected", expected, "got", str)
+	}
+
+	// Only the returned string is allocated.
+	if allocs != 1 {
+		t.Error("Expected 1 allocation got", allocs)
+	}
+}
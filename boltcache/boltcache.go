@@ -0,0 +1,79 @@
+// Package boltcache provides a bbolt backed spf.Cache, keeping SPF
+// resolution state on disk across restarts.
+package boltcache
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/asggo/spf"
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultBucket is the bucket used when Cache.Bucket is empty.
+var DefaultBucket = []byte("spf")
+
+// Cache is an spf.Cache storing values in a bbolt database. Each value is
+// stored with its expiry time, expired values are ignored by Get and
+// replaced by the next Set.
+type Cache struct {
+	DB     *bolt.DB
+	Bucket []byte
+}
+
+// New returns a Cache storing values in the default bucket of the database.
+func New(db *bolt.DB) *Cache {
+	return &Cache{DB: db, Bucket: DefaultBucket}
+}
+
+func (c *Cache) bucket() []byte {
+	if len(c.Bucket) == 0 {
+		return DefaultBucket
+	}
+
+	return c.Bucket
+}
+
+// Get returns the value of the key, spf.ErrCacheMiss if not set or expired.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+
+	err := c.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(c.bucket())
+		if b == nil {
+			return spf.ErrCacheMiss
+		}
+
+		data := b.Get([]byte(key))
+		if len(data) < 8 {
+			return spf.ErrCacheMiss
+		}
+
+		expires := time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+		if !time.Now().Before(expires) {
+			return spf.ErrCacheMiss
+		}
+
+		value = append([]byte(nil), data[8:]...)
+		return nil
+	})
+
+	return value, err
+}
+
+// Set stores the value of the key for the ttl.
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	data := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(data, uint64(time.Now().Add(ttl).UnixNano()))
+	data = append(data, value...)
+
+	return c.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(c.bucket())
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(key), data)
+	})
+}
@@ -0,0 +1,38 @@
+package boltcache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asggo/spf"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestCache(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "spf.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	c := New(db)
+	ctx := context.Background()
+
+	if _, err := c.Get(ctx, "TXT example.com"); err != spf.ErrCacheMiss {
+		t.Error("Expected", spf.ErrCacheMiss, "got", err)
+	}
+
+	c.Set(ctx, "TXT example.com", []byte("value"), time.Hour)
+	c.Set(ctx, "TXT expired.example.com", []byte("value"), -time.Second)
+
+	value, err := c.Get(ctx, "TXT example.com")
+	if err != nil || string(value) != "value" {
+		t.Error("Expected value got", string(value), err)
+	}
+
+	if _, err := c.Get(ctx, "TXT expired.example.com"); err != spf.ErrCacheMiss {
+		t.Error("Expected", spf.ErrCacheMiss, "got", err)
+	}
+}
@@ -0,0 +1,221 @@
+package spf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+)
+
+// IncludeCost is the share of the lookup limit taken by a top-level include
+// or by the redirect of a record.
+type IncludeCost struct {
+	// Term is the include or redirect, e.g. "include:_spf.example.net".
+	Term string
+
+	// Provider is the known mail provider of the term, see
+	// IdentifyProvider.
+	Provider string
+
+	// Lookups counts the term itself and every lookup of the records it
+	// references. Unknown lists the domains of the subtree whose record
+	// was not found or depends on macros, counted as a single lookup.
+	Lookups int
+	Unknown []string
+
+	// Savings is the number of lookups flattening the subtree saves. The
+	// ptr and exists terms of the subtree keep their lookups once
+	// flattened, and subtrees with unknown records cannot be flattened.
+	Savings int
+
+	// Err is why the subtree could not be analyzed, e.g. ErrIncludeLoop.
+	Err error
+}
+
+// LookupBreakdown shows how the lookups of the record of a domain are spent
+// and which terms to flatten, or else drop, to bring them down to Target.
+type LookupBreakdown struct {
+	Domain  string
+	Target  int
+	Lookups int
+
+	// Own counts the lookups of the a, mx, ptr and exists terms of the
+	// record itself.
+	Own int
+
+	// Includes holds the cost of each include, in record order, then of
+	// the redirect.
+	Includes []IncludeCost
+
+	// Flatten lists the terms to flatten, by decreasing savings, and Drop
+	// the terms to remove, by decreasing cost, when flattening is not
+	// enough to reach Target. Both are empty when the record is within it.
+	Flatten []string
+	Drop    []string
+}
+
+// BreakDownLookups breaks down the lookups of the record of the domain using
+// the DefaultChecker. See Checker.BreakDownLookups.
+func BreakDownLookups(ctx context.Context, domain string, target int) (*LookupBreakdown, error) {
+	return DefaultChecker.BreakDownLookups(ctx, domain, target)
+}
+
+// BreakDownLookups fetches the record of the domain and the records it
+// references and reports the lookups each top-level include consumes. The
+// record is parsed leniently, so records over the limit can be analyzed. A
+// target of zero or less is the most lookups NewSPF accepts, MaxCount - 1.
+// The a and mx terms of the included records are resolved to compute the
+// savings of flattening them.
+func (c *Checker) BreakDownLookups(ctx context.Context, domain string, target int) (*LookupBreakdown, error) {
+	spf, _, err := c.NewSPFLenient(ctx, domain, "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if target <= 0 {
+		target = MaxCount - 1
+	}
+
+	b := &LookupBreakdown{Domain: domain, Target: target}
+
+	for _, m := range spf.terms() {
+		if m.Name != "include" && m.Name != "redirect" {
+			b.Own += lookupCost(m)
+			continue
+		}
+
+		b.Includes = append(b.Includes, c.includeCost(ctx, domain, m))
+	}
+
+	b.Lookups = b.Own
+	for _, inc := range b.Includes {
+		b.Lookups += inc.Lookups
+	}
+
+	b.plan()
+
+	return b, nil
+}
+
+// includeCost computes the cost of the include or redirect m of the record
+// of domain.
+func (c *Checker) includeCost(ctx context.Context, domain string, m Mechanism) IncludeCost {
+	cost := IncludeCost{Term: m.SPFString(), Provider: IdentifyProvider(m), Lookups: 1}
+
+	var record string
+	if !hasMacro(m.Domain) {
+		record, _ = c.source().Record(ctx, m.Domain)
+	}
+
+	if record == "" {
+		cost.Unknown = []string{m.Domain}
+		return cost
+	}
+
+	var est Estimate
+	e := estimator{checker: c, visited: map[string]bool{canonicalName(domain): true}, est: &est}
+	if cost.Err = e.estimate(m.Domain, record); cost.Err != nil {
+		return cost
+	}
+
+	cost.Lookups += est.Lookups
+	cost.Unknown = est.Unknown
+	if len(cost.Unknown) > 0 {
+		return cost
+	}
+
+	f := flattener{ctx: ctx, checker: c, visited: map[string]bool{domain: true}}
+	target, err := f.record(m.Domain)
+	if err != nil {
+		return cost
+	}
+
+	flat, err := f.flatten(target, Pass, false)
+	if err != nil {
+		return cost
+	}
+
+	cost.Savings = cost.Lookups
+	for _, t := range flat {
+		cost.Savings -= lookupCost(t)
+	}
+
+	return cost
+}
+
+// plan fills Flatten and Drop.
+func (b *LookupBreakdown) plan() {
+	lookups := b.Lookups
+
+	order := make([]int, len(b.Includes))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return b.Includes[order[i]].Savings > b.Includes[order[j]].Savings
+	})
+
+	flattened := make(map[int]bool)
+	for _, i := range order {
+		if lookups <= b.Target || b.Includes[i].Savings == 0 {
+			break
+		}
+
+		b.Flatten = append(b.Flatten, b.Includes[i].Term)
+		lookups -= b.Includes[i].Savings
+		flattened[i] = true
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return b.Includes[order[i]].Lookups > b.Includes[order[j]].Lookups
+	})
+
+	for _, i := range order {
+		if lookups <= b.Target {
+			break
+		}
+
+		if flattened[i] {
+			continue
+		}
+
+		b.Drop = append(b.Drop, b.Includes[i].Term)
+		lookups -= b.Includes[i].Lookups
+	}
+}
+
+// Return a LookupBreakdown as text, one line per include followed by the
+// recommendations.
+func (b *LookupBreakdown) String() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("%s: %d lookups, target %d\n", b.Domain, b.Lookups, b.Target))
+	buf.WriteString(fmt.Sprintf("  own terms: %d\n", b.Own))
+
+	for _, inc := range b.Includes {
+		term := inc.Term
+		if inc.Provider != "" {
+			term += " (" + inc.Provider + ")"
+		}
+
+		switch {
+		case inc.Err != nil:
+			buf.WriteString(fmt.Sprintf("  %s: %d (%s)\n", term, inc.Lookups, inc.Err))
+		case len(inc.Unknown) > 0:
+			buf.WriteString(fmt.Sprintf("  %s: at least %d\n", term, inc.Lookups))
+		default:
+			buf.WriteString(fmt.Sprintf("  %s: %d, flattening saves %d\n", term, inc.Lookups, inc.Savings))
+		}
+	}
+
+	for _, term := range b.Flatten {
+		buf.WriteString(fmt.Sprintf("flatten: %s\n", term))
+	}
+
+	for _, term := range b.Drop {
+		buf.WriteString(fmt.Sprintf("drop: %s\n", term))
+	}
+
+	return buf.String()
+}
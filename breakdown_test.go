@@ -0,0 +1,71 @@
+package spf
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBreakDownLookups(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 mx include:_spf.crm.example include:mail.bulk.example include:%{i}._spf.rbl.example include:relay.example -all"},
+		ZoneRecord{Name: "example.com", Type: "MX", Data: "mx.example.com"},
+		ZoneRecord{Name: "_spf.crm.example", Type: "TXT", Data: "v=spf1 include:_netblocks1.crm.example include:_netblocks2.crm.example include:_netblocks3.crm.example ~all"},
+		ZoneRecord{Name: "_netblocks1.crm.example", Type: "TXT", Data: "v=spf1 ip4:192.0.2.0/25 ~all"},
+		ZoneRecord{Name: "_netblocks2.crm.example", Type: "TXT", Data: "v=spf1 ip4:192.0.2.128/25 ~all"},
+		ZoneRecord{Name: "_netblocks3.crm.example", Type: "TXT", Data: "v=spf1 a:out.crm.example ~all"},
+		ZoneRecord{Name: "out.crm.example", Type: "A", Data: "198.51.100.1"},
+		ZoneRecord{Name: "mail.bulk.example", Type: "TXT", Data: "v=spf1 exists:%{i}.allow.bulk.example include:_ips.bulk.example -all"},
+		ZoneRecord{Name: "_ips.bulk.example", Type: "TXT", Data: "v=spf1 ip4:203.0.113.0/24 -all"},
+		ZoneRecord{Name: "relay.example", Type: "TXT", Data: "v=spf1 include:example.com -all"},
+	)
+
+	c := &Checker{Resolver: z}
+
+	b, err := c.BreakDownLookups(context.Background(), "example.com", 0)
+	if err != nil {
+		t.Fatal("Expected a breakdown got", err)
+	}
+
+	tests := []struct {
+		lookups int
+		savings int
+		unknown bool
+		err     error
+	}{
+		{5, 5, false, nil},
+		{3, 2, false, nil},
+		{1, 0, true, nil},
+		{1, 0, false, ErrIncludeLoop},
+	}
+
+	if len(b.Includes) != len(tests) {
+		t.Fatal("Expected", len(tests), "includes got", b.Includes)
+	}
+
+	for i, test := range tests {
+		inc := b.Includes[i]
+		t.Log("Analyzing", inc.Term)
+
+		if inc.Lookups != test.lookups || inc.Savings != test.savings || (len(inc.Unknown) > 0) != test.unknown || inc.Err != test.err {
+			t.Error("Expected", test.lookups, test.savings, test.unknown, test.err, "got", inc.Lookups, inc.Savings, inc.Unknown, inc.Err)
+		}
+	}
+
+	if b.Own != 1 || b.Lookups != 11 {
+		t.Error("Expected 1 and 11 lookups got", b.Own, b.Lookups)
+	}
+
+	if len(b.Flatten) != 1 || b.Flatten[0] != "include:_spf.crm.example" || len(b.Drop) != 0 {
+		t.Error("Expected to flatten the crm include got", b.Flatten, b.Drop)
+	}
+
+	b, err = c.BreakDownLookups(context.Background(), "example.com", 3)
+	if err != nil {
+		t.Fatal("Expected a breakdown got", err)
+	}
+
+	if len(b.Flatten) != 2 || len(b.Drop) != 1 || b.Drop[0] != "include:%{i}._spf.rbl.example" {
+		t.Error("Expected two includes flattened and one dropped got", b.Flatten, b.Drop)
+	}
+}
@@ -0,0 +1,78 @@
+package spf
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// BuildRecord creates an SPF record for the domain authorizing every entry of
+// the inventory. Entries may be IP addresses, CIDR networks or hostnames.
+// Addresses and networks are aggregated into the minimal set of ip4 and ip6
+// mechanisms, hostnames become a mechanisms. Hostnames are domain-specs and
+// may contain macros; those NewSPF would reject return the same error. The
+// record ends with an all mechanism using the given result, Fail if empty.
+// Results that cannot qualify it, such as None, return ErrNoTerminal. Use
+// Split on the result to obtain an include structure when the record is too
+// long to publish.
+func BuildRecord(domain string, inventory []string, all Result) (SPF, error) {
+	var spf SPF
+	var prefixes []netip.Prefix
+	var hosts []Mechanism
+
+	switch all {
+	case "":
+		all = Fail
+	case Pass, Fail, SoftFail, Neutral:
+	default:
+		return spf, ErrNoTerminal
+	}
+
+	spf.Domain = domain
+	spf.Version = "spf1"
+
+	seen := make(map[string]bool)
+	for _, entry := range inventory {
+		entry = strings.TrimSpace(entry)
+
+		if p, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, p)
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		// Macro letters are case sensitive, uppercase ones are URL escaped.
+		host := strings.TrimSuffix(entry, ".")
+		if !hasMacro(host) {
+			host = strings.ToLower(host)
+		}
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+
+		if err := validDomainSpec(host); err != nil {
+			return spf, err
+		}
+
+		hosts = append(hosts, Mechanism{Name: "a", Domain: host, Result: Pass})
+	}
+
+	for _, p := range AggregatePrefixes(prefixes) {
+		spf.Mechanisms = append(spf.Mechanisms, prefixMechanism(p, Pass))
+	}
+
+	spf.Mechanisms = append(spf.Mechanisms, hosts...)
+	spf.Mechanisms = append(spf.Mechanisms, Mechanism{Name: "all", Domain: domain, Result: all})
+	spf.Count = len(hosts)
+	spf.Raw = spf.SPFString()
+
+	if spf.Count >= MaxCount {
+		return spf, ErrMaxCount
+	}
+
+	return spf, nil
+}
@@ -0,0 +1,84 @@
+package spf
+
+import (
+	"testing"
+)
+
+func TestBuildRecord(t *testing.T) {
+	inventory := []string{
+		"192.0.2.0/25",
+		"192.0.2.128/25",
+		"192.0.2.10",
+		"2001:db8::1",
+		"Relay.example.com.",
+		"relay.example.com",
+	}
+
+	s, err := BuildRecord("example.com", inventory, SoftFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::1 a:relay.example.com ~all"
+	if s.SPFString() != expected {
+		t.Error("Expected", expected, "got", s.SPFString())
+	}
+
+	if s.Count != 1 {
+		t.Error("Expected count 1 got", s.Count)
+	}
+
+	if _, err := BuildRecord("example.com", []string{"not a host"}, ""); err != ErrInvalidDomain {
+		t.Error("Expected", ErrInvalidDomain, "got", err)
+	}
+
+	if _, err := BuildRecord("example.com", inventory, None); err != ErrNoTerminal {
+		t.Error("Expected", ErrNoTerminal, "got", err)
+	}
+}
+
+func TestBuildRecordDomainSpec(t *testing.T) {
+	tests := []struct {
+		host string
+		err  error
+	}{
+		{"%{i}._spf.example.com", nil},
+		{"%{ir}.%{v}._spf.%{D}", nil},
+		{"_spf.%{d2}", nil},
+		{"%{z}.example.com", ErrInvalidMacro},
+		{"%{i.example.com", ErrInvalidMacro},
+		{"relay..example.com", ErrInvalidDomain},
+		{".example.com", ErrInvalidDomain},
+		{"localhost", ErrInvalidDomain},
+		{"example.123", ErrInvalidDomain},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.host)
+
+		s, err := BuildRecord("example.com", []string{test.host}, "")
+		if err != test.err {
+			t.Error("Expected", test.err, "got", err)
+		}
+
+		// Build and Parse must accept and reject the same specs.
+		_, perr := NewSPF("example.com", "v=spf1 a:"+test.host+" -all", 0)
+		if perr != test.err {
+			t.Error("Expected", test.err, "got", perr)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		parsed, err := NewSPF("example.com", s.SPFString(), 0)
+		if err != nil {
+			t.Error("Expected nil got", err)
+			continue
+		}
+
+		if parsed.SPFString() != s.SPFString() {
+			t.Error("Expected", s.SPFString(), "got", parsed.SPFString())
+		}
+	}
+}
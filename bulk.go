@@ -0,0 +1,249 @@
+package spf
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultConcurrency is the number of checks run at once by the bulk
+	// functions when none is given.
+	DefaultConcurrency = 8
+)
+
+var (
+	ErrCheckPanic = errors.New("Check panicked.")
+)
+
+// CheckMany checks the requests using the DefaultChecker. See
+// Checker.CheckMany.
+func CheckMany(ctx context.Context, requests []Request, concurrency int) []CheckResult {
+	return DefaultChecker.CheckMany(ctx, requests, concurrency)
+}
+
+// CheckMany checks the requests with up to concurrency checks at once, or
+// DefaultConcurrency if zero or less, and returns their results in request
+// order. The checks share an in-memory cache of the DNS answers, so the
+// records and hosts of a domain are only looked up once for the whole
+// batch. A check failing, or even panicking in a custom Resolver or Source,
+// only affects its own result: a panic results in TempError and
+// ErrCheckPanic. Requests not started before the context is
+// cancelled result in TempError with the context's error.
+func (c *Checker) CheckMany(ctx context.Context, requests []Request, concurrency int) []CheckResult {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	b := c.batch()
+	results := make([]CheckResult, len(requests))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for n := 0; n < concurrency && n < len(requests); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					results[i] = CheckResult{Result: TempError, Err: err}
+					continue
+				}
+
+				results[i] = b.safeCheck(ctx, requests[i])
+			}
+		}()
+	}
+
+	for i := range requests {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// batch returns a copy of the Checker for a batch of checks, whose lookups
+// go through a shared in-memory cache. Concurrent identical lookups share a
+// single query.
+func (c *Checker) batch() *Checker {
+	b := *c
+	b.Nameservers = nil
+	b.Routes = nil
+	b.Resolver = &SharedResolver{Resolver: &CachingResolver{Resolver: c.resolver()}}
+
+	return &b
+}
+
+// safeCheck is Check, turning a panic into a TempError result.
+func (c *Checker) safeCheck(ctx context.Context, req Request) (cr CheckResult) {
+	defer func() {
+		if v := recover(); v != nil {
+			cr = CheckResult{Result: TempError, Err: ErrCheckPanic}
+		}
+	}()
+
+	return c.Check(ctx, req)
+}
+
+// csvHeader holds the columns written by CheckCSV.
+var csvHeader = []string{"ip", "sender", "helo", "result", "domain", "mechanism", "error", "duration_ms"}
+
+// CheckCSV checks the rows of r using the DefaultChecker. See
+// Checker.CheckCSV.
+func CheckCSV(ctx context.Context, r io.Reader, w io.Writer, concurrency int) error {
+	return DefaultChecker.CheckCSV(ctx, r, w, concurrency)
+}
+
+// CheckCSV reads CSV rows of ip,sender pairs from r, optionally followed by a
+// helo column, checks them like CheckMany, with up to concurrency checks at
+// once and a shared cache, and writes a row per input row to w,
+// in input order, with the columns ip, sender, helo, result, domain,
+// mechanism, error and duration_ms. A first row whose ip is not an address
+// is taken as a header and skipped. Rows without a valid address result in
+// None with the error. Reading and writing errors stop the run and are
+// returned, as is the context's error if it is cancelled.
+func (c *Checker) CheckCSV(ctx context.Context, r io.Reader, w io.Writer, concurrency int) error {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	b := c.batch()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type row struct {
+		index  int
+		fields []string
+	}
+
+	rows := make(chan row)
+	results := make(chan row)
+
+	// The reader sends the rows to the workers until the input ends.
+	var readErr error
+	go func() {
+		defer close(rows)
+
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+
+		first := true
+		for index := 0; ; {
+			fields, err := cr.Read()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				readErr = err
+				cancel()
+				return
+			}
+
+			if first {
+				first = false
+				if _, err := netip.ParseAddr(fields[0]); err != nil {
+					continue
+				}
+			}
+
+			select {
+			case rows <- row{index, fields}:
+				index++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for n := 0; n < concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for in := range rows {
+				out := row{in.index, b.checkRow(ctx, in.fields)}
+
+				select {
+				case results <- out:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+
+	// Results arriving out of order wait in pending until the rows before
+	// them were written.
+	next := 0
+	pending := make(map[int][]string)
+	var writeErr error
+
+	for out := range results {
+		pending[out.index] = out.fields
+
+		for fields, ok := pending[next]; ok && writeErr == nil; fields, ok = pending[next] {
+			delete(pending, next)
+			next++
+
+			if writeErr = cw.Write(fields); writeErr != nil {
+				cancel()
+			}
+		}
+	}
+
+	cw.Flush()
+
+	switch {
+	case readErr != nil:
+		return readErr
+	case writeErr != nil:
+		return writeErr
+	}
+
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// checkRow checks an input row of CheckCSV and returns the output row.
+func (c *Checker) checkRow(ctx context.Context, fields []string) []string {
+	fields = append(fields, "", "", "")
+	req := Request{IP: fields[0], Sender: fields[1], Helo: fields[2]}
+
+	start := time.Now()
+	cr := c.safeCheck(ctx, req)
+	elapsed := time.Since(start)
+
+	var mechanism, errText string
+	if cr.Mechanism != nil {
+		mechanism = cr.Mechanism.SPFString()
+	}
+
+	if cr.Err != nil {
+		errText = cr.Err.Error()
+	}
+
+	ms := strconv.FormatFloat(float64(elapsed)/float64(time.Millisecond), 'f', 3, 64)
+
+	return []string{req.IP, req.Sender, req.Helo, string(cr.Result), cr.Domain, mechanism, errText, ms}
+}
@@ -0,0 +1,135 @@
+package spf
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestCheckCSV(t *testing.T) {
+	source := MapSource{
+		"example.com":      "v=spf1 include:_spf.example.com -all",
+		"_spf.example.com": "v=spf1 ip4:192.0.2.0/24 -all",
+		"example.org":      "v=spf1 ~all",
+	}
+	c := &Checker{Source: source}
+
+	input := "ip,sender,helo\n" +
+		"192.0.2.1,info@example.com\n" +
+		"203.0.113.1,info@example.com,mx.example.net\n" +
+		"192.0.2.2,,example.org\n" +
+		"bogus,info@example.com\n" +
+		"192.0.2.3,info@example.net\n"
+
+	var buf bytes.Buffer
+	if err := c.CheckCSV(context.Background(), strings.NewReader(input), &buf, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip        string
+		result    Result
+		domain    string
+		mechanism string
+		err       bool
+	}{
+		{"192.0.2.1", Pass, "example.com", "include:_spf.example.com", false},
+		{"203.0.113.1", Fail, "example.com", "-all", false},
+		{"192.0.2.2", SoftFail, "example.org", "~all", false},
+		{"bogus", None, "", "", true},
+		{"192.0.2.3", None, "example.net", "", false},
+	}
+
+	if len(rows) != len(tests)+1 || strings.Join(rows[0], ",") != strings.Join(csvHeader, ",") {
+		t.Fatal("Expected a header and", len(tests), "rows got", rows)
+	}
+
+	for i, test := range tests {
+		row := rows[i+1]
+		t.Log("Analyzing", row)
+
+		if row[0] != test.ip || row[3] != string(test.result) || row[4] != test.domain || row[5] != test.mechanism || (row[6] != "") != test.err || row[7] == "" {
+			t.Error("Expected", test, "got", row)
+		}
+	}
+
+	if err := c.CheckCSV(context.Background(), strings.NewReader("192.0.2.1,\"info@example.com\n"), &buf, 0); err == nil {
+		t.Error("Expected a CSV error got nil")
+	}
+}
+
+// panickingResolver panics when looking up the TXT records of panic.example.
+type panickingResolver struct {
+	Resolver
+}
+
+func (r panickingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if name == "panic.example" {
+		panic("broken resolver")
+	}
+	return r.Resolver.LookupTXT(ctx, name)
+}
+
+func TestCheckMany(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 mx -all"},
+		ZoneRecord{Name: "example.com", Type: "MX", Data: "10 mx.example.com"},
+		ZoneRecord{Name: "mx.example.com", Type: "A", Data: "192.0.2.25"},
+	)
+
+	r := &countingResolver{Resolver: z}
+	c := &Checker{Resolver: panickingResolver{r}}
+
+	var requests []Request
+	for i := 0; i < 20; i++ {
+		requests = append(requests, Request{IP: "192.0.2.25", Sender: "info@example.com"}, Request{IP: "203.0.113.1", Sender: "info@example.com"})
+	}
+	requests = append(requests, Request{IP: "192.0.2.25", Sender: "info@panic.example"}, Request{IP: "bogus", Sender: "info@example.com"})
+
+	results := c.CheckMany(context.Background(), requests, 4)
+	if len(results) != len(requests) {
+		t.Fatal("Expected", len(requests), "results got", len(results))
+	}
+
+	for i, cr := range results[:40] {
+		expected := Pass
+		if i%2 == 1 {
+			expected = Fail
+		}
+
+		if cr.Result != expected {
+			t.Error("Expected", expected, "got", cr.Result)
+		}
+	}
+
+	if results[40].Result != TempError || results[40].Err != ErrCheckPanic {
+		t.Error("Expected TempError and ErrCheckPanic got", results[40].Result, results[40].Err)
+	}
+
+	if results[41].Result != None || results[41].Err != ErrInvalidIP {
+		t.Error("Expected None and ErrInvalidIP got", results[41].Result, results[41].Err)
+	}
+
+	for key, n := range r.count {
+		if n != 1 {
+			t.Error("Expected a single lookup of", key, "got", n)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, cr := range c.CheckMany(ctx, requests[:3], 0) {
+		if cr.Result != TempError || cr.Err != context.Canceled {
+			t.Error("Expected TempError and context.Canceled got", cr.Result, cr.Err)
+		}
+	}
+}
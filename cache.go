@@ -0,0 +1,300 @@
+package spf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCacheMiss = errors.New("Key not found in cache.")
+)
+
+// Cache stores the answers of a CachingResolver. Implementations may keep
+// them in memory, like MemoryCache, or in a store shared by several
+// processes and surviving restarts. Get returns ErrCacheMiss for keys that
+// were never set or whose TTL expired. Values are never set with a TTL of
+// zero or less.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// MemoryCache is a Cache keeping values in memory. The zero value is ready to
+// use. A MemoryCache must not be copied after first use.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a value held by a MemoryCache.
+type cacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// Get returns the value of the key.
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+
+	if !time.Now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, ErrCacheMiss
+	}
+
+	return entry.value, nil
+}
+
+// Set stores the value of the key for the ttl.
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.entries[key] = cacheEntry{value: value, expires: time.Now().Add(ttl)}
+
+	return nil
+}
+
+// Flush removes every value.
+func (c *MemoryCache) Flush() {
+	c.mu.Lock()
+	c.entries = nil
+	c.mu.Unlock()
+}
+
+// CachingResolver wraps a Resolver and keeps its answers, including answers
+// for names that do not exist, until their TTL expires. Other errors are not
+// cached. A CachingResolver must not be copied after first use.
+type CachingResolver struct {
+	// Resolver performs the lookups. If nil, net.DefaultResolver is used.
+	Resolver Resolver
+
+	// Cache stores the answers. If nil, answers are kept in memory.
+	Cache Cache
+
+	// TTL is how long answers are kept when the Resolver does not
+	// implement TTLResolver. If zero, DefaultTTL is used.
+	TTL time.Duration
+
+	once   sync.Once
+	memory *MemoryCache
+}
+
+// cachedAnswer is the encoding of an answer in the Cache.
+type cachedAnswer struct {
+	Values   []string `json:"v,omitempty"`
+	NotFound bool     `json:"nx,omitempty"`
+
+	// Expires is when the answer expires, in milliseconds since the Unix
+	// epoch.
+	Expires int64 `json:"exp,omitempty"`
+}
+
+// ttlKinds maps record types to the kinds of the lookups whose answers hold
+// them.
+var ttlKinds = map[string][]string{
+	"TXT":  {"TXT"},
+	"A":    {"IP ip4", "HOST"},
+	"AAAA": {"IP ip6"},
+	"MX":   {"MX"},
+	"PTR":  {"PTR"},
+}
+
+func (r *CachingResolver) resolver() Resolver {
+	if r.Resolver == nil {
+		return net.DefaultResolver
+	}
+
+	return r.Resolver
+}
+
+func (r *CachingResolver) cache() Cache {
+	if r.Cache != nil {
+		return r.Cache
+	}
+
+	r.once.Do(func() { r.memory = &MemoryCache{} })
+
+	return r.memory
+}
+
+// ttl returns how long the answer for name may be kept. A Resolver
+// implementing TTLResolver is asked right after the lookup, so that it
+// reports the TTL of the answer it just got, as DNSResolver does.
+func (r *CachingResolver) ttl(ctx context.Context, name, rtype string) time.Duration {
+	if tr, ok := r.resolver().(TTLResolver); ok {
+		if ttl, err := tr.LookupTTL(ctx, name, rtype); err == nil {
+			return ttl
+		}
+	}
+
+	if r.TTL > 0 {
+		return r.TTL
+	}
+
+	return DefaultTTL
+}
+
+// lookup returns the cached answer for the lookup of name, calling fn on a
+// miss. The answer is kept for the TTL of the name's records of type rtype.
+// Cache failures are not reported, the lookup is made instead.
+func (r *CachingResolver) lookup(ctx context.Context, kind, name, rtype string, fn func() ([]string, error)) ([]string, error) {
+	key := kind + " " + canonicalName(name)
+
+	if data, err := r.cache().Get(ctx, key); err == nil {
+		var answer cachedAnswer
+		if json.Unmarshal(data, &answer) == nil {
+			if answer.NotFound {
+				return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+			}
+			return answer.Values, nil
+		}
+	}
+
+	values, err := fn()
+	if err != nil && !isNotFound(err) {
+		return values, err
+	}
+
+	// Answers that must not be kept are not stored, a store such as Redis
+	// taking a zero TTL for no expiry.
+	ttl := r.ttl(ctx, name, rtype)
+	if ttl <= 0 {
+		return values, err
+	}
+
+	data, _ := json.Marshal(cachedAnswer{Values: values, NotFound: err != nil, Expires: time.Now().Add(ttl).UnixMilli()})
+	r.cache().Set(ctx, key, data, ttl)
+
+	return values, err
+}
+
+// Flush removes every cached answer kept in memory. Answers stored in the
+// Cache are not affected.
+func (r *CachingResolver) Flush() {
+	if r.Cache == nil {
+		r.cache().(*MemoryCache).Flush()
+	}
+}
+
+// LookupTXT looks up the TXT records for name.
+func (r *CachingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return r.lookup(ctx, "TXT", name, "TXT", func() ([]string, error) {
+		return r.resolver().LookupTXT(ctx, name)
+	})
+}
+
+// LookupHost looks up the addresses of host.
+func (r *CachingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return r.lookup(ctx, "HOST", host, "A", func() ([]string, error) {
+		return r.resolver().LookupHost(ctx, host)
+	})
+}
+
+// LookupMX looks up the MX records for name.
+func (r *CachingResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	values, err := r.lookup(ctx, "MX", name, "MX", func() ([]string, error) {
+		mxs, err := r.resolver().LookupMX(ctx, name)
+
+		var values []string
+		for _, mx := range mxs {
+			values = append(values, strconv.Itoa(int(mx.Pref))+" "+mx.Host)
+		}
+		return values, err
+	})
+
+	var mxs []*net.MX
+	for _, v := range values {
+		pref, host, _ := strings.Cut(v, " ")
+		n, _ := strconv.Atoi(pref)
+		mxs = append(mxs, &net.MX{Host: host, Pref: uint16(n)})
+	}
+
+	return mxs, err
+}
+
+// LookupAddr looks up the names of addr.
+func (r *CachingResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	name, err := reverseName(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.lookup(ctx, "PTR", name, "PTR", func() ([]string, error) {
+		return r.resolver().LookupAddr(ctx, addr)
+	})
+}
+
+// LookupIP looks up the addresses of host for the given network.
+func (r *CachingResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	rtype := "A"
+	if network == "ip6" {
+		rtype = "AAAA"
+	}
+
+	values, err := r.lookup(ctx, "IP "+network, host, rtype, func() ([]string, error) {
+		ips, err := r.resolver().LookupIP(ctx, network, host)
+
+		var values []string
+		for _, ip := range ips {
+			values = append(values, ip.String())
+		}
+		return values, err
+	})
+
+	var ips []net.IP
+	for _, v := range values {
+		if ip := net.ParseIP(v); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+
+	return ips, err
+}
+
+// LookupTTL returns the TTL left to the cached answer holding the records of
+// type rtype for name, and forwards to the wrapped Resolver if none is
+// cached. ErrNoTTL is returned if the Resolver does not implement
+// TTLResolver.
+func (r *CachingResolver) LookupTTL(ctx context.Context, name, rtype string) (time.Duration, error) {
+	tr, ok := r.resolver().(TTLResolver)
+	if !ok {
+		return 0, ErrNoTTL
+	}
+
+	for _, kind := range ttlKinds[strings.ToUpper(rtype)] {
+		data, err := r.cache().Get(ctx, kind+" "+canonicalName(name))
+		if err != nil {
+			continue
+		}
+
+		var answer cachedAnswer
+		if json.Unmarshal(data, &answer) != nil || answer.Expires == 0 {
+			continue
+		}
+
+		if answer.NotFound {
+			return 0, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+
+		if ttl := time.Until(time.UnixMilli(answer.Expires)).Round(time.Second); ttl > 0 {
+			return ttl, nil
+		}
+	}
+
+	return tr.LookupTTL(ctx, name, rtype)
+}
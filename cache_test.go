@@ -0,0 +1,153 @@
+package spf
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingResolver counts the lookups reaching the wrapped Resolver.
+type countingResolver struct {
+	Resolver
+
+	mu    sync.Mutex
+	count map[string]int
+}
+
+func (r *countingResolver) add(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.count == nil {
+		r.count = make(map[string]int)
+	}
+	r.count[key]++
+}
+
+func (r *countingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	r.add("TXT " + name)
+	return r.Resolver.LookupTXT(ctx, name)
+}
+
+func (r *countingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.add("HOST " + host)
+	return r.Resolver.LookupHost(ctx, host)
+}
+
+func (r *countingResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.add("MX " + name)
+	return r.Resolver.LookupMX(ctx, name)
+}
+
+func TestCachingResolver(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(testZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter := &countingResolver{Resolver: z}
+	cache := &CachingResolver{Resolver: counter}
+	c := &Checker{Resolver: cache}
+
+	for i := 0; i < 3; i++ {
+		result, _ := c.SPFTest(context.Background(), "192.0.2.25", "info@example.org")
+		if result != Pass {
+			t.Error("Expected", Pass, "got", result)
+		}
+
+		c.SPFTest(context.Background(), "192.0.2.1", "info@missing.example.org")
+	}
+
+	for key, n := range counter.count {
+		if n != 1 {
+			t.Error("Expected a single lookup for", key, "got", n)
+		}
+	}
+
+	cache.Flush()
+	c.SPFTest(context.Background(), "192.0.2.1", "info@missing.example.org")
+	if counter.count["TXT missing.example.org"] != 2 {
+		t.Error("Expected a lookup after Flush got", counter.count["TXT missing.example.org"])
+	}
+}
+
+func TestCachingResolverTTL(t *testing.T) {
+	z := NewZone()
+	z.Add(ZoneRecord{Name: "example.org", Type: "TXT", Data: "v=spf1 -all"})
+
+	counter := &countingResolver{Resolver: z}
+	cache := &CachingResolver{Resolver: counter, TTL: time.Nanosecond}
+
+	cache.LookupTXT(context.Background(), "example.org")
+	time.Sleep(time.Millisecond)
+	cache.LookupTXT(context.Background(), "example.org")
+
+	if counter.count["TXT example.org"] != 2 {
+		t.Error("Expected expired answers to be looked up again got", counter.count)
+	}
+}
+
+func TestWarm(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(testZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter := &countingResolver{Resolver: z}
+	c := &Checker{Resolver: &CachingResolver{Resolver: counter}}
+
+	if err := c.Warm(context.Background(), []string{"example.org", "other.example.net"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Warm(context.Background(), []string{"missing.example.org"}); err != ErrNoRecord {
+		t.Error("Expected", ErrNoRecord, "got", err)
+	}
+
+	for _, key := range []string{"TXT example.org", "TXT _spf.example.org", "TXT other.example.net"} {
+		if counter.count[key] != 1 {
+			t.Error("Expected", key, "to be warmed got", counter.count)
+		}
+	}
+
+	c.SPFTest(context.Background(), "198.51.100.1", "info@example.org")
+	if counter.count["TXT example.org"] != 1 || counter.count["TXT _spf.example.org"] != 1 {
+		t.Error("Expected records to be cached got", counter.count)
+	}
+}
+
+func TestSharedCache(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(testZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter := &countingResolver{Resolver: z}
+	shared := &MemoryCache{}
+
+	for i := 0; i < 2; i++ {
+		c := &Checker{Resolver: &CachingResolver{Resolver: counter, Cache: shared}}
+
+		result, _ := c.SPFTest(context.Background(), "192.0.2.25", "info@example.org")
+		if result != Pass {
+			t.Error("Expected", Pass, "got", result)
+		}
+	}
+
+	for key, n := range counter.count {
+		if n != 1 {
+			t.Error("Expected a single lookup for", key, "got", n)
+		}
+	}
+
+	if _, err := shared.Get(context.Background(), "MX example.org"); err != nil {
+		t.Error("Expected MX answer in cache got", err)
+	}
+
+	if _, err := shared.Get(context.Background(), "TXT missing.example.org"); err != ErrCacheMiss {
+		t.Error("Expected", ErrCacheMiss, "got", err)
+	}
+}
@@ -0,0 +1,204 @@
+package spf
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The vendor, product and version reported in the headers of CEF and LEEF
+// events.
+const (
+	SIEMVendor  = "asggo"
+	SIEMProduct = "spf"
+	SIEMVersion = "1"
+)
+
+// siemField is a key and value of the extension of a CEF or LEEF event.
+type siemField struct {
+	key, value string
+}
+
+// FormatCEF returns the evaluation event in the ArcSight Common Event Format.
+// It can be used as the Format of a SyslogSink. The signature ID is
+// "evaluation" and the severity grows from 1 for Pass to 6 for Fail.
+func FormatCEF(event EvaluationEvent) string {
+	return formatCEF("evaluation", "SPF "+string(event.Result), resultSeverity(event.Result), event.Time, evaluationFields(event, "src", "suser", "shost"))
+}
+
+// FormatLEEF returns the evaluation event in the QRadar Log Event Extended
+// Format, version 1.0. It can be used as the Format of a SyslogSink.
+func FormatLEEF(event EvaluationEvent) string {
+	fields := append(evaluationFields(event, "src", "usrName", "identHostName"), siemField{"sev", strconv.Itoa(resultSeverity(event.Result))})
+
+	return formatLEEF("evaluation", event.Time, fields)
+}
+
+// FormatEventCEF returns the policy event of a Monitor or a Flattener in the
+// Common Event Format. The signature ID is the type of the event.
+func FormatEventCEF(event Event) string {
+	return formatCEF(string(event.Type), eventName(event.Type), eventSeverity(event.Type), event.Time, policyFields(event))
+}
+
+// FormatEventLEEF returns the policy event of a Monitor or a Flattener in
+// the Log Event Extended Format, version 1.0.
+func FormatEventLEEF(event Event) string {
+	fields := append(policyFields(event), siemField{"sev", strconv.Itoa(eventSeverity(event.Type))})
+
+	return formatLEEF(string(event.Type), event.Time, fields)
+}
+
+// evaluationFields returns the fields of the evaluation event, using the
+// given keys for the client address, the identity and the HELO domain.
+func evaluationFields(event EvaluationEvent, src, user, host string) []siemField {
+	fields := []siemField{{"outcome", string(event.Result)}}
+
+	// Both formats require src to be an IP address.
+	if _, err := netip.ParseAddr(event.ClientIP); err == nil {
+		fields = append(fields, siemField{src, event.ClientIP})
+	}
+
+	fields = append(fields,
+		siemField{user, event.Identity},
+		siemField{host, event.Helo},
+		siemField{"cs1Label", "domain"}, siemField{"cs1", event.Domain},
+		siemField{"cs2Label", "mechanism"}, siemField{"cs2", event.Mechanism},
+		siemField{"cs3Label", "override"}, siemField{"cs3", event.Override},
+		siemField{"cn1Label", "dnsQueries"}, siemField{"cn1", strconv.Itoa(event.Queries)},
+		siemField{"reason", event.Error},
+	)
+
+	return fields
+}
+
+// policyFields returns the fields of the policy event.
+func policyFields(event Event) []siemField {
+	fields := []siemField{
+		{"cs1Label", "domain"}, {"cs1", event.Domain},
+		{"cs2Label", "oldRecord"}, {"cs2", event.Old},
+		{"cs3Label", "newRecord"}, {"cs3", event.New},
+		{"cs4Label", "added"}, {"cs4", strings.Join(event.Added, " ")},
+		{"cs5Label", "removed"}, {"cs5", strings.Join(event.Removed, " ")},
+	}
+
+	if event.Lookups != 0 {
+		fields = append(fields, siemField{"cn1Label", "lookups"}, siemField{"cn1", strconv.Itoa(event.Lookups)})
+	}
+
+	return fields
+}
+
+// formatCEF returns a CEF event, starting with the time of the event as rt.
+// Fields without a value are omitted.
+func formatCEF(signature, name string, severity int, t time.Time, fields []siemField) string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|", cefHeader.Replace(SIEMVendor), cefHeader.Replace(SIEMProduct),
+		cefHeader.Replace(SIEMVersion), cefHeader.Replace(signature), cefHeader.Replace(name), severity))
+
+	buf.WriteString(fmt.Sprintf("rt=%d", t.UnixMilli()))
+
+	for _, f := range withValues(fields) {
+		buf.WriteString(fmt.Sprintf(" %s=%s", f.key, cefValue.Replace(f.value)))
+	}
+
+	return buf.String()
+}
+
+// formatLEEF returns a LEEF 1.0 event, whose attributes are separated by
+// tabs, starting with the time of the event as devTime. Fields without a
+// value are omitted.
+func formatLEEF(eventID string, t time.Time, fields []siemField) string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("LEEF:1.0|%s|%s|%s|%s|", leefHeader.Replace(SIEMVendor), leefHeader.Replace(SIEMProduct),
+		leefHeader.Replace(SIEMVersion), leefHeader.Replace(eventID)))
+
+	buf.WriteString("devTime=" + t.UTC().Format("Jan 02 2006 15:04:05.000 MST"))
+	buf.WriteString("\tdevTimeFormat=MMM dd yyyy HH:mm:ss.SSS zzz")
+
+	for _, f := range withValues(fields) {
+		buf.WriteString(fmt.Sprintf("\t%s=%s", f.key, leefValue.Replace(f.value)))
+	}
+
+	return buf.String()
+}
+
+// withValues returns the fields with a value, dropping the cs and cn labels
+// of fields without one.
+func withValues(fields []siemField) []siemField {
+	values := make(map[string]bool)
+	for _, f := range fields {
+		values[f.key] = f.value != ""
+	}
+
+	var kept []siemField
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+
+		if label := strings.TrimSuffix(f.key, "Label"); label != f.key && !values[label] {
+			continue
+		}
+
+		kept = append(kept, f)
+	}
+
+	return kept
+}
+
+var (
+	cefHeader  = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefValue   = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+	leefHeader = strings.NewReplacer(`|`, `\|`, "\t", " ", "\r", " ", "\n", " ")
+	leefValue  = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+)
+
+// resultSeverity returns the CEF and LEEF severity, from 0 to 10, of the
+// events of a result.
+func resultSeverity(r Result) int {
+	switch r {
+	case Pass:
+		return 1
+	case Neutral, None:
+		return 3
+	case TempError, PermError:
+		return 4
+	case SoftFail:
+		return 5
+	}
+
+	return 6
+}
+
+// eventName returns the name of the events of a type.
+func eventName(t EventType) string {
+	switch t {
+	case RecordChanged:
+		return "SPF record changed"
+	case RecordDisappeared:
+		return "SPF record disappeared"
+	case RecordMalformed:
+		return "SPF record malformed"
+	case LookupLimitExceeded:
+		return "SPF lookup limit exceeded"
+	}
+
+	return string(t)
+}
+
+// eventSeverity returns the CEF and LEEF severity of the events of a type.
+func eventSeverity(t EventType) int {
+	switch t {
+	case RecordDisappeared:
+		return 8
+	case LookupLimitExceeded, RecordMalformed:
+		return 7
+	}
+
+	return 5
+}
@@ -0,0 +1,63 @@
+package spf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatCEF(t *testing.T) {
+	event := EvaluationEvent{
+		Time:      time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		ClientIP:  "192.0.2.1",
+		Identity:  "info@example.com",
+		Domain:    "example.com",
+		Result:    Fail,
+		Mechanism: "-all",
+		Queries:   2,
+		Error:     "a=b\\c",
+	}
+
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{FormatCEF(event), `CEF:0|asggo|spf|1|evaluation|SPF Fail|6|rt=1714564800000 outcome=Fail src=192.0.2.1 suser=info@example.com ` +
+			`cs1Label=domain cs1=example.com cs2Label=mechanism cs2=-all cn1Label=dnsQueries cn1=2 reason=a\=b\\c`},
+		{FormatLEEF(event), "LEEF:1.0|asggo|spf|1|evaluation|devTime=May 01 2024 12:00:00.000 UTC\tdevTimeFormat=MMM dd yyyy HH:mm:ss.SSS zzz\t" +
+			"outcome=Fail\tsrc=192.0.2.1\tusrName=info@example.com\tcs1Label=domain\tcs1=example.com\tcs2Label=mechanism\tcs2=-all\t" +
+			"cn1Label=dnsQueries\tcn1=2\treason=a=b\\c\tsev=6"},
+		{FormatCEF(EvaluationEvent{Time: event.Time, ClientIP: "bogus", Result: None}), "CEF:0|asggo|spf|1|evaluation|SPF None|3|rt=1714564800000 outcome=None cn1Label=dnsQueries cn1=0"},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.expected)
+
+		if test.text != test.expected {
+			t.Error("Expected", test.expected, "got", test.text)
+		}
+	}
+}
+
+func TestFormatEventCEF(t *testing.T) {
+	event := Event{
+		Type:   RecordChanged,
+		Domain: "example.com",
+		Time:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Old:    "v=spf1 -all",
+		New:    "v=spf1 ip4:192.0.2.1 -all",
+		Added:  []string{"ip4:192.0.2.1"},
+	}
+
+	cef := FormatEventCEF(event)
+	expected := `CEF:0|asggo|spf|1|record_changed|SPF record changed|5|rt=1714564800000 cs1Label=domain cs1=example.com ` +
+		`cs2Label=oldRecord cs2=v\=spf1 -all cs3Label=newRecord cs3=v\=spf1 ip4:192.0.2.1 -all cs4Label=added cs4=ip4:192.0.2.1`
+	if cef != expected {
+		t.Error("Expected", expected, "got", cef)
+	}
+
+	leef := FormatEventLEEF(Event{Type: LookupLimitExceeded, Domain: "example.com", Lookups: 12})
+	if !strings.HasPrefix(leef, "LEEF:1.0|asggo|spf|1|lookup_limit_exceeded|") || !strings.HasSuffix(leef, "\tcn1Label=lookups\tcn1=12\tsev=7") {
+		t.Error("Unexpected LEEF event", leef)
+	}
+}
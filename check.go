@@ -0,0 +1,155 @@
+package spf
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// Request describes the message to check.
+type Request struct {
+	// IP is the address of the SMTP client. Addr is used instead when
+	// valid, saving the parsing of IP.
+	IP   string
+	Addr netip.Addr
+
+	// Sender is the MAIL FROM identity, e.g. user@example.com. Its local
+	// part and domain are the values of the %{l} and %{o} macros.
+	Sender string
+
+	// Helo is the domain given by the client in the HELO or EHLO command,
+	// the value of the %{h} macro. When Sender is empty, as for bounces,
+	// the identity checked is postmaster@Helo, RFC 7208 section 2.4.
+	Helo string
+
+	// ARC holds SPF results reported by intermediaries in the ARC sets of
+	// the message, used when the direct check fails due to forwarding.
+	ARC []ARCResult
+
+	// PriorPass reports that a trusted intermediary already verified SPF
+	// for the message with a Pass result.
+	PriorPass bool
+
+	// Receiver is the domain of the receiving MTA, the value of the %{r}
+	// macro of explanations, "unknown" if empty.
+	Receiver string
+}
+
+// CheckResult is the detailed outcome of a check.
+type CheckResult struct {
+	Result Result
+
+	// Err is the cause of TempError and PermError results, when known.
+	Err error
+
+	// Domain is the domain whose record was evaluated.
+	Domain string
+
+	// Mechanism is the mechanism of the record that determined the
+	// result, nil when no mechanism matched.
+	Mechanism *Mechanism
+
+	// Explanation is the explanation of a Fail result given by the exp
+	// modifier of the record, RFC 7208 section 6.2, empty if it has none or
+	// it cannot be fetched.
+	Explanation string
+
+	// NoMatch is set for the Neutral result of a record none of whose
+	// mechanisms matched, as opposed to one matching a ?-qualified
+	// mechanism such as ?all. A None result means the domain publishes no
+	// record at all.
+	NoMatch bool
+
+	// Override is set when the result of the direct check was replaced,
+	// e.g. "arc" for a Pass established by a trusted intermediary or
+	// "local" for a result replaced by one of Checker.Rules. The
+	// result of the direct check is then held in DirectResult.
+	Override     string
+	DirectResult Result
+
+	// Budget breaks down the DNS queries the evaluation made, showing
+	// which terms to flatten to stay within MaxCount.
+	Budget Budget
+
+	// Org reports the record of the organizational domain of a domain
+	// publishing none, with Checker.OrgFallback. It does not change the
+	// result.
+	Org *OrgFallback
+}
+
+// Return a CheckResult as a string, e.g. "Pass" or "Pass (arc)".
+func (r CheckResult) String() string {
+	if r.Override != "" {
+		return fmt.Sprintf("%s (%s)", r.Result, r.Override)
+	}
+
+	return string(r.Result)
+}
+
+// Check evaluates the request using the DefaultChecker.
+func Check(ctx context.Context, req Request) CheckResult {
+	return DefaultChecker.Check(ctx, req)
+}
+
+// Check evaluates the SPF record of the sender's domain for the request and
+// returns the detailed result.
+//
+// A request without a valid client address results in None and ErrInvalidIP.
+func (c *Checker) Check(ctx context.Context, req Request) CheckResult {
+	if c.Events == nil {
+		return c.checkRequest(ctx, req)
+	}
+
+	start := time.Now()
+	cr := c.checkRequest(ctx, req)
+	c.Events.Emit(newEvaluationEvent(start, req, cr))
+
+	return cr
+}
+
+func (c *Checker) checkRequest(ctx context.Context, req Request) CheckResult {
+	addr := req.Addr
+	if !addr.IsValid() {
+		var err error
+		if addr, err = parseIP(req.IP); err != nil {
+			return CheckResult{Result: None, Err: err}
+		}
+	}
+
+	sender := req.Sender
+	if sender == "" && req.Helo != "" {
+		sender = "postmaster@" + req.Helo
+	}
+
+	if result, override, ok := c.listed(addr); ok {
+		return CheckResult{Result: result, Domain: senderDomain(sender), Override: override}
+	}
+
+	e := newEvaluation(ctx, c, addr)
+	e.helo = req.Helo
+	e.receiver = req.Receiver
+	result := c.check(e, sender)
+
+	cr := CheckResult{
+		Result:    result,
+		Err:       e.err,
+		Domain:    e.domain,
+		Mechanism: e.matched,
+		Budget:    e.budget,
+		NoMatch:   result == Neutral && e.noMatch,
+	}
+
+	if result == Fail {
+		cr.Explanation = e.explanation()
+	}
+
+	if c.OrgFallback && result == None && e.err == nil {
+		cr.Org = c.orgFallback(ctx, e)
+	}
+
+	cr.applyARC(req, e.domain)
+	cr.applyRules(c.Rules, addr)
+
+	return cr
+}
@@ -0,0 +1,92 @@
+package spf
+
+import (
+	"context"
+	"testing"
+)
+
+type arctest struct {
+	req      Request
+	expected string
+}
+
+func TestCheck(t *testing.T) {
+	c := &Checker{Source: testSource}
+
+	cr := c.Check(context.Background(), Request{IP: "198.51.100.10", Sender: "info@example.com"})
+	if cr.Result != Pass || cr.Domain != "example.com" || cr.Mechanism == nil || cr.Mechanism.SPFString() != "include:_spf.example.com" {
+		t.Error("Unexpected result", cr, cr.Domain, cr.Mechanism)
+	}
+
+	cr = c.Check(context.Background(), Request{IP: "127.0.0.1", Sender: "info@neutral.example"})
+	if cr.Result != Neutral || cr.Mechanism != nil {
+		t.Error("Unexpected result", cr, cr.Mechanism)
+	}
+}
+
+func TestCheckARC(t *testing.T) {
+	c := &Checker{Source: testSource}
+	forwarded := "127.0.0.1"
+
+	tests := []arctest{
+		arctest{Request{IP: forwarded, Sender: "info@example.com"}, "Fail"},
+		arctest{Request{IP: forwarded, Sender: "info@example.com", PriorPass: true}, "Pass (arc)"},
+		arctest{Request{IP: forwarded, Sender: "info@softfail.example", ARC: []ARCResult{
+			ARCResult{Instance: 1, Result: Pass, Sender: "info@softfail.example", Trusted: true},
+		}}, "Pass (arc)"},
+		arctest{Request{IP: forwarded, Sender: "info@example.com", ARC: []ARCResult{
+			ARCResult{Instance: 1, Result: Pass, Sender: "info@example.com"},
+		}}, "Fail"},
+		arctest{Request{IP: forwarded, Sender: "info@example.com", ARC: []ARCResult{
+			ARCResult{Instance: 1, Result: Pass, Sender: "info@other.example", Trusted: true},
+		}}, "Fail"},
+		arctest{Request{IP: forwarded, Sender: "info@broken.example", PriorPass: true}, "PermError"},
+	}
+
+	for _, tcase := range tests {
+		cr := c.Check(context.Background(), tcase.req)
+
+		if cr.String() != tcase.expected {
+			t.Error("For", tcase.req, "Expected", tcase.expected, "got", cr.String())
+		}
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 a mx ptr exists:%{i}.bl.example include:_spf.example.com redirect=_rest.example.com"},
+		ZoneRecord{Name: "_spf.example.com", Type: "TXT", Data: "v=spf1 a:relay.example.com -all"},
+		ZoneRecord{Name: "_rest.example.com", Type: "TXT", Data: "v=spf1 -all"},
+		ZoneRecord{Name: "example.com", Type: "MX", Data: "10 mx1.example.com"},
+		ZoneRecord{Name: "example.com", Type: "MX", Data: "20 mx2.example.com"},
+	)
+
+	req := Request{IP: "192.0.2.1", Sender: "info@example.com"}
+
+	cr := (&Checker{Resolver: z}).Check(context.Background(), req)
+	if cr.Result != Fail {
+		t.Error("Expected", Fail, "got", cr.Result)
+	}
+
+	// Only the A records of the hosts are looked up for an IPv4 client.
+	expected := Budget{A: 2, MX: 1, MXHosts: 2, PTR: 1, Exists: 1, Include: 1, Redirect: 1}
+	if cr.Budget != expected {
+		t.Error("Expected", expected, "got", cr.Budget)
+	}
+
+	if cr.Budget.Total() != 9 {
+		t.Error("Expected 9 got", cr.Budget.Total())
+	}
+
+	cr = (&Checker{Resolver: z, DualLookups: true}).Check(context.Background(), req)
+
+	expected = Budget{A: 4, MX: 1, MXHosts: 4, PTR: 1, Exists: 1, Include: 1, Redirect: 1}
+	if cr.Budget != expected {
+		t.Error("Expected", expected, "got", cr.Budget)
+	}
+
+	if cr.Budget.Total() != 13 {
+		t.Error("Expected 13 got", cr.Budget.Total())
+	}
+}
@@ -0,0 +1,406 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Checker evaluates SPF records. The zero value is ready to use and fetches
+// records from DNS.
+type Checker struct {
+	// Source provides the SPF record text for each domain. If nil, records
+	// are fetched from DNS.
+	Source RecordSource
+
+	// Resolver performs the DNS lookups required by the a, mx, ptr and
+	// exists mechanisms, and fetches records when Source is nil. If nil,
+	// a DNSResolver querying Nameservers is used, or net.DefaultResolver
+	// when there are none.
+	Resolver Resolver
+
+	// Nameservers are the addresses, as IP:port, of the recursive
+	// nameservers queried for all lookups instead of those of the system
+	// configuration. The port defaults to 53. Ignored when Resolver is set.
+	Nameservers []string
+
+	// DeadlineMargin stops an evaluation once the context deadline is
+	// closer than the margin, leaving time to report the TempError result
+	// and the partial trace before the deadline expires.
+	DeadlineMargin time.Duration
+
+	// Routes sends the lookups for some domains, e.g. internal ones, to
+	// other resolvers than Resolver, which performs the remaining lookups.
+	// See RoutingResolver.
+	Routes []Route
+
+	// Normalize repairs irregular whitespace and terms split around their
+	// delimiter in Strict mode too, before records are parsed. See
+	// Normalize.
+	Normalize bool
+
+	// StripIgnoredRedirect removes the redirect modifiers of records with
+	// an all mechanism when they are normalized. See StripIgnoredRedirect.
+	StripIgnoredRedirect bool
+
+	// MXLimit and PTRLimit lower the RFC limits of MaxMXRecords and
+	// MaxPTRNames for a stricter local policy. An mx mechanism resolving to
+	// more MX records is a PermError, while the client names over the limit
+	// are ignored by ptr mechanisms. Zero or larger values use the RFC
+	// limits.
+	MXLimit  int
+	PTRLimit int
+
+	// DualLookups resolves both the A and AAAA records of the hosts of a
+	// and mx mechanisms. By default only the records of the family of the
+	// client address are looked up, as the others can never match it.
+	DualLookups bool
+
+	// Extensions opts in to mechanisms and modifiers RFC 7208 does not
+	// define, keyed by lowercase name. A mechanism with an Extension is
+	// evaluated by it instead of resulting in a PermError, and is kept by
+	// lenient parsing. The modifiers with an Extension are evaluated in
+	// order once no mechanism matched, before the redirect. Extensions for
+	// the names of RFC 7208 are ignored.
+	Extensions map[string]Extension
+
+	// BeforeMechanism and AfterMechanism are called around the evaluation
+	// of each term of the records evaluated by a check, including those of
+	// included and redirected records, e.g. for custom telemetry or
+	// experiments. BeforeMechanism decides the outcome of the term instead
+	// of the evaluation, which is skipped, by setting the Result and
+	// Matched fields of the event and returning true. AfterMechanism may
+	// change the tentative outcome of the event to override it. See
+	// MechanismEvent.
+	BeforeMechanism func(ctx context.Context, ev *MechanismEvent) bool
+	AfterMechanism  func(ctx context.Context, ev *MechanismEvent)
+
+	// Allow and Block short-circuit Check, SPFTest and SPFTestAddr for the
+	// clients within their prefixes, e.g. internal relays and known-bad
+	// ranges, which result in Pass and Fail before any lookup. The longest
+	// matching prefix decides, Block on a tie. Check sets Override to
+	// "allow" or "block" and applies no Rules to them.
+	Allow []netip.Prefix
+	Block []netip.Prefix
+
+	// OrgFallback makes Check look up the record of the organizational
+	// domain of a domain publishing none, and report it in CheckResult.Org
+	// for audits. The result remains None as RFC 7208 requires.
+	OrgFallback bool
+
+	// Rules are local policy rules applied by Check once the record was
+	// evaluated, after any ARC override. The first rule matching the
+	// check replaces its result and sets Override to "local". See
+	// LocalRule and LoadRules.
+	Rules []LocalRule
+
+	// Events receives an EvaluationEvent for each evaluation made by Check,
+	// SPFTest or SPFTestAddr. If nil, no events are produced.
+	Events EventSink
+}
+
+// DefaultChecker is the Checker used by the package level functions.
+var DefaultChecker = &Checker{}
+
+func (c *Checker) mxLimit() int {
+	if c.MXLimit <= 0 || c.MXLimit > MaxMXRecords {
+		return MaxMXRecords
+	}
+
+	return c.MXLimit
+}
+
+func (c *Checker) ptrLimit() int {
+	if c.PTRLimit <= 0 || c.PTRLimit > MaxPTRNames {
+		return MaxPTRNames
+	}
+
+	return c.PTRLimit
+}
+
+func (c *Checker) source() RecordSource {
+	if c.Source == nil {
+		return DNSSource{Resolver: c.resolver()}
+	}
+
+	return c.Source
+}
+
+func (c *Checker) resolver() Resolver {
+	r := c.Resolver
+	switch {
+	case r != nil:
+	case len(c.Nameservers) > 0:
+		r = &DNSResolver{Servers: c.Nameservers}
+	default:
+		r = net.DefaultResolver
+	}
+
+	if len(c.Routes) > 0 {
+		return &RoutingResolver{Routes: c.Routes, Default: r}
+	}
+
+	return r
+}
+
+// NewSPF creates a new SPF record for the given domain using the provided
+// string. If the provided string is empty the record is fetched from the
+// Checker's Source. If the record is not valid an error is returned.
+func (c *Checker) NewSPF(ctx context.Context, domain, record string, count int) (SPF, error) {
+	spf, _, err := c.parse(ctx, domain, record, count, Strict)
+
+	return spf, err
+}
+
+// NewSPFLenient is like NewSPF but parses as much of the record as possible.
+// Invalid terms are skipped and reported as warnings together with other
+// problems that NewSPF treats as errors. An error is only returned when no
+// record could be fetched or the record is not an SPF record at all.
+func (c *Checker) NewSPFLenient(ctx context.Context, domain, record string, count int) (SPF, []Warning, error) {
+	return c.parse(ctx, domain, record, count, Lenient)
+}
+
+// parse fetches the record if needed and parses it. In lenient mode problems
+// are collected as warnings instead of aborting the parse.
+func (c *Checker) parse(ctx context.Context, domain, record string, count int, mode ParseMode) (SPF, []Warning, error) {
+	var spf SPF
+	var warnings []Warning
+
+	if record == "" {
+		spfText, err := c.source().Record(ctx, domain)
+		if err != nil {
+			return spf, nil, err
+		}
+
+		if spfText == "" {
+			return spf, nil, ErrNoRecord
+		}
+
+		record = spfText
+	}
+
+	spf.Count = count
+	spf.Raw = record
+	spf.Domain = domain
+	spf.checker = c
+
+	lenient := mode == Lenient
+	if lenient || c.Normalize {
+		record, warnings = Normalize(record)
+
+		if c.StripIgnoredRedirect {
+			var stripped []Warning
+			record, stripped = StripIgnoredRedirect(record)
+			warnings = append(warnings, stripped...)
+		}
+	}
+
+	fields := strings.Fields(record)
+	if !strings.HasPrefix(record, "v=spf1") || fields[0] != "v=spf1" {
+		return spf, nil, ErrInvalidSPF
+	}
+
+	for _, f := range fields {
+		if strings.HasPrefix(f, "v=") {
+			spf.Version = f[2:]
+			continue
+		}
+
+		modifier, ok, err := parseModifier(f)
+		if !ok {
+			var mechanism Mechanism
+			mechanism, err = NewMechanism(f, domain)
+
+			if err == nil {
+				err = mechanism.validate()
+			}
+
+			// Unknown mechanisms are only an error once evaluated, unless
+			// the record is parsed leniently. Those with an Extension are
+			// valid.
+			if err == ErrUnknownMechanism {
+				if _, ok := c.extension(mechanism.Name); ok || !lenient {
+					err = nil
+				}
+			}
+
+			if err != nil {
+				if !lenient {
+					return spf, nil, err
+				}
+
+				warnings = append(warnings, Warning{Term: f, Err: termError(f, mechanism, err)})
+				continue
+			}
+
+			if lenient && trimTrailingDot(&mechanism) {
+				warnings = append(warnings, Warning{Term: f, Err: ErrTrailingDot})
+			}
+
+			switch mechanism.Name {
+			case "include":
+				if mechanism.Domain == domain {
+					if !lenient {
+						return spf, nil, ErrIncludeLoop
+					}
+
+					warnings = append(warnings, Warning{Term: f, Err: ErrIncludeLoop})
+					continue
+				}
+				spf.Count = spf.Count + 1
+			case "redirect":
+				// redirect:domain is taken for redirect=domain.
+				modifier, ok = Modifier{Name: mechanism.Name, Value: mechanism.Domain}, true
+				if strings.ContainsRune("+-~?", rune(f[0])) {
+					err = ErrQualifiedModifier
+				}
+			case "exists", "a", "mx", "ptr":
+				spf.Count = spf.Count + 1
+			default:
+				// No action
+			}
+
+			if !ok {
+				spf.Mechanisms = append(spf.Mechanisms, mechanism)
+				continue
+			}
+		}
+
+		if isModifier(modifier.Name) && spf.modifier(modifier.Name) != nil && (err == nil || err == ErrQualifiedModifier) {
+			err = ErrDuplicateModifier
+		}
+
+		// Qualified modifiers are kept by lenient parsing, the others in
+		// error are skipped.
+		if err != nil {
+			if !lenient {
+				return spf, nil, err
+			}
+
+			warnings = append(warnings, Warning{Term: f, Err: termError(f, Mechanism{}, err)})
+			if err != ErrQualifiedModifier {
+				continue
+			}
+		}
+
+		// RFC 7208 section 6: unknown modifiers are ignored by the
+		// evaluation, they are only kept to write the record back.
+		if lenient && isModifier(modifier.Name) && len(modifier.Value) > 1 && strings.HasSuffix(modifier.Value, ".") {
+			modifier.Value = strings.TrimSuffix(modifier.Value, ".")
+			warnings = append(warnings, Warning{Term: f, Err: ErrTrailingDot})
+		}
+
+		if modifier.Name == "redirect" {
+			spf.Count = spf.Count + 1
+		}
+
+		spf.Modifiers = append(spf.Modifiers, modifier)
+	}
+
+	if spf.Count >= MaxCount {
+		if !lenient {
+			return spf, nil, ErrMaxCount
+		}
+
+		warnings = append(warnings, Warning{Term: record, Err: ErrMaxCount})
+	}
+
+	return spf, warnings, nil
+}
+
+// SPFTest determines the clients sending status for the given email address
+// using the records provided by the Checker's Source.
+//
+// SPFTest will return one of the following results:
+// Pass, Fail, SoftFail, Neutral, None, TempError, or PermError
+//
+// When known, the cause of a TempError or PermError result is returned as
+// the error, e.g. ErrIncludeLoop. An ip that is not an IP address results in
+// None and ErrInvalidIP.
+func (c *Checker) SPFTest(ctx context.Context, ip, email string) (Result, error) {
+	addr, err := parseIP(ip)
+	if err != nil {
+		return None, err
+	}
+
+	return c.SPFTestAddr(ctx, addr, email)
+}
+
+// SPFTestAddr is like SPFTest for a parsed client address. A net.IP can be
+// converted with netip.AddrFromSlice.
+func (c *Checker) SPFTestAddr(ctx context.Context, addr netip.Addr, email string) (Result, error) {
+	if !addr.IsValid() {
+		return None, ErrInvalidIP
+	}
+
+	start := time.Now()
+	if result, override, ok := c.listed(addr); ok {
+		if c.Events != nil {
+			cr := CheckResult{Result: result, Domain: senderDomain(email), Override: override}
+			c.Events.Emit(newEvaluationEvent(start, Request{Addr: addr, Sender: email}, cr))
+		}
+
+		return result, nil
+	}
+
+	e := newEvaluation(ctx, c, addr)
+	result := c.check(e, email)
+
+	if c.Events != nil {
+		cr := CheckResult{Result: result, Err: e.err, Domain: e.domain, Mechanism: e.matched, Budget: e.budget}
+		c.Events.Emit(newEvaluationEvent(start, Request{Addr: addr, Sender: email}, cr))
+	}
+
+	return result, e.err
+}
+
+// check evaluates the SPF record of the domain of the email address. Errors
+// are recorded on the evaluation.
+func (c *Checker) check(e *evaluation, email string) Result {
+	var domain string
+
+	// Get domain name from email address. The local part may itself
+	// contain a quoted @ sign.
+	if i := strings.LastIndex(email, "@"); i != -1 {
+		domain = email[i+1:]
+	} else {
+		return e.fail(None, errors.New("Email address must contain an @ sign."))
+	}
+
+	e.domain = domain
+	e.sender = email
+
+	// RFC 7208 section 4.5: several records are a PermError.
+	spfText, err := c.source().Record(e.ctx, domain)
+	if err == ErrMultipleRecords {
+		return e.fail(PermError, err)
+	}
+
+	if err != nil {
+		return e.fail(TempError, err)
+	}
+
+	return c.checkRecord(e, spfText)
+}
+
+// checkRecord evaluates the SPF record published by the domain of the
+// evaluation.
+func (c *Checker) checkRecord(e *evaluation, spfText string) Result {
+	domain := e.domain
+
+	// No SPF record should result in None.
+	if spfText == "" {
+		return None
+	}
+
+	// Create a new SPF struct
+	spf, err := c.NewSPF(e.ctx, domain, spfText, 0)
+	if err != nil {
+		return e.fail(PermError, err)
+	}
+
+	return spf.evaluate(e)
+}
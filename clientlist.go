@@ -0,0 +1,38 @@
+package spf
+
+import (
+	"net/netip"
+)
+
+// listed returns the result for a client within the Allow or Block prefixes
+// of the Checker, and the override recording it.
+func (c *Checker) listed(addr netip.Addr) (Result, string, bool) {
+	if len(c.Allow) == 0 && len(c.Block) == 0 {
+		return None, "", false
+	}
+
+	addr = addr.Unmap()
+	allow, block := longestMatch(c.Allow, addr), longestMatch(c.Block, addr)
+
+	switch {
+	case block >= 0 && block >= allow:
+		return Fail, "block", true
+	case allow >= 0:
+		return Pass, "allow", true
+	}
+
+	return None, "", false
+}
+
+// longestMatch returns the length of the longest of the prefixes containing
+// addr, -1 if none does.
+func longestMatch(prefixes []netip.Prefix, addr netip.Addr) int {
+	longest := -1
+	for _, p := range prefixes {
+		if p.Bits() > longest && p.Contains(addr) {
+			longest = p.Bits()
+		}
+	}
+
+	return longest
+}
@@ -0,0 +1,46 @@
+package spf
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+)
+
+func TestClientLists(t *testing.T) {
+	lookups := 0
+	c := &Checker{
+		Source: RecordSourceFunc(func(ctx context.Context, domain string) (string, error) {
+			lookups++
+			return "v=spf1 ip4:192.0.2.0/24 -all", nil
+		}),
+		Allow: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("203.0.113.7/32")},
+		Block: []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24"), netip.MustParsePrefix("10.0.0.0/8")},
+	}
+
+	tests := []struct {
+		ip       string
+		result   Result
+		override string
+		lookups  int
+	}{
+		{"10.1.2.3", Fail, "block", 0},
+		{"203.0.113.7", Pass, "allow", 0},
+		{"::ffff:203.0.113.8", Fail, "block", 0},
+		{"192.0.2.1", Pass, "", 1},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.ip)
+
+		lookups = 0
+		cr := c.Check(context.Background(), Request{IP: test.ip, Sender: "info@example.com"})
+		if cr.Result != test.result || cr.Override != test.override || lookups != test.lookups {
+			t.Error("Expected", test.result, test.override, test.lookups, "got", cr.Result, cr.Override, lookups)
+		}
+
+		result, _ := c.SPFTest(context.Background(), test.ip, "info@example.com")
+		if result != test.result {
+			t.Error("Expected", test.result, "got", result)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+// Package dmarc fetches and parses DMARC records, RFC 7489, and evaluates
+// their policy using the SPF result of the spf package and the DKIM results
+// supplied by the caller.
+package dmarc
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/asggo/spf"
+)
+
+var (
+	ErrNoRecord      = errors.New("No DMARC record found.")
+	ErrInvalidRecord = errors.New("Invalid DMARC record.")
+	ErrFailedLookup  = errors.New("DMARC record lookup failed.")
+)
+
+// Policy is the p= or sp= policy of a record, the disposition requested
+// for messages failing DMARC.
+type Policy string
+
+const (
+	None       Policy = "none"
+	Quarantine Policy = "quarantine"
+	Reject     Policy = "reject"
+)
+
+// Record is a parsed DMARC record. Tags holds every tag of the record,
+// keyed by lowercase name; the ones used for the evaluation are also
+// available as fields, with their RFC 7489 defaults.
+type Record struct {
+	Raw             string
+	Policy          Policy
+	SubdomainPolicy Policy
+	Percent         int
+	DKIMAlignment   spf.AlignmentMode
+	SPFAlignment    spf.AlignmentMode
+	AggregateURIs   []string
+	FailureURIs     []string
+	Tags            map[string]string
+}
+
+// Parse parses the text of a DMARC record. The record must start with the
+// v=DMARC1 tag and give a p= policy.
+func Parse(text string) (*Record, error) {
+	r := &Record{
+		Raw:           text,
+		Percent:       100,
+		DKIMAlignment: spf.RelaxedAlignment,
+		SPFAlignment:  spf.RelaxedAlignment,
+		Tags:          make(map[string]string),
+	}
+
+	first := true
+	for _, part := range strings.Split(text, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if !ok || name == "" {
+			return nil, ErrInvalidRecord
+		}
+
+		if first && (name != "v" || value != "DMARC1") {
+			return nil, ErrInvalidRecord
+		}
+		first = false
+
+		r.Tags[name] = value
+	}
+
+	if first {
+		return nil, ErrInvalidRecord
+	}
+
+	var err error
+	if r.Policy, err = parsePolicy(r.Tags["p"]); err != nil {
+		return nil, err
+	}
+
+	r.SubdomainPolicy = r.Policy
+	if sp, ok := r.Tags["sp"]; ok {
+		if r.SubdomainPolicy, err = parsePolicy(sp); err != nil {
+			return nil, err
+		}
+	}
+
+	if pct, ok := r.Tags["pct"]; ok {
+		n, err := strconv.Atoi(pct)
+		if err != nil || n < 0 || n > 100 {
+			return nil, ErrInvalidRecord
+		}
+		r.Percent = n
+	}
+
+	for tag, mode := range map[string]*spf.AlignmentMode{"adkim": &r.DKIMAlignment, "aspf": &r.SPFAlignment} {
+		switch value, ok := r.Tags[tag]; {
+		case !ok:
+		case value == "r" || value == "s":
+			*mode = spf.AlignmentMode(value)
+		default:
+			return nil, ErrInvalidRecord
+		}
+	}
+
+	r.AggregateURIs = splitURIs(r.Tags["rua"])
+	r.FailureURIs = splitURIs(r.Tags["ruf"])
+
+	return r, nil
+}
+
+func parsePolicy(text string) (Policy, error) {
+	switch p := Policy(strings.ToLower(text)); p {
+	case None, Quarantine, Reject:
+		return p, nil
+	}
+
+	return "", ErrInvalidRecord
+}
+
+func splitURIs(text string) []string {
+	var uris []string
+	for _, uri := range strings.Split(text, ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+
+	return uris
+}
+
+// Checker fetches DMARC records and evaluates messages against them. The
+// zero value is ready to use and queries net.DefaultResolver.
+type Checker struct {
+	// Resolver fetches the _dmarc TXT records. If nil, net.DefaultResolver
+	// is used.
+	Resolver spf.Resolver
+
+	// Sample returns a number in [0, 100) for each failing message, which
+	// is only subject to the policy of a record with a pct= tag when the
+	// number is below it. If nil, a random number is drawn.
+	Sample func() int
+}
+
+// DefaultChecker is the Checker used by the package level functions.
+var DefaultChecker = &Checker{}
+
+func (c *Checker) resolver() spf.Resolver {
+	if c.Resolver == nil {
+		return net.DefaultResolver
+	}
+
+	return c.Resolver
+}
+
+// Lookup fetches the DMARC record of the domain using the DefaultChecker.
+// See Checker.Lookup.
+func Lookup(ctx context.Context, domain string) (*Record, string, error) {
+	return DefaultChecker.Lookup(ctx, domain)
+}
+
+// Lookup fetches the DMARC record of the domain of the RFC5322.From header,
+// falling back to the record of its organizational domain, RFC 7489 section
+// 6.6.3. The domain the record was found at is returned with it.
+func (c *Checker) Lookup(ctx context.Context, domain string) (*Record, string, error) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	r, err := c.fetch(ctx, domain)
+	if err != ErrNoRecord {
+		return r, domain, err
+	}
+
+	org := spf.OrganizationalDomain(domain)
+	if org == domain {
+		return nil, "", ErrNoRecord
+	}
+
+	r, err = c.fetch(ctx, org)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return r, org, nil
+}
+
+// fetch returns the DMARC record published at _dmarc.<domain>. Domains
+// publishing several records have none.
+func (c *Checker) fetch(ctx context.Context, domain string) (*Record, error) {
+	txts, err := c.resolver().LookupTXT(ctx, "_dmarc."+domain)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return nil, ErrNoRecord
+		}
+
+		return nil, ErrFailedLookup
+	}
+
+	var records []string
+	for _, txt := range txts {
+		if strings.HasPrefix(strings.TrimSpace(txt), "v=DMARC1") {
+			records = append(records, txt)
+		}
+	}
+
+	if len(records) != 1 {
+		return nil, ErrNoRecord
+	}
+
+	return Parse(records[0])
+}
+
+// Result is the outcome of a DMARC evaluation.
+type Result struct {
+	// Record is the record found at PolicyDomain, nil if none.
+	Record       *Record
+	PolicyDomain string
+
+	// SPFAligned and DKIMAligned report whether SPF and at least one
+	// DKIM signature passed for a domain aligned with the From domain.
+	// Pass is set when either is.
+	SPFAligned  bool
+	DKIMAligned bool
+	Pass        bool
+
+	// Disposition is the policy to apply to the message, None for the
+	// messages that pass or are sampled out by the pct= tag.
+	Disposition Policy
+}
+
+// Evaluate evaluates the message using the DefaultChecker. See
+// Checker.Evaluate.
+func Evaluate(ctx context.Context, fromDomain string, v spf.Verdict) (Result, error) {
+	return DefaultChecker.Evaluate(ctx, fromDomain, v)
+}
+
+// Evaluate fetches the DMARC record for the domain of the RFC5322.From
+// header and checks the SPF and DKIM results of the verdict for alignment
+// with it. The subdomain policy applies when the record was found at the
+// organizational domain. Without a record the disposition is None and
+// ErrNoRecord is returned.
+func (c *Checker) Evaluate(ctx context.Context, fromDomain string, v spf.Verdict) (Result, error) {
+	res := Result{Disposition: None}
+
+	record, domain, err := c.Lookup(ctx, fromDomain)
+	if err != nil {
+		return res, err
+	}
+	res.Record, res.PolicyDomain = record, domain
+
+	res.SPFAligned = spf.SPFAligned(v.SPF.Result, v.SPF.Domain, fromDomain, record.SPFAlignment)
+	for _, r := range v.DKIM {
+		if r.Result == spf.Pass && spf.Aligned(r.Domain, fromDomain, record.DKIMAlignment) {
+			res.DKIMAligned = true
+		}
+	}
+
+	res.Pass = res.SPFAligned || res.DKIMAligned
+	if res.Pass {
+		return res, nil
+	}
+
+	policy := record.Policy
+	if !strings.EqualFold(strings.TrimSuffix(fromDomain, "."), domain) {
+		policy = record.SubdomainPolicy
+	}
+
+	// RFC 7489 section 6.6.4: messages sampled out of the pct= tag get
+	// the next less strict policy.
+	if record.Percent < 100 && c.sample() >= record.Percent {
+		switch policy {
+		case Reject:
+			policy = Quarantine
+		case Quarantine:
+			policy = None
+		}
+	}
+	res.Disposition = policy
+
+	return res, nil
+}
+
+func (c *Checker) sample() int {
+	if c.Sample == nil {
+		return rand.Intn(100)
+	}
+
+	return c.Sample()
+}
@@ -0,0 +1,82 @@
+package dmarc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/asggo/spf"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		err  error
+	}{
+		{"v=DMARC1; p=reject; sp=quarantine; pct=25; adkim=s; rua=mailto:a@example.com, mailto:b@example.net", nil},
+		{"v=DMARC1; p=None", nil},
+		{"p=reject; v=DMARC1", ErrInvalidRecord},
+		{"v=DMARC1", ErrInvalidRecord},
+		{"v=DMARC1; p=block", ErrInvalidRecord},
+		{"v=DMARC1; p=none; pct=101", ErrInvalidRecord},
+		{"v=DMARC1; p=none; aspf=x", ErrInvalidRecord},
+		{"", ErrInvalidRecord},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.text)
+
+		if _, err := Parse(test.text); err != test.err {
+			t.Error("Expected", test.err, "got", err)
+		}
+	}
+
+	r, _ := Parse(tests[0].text)
+	if r.Policy != Reject || r.SubdomainPolicy != Quarantine || r.Percent != 25 ||
+		r.DKIMAlignment != spf.StrictAlignment || r.SPFAlignment != spf.RelaxedAlignment || len(r.AggregateURIs) != 2 {
+		t.Error("Unexpected record", r)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	z := spf.NewZone()
+	z.Add(
+		spf.ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 ip4:192.0.2.0/24 -all"},
+		spf.ZoneRecord{Name: "_dmarc.example.com", Type: "TXT", Data: "v=DMARC1; p=reject; sp=quarantine; aspf=s"},
+		spf.ZoneRecord{Name: "bounces.example.com", Type: "TXT", Data: "v=spf1 ip4:198.51.100.0/24 -all"},
+		spf.ZoneRecord{Name: "_dmarc.lax.example", Type: "TXT", Data: "v=DMARC1; p=reject; pct=50"},
+	)
+
+	c := &Checker{Resolver: z, Sample: func() int { return 75 }}
+	checker := &spf.Checker{Resolver: z}
+
+	tests := []struct {
+		ip, sender, from string
+		dkim             []spf.DKIMResult
+		pass             bool
+		policy           Policy
+		err              error
+	}{
+		{"192.0.2.1", "info@example.com", "example.com", nil, true, None, nil},
+		{"198.51.100.1", "info@bounces.example.com", "example.com", nil, false, Reject, nil},
+		{"198.51.100.1", "info@bounces.example.com", "example.com", []spf.DKIMResult{{Result: spf.Pass, Domain: "mail.example.com"}}, true, None, nil},
+		{"203.0.113.1", "info@example.com", "news.example.com", nil, false, Quarantine, nil},
+		{"203.0.113.1", "info@example.com", "lax.example", nil, false, Quarantine, nil},
+		{"203.0.113.1", "info@example.com", "example.org", nil, false, None, ErrNoRecord},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.ip, test.sender, test.from)
+
+		req := spf.Request{IP: test.ip, Sender: test.sender}
+		v := spf.NewVerdict(req, checker.Check(context.Background(), req), test.dkim...)
+
+		res, err := c.Evaluate(context.Background(), test.from, v)
+		if err != test.err {
+			t.Error("Expected", test.err, "got", err)
+		}
+
+		if res.Pass != test.pass || res.Disposition != test.policy {
+			t.Error("Expected", test.pass, test.policy, "got", res.Pass, res.Disposition)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package spf
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	ErrMultipleRecords = errors.New("Multiple SPF records found.")
+)
+
+// TXTFromMsg returns the TXT records in the answer section of the message.
+// The character-strings of each record are concatenated without separator,
+// as RFC 7208 section 3.3 requires.
+func TXTFromMsg(msg *dns.Msg) []string {
+	var records []string
+
+	for _, rr := range msg.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			records = append(records, strings.Join(txt.Txt, ""))
+		}
+	}
+
+	return records
+}
+
+// RecordFromMsg returns the SPF record in the answer to a TXT query, ready to
+// be passed to NewSPF. An empty string is returned when the name does not
+// exist or publishes no SPF record. ErrFailedLookup is returned for failed
+// queries, ErrTruncated for truncated answers, which may miss records, and
+// ErrMultipleRecords when several SPF records are published.
+func RecordFromMsg(msg *dns.Msg) (string, error) {
+	if msg.Truncated {
+		return "", ErrTruncated
+	}
+
+	switch msg.Rcode {
+	case dns.RcodeSuccess:
+	case dns.RcodeNameError:
+		return "", nil
+	default:
+		return "", ErrFailedLookup
+	}
+
+	return SelectRecord(TXTFromMsg(msg))
+}
@@ -0,0 +1,51 @@
+package spf
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func txtMsg(t *testing.T, rcode int, records ...string) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.com.", dns.TypeTXT)
+	msg.Rcode = rcode
+
+	for _, record := range records {
+		rr, err := dns.NewRR("example.com. 300 IN TXT " + record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg.Answer = append(msg.Answer, rr)
+	}
+
+	return msg
+}
+
+func TestRecordFromMsg(t *testing.T) {
+	tests := []struct {
+		msg    *dns.Msg
+		record string
+		err    error
+	}{
+		{txtMsg(t, dns.RcodeSuccess, `"v=spf1 ip4:192.0.2.0/24 " "include:_spf.example.com -all"`), "v=spf1 ip4:192.0.2.0/24 include:_spf.example.com -all", nil},
+		{txtMsg(t, dns.RcodeSuccess, `"google-site-verification=abc"`, `"v=spf1 -all"`), "v=spf1 -all", nil},
+		{txtMsg(t, dns.RcodeSuccess, `"v=spf10 -all"`), "", nil},
+		{txtMsg(t, dns.RcodeSuccess, `"v=spf1 -all"`, `"v=spf1 ~all"`), "", ErrMultipleRecords},
+		{txtMsg(t, dns.RcodeNameError), "", nil},
+		{txtMsg(t, dns.RcodeServerFailure), "", ErrFailedLookup},
+		{truncated(txtMsg(t, dns.RcodeSuccess, `"v=spf1 -all"`)), "", ErrTruncated},
+	}
+
+	for _, test := range tests {
+		record, err := RecordFromMsg(test.msg)
+		if record != test.record || err != test.err {
+			t.Error("Expected", test.record, test.err, "got", record, err)
+		}
+	}
+}
+
+func truncated(msg *dns.Msg) *dns.Msg {
+	msg.Truncated = true
+	return msg
+}
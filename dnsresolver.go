@@ -0,0 +1,488 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// DefaultDNSTimeout bounds each exchange of a DNSResolver with a
+	// nameserver when no Timeout is set.
+	DefaultDNSTimeout = 5 * time.Second
+
+	// DefaultUDPSize is the EDNS0 UDP buffer size advertised by a
+	// DNSResolver that needs an OPT record but has no UDPSize. It avoids IP
+	// fragmentation on most networks.
+	DefaultUDPSize = 1232
+
+	// truncatedBufferSize is the EDNS0 UDP buffer size advertised when a
+	// truncated answer is retried over UDP.
+	truncatedBufferSize = 4096
+
+	resolvConf = "/etc/resolv.conf"
+
+	// maxRecentAnswers is the number of recent answers whose TTL a
+	// DNSResolver keeps before the expired ones are dropped.
+	maxRecentAnswers = 4096
+
+	// recentAnswerWindow is how long a DNSResolver remembers that an
+	// answer held no records.
+	recentAnswerWindow = time.Minute
+)
+
+var (
+	ErrTruncated = errors.New("DNS answer truncated.")
+)
+
+// DNSResolver is a Resolver built on github.com/miekg/dns. It queries the
+// nameservers directly, never uses a truncated answer, see Exchange, and
+// reports the TTL of its answers: LookupTTL returns the TTL of the last
+// answer for the name and type while it is valid, so wrappers such as
+// CachingResolver learn the TTL of the answer they got without another
+// query. LookupRR gives access to the raw resource records, e.g. of the SPF
+// type 99 or DNSSEC records. A DNSResolver must not be copied after first
+// use.
+type DNSResolver struct {
+	// Servers are the addresses, as host:port, of the recursive nameservers
+	// queried in order until one answers. The port defaults to 53. If
+	// empty, the nameservers of /etc/resolv.conf are used.
+	Servers []string
+
+	// Timeout bounds each exchange with a nameserver. If zero,
+	// DefaultDNSTimeout is used.
+	Timeout time.Duration
+
+	// UDPSize is the EDNS0 UDP buffer size advertised by the queries. If
+	// zero, queries carry no EDNS0 OPT record unless DNSSEC or
+	// NoClientSubnet needs one, which advertises DefaultUDPSize.
+	UDPSize uint16
+
+	// DNSSEC sets the DO bit of the queries, asking for the DNSSEC records
+	// of the answers.
+	DNSSEC bool
+
+	// NoClientSubnet adds an EDNS0 client subnet option with a source
+	// prefix length of zero to the queries, asking the resolvers not to
+	// forward the subnet of the client to authoritative servers, see RFC
+	// 7871 section 7.1.2.
+	NoClientSubnet bool
+
+	once    sync.Once
+	system  []string
+	confErr error
+
+	mu     sync.Mutex
+	recent map[string]recentAnswer
+}
+
+// recentAnswer is the TTL of an answer received by a DNSResolver, ok being
+// false for an answer without records.
+type recentAnswer struct {
+	ttl      time.Duration
+	ok       bool
+	received time.Time
+}
+
+// servers returns the nameservers to query.
+func (r *DNSResolver) servers() ([]string, error) {
+	if len(r.Servers) > 0 {
+		servers := make([]string, len(r.Servers))
+		for i, s := range r.Servers {
+			servers[i] = nameserverAddr(s)
+		}
+
+		return servers, nil
+	}
+
+	r.once.Do(func() {
+		conf, err := dns.ClientConfigFromFile(resolvConf)
+		if err != nil {
+			r.confErr = err
+			return
+		}
+
+		for _, s := range conf.Servers {
+			r.system = append(r.system, net.JoinHostPort(s, conf.Port))
+		}
+	})
+
+	return r.system, r.confErr
+}
+
+// nameserverAddr adds the default port to the address of a nameserver
+// without one, e.g. "192.0.2.53" or "2001:db8::53".
+func nameserverAddr(addr string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+
+	return net.JoinHostPort(strings.Trim(addr, "[]"), "53")
+}
+
+func (r *DNSResolver) timeout() time.Duration {
+	if r.Timeout == 0 {
+		return DefaultDNSTimeout
+	}
+
+	return r.Timeout
+}
+
+// Exchange sends the query to the nameservers in order and returns the first
+// answer that is not a server failure. A truncated UDP answer is retried with
+// a larger EDNS0 buffer, then over TCP. ErrTruncated is returned rather than
+// an incomplete answer, so the TXT records of a large record are never
+// evaluated partially.
+func (r *DNSResolver) Exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error) {
+	servers, err := r.servers()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *dns.Msg
+	err = &net.DNSError{Err: "no nameservers", Name: questionName(query)}
+
+	for _, server := range servers {
+		resp, err = r.exchange(ctx, query, server)
+		if err == nil && resp.Rcode != dns.RcodeServerFailure {
+			return resp, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	if err == nil {
+		err = &net.DNSError{Err: "server misbehaving", Name: questionName(query), Server: servers[len(servers)-1], IsTemporary: true}
+	}
+
+	return nil, err
+}
+
+// exchange queries a single nameserver. A truncated answer is never
+// returned, as the missing records could change the result of an
+// evaluation: the query is retried over UDP with a larger EDNS0 buffer, then
+// over TCP, and ErrTruncated is returned if the answer is still incomplete.
+func (r *DNSResolver) exchange(ctx context.Context, query *dns.Msg, server string) (*dns.Msg, error) {
+	client := &dns.Client{Net: "udp", Timeout: r.timeout(), UDPSize: r.UDPSize}
+
+	resp, _, err := client.ExchangeContext(ctx, query, server)
+	if err != nil || !resp.Truncated {
+		return resp, err
+	}
+
+	if opt := query.IsEdns0(); opt == nil || opt.UDPSize() < truncatedBufferSize {
+		larger := query.Copy()
+		if opt := larger.IsEdns0(); opt != nil {
+			opt.SetUDPSize(truncatedBufferSize)
+		} else {
+			larger.SetEdns0(truncatedBufferSize, false)
+		}
+
+		client.UDPSize = truncatedBufferSize
+		resp, _, err = client.ExchangeContext(ctx, larger, server)
+		if err == nil && !resp.Truncated {
+			return resp, nil
+		}
+	}
+
+	client.Net = "tcp"
+	resp, _, err = client.ExchangeContext(ctx, query, server)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, ErrTruncated
+	}
+
+	if resp.Truncated {
+		return nil, ErrTruncated
+	}
+
+	return resp, nil
+}
+
+// LookupRR returns the resource records of type qtype, e.g. dns.TypeSPF,
+// published for name. Records of other types in the answer, like the CNAME
+// records leading to name, are left out.
+func (r *DNSResolver) LookupRR(ctx context.Context, name string, qtype uint16) ([]dns.RR, error) {
+	resp, err := r.Exchange(ctx, r.query(name, qtype))
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Rcode {
+	case dns.RcodeSuccess:
+	case dns.RcodeNameError:
+		r.remember(name, qtype, nil)
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	default:
+		return nil, &net.DNSError{Err: dns.RcodeToString[resp.Rcode], Name: name}
+	}
+
+	var rrs []dns.RR
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype == qtype {
+			rrs = append(rrs, rr)
+		}
+	}
+
+	r.remember(name, qtype, rrs)
+
+	return rrs, nil
+}
+
+// answerKey returns the key of the answers for the records of type qtype of
+// name.
+func answerKey(name string, qtype uint16) string {
+	return dns.TypeToString[qtype] + " " + canonicalName(name)
+}
+
+// minTTL returns the lowest TTL of the records.
+func minTTL(rrs []dns.RR) time.Duration {
+	ttl := rrs[0].Header().Ttl
+	for _, rr := range rrs[1:] {
+		if rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+
+	return time.Duration(ttl) * time.Second
+}
+
+// remember keeps the TTL of the answer holding the records.
+func (r *DNSResolver) remember(name string, qtype uint16, rrs []dns.RR) {
+	answer := recentAnswer{received: time.Now()}
+	if len(rrs) > 0 {
+		answer.ttl, answer.ok = minTTL(rrs), true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.recent == nil {
+		r.recent = make(map[string]recentAnswer)
+	}
+
+	if len(r.recent) >= maxRecentAnswers {
+		for key, a := range r.recent {
+			if _, _, valid := a.remaining(answer.received); !valid {
+				delete(r.recent, key)
+			}
+		}
+
+		if len(r.recent) >= maxRecentAnswers {
+			r.recent = make(map[string]recentAnswer)
+		}
+	}
+
+	r.recent[answerKey(name, qtype)] = answer
+}
+
+// remaining returns the TTL left at now, whether the answer held records and
+// whether it is still valid.
+func (a recentAnswer) remaining(now time.Time) (time.Duration, bool, bool) {
+	age := now.Sub(a.received)
+	if !a.ok {
+		return 0, false, age < recentAnswerWindow
+	}
+
+	// An answer is valid for a second at least, so that a TTL of zero is
+	// reported right after the lookup instead of being queried again.
+	if age >= max(a.ttl, time.Second) {
+		return 0, true, false
+	}
+
+	return max((a.ttl - age).Round(time.Second), 0), true, true
+}
+
+// query returns the query for the records of type qtype of name, with the
+// EDNS0 options of the resolver.
+func (r *DNSResolver) query(name string, qtype uint16) *dns.Msg {
+	query := new(dns.Msg)
+	query.SetQuestion(dns.Fqdn(name), qtype)
+
+	if r.UDPSize == 0 && !r.DNSSEC && !r.NoClientSubnet {
+		return query
+	}
+
+	size := r.UDPSize
+	if size == 0 {
+		size = DefaultUDPSize
+	}
+
+	query.SetEdns0(size, r.DNSSEC)
+
+	if r.NoClientSubnet {
+		opt := query.IsEdns0()
+		opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{
+			Code:    dns.EDNS0SUBNET,
+			Family:  1,
+			Address: net.IPv4zero,
+		})
+	}
+
+	return query
+}
+
+// LookupTXT looks up the TXT records for name. The character-strings of each
+// record are concatenated.
+func (r *DNSResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	rrs, err := r.LookupRR(ctx, name, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+
+	var txts []string
+	for _, rr := range rrs {
+		txts = append(txts, strings.Join(rr.(*dns.TXT).Txt, ""))
+	}
+
+	return txts, nil
+}
+
+// LookupHost looks up the IPv4 and IPv6 addresses of host.
+func (r *DNSResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	ips, err := r.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = ip.String()
+	}
+
+	return addrs, nil
+}
+
+// LookupIP looks up the addresses of host for the network, "ip4", "ip6" or
+// "ip" for both. Like net.Resolver, it returns a not found error when the
+// host has no address.
+func (r *DNSResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	var qtypes []uint16
+
+	switch network {
+	case "ip4":
+		qtypes = []uint16{dns.TypeA}
+	case "ip6":
+		qtypes = []uint16{dns.TypeAAAA}
+	case "ip":
+		qtypes = []uint16{dns.TypeA, dns.TypeAAAA}
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+
+	var ips []net.IP
+	for _, qtype := range qtypes {
+		rrs, err := r.LookupRR(ctx, host, qtype)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rr := range rrs {
+			switch rr := rr.(type) {
+			case *dns.A:
+				ips = append(ips, rr.A)
+			case *dns.AAAA:
+				ips = append(ips, rr.AAAA)
+			}
+		}
+	}
+
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return ips, nil
+}
+
+// LookupMX looks up the MX records for name, sorted by preference.
+func (r *DNSResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	rrs, err := r.LookupRR(ctx, name, dns.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+
+	var mxs []*net.MX
+	for _, rr := range rrs {
+		mx := rr.(*dns.MX)
+		mxs = append(mxs, &net.MX{Host: mx.Mx, Pref: mx.Preference})
+	}
+
+	sort.SliceStable(mxs, func(i, j int) bool {
+		return mxs[i].Pref < mxs[j].Pref
+	})
+
+	return mxs, nil
+}
+
+// LookupAddr looks up the names of addr.
+func (r *DNSResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	arpa, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return nil, &net.DNSError{Err: "unrecognized address", Name: addr}
+	}
+
+	rrs, err := r.LookupRR(ctx, arpa, dns.TypePTR)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, rr := range rrs {
+		names = append(names, rr.(*dns.PTR).Ptr)
+	}
+
+	return names, nil
+}
+
+// LookupTTL returns the lowest TTL of the records of type rtype published for
+// name, left from the last answer for them if still valid, else from a new
+// query.
+func (r *DNSResolver) LookupTTL(ctx context.Context, name, rtype string) (time.Duration, error) {
+	qtype, ok := dns.StringToType[strings.ToUpper(rtype)]
+	if !ok {
+		return 0, &net.DNSError{Err: "unknown record type " + rtype, Name: name}
+	}
+
+	r.mu.Lock()
+	answer, found := r.recent[answerKey(name, qtype)]
+	r.mu.Unlock()
+
+	if found {
+		if ttl, ok, valid := answer.remaining(time.Now()); valid {
+			if !ok {
+				return 0, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+			}
+			return ttl, nil
+		}
+	}
+
+	rrs, err := r.LookupRR(ctx, name, qtype)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(rrs) == 0 {
+		return 0, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return minTTL(rrs), nil
+}
+
+// questionName returns the name queried by the message.
+func questionName(msg *dns.Msg) string {
+	if len(msg.Question) == 0 {
+		return ""
+	}
+
+	return msg.Question[0].Name
+}
@@ -0,0 +1,349 @@
+package spf
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Truncation modes of the test DNS server.
+const (
+	truncateNever = iota
+	truncateSmall // UDP TXT answers without a 4096 bytes EDNS0 buffer
+	truncateUDP   // all UDP TXT answers
+)
+
+// startDNSServer serves the zone over UDP and, unless noTCP is set, TCP on
+// the same local port. TXT answers are truncated following the mode. The
+// queries received over TCP and in all are counted.
+func startDNSServer(t *testing.T, zone []string, truncate int, noTCP bool) (addr string, tcpQueries, queries *int32) {
+	var rrs []dns.RR
+	for _, s := range zone {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rrs = append(rrs, rr)
+	}
+
+	tcpQueries, queries = new(int32), new(int32)
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		atomic.AddInt32(queries, 1)
+
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+
+		q := req.Question[0]
+		found := false
+		for _, rr := range rrs {
+			if !strings.EqualFold(rr.Header().Name, q.Name) {
+				continue
+			}
+			found = true
+
+			if rr.Header().Rrtype == q.Qtype {
+				resp.Answer = append(resp.Answer, rr)
+			}
+		}
+
+		if !found {
+			resp.Rcode = dns.RcodeNameError
+		}
+
+		tcp := w.RemoteAddr().Network() == "tcp"
+		if tcp {
+			atomic.AddInt32(tcpQueries, 1)
+		}
+
+		small := req.IsEdns0() == nil || req.IsEdns0().UDPSize() < 4096
+		if !tcp && q.Qtype == dns.TypeTXT && (truncate == truncateUDP || truncate == truncateSmall && small) {
+			resp.Answer = resp.Answer[:len(resp.Answer)/2]
+			resp.Truncated = true
+		}
+
+		w.WriteMsg(resp)
+	})
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("Cannot listen:", err)
+	}
+
+	udp := &dns.Server{PacketConn: pc, Handler: handler}
+	go udp.ActivateAndServe()
+	t.Cleanup(func() { udp.Shutdown() })
+
+	if !noTCP {
+		l, err := net.Listen("tcp", pc.LocalAddr().String())
+		if err != nil {
+			t.Skip("Cannot listen:", err)
+		}
+
+		tcp := &dns.Server{Listener: l, Handler: handler}
+		go tcp.ActivateAndServe()
+		t.Cleanup(func() { tcp.Shutdown() })
+	}
+
+	return pc.LocalAddr().String(), tcpQueries, queries
+}
+
+var resolverZone = []string{
+	`example.com. 300 IN TXT "v=spf1 mx " "-all"`,
+	`example.com. 600 IN MX 20 mx2.example.com.`,
+	`example.com. 600 IN MX 10 mx1.example.com.`,
+	`mx1.example.com. 300 IN A 192.0.2.1`,
+	`mx1.example.com. 300 IN AAAA 2001:db8::1`,
+	`1.2.0.192.in-addr.arpa. 300 IN PTR mx1.example.com.`,
+	`example.com. 300 IN SPF "v=spf1 -all"`,
+}
+
+func TestDNSResolver(t *testing.T) {
+	addr, _, _ := startDNSServer(t, resolverZone, truncateNever, false)
+	r := &DNSResolver{Servers: []string{addr}}
+	ctx := context.Background()
+
+	txt, err := r.LookupTXT(ctx, "example.com")
+	if err != nil || len(txt) != 1 || txt[0] != "v=spf1 mx -all" {
+		t.Error("Expected [v=spf1 mx -all] got", txt, err)
+	}
+
+	mxs, err := r.LookupMX(ctx, "example.com")
+	if err != nil || len(mxs) != 2 || mxs[0].Host != "mx1.example.com." {
+		t.Error("Expected mx1.example.com. first got", mxs, err)
+	}
+
+	hosts, err := r.LookupHost(ctx, "mx1.example.com")
+	if err != nil || len(hosts) != 2 || hosts[0] != "192.0.2.1" || hosts[1] != "2001:db8::1" {
+		t.Error("Expected [192.0.2.1 2001:db8::1] got", hosts, err)
+	}
+
+	names, err := r.LookupAddr(ctx, "192.0.2.1")
+	if err != nil || len(names) != 1 || names[0] != "mx1.example.com." {
+		t.Error("Expected [mx1.example.com.] got", names, err)
+	}
+
+	if _, err := r.LookupTXT(ctx, "missing.example.com"); !isNotFound(err) {
+		t.Error("Expected a not found error got", err)
+	}
+
+	if _, err := r.LookupIP(ctx, "ip6", "example.com"); !isNotFound(err) {
+		t.Error("Expected a not found error got", err)
+	}
+
+	ttl, err := r.LookupTTL(ctx, "example.com", "MX")
+	if err != nil || ttl.Seconds() != 600 {
+		t.Error("Expected 10m0s got", ttl, err)
+	}
+
+	rrs, err := r.LookupRR(ctx, "example.com", dns.TypeSPF)
+	if err != nil || len(rrs) != 1 {
+		t.Error("Expected an SPF record got", rrs, err)
+	}
+}
+
+func TestDNSResolverTTLReused(t *testing.T) {
+	addr, _, queries := startDNSServer(t, resolverZone, truncateNever, false)
+	r := &CachingResolver{Resolver: &DNSResolver{Servers: []string{addr}}}
+	ctx := context.Background()
+
+	lookups := []func() error{
+		func() error { _, err := r.LookupTXT(ctx, "example.com"); return err },
+		func() error { _, err := r.LookupMX(ctx, "example.com"); return err },
+		func() error { r.LookupTXT(ctx, "missing.example.com"); return nil },
+	}
+
+	for i, lookup := range lookups {
+		if err := lookup(); err != nil {
+			t.Fatal("Expected", nil, "got", err)
+		}
+
+		if n := atomic.LoadInt32(queries); n != int32(i+1) {
+			t.Error("Expected", i+1, "queries got", n)
+		}
+	}
+
+	ttl, err := r.LookupTTL(ctx, "example.com", "TXT")
+	if err != nil || ttl.Seconds() != 300 {
+		t.Error("Expected 5m0s got", ttl, err)
+	}
+
+	if _, err := r.LookupTTL(ctx, "missing.example.com", "TXT"); !isNotFound(err) {
+		t.Error("Expected a not found error got", err)
+	}
+
+	if n := atomic.LoadInt32(queries); n != int32(len(lookups)) {
+		t.Error("Expected", len(lookups), "queries got", n)
+	}
+}
+
+// ttlCache records the TTL of the values set in a MemoryCache.
+type ttlCache struct {
+	MemoryCache
+	ttls map[string]time.Duration
+}
+
+func (c *ttlCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.ttls[key] = ttl
+	return c.MemoryCache.Set(ctx, key, value, ttl)
+}
+
+func TestCachingResolverZeroTTL(t *testing.T) {
+	zone := append([]string{`zero.example.com. 0 IN TXT "v=spf1 -all"`}, resolverZone...)
+	addr, _, queries := startDNSServer(t, zone, truncateNever, false)
+
+	cache := &ttlCache{ttls: make(map[string]time.Duration)}
+	r := &CachingResolver{Resolver: &DNSResolver{Servers: []string{addr}}, Cache: cache}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.LookupTXT(ctx, "zero.example.com"); err != nil {
+			t.Fatal("Expected", nil, "got", err)
+		}
+	}
+
+	if _, ok := cache.ttls["TXT zero.example.com"]; ok {
+		t.Error("Expected a zero TTL answer not to be cached got", cache.ttls)
+	}
+
+	if n := atomic.LoadInt32(queries); n != 2 {
+		t.Error("Expected", 2, "queries got", n)
+	}
+
+	r.LookupTXT(ctx, "example.com")
+	if ttl := cache.ttls["TXT example.com"]; ttl.Seconds() != 300 {
+		t.Error("Expected 5m0s got", ttl)
+	}
+}
+
+func TestDNSResolverTCPFallback(t *testing.T) {
+	addr, tcpQueries, _ := startDNSServer(t, resolverZone, truncateUDP, false)
+	r := &DNSResolver{Servers: []string{addr}}
+
+	txt, err := r.LookupTXT(context.Background(), "example.com")
+	if err != nil || len(txt) != 1 || txt[0] != "v=spf1 mx -all" {
+		t.Error("Expected [v=spf1 mx -all] got", txt, err)
+	}
+
+	if *tcpQueries != 1 {
+		t.Error("Expected 1 TCP query got", *tcpQueries)
+	}
+}
+
+func TestDNSResolverChecker(t *testing.T) {
+	addr, _, _ := startDNSServer(t, resolverZone, truncateNever, false)
+	c := &Checker{Resolver: &DNSResolver{Servers: []string{addr}}}
+
+	result, err := c.SPFTest(context.Background(), "2001:db8::1", "info@example.com")
+	if result != Pass {
+		t.Error("Expected", Pass, "got", result, err)
+	}
+}
+
+func TestDNSResolverTruncated(t *testing.T) {
+	zone := append([]string{`example.com. 300 IN TXT "google-site-verification=abc"`}, resolverZone...)
+
+	// A larger EDNS0 buffer avoids the TCP query.
+	addr, tcpQueries, _ := startDNSServer(t, zone, truncateSmall, false)
+	r := &DNSResolver{Servers: []string{addr}}
+
+	txt, err := r.LookupTXT(context.Background(), "example.com")
+	if err != nil || len(txt) != 2 {
+		t.Error("Expected 2 records got", txt, err)
+	}
+
+	if *tcpQueries != 0 {
+		t.Error("Expected no TCP query got", *tcpQueries)
+	}
+
+	// Without TCP the partial answer is not used.
+	addr, _, _ = startDNSServer(t, zone, truncateUDP, true)
+	r = &DNSResolver{Servers: []string{addr}, Timeout: time.Second}
+
+	if txt, err := r.LookupTXT(context.Background(), "example.com"); err != ErrTruncated {
+		t.Error("Expected", ErrTruncated, "got", txt, err)
+	}
+
+	c := &Checker{Resolver: r}
+	if result, _ := c.SPFTest(context.Background(), "192.0.2.1", "info@example.com"); result != TempError {
+		t.Error("Expected", TempError, "got", result)
+	}
+}
+
+func TestDNSResolverEDNS0(t *testing.T) {
+	tests := []struct {
+		resolver *DNSResolver
+		size     uint16
+		do       bool
+		subnet   bool
+	}{
+		{&DNSResolver{}, 0, false, false},
+		{&DNSResolver{UDPSize: 4096}, 4096, false, false},
+		{&DNSResolver{DNSSEC: true}, DefaultUDPSize, true, false},
+		{&DNSResolver{NoClientSubnet: true, UDPSize: 1400}, 1400, false, true},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.resolver.UDPSize, test.resolver.DNSSEC, test.resolver.NoClientSubnet)
+
+		opt := test.resolver.query("example.com", dns.TypeTXT).IsEdns0()
+		if opt == nil {
+			if test.size != 0 {
+				t.Error("Expected an OPT record")
+			}
+			continue
+		}
+
+		if opt.UDPSize() != test.size || opt.Do() != test.do {
+			t.Error("Expected", test.size, test.do, "got", opt.UDPSize(), opt.Do())
+		}
+
+		subnet := len(opt.Option) == 1 && opt.Option[0].(*dns.EDNS0_SUBNET).SourceNetmask == 0
+		if subnet != test.subnet {
+			t.Error("Expected", test.subnet, "got", subnet)
+		}
+	}
+
+	// The options survive the wire format.
+	addr, _, _ := startDNSServer(t, resolverZone, truncateNever, false)
+	r := &DNSResolver{Servers: []string{addr}, DNSSEC: true, NoClientSubnet: true}
+
+	if txt, err := r.LookupTXT(context.Background(), "example.com"); err != nil || len(txt) != 1 {
+		t.Error("Expected 1 record got", txt, err)
+	}
+}
+
+func TestNameserverAddr(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{"192.0.2.53", "192.0.2.53:53"},
+		{"192.0.2.53:5353", "192.0.2.53:5353"},
+		{"2001:db8::53", "[2001:db8::53]:53"},
+		{"[2001:db8::53]", "[2001:db8::53]:53"},
+		{"[2001:db8::53]:5353", "[2001:db8::53]:5353"},
+	}
+
+	for _, test := range tests {
+		if addr := nameserverAddr(test.addr); addr != test.expected {
+			t.Error("Expected", test.expected, "got", addr)
+		}
+	}
+}
+
+func TestCheckerNameservers(t *testing.T) {
+	addr, _, _ := startDNSServer(t, resolverZone, truncateNever, false)
+
+	// The first nameserver does not answer.
+	c := &Checker{Nameservers: []string{"127.0.0.1:1", addr}}
+
+	result, err := c.SPFTest(context.Background(), "192.0.2.1", "info@example.com")
+	if result != Pass {
+		t.Error("Expected", Pass, "got", result, err)
+	}
+}
@@ -0,0 +1,90 @@
+package spf
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+var (
+	ErrInvalidDomain = errors.New("Invalid domain-spec in SPF string.")
+)
+
+// validDomainSpec checks the domain-spec of a mechanism or modifier against
+// the grammar of RFC 7208 section 7.1: macros must be well formed, other
+// characters visible ASCII, and the domain must end with a macro or a
+// top-level label, optionally followed by a dot. Labels without macros must
+// be 1 to 63 characters long.
+func validDomainSpec(spec string) error {
+	if spec == "" {
+		return ErrEmptyDomain
+	}
+
+	for i := 0; i < len(spec); i++ {
+		if spec[i] < 0x21 || spec[i] > 0x7e {
+			return ErrInvalidDomain
+		}
+	}
+
+	if hasMacro(spec) {
+		mc := macroContext{sender: "postmaster@example.com", domain: "example.com", ip: net.IPv4(192, 0, 2, 1)}
+		if _, err := mc.expand(spec); err != nil {
+			return err
+		}
+
+		// A domain-spec may end with a macro instead of a top-level label.
+		if strings.HasSuffix(spec, "}") || strings.HasSuffix(spec, "%%") ||
+			strings.HasSuffix(spec, "%_") || strings.HasSuffix(spec, "%-") {
+			return validLabels(spec, false)
+		}
+	}
+
+	return validLabels(strings.TrimSuffix(spec, "."), true)
+}
+
+// validLabels checks the dot separated labels of the domain-spec. When top is
+// set the last label must be a top-level label.
+func validLabels(spec string, top bool) error {
+	labels := strings.Split(spec, ".")
+	if top && len(labels) < 2 {
+		return ErrInvalidDomain
+	}
+
+	for _, label := range labels {
+		if label == "" {
+			return ErrInvalidDomain
+		}
+
+		if !hasMacro(label) && len(label) > 63 {
+			return ErrInvalidDomain
+		}
+	}
+
+	if top && !validTopLabel(labels[len(labels)-1]) {
+		return ErrInvalidDomain
+	}
+
+	return nil
+}
+
+// validTopLabel reports whether the label is a toplabel: alphanumeric
+// characters and inner hyphens, not only digits.
+func validTopLabel(label string) bool {
+	if hasMacro(label) || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+
+	alpha := false
+	for i := 0; i < len(label); i++ {
+		c := label[i] | 0x20
+		switch {
+		case c >= 'a' && c <= 'z':
+			alpha = true
+		case label[i] >= '0' && label[i] <= '9', label[i] == '-':
+		default:
+			return false
+		}
+	}
+
+	return alpha || strings.Contains(label, "-")
+}
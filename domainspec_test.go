@@ -0,0 +1,48 @@
+package spf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDomainSpec(t *testing.T) {
+	tests := []struct {
+		term string
+		err  error
+	}{
+		{"include:_spf.example.com", nil},
+		{"include:_spf.example.com.", nil},
+		{"a:mail-1.example.co.uk/24", nil},
+		{"exists:%{i}.%{d}", nil},
+		{"exists:%{ir}.%{v}._spf.%{d2}", nil},
+		{"redirect=%{l1r+-}._spf.example.com", nil},
+		{"include:example..com", ErrInvalidDomain},
+		{"include:.example.com", ErrInvalidDomain},
+		{"include:example.com..", ErrInvalidDomain},
+		{"include:localhost", ErrInvalidDomain},
+		{"a:mail.example.123", ErrInvalidDomain},
+		{"mx:mail.example.-com", ErrInvalidDomain},
+		{"ptr:exa\tmple.com", ErrInvalidDomain},
+		{"include:" + strings.Repeat("a", 64) + ".example.com", ErrInvalidDomain},
+		{"exists:%{x}.example.com", ErrInvalidMacro},
+		{"exists:%{i.example.com", ErrInvalidMacro},
+		{"exists:%i.example.com", ErrInvalidMacro},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.term)
+
+		if _, err := NewMechanism(test.term, "example.com"); err != test.err {
+			t.Error("Expected", test.err, "got", err)
+		}
+	}
+
+	if _, err := NewSPF("example.com", "v=spf1 include:example..com -all", 0); err != ErrInvalidDomain {
+		t.Error("Expected", ErrInvalidDomain, "got", err)
+	}
+
+	_, warnings, _ := ParseLenient("example.com", "v=spf1 include:example..com -all")
+	if len(warnings) != 1 || warnings[0].Err != ErrInvalidDomain {
+		t.Error("Expected", ErrInvalidDomain, "got", warnings)
+	}
+}
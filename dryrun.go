@@ -0,0 +1,121 @@
+package spf
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// Query is a DNS query made while evaluating a record.
+type Query struct {
+	Type string // e.g. TXT, A, AAAA, MX or PTR
+	Name string
+}
+
+// Return a Query as a string.
+func (q Query) String() string {
+	return fmt.Sprintf("%s %s", q.Type, q.Name)
+}
+
+// DryRun evaluates the record of the domain of the email address for the ip
+// without sending any DNS query and returns, in order, the queries the
+// evaluation would make. Queries are answered as if the name did not exist,
+// so every mechanism requiring a lookup is reached unless an ip4, ip6 or all
+// mechanism matches first. Routes and Nameservers are ignored. Records are
+// taken from the Checker's Source when it is a MapSource, possibly shared by
+// a SharedSource; other Sources may reach the network and are answered as
+// not found too, so without a MapSource only the query for the first record
+// is listed.
+func (c *Checker) DryRun(ctx context.Context, ip, email string) []Query {
+	rec := &recordingResolver{}
+
+	dry := *c
+	dry.Resolver = rec
+	dry.Routes = nil
+	dry.Nameservers = nil
+	dry.Events = nil
+	dry.Source = dryRunSource(c.Source, rec)
+
+	dry.SPFTest(ctx, ip, email)
+
+	// The A and AAAA queries of a host are made concurrently, list them in
+	// a stable order.
+	queries := rec.queries
+	for i := 1; i < len(queries); i++ {
+		if queries[i-1].Type == "AAAA" && queries[i].Type == "A" && queries[i-1].Name == queries[i].Name {
+			queries[i-1], queries[i] = queries[i], queries[i-1]
+		}
+	}
+
+	return queries
+}
+
+// dryRunSource returns the Source of a dry run, recording the record fetches
+// and answering them only from a MapSource.
+func dryRunSource(source RecordSource, rec *recordingResolver) RecordSource {
+	switch s := source.(type) {
+	case nil:
+		return nil
+	case *SharedSource:
+		return dryRunSource(s.Source, rec)
+	case DNSSource:
+		return DNSSource{Resolver: rec, SenderID: s.SenderID}
+	case MapSource:
+		return RecordSourceFunc(func(ctx context.Context, domain string) (string, error) {
+			rec.add(domain, "TXT")
+			return s.Record(ctx, domain)
+		})
+	}
+
+	return DNSSource{Resolver: rec}
+}
+
+// recordingResolver records the queries made and answers all of them as not
+// found.
+type recordingResolver struct {
+	mu      sync.Mutex
+	queries []Query
+}
+
+func (r *recordingResolver) add(name string, rtypes ...string) error {
+	r.mu.Lock()
+	for _, rtype := range rtypes {
+		r.queries = append(r.queries, Query{Type: rtype, Name: canonicalName(name)})
+	}
+	r.mu.Unlock()
+
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *recordingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return nil, r.add(name, "TXT")
+}
+
+func (r *recordingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return nil, r.add(host, "A", "AAAA")
+}
+
+func (r *recordingResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return nil, r.add(name, "MX")
+}
+
+func (r *recordingResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	name, err := reverseName(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.add(name, "PTR")
+}
+
+func (r *recordingResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	switch network {
+	case "ip4":
+		return nil, r.add(host, "A")
+	case "ip6":
+		return nil, r.add(host, "AAAA")
+	}
+
+	return nil, r.add(host, "A", "AAAA")
+}
@@ -0,0 +1,80 @@
+package spf
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	c := &Checker{Source: MapSource{
+		"example.com":      "v=spf1 a mx/24 include:_spf.example.com ptr exists:%{i}.spf.example.com -all",
+		"_spf.example.com": "v=spf1 ip4:198.51.100.0/24 a:mail.example.net ~all",
+	}}
+
+	expected := []string{
+		"TXT example.com",
+		"A example.com",
+		"MX example.com",
+		"TXT _spf.example.com",
+		"A mail.example.net",
+		"PTR 1.2.0.192.in-addr.arpa",
+		"A 192.0.2.1.spf.example.com",
+	}
+
+	queries := c.DryRun(context.Background(), "192.0.2.1", "info@example.com")
+	if len(queries) != len(expected) {
+		t.Fatal("Expected", expected, "got", queries)
+	}
+
+	for i, q := range queries {
+		if q.String() != expected[i] {
+			t.Error("Expected", expected[i], "got", q)
+		}
+	}
+
+	queries = c.DryRun(context.Background(), "198.51.100.1", "info@example.com")
+	if len(queries) != 4 {
+		t.Error("Expected evaluation to stop at the matching ip4 got", queries)
+	}
+
+	queries = (&Checker{}).DryRun(context.Background(), "192.0.2.1", "info@example.com")
+	if len(queries) != 1 || queries[0] != (Query{"TXT", "example.com"}) {
+		t.Error("Expected a single TXT query got", queries)
+	}
+}
+
+func TestDryRunOffline(t *testing.T) {
+	// Lookups reaching the failing resolver would be real queries.
+	offline := failingResolver{NewZone()}
+
+	source := MapSource{
+		"example.com":           "v=spf1 include:_spf.internal.example a:mail.internal.example -all",
+		"_spf.internal.example": "v=spf1 ip4:198.51.100.0/24 -all",
+	}
+
+	checkers := []*Checker{
+		{Source: source, Routes: []Route{{Suffix: "internal.example", Resolver: offline}}, Nameservers: []string{"192.0.2.53"}},
+		{Source: &SharedSource{Source: source}, Routes: []Route{{Suffix: "internal.example", Resolver: offline}}},
+		{Source: &SharedSource{Source: DNSSource{}}},
+	}
+
+	expected := [][]string{
+		{"TXT example.com", "TXT _spf.internal.example", "A mail.internal.example"},
+		{"TXT example.com", "TXT _spf.internal.example", "A mail.internal.example"},
+		{"TXT example.com"},
+	}
+
+	for i, c := range checkers {
+		queries := c.DryRun(context.Background(), "192.0.2.1", "info@example.com")
+		if len(queries) != len(expected[i]) {
+			t.Error("Expected", expected[i], "got", queries)
+			continue
+		}
+
+		for j, q := range queries {
+			if q.String() != expected[i][j] {
+				t.Error("Expected", expected[i][j], "got", q)
+			}
+		}
+	}
+}
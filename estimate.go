@@ -0,0 +1,90 @@
+package spf
+
+import (
+	"context"
+)
+
+// Estimate is the number of DNS lookups a record requires, computed without
+// performing any query.
+type Estimate struct {
+	// Lookups is the number of terms counting towards the lookup limit,
+	// including the terms of the known included and redirected records.
+	Lookups int
+
+	// Unknown lists the included and redirected domains whose record was not
+	// provided or depends on macros. Each is counted as a single lookup, so
+	// Lookups is a lower bound when Unknown is not empty.
+	Unknown []string
+}
+
+// EstimateLookups estimates the DNS lookups required to evaluate the record
+// of the domain. The records of included and redirected domains are taken
+// from records when present. No network query is made, which makes it
+// suitable to check records before publishing them. NewSPF rejects records
+// whose lookups reach MaxCount.
+func EstimateLookups(domain, record string, records map[string]string) (Estimate, error) {
+	var est Estimate
+
+	e := estimator{
+		checker: &Checker{Source: MapSource(records)},
+		visited: make(map[string]bool),
+		est:     &est,
+	}
+
+	err := e.estimate(domain, record)
+
+	return est, err
+}
+
+// estimator holds the state of a single EstimateLookups call.
+type estimator struct {
+	checker *Checker
+	visited map[string]bool
+	est     *Estimate
+}
+
+// estimate adds the lookups of the record and of the known records it
+// includes or redirects to.
+func (e *estimator) estimate(domain, record string) error {
+	name := canonicalName(domain)
+	if e.visited[name] {
+		return ErrIncludeLoop
+	}
+	e.visited[name] = true
+	defer delete(e.visited, name)
+
+	spf, warnings, err := e.checker.parse(context.Background(), domain, record, 0, Lenient)
+	if err != nil {
+		return err
+	}
+
+	for _, w := range warnings {
+		if w.Err != ErrMaxCount {
+			return w.Err
+		}
+	}
+
+	for _, m := range spf.terms() {
+		e.est.Lookups += lookupCost(m)
+
+		if m.Name != "include" && m.Name != "redirect" {
+			continue
+		}
+
+		var included string
+		if !hasMacro(m.Domain) {
+			included, _ = e.checker.source().Record(context.Background(), m.Domain)
+		}
+
+		if included == "" {
+			e.est.Unknown = append(e.est.Unknown, m.Domain)
+			continue
+		}
+
+		if err := e.estimate(m.Domain, included); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
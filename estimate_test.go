@@ -0,0 +1,46 @@
+package spf
+
+import (
+	"testing"
+)
+
+func TestEstimateLookups(t *testing.T) {
+	records := map[string]string{
+		"_spf.example.com":   "v=spf1 a mx include:_spf2.example.com ~all",
+		"_spf2.example.com":  "v=spf1 ip4:192.0.2.0/24 exists:%{i}.example.com -all",
+		"loop.example.com":   "v=spf1 include:loop2.example.com -all",
+		"loop2.example.com":  "v=spf1 include:loop.example.com -all",
+		"broken.example.com": "v=spf1 foo:bar -all",
+	}
+
+	tests := []struct {
+		record  string
+		lookups int
+		unknown int
+		err     error
+	}{
+		{"v=spf1 ip4:192.0.2.1 -all", 0, 0, nil},
+		{"v=spf1 include:_spf.example.com -all", 5, 0, nil},
+		{"v=spf1 include:_spf.example.com include:vendor.example redirect=%{d}.example.net", 7, 2, nil},
+		{"v=spf1 include:loop.example.com -all", 0, 0, ErrIncludeLoop},
+		{"v=spf1 include:broken.example.com -all", 0, 0, ErrUnknownMechanism},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.record)
+
+		est, err := EstimateLookups("example.com", test.record, records)
+		if err != test.err {
+			t.Error("Expected", test.err, "got", err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if est.Lookups != test.lookups || len(est.Unknown) != test.unknown {
+			t.Error("Expected", test.lookups, test.unknown, "got", est.Lookups, est.Unknown)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package spf
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// evaluation holds the state of a single check_host evaluation, shared by
+// the record it starts with and every included or redirected record.
+type evaluation struct {
+	ctx     context.Context
+	checker *Checker
+	ip      string
+	domain  string
+	sender  string
+	helo    string
+
+	// receiver is the domain of the receiving MTA, the target of the %{r}
+	// macro of explanations.
+	receiver string
+
+	// addr and parsedIP hold the client IP parsed once for all mechanisms,
+	// addr with IPv4-mapped addresses unmapped.
+	addr     netip.Addr
+	parsedIP net.IP
+
+	// current is the domain of the record being evaluated, the target of
+	// the %{d} macro.
+	current string
+
+	// visited holds the domains on the current chain of includes and
+	// redirects, used to detect loops.
+	visited map[string]bool
+
+	// err records why the evaluation resulted in an error result.
+	err error
+
+	// depth is the number of nested records being evaluated and matched
+	// the mechanism of the top-level record that matched.
+	depth   int
+	matched *Mechanism
+
+	// failed is the record whose mechanism last matched with Fail, whose
+	// exp modifier explains a Fail result.
+	failed *SPF
+
+	// noMatch is set while the result is the default Neutral of a record
+	// none of whose mechanisms matched.
+	noMatch bool
+
+	// budget counts the DNS queries made by the terms.
+	budget Budget
+
+	// timedOut is set once the context deadline was found exceeded.
+	timedOut bool
+
+	// tracing enables recording of the evaluation, starting at root. term
+	// is the mechanism currently being traced.
+	tracing bool
+	root    *Trace
+	term    *TraceTerm
+}
+
+// newEvaluation starts the evaluation of a check for the client address,
+// parsed once by the entry points with parseIP.
+func newEvaluation(ctx context.Context, c *Checker, addr netip.Addr) *evaluation {
+	if c == nil {
+		c = DefaultChecker
+	}
+
+	e := &evaluation{
+		ctx:     ctx,
+		checker: c,
+		ip:      addr.Unmap().String(),
+		addr:    addr.Unmap(),
+		visited: make(map[string]bool),
+	}
+
+	// Addresses with a zone never match, see ipMechanismContains.
+	if addr.Zone() == "" {
+		e.parsedIP = net.IP(e.addr.AsSlice())
+	}
+
+	return e
+}
+
+// parseIP parses the address of a client given as text, e.g. "192.0.2.1" or
+// "2001:db8::1".
+func parseIP(ip string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return addr, ErrInvalidIP
+	}
+
+	return addr, nil
+}
+
+// enter marks the domain as being evaluated. It returns false if the domain
+// is already on the chain of includes and redirects.
+func (e *evaluation) enter(domain string) bool {
+	domain = canonicalName(domain)
+	if e.visited[domain] {
+		return false
+	}
+	e.visited[domain] = true
+
+	return true
+}
+
+// leave removes the domain from the chain of includes and redirects.
+func (e *evaluation) leave(domain string) {
+	delete(e.visited, canonicalName(domain))
+}
+
+// fail records err as the cause of the evaluation result r and returns r.
+// Only the first cause is kept.
+func (e *evaluation) fail(r Result, err error) Result {
+	if e.err == nil {
+		e.err = err
+	}
+
+	return r
+}
+
+// expired reports whether the context is done or its deadline is closer than
+// the Checker's DeadlineMargin.
+func (e *evaluation) expired() bool {
+	if e.timedOut {
+		return true
+	}
+
+	if e.ctx.Err() != nil {
+		e.timedOut = true
+	} else if deadline, ok := e.ctx.Deadline(); ok && time.Until(deadline) < e.checker.DeadlineMargin {
+		e.timedOut = true
+	}
+
+	return e.timedOut
+}
+
+// families reports whether the A and AAAA records of the hosts of a and mx
+// mechanisms are looked up, only those of the family of the client unless
+// the Checker's DualLookups is set.
+func (e *evaluation) families() (a, aaaa bool) {
+	if e.checker.DualLookups || !e.addr.IsValid() {
+		return true, true
+	}
+
+	return e.addr.Is4(), e.addr.Is6()
+}
+
+// queries returns the number of address queries made for a host.
+func (e *evaluation) queries() int {
+	if a, aaaa := e.families(); a && aaaa {
+		return 2
+	}
+
+	return 1
+}
+
+// lookupError returns the result of a mechanism whose DNS lookup failed, a
+// TempError for the whole check as RFC 7208 section 5 requires. When the
+// deadline was exceeded the mechanism does not match instead, leaving the
+// timeout to be reported by SPF.evaluate.
+func (e *evaluation) lookupError() (Result, error) {
+	if e.expired() {
+		return None, ErrNoMatch
+	}
+
+	return e.fail(TempError, ErrFailedLookup), nil
+}
+
+// macros returns the values macros expand to at this point of the
+// evaluation.
+func (e *evaluation) macros() macroContext {
+	return macroContext{sender: e.sender, domain: e.current, ip: e.parsedIP, helo: e.helo}
+}
+
+// explanation returns the explanation of a Fail result, the expansion of the
+// single TXT record at the domain of the exp modifier of the failed record,
+// RFC 7208 section 6.2. Any error results in no explanation.
+func (e *evaluation) explanation() string {
+	if e.failed == nil || e.failed.Exp() == "" {
+		return ""
+	}
+
+	mc := e.macros()
+	mc.domain = e.failed.Domain
+
+	domain, err := mc.expand(e.failed.Exp())
+	if err != nil {
+		return ""
+	}
+
+	txts, err := e.checker.resolver().LookupTXT(e.ctx, domain)
+	if err != nil || len(txts) != 1 {
+		return ""
+	}
+
+	mc.explain, mc.receiver = true, e.receiver
+	text, err := mc.expandString(txts[0])
+	if err != nil {
+		return ""
+	}
+
+	return text
+}
+
+// Budget breaks down the DNS queries made by the terms of an evaluation,
+// including those of included and redirected records, by kind of term. An
+// address lookup counts as two queries, A and AAAA, when both families are
+// resolved, see Checker.DualLookups. The query fetching the record of the
+// evaluated domain itself is not counted.
+type Budget struct {
+	A        int `json:"a"`        // a mechanisms
+	MX       int `json:"mx"`       // MX queries of mx mechanisms
+	MXHosts  int `json:"mx_hosts"` // address lookups of the hosts returned for mx mechanisms
+	PTR      int `json:"ptr"`      // ptr mechanisms
+	Exists   int `json:"exists"`   // exists mechanisms
+	Include  int `json:"include"`  // record fetches of include mechanisms
+	Redirect int `json:"redirect"` // record fetches of redirect modifiers
+}
+
+// Total returns the number of queries.
+func (b Budget) Total() int {
+	return b.A + b.MX + b.MXHosts + b.PTR + b.Exists + b.Include + b.Redirect
+}
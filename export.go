@@ -0,0 +1,137 @@
+package spf
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// ExportFormat selects the output format of Export.
+type ExportFormat string
+
+const (
+	Nftables ExportFormat = "nftables"
+	Ipset    ExportFormat = "ipset"
+	HAProxy  ExportFormat = "haproxy"
+)
+
+var (
+	ErrUnknownFormat = errors.New("Unknown export format.")
+)
+
+// Export writes the networks authorized by the SPF record of the domain to w
+// in the given format using the DefaultChecker. See Checker.Export.
+func Export(ctx context.Context, domain string, format ExportFormat, name string, w io.Writer) error {
+	return DefaultChecker.Export(ctx, domain, format, name, w)
+}
+
+// Export writes the networks authorized by the SPF record of the domain to w
+// in the given format. The name is used for the generated sets. When name is
+// empty it is derived from the domain, e.g. "spf_example_com".
+func (c *Checker) Export(ctx context.Context, domain string, format ExportFormat, name string, w io.Writer) error {
+	prefixes, err := c.Networks(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		name = "spf_" + strings.NewReplacer(".", "_", "-", "_").Replace(domain)
+	}
+
+	switch format {
+	case Nftables:
+		return WriteNftables(w, name, prefixes)
+	case Ipset:
+		return WriteIpset(w, name, prefixes)
+	case HAProxy:
+		return WriteHAProxyACL(w, prefixes)
+	}
+
+	return ErrUnknownFormat
+}
+
+// WriteNftables writes nftables set definitions named <name>_v4 and
+// <name>_v6 holding the prefixes, suitable for inclusion in a table block.
+// Sets without elements are omitted.
+func WriteNftables(w io.Writer, name string, prefixes []netip.Prefix) error {
+	bw := bufio.NewWriter(w)
+	v4, v6 := splitFamilies(prefixes)
+
+	sets := []struct {
+		suffix   string
+		kind     string
+		prefixes []netip.Prefix
+	}{
+		{"_v4", "ipv4_addr", v4},
+		{"_v6", "ipv6_addr", v6},
+	}
+
+	for _, set := range sets {
+		if len(set.prefixes) == 0 {
+			continue
+		}
+
+		elements := make([]string, len(set.prefixes))
+		for i, p := range set.prefixes {
+			elements[i] = p.String()
+		}
+
+		fmt.Fprintf(bw, "set %s%s {\n", name, set.suffix)
+		fmt.Fprintf(bw, "\ttype %s\n", set.kind)
+		fmt.Fprintf(bw, "\tflags interval\n")
+		fmt.Fprintf(bw, "\telements = { %s }\n", strings.Join(elements, ", "))
+		fmt.Fprintf(bw, "}\n")
+	}
+
+	return bw.Flush()
+}
+
+// WriteIpset writes an ipset restore file creating the hash:net sets
+// <name>_v4 and <name>_v6 and adding the prefixes to them.
+func WriteIpset(w io.Writer, name string, prefixes []netip.Prefix) error {
+	bw := bufio.NewWriter(w)
+	v4, v6 := splitFamilies(prefixes)
+
+	fmt.Fprintf(bw, "create %s_v4 hash:net family inet -exist\n", name)
+	for _, p := range v4 {
+		fmt.Fprintf(bw, "add %s_v4 %s -exist\n", name, p)
+	}
+
+	fmt.Fprintf(bw, "create %s_v6 hash:net family inet6 -exist\n", name)
+	for _, p := range v6 {
+		fmt.Fprintf(bw, "add %s_v6 %s -exist\n", name, p)
+	}
+
+	return bw.Flush()
+}
+
+// WriteHAProxyACL writes the prefixes one per line, the format expected by
+// HAProxy ACL files such as "acl spf_senders src -f /etc/haproxy/spf.acl".
+func WriteHAProxyACL(w io.Writer, prefixes []netip.Prefix) error {
+	bw := bufio.NewWriter(w)
+
+	for _, p := range prefixes {
+		fmt.Fprintln(bw, p)
+	}
+
+	return bw.Flush()
+}
+
+// splitFamilies separates IPv4 from IPv6 prefixes.
+func splitFamilies(prefixes []netip.Prefix) ([]netip.Prefix, []netip.Prefix) {
+	var v4, v6 []netip.Prefix
+
+	for _, p := range prefixes {
+		if p.Addr().Is4() {
+			v4 = append(v4, p)
+		} else {
+			v6 = append(v6, p)
+		}
+	}
+
+	return v4, v6
+}
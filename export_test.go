@@ -0,0 +1,43 @@
+package spf
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+type exporttest struct {
+	format   ExportFormat
+	expected string
+}
+
+func TestExport(t *testing.T) {
+	c := &Checker{Source: MapSource{
+		"example.com": "v=spf1 ip4:192.0.2.0/25 ip4:192.0.2.128/25 ip6:2001:db8::/32 -all",
+	}}
+
+	tests := []exporttest{
+		exporttest{Nftables, "set spf_example_com_v4 {\n\ttype ipv4_addr\n\tflags interval\n\telements = { 192.0.2.0/24 }\n}\n" +
+			"set spf_example_com_v6 {\n\ttype ipv6_addr\n\tflags interval\n\telements = { 2001:db8::/32 }\n}\n"},
+		exporttest{Ipset, "create spf_example_com_v4 hash:net family inet -exist\nadd spf_example_com_v4 192.0.2.0/24 -exist\n" +
+			"create spf_example_com_v6 hash:net family inet6 -exist\nadd spf_example_com_v6 2001:db8::/32 -exist\n"},
+		exporttest{HAProxy, "192.0.2.0/24\n2001:db8::/32\n"},
+	}
+
+	for _, tcase := range tests {
+		var buf bytes.Buffer
+
+		if err := c.Export(context.Background(), "example.com", tcase.format, "", &buf); err != nil {
+			t.Error(err)
+		}
+
+		if buf.String() != tcase.expected {
+			t.Error("For", tcase.format, "Expected", tcase.expected, "got", buf.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := c.Export(context.Background(), "example.com", "csv", "", &buf); err != ErrUnknownFormat {
+		t.Error("Expected", ErrUnknownFormat, "got", err)
+	}
+}
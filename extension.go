@@ -0,0 +1,77 @@
+package spf
+
+import (
+	"context"
+)
+
+// Extension evaluates a mechanism or modifier that RFC 7208 does not define,
+// e.g. an extension private to a mail system, registered with
+// Checker.Extensions. The event describes the term, with its macros
+// expanded, and the client. Result is preset to the qualifier of the term,
+// Pass for a modifier; when the extension reports a match it is the result
+// of the record and the extension may change it. An error results in a
+// TempError recording it.
+type Extension func(ctx context.Context, ev *MechanismEvent) (bool, error)
+
+// extension returns the Extension registered for the name, which must not be
+// defined by RFC 7208.
+func (c *Checker) extension(name string) (Extension, bool) {
+	probe := Mechanism{Name: name}
+	if !probe.Unknown() || isModifier(name) {
+		return nil, false
+	}
+
+	ext, ok := c.Extensions[name]
+
+	return ext, ok && ext != nil
+}
+
+// evaluateExtension evaluates the term m with the extension.
+func (e *evaluation) evaluateExtension(ext Extension, m *Mechanism) (Result, error) {
+	ev := &MechanismEvent{
+		Mechanism: *m,
+		Domain:    e.current,
+		Depth:     e.depth,
+		Addr:      e.addr,
+		Sender:    e.sender,
+		Helo:      e.helo,
+		Result:    m.Result,
+	}
+
+	matched, err := ext(e.ctx, ev)
+	if err != nil {
+		return e.fail(TempError, err), nil
+	}
+
+	if !matched {
+		return None, ErrNoMatch
+	}
+
+	return ev.Result, nil
+}
+
+// extensionTerms returns the terms of the record to evaluate: the
+// mechanisms, then the modifiers with a registered Extension, as mechanisms
+// with their value as domain, then the redirect.
+func (s *SPF) extensionTerms(c *Checker) []Mechanism {
+	terms := s.terms()
+	if len(c.Extensions) == 0 {
+		return terms
+	}
+
+	var modifiers []Mechanism
+	for _, mod := range s.Modifiers {
+		if _, ok := c.extension(mod.Name); ok {
+			modifiers = append(modifiers, Mechanism{Name: mod.Name, Domain: mod.Value, Result: Pass})
+		}
+	}
+
+	if len(modifiers) == 0 {
+		return terms
+	}
+
+	n := len(s.Mechanisms)
+	extended := append(append(append([]Mechanism(nil), terms[:n]...), modifiers...), terms[n:]...)
+
+	return extended
+}
@@ -0,0 +1,84 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExtensions(t *testing.T) {
+	source := MapSource{
+		"example.com":  "v=spf1 -x-trusted:%{i}.trust.example.com ~x-down x-default=neutral",
+		"example.net":  "v=spf1 x-unknown -all",
+		"example.org":  "v=spf1 x-default=pass -all",
+		"example.info": "v=spf1 ip4:192.0.2.0/24 -all",
+	}
+
+	c := &Checker{Source: source}
+	c.Extensions = map[string]Extension{
+		"x-trusted": func(ctx context.Context, ev *MechanismEvent) (bool, error) {
+			return ev.Mechanism.Domain == "192.0.2.1.trust.example.com", nil
+		},
+		"x-down": func(ctx context.Context, ev *MechanismEvent) (bool, error) {
+			if ev.Addr.String() == "192.0.2.2" {
+				return false, errors.New("Reputation service unavailable.")
+			}
+			return false, nil
+		},
+		"x-default": func(ctx context.Context, ev *MechanismEvent) (bool, error) {
+			switch strings.ToLower(ev.Mechanism.Domain) {
+			case "neutral":
+				ev.Result = Neutral
+			case "pass":
+				ev.Result = Pass
+			default:
+				return false, nil
+			}
+			return true, nil
+		},
+		// RFC 7208 names cannot be overridden.
+		"ip4": func(ctx context.Context, ev *MechanismEvent) (bool, error) {
+			return true, nil
+		},
+	}
+
+	tests := []struct {
+		domain string
+		ip     string
+		result Result
+	}{
+		{"example.com", "192.0.2.1", Fail},
+		{"example.com", "192.0.2.2", TempError},
+		{"example.com", "192.0.2.3", Neutral},
+		{"example.net", "192.0.2.1", PermError},
+		{"example.org", "192.0.2.1", Fail},
+		{"example.info", "198.51.100.1", Fail},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.domain, test.ip)
+
+		result, _ := c.SPFTest(context.Background(), test.ip, "info@"+test.domain)
+		if result != test.result {
+			t.Error("Expected", test.result, "got", result)
+		}
+	}
+}
+
+func TestExtensionsLenient(t *testing.T) {
+	c := &Checker{Extensions: map[string]Extension{
+		"x-trusted": func(ctx context.Context, ev *MechanismEvent) (bool, error) {
+			return true, nil
+		},
+	}}
+
+	s, warnings, err := c.NewSPFLenient(context.Background(), "example.com", "v=spf1 x-trusted x-other -all", 0)
+	if err != nil {
+		t.Fatal("Expected", nil, "got", err)
+	}
+
+	if len(s.Mechanisms) != 2 || s.Mechanisms[0].Name != "x-trusted" || len(warnings) != 1 {
+		t.Error("Expected", "x-trusted and a warning", "got", s.Mechanisms, warnings)
+	}
+}
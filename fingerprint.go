@@ -0,0 +1,48 @@
+package spf
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Fingerprint returns a stable hash of the normalized record, in hex. Records
+// differing only in whitespace, the case of names, redundant "+" qualifiers
+// or the spelling of ip6 addresses share a fingerprint.
+func (s *SPF) Fingerprint() string {
+	norm := *s
+	norm.Mechanisms = append([]Mechanism(nil), s.Mechanisms...)
+	for i := range norm.Mechanisms {
+		norm.Mechanisms[i].plus = false
+	}
+
+	return fingerprint(norm.SPFString())
+}
+
+// Fingerprint returns a stable hash of the resolved networks and remaining
+// terms of the flattened record, in hex. Unlike the fingerprint of the
+// published record, it changes whenever a lookup resolves differently, e.g.
+// when an included provider adds a network. It ignores the order of
+// consecutive terms sharing a qualifier, which never changes the results,
+// but not the order of the others.
+func (f *Flattened) Fingerprint() string {
+	return fingerprint(strings.Join(termStrings(f.Mechanisms), " "))
+}
+
+// ResolvedFingerprint flattens the record of the domain and returns its
+// Fingerprint.
+func (c *Checker) ResolvedFingerprint(ctx context.Context, domain string) (string, error) {
+	flat, err := c.Flatten(ctx, domain)
+	if err != nil {
+		return "", err
+	}
+
+	return flat.Fingerprint(), nil
+}
+
+func fingerprint(text string) string {
+	sum := sha256.Sum256([]byte(text))
+
+	return hex.EncodeToString(sum[:])
+}
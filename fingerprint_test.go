@@ -0,0 +1,97 @@
+package spf
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	base, err := NewSPF("example.com", "v=spf1 mx ip6:2001:db8::/32 -all", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		record string
+		same   bool
+	}{
+		{"v=spf1  MX +ip6:2001:DB8:0::/32 -all", true},
+		{"v=spf1 mx ip6:2001:db8::/32 -all", true},
+		{"v=spf1 mx ip6:2001:db8::/32 ~all", false},
+		{"v=spf1 ip6:2001:db8::/32 mx -all", false},
+		{"v=spf1 mx:example.net ip6:2001:db8::/32 -all", false},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.record)
+
+		s, err := NewSPF("example.com", test.record, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if same := s.Fingerprint() == base.Fingerprint(); same != test.same {
+			t.Error("Expected", test.same, "got", same)
+		}
+	}
+}
+
+func TestFlattenedFingerprint(t *testing.T) {
+	records := []string{
+		"v=spf1 -ip4:192.0.2.1 ip4:192.0.2.0/24 ip6:2001:db8::/32 ~all",
+		"v=spf1 -ip4:192.0.2.1 ip6:2001:db8::/32 ip4:192.0.2.0/24 ~all",
+		"v=spf1 ip4:192.0.2.0/24 -ip4:192.0.2.1 ip6:2001:db8::/32 ~all",
+	}
+
+	var fingerprints []string
+	for _, record := range records {
+		var flat Flattened
+		s, err := NewSPF("example.com", record, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		flat.SPF = s
+
+		fingerprints = append(fingerprints, flat.Fingerprint())
+	}
+
+	if fingerprints[0] != fingerprints[1] {
+		t.Error("Expected the same fingerprint for reordered Pass terms")
+	}
+
+	if fingerprints[0] == fingerprints[2] {
+		t.Error("Expected another fingerprint for a Fail term moved after a Pass term")
+	}
+}
+
+func TestResolvedFingerprint(t *testing.T) {
+	source := MapSource{
+		"example.com":      "v=spf1 include:_spf.esp.example -all",
+		"_spf.esp.example": "v=spf1 ip4:192.0.2.0/24 -all",
+	}
+
+	c := &Checker{Source: source}
+	ctx := context.Background()
+
+	before, err := c.ResolvedFingerprint(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, _ := c.ResolvedFingerprint(ctx, "example.com")
+	if again != before {
+		t.Error("Expected", before, "got", again)
+	}
+
+	// The published record is unchanged, but the included one is not.
+	source["_spf.esp.example"] = "v=spf1 ip4:198.51.100.0/24 -all"
+
+	after, err := c.ResolvedFingerprint(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if after == before {
+		t.Error("Expected the fingerprint to change")
+	}
+}
@@ -0,0 +1,463 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotFlattenable = errors.New("Record cannot be flattened without changing its results.")
+)
+
+const (
+	// DefaultTTL is assumed for resolved data when the Resolver cannot
+	// report the TTL of its answers.
+	DefaultTTL = time.Hour
+)
+
+// TTLResolver is implemented by Resolvers that can report the TTL of the
+// records they return. LookupTTL returns the lowest TTL of the records of
+// type rtype (e.g. "TXT", "A", "MX") published for name.
+type TTLResolver interface {
+	LookupTTL(ctx context.Context, name, rtype string) (time.Duration, error)
+}
+
+// Flattened is an SPF record in which the include, redirect, a and mx terms
+// have been replaced by the ip4 and ip6 networks they resolved to. Terms that
+// cannot be resolved ahead of time, like ptr and exists, are kept as is, and
+// records where that would change the results are not flattened.
+type Flattened struct {
+	SPF
+
+	// TTL is the lowest TTL of all DNS data used to build the record.
+	TTL time.Duration
+
+	// RefreshAfter is the time after which the flattened record may no
+	// longer reflect the published DNS data.
+	RefreshAfter time.Time
+
+	// Lookups is the number of DNS lookups evaluating the published,
+	// unflattened record requires.
+	Lookups int
+}
+
+// Flatten resolves the SPF record of the domain into a Flattened record using
+// the DefaultChecker.
+func Flatten(ctx context.Context, domain string) (*Flattened, error) {
+	return DefaultChecker.Flatten(ctx, domain)
+}
+
+// Flatten resolves the SPF record of the domain into a Flattened record.
+func (c *Checker) Flatten(ctx context.Context, domain string) (*Flattened, error) {
+	f := flattener{ctx: ctx, checker: c, visited: make(map[string]bool)}
+
+	spf, err := f.record(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.result(spf)
+}
+
+// result flattens the top level record.
+func (f *flattener) result(spf SPF) (*Flattened, error) {
+	c := f.checker
+
+	mechanisms, err := f.flatten(spf, Pass, true)
+	if err != nil {
+		return nil, err
+	}
+
+	flat := &Flattened{TTL: f.ttl, Lookups: f.lookups}
+	flat.Domain = spf.Domain
+	flat.Version = spf.Version
+	flat.Mechanisms = mechanisms
+	flat.checker = c
+	flat.Raw = flat.SPFString()
+	flat.RefreshAfter = time.Now().Add(f.ttl)
+
+	for _, m := range mechanisms {
+		switch m.Name {
+		case "ptr", "exists":
+			flat.Count++
+		}
+	}
+
+	return flat, nil
+}
+
+// Stale reports whether the flattened record is past its RefreshAfter time.
+func (f *Flattened) Stale() bool {
+	return time.Now().After(f.RefreshAfter)
+}
+
+// Refresh flattens the record of the domain again, updating the mechanisms,
+// TTL and RefreshAfter time. On error the record is left unchanged.
+func (f *Flattened) Refresh(ctx context.Context) error {
+	c := f.checker
+	if c == nil {
+		c = DefaultChecker
+	}
+
+	flat, err := c.Flatten(ctx, f.Domain)
+	if err != nil {
+		return err
+	}
+
+	*f = *flat
+
+	return nil
+}
+
+// flattener holds the state of a single flattening run.
+type flattener struct {
+	ctx     context.Context
+	checker *Checker
+	visited map[string]bool
+	ttl     time.Duration
+
+	// lookups counts the terms requiring a DNS lookup in every record
+	// visited.
+	lookups int
+
+	// chain holds the include and redirect terms followed to reach the
+	// record being flattened.
+	chain []string
+
+	// union resolves nested records to the union of the networks of their
+	// Pass terms, skipping the other terms, instead of their exact Pass
+	// set. It over-approximates the authorized networks for reports, which
+	// never fail on records that cannot be flattened exactly.
+	union bool
+}
+
+// observe lowers the tracked TTL to that of the given DNS data.
+func (f *flattener) observe(name, rtype string) {
+	ttl := DefaultTTL
+
+	if r, ok := f.checker.resolver().(TTLResolver); ok {
+		if t, err := r.LookupTTL(f.ctx, name, rtype); err == nil {
+			ttl = t
+		}
+	}
+
+	if f.ttl == 0 || ttl < f.ttl {
+		f.ttl = ttl
+	}
+}
+
+// record fetches and parses the SPF record of the domain.
+func (f *flattener) record(domain string) (SPF, error) {
+	if f.visited[domain] {
+		return SPF{}, ErrIncludeLoop
+	}
+
+	spf, err := f.checker.NewSPF(f.ctx, domain, "", 0)
+	f.observe(domain, "TXT")
+
+	return spf, err
+}
+
+// flatten returns the resolved mechanisms of spf. The terms of the top level
+// record, and of the records it redirects to, keep their order and
+// qualifiers. A nested record only matches on Pass, so it is replaced by the
+// terms of its Pass set, see passTerms, qualified with q.
+func (f *flattener) flatten(spf SPF, q Result, top bool) ([]Mechanism, error) {
+	if !top {
+		mechanisms, err := f.passTerms(spf, &exclusions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range mechanisms {
+			mechanisms[i].Result = q
+		}
+
+		return dedupeMechanisms(mechanisms), nil
+	}
+
+	var mechanisms []Mechanism
+	var hasAll bool
+
+	f.visited[spf.Domain] = true
+	defer delete(f.visited, spf.Domain)
+
+	for _, m := range spf.Mechanisms {
+		switch m.Name {
+		case "include", "exists", "a", "mx", "ptr":
+			f.lookups++
+		}
+
+		origin := f.origin(m, spf.Domain)
+
+		switch m.Name {
+		case "all":
+			hasAll = true
+			m.origin = origin
+			mechanisms = append(mechanisms, m)
+		case "include":
+			inc, err := f.record(m.Domain)
+			if err != nil {
+				return nil, err
+			}
+
+			f.chain = append(f.chain, m.SPFString())
+			nested, err := f.flatten(inc, m.Result, false)
+			f.chain = f.chain[:len(f.chain)-1]
+			if err != nil {
+				return nil, err
+			}
+
+			mechanisms = append(mechanisms, nested...)
+		case "a", "mx":
+			networks, err := f.networks(m)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, n := range networkMechanisms(networks, m.Result) {
+				n.origin = origin
+				mechanisms = append(mechanisms, n)
+			}
+		default:
+			m.origin = origin
+			mechanisms = append(mechanisms, m)
+		}
+
+		// Terms after all are never reached.
+		if hasAll {
+			break
+		}
+	}
+
+	// A redirect is only followed when the record has no all mechanism.
+	if redirect := spf.Redirect(); redirect != "" {
+		f.lookups++
+
+		if !hasAll {
+			target, err := f.record(redirect)
+			if err != nil {
+				return nil, err
+			}
+
+			f.chain = append(f.chain, "redirect="+redirect)
+			nested, err := f.flatten(target, q, top)
+			f.chain = f.chain[:len(f.chain)-1]
+			if err != nil {
+				return nil, err
+			}
+
+			mechanisms = append(mechanisms, nested...)
+		}
+	}
+
+	return dedupeMechanisms(mechanisms), nil
+}
+
+// exclusions are the addresses an earlier term of a nested record matched
+// with another result than Pass, leaving them out of its Pass set. opaque is
+// set once such a term, e.g. -exists, resolves to no networks.
+type exclusions struct {
+	prefixes []netip.Prefix
+	opaque   bool
+}
+
+// passTerms returns the terms matching the addresses for which spf, and the
+// records it redirects to, evaluate to Pass, qualified with Pass. The
+// networks of a term are those not excluded by earlier terms, and an all
+// mechanism covers every network left. Terms after all are never reached.
+// With union set, the terms are kept whole and exclusions are ignored.
+// ErrNotFlattenable is returned when a term resolving to no networks, like
+// ptr or exists, would be reached by excluded addresses or exclude
+// addresses itself before a Pass term.
+func (f *flattener) passTerms(spf SPF, ex *exclusions) ([]Mechanism, error) {
+	var mechanisms []Mechanism
+	var hasAll bool
+
+	f.visited[spf.Domain] = true
+	defer delete(f.visited, spf.Domain)
+
+	for _, m := range spf.Mechanisms {
+		switch m.Name {
+		case "include", "exists", "a", "mx", "ptr":
+			f.lookups++
+		}
+
+		origin := f.origin(m, spf.Domain)
+
+		var terms []Mechanism
+		switch m.Name {
+		case "all":
+			hasAll = true
+			terms = []Mechanism{
+				{Name: "ip4", Domain: "0.0.0.0", Prefix: "0"},
+				{Name: "ip6", Domain: "::", Prefix: "0"},
+			}
+		case "include":
+			inc, err := f.record(m.Domain)
+			if err != nil {
+				return nil, err
+			}
+
+			f.chain = append(f.chain, m.SPFString())
+			nested, err := f.passTerms(inc, &exclusions{})
+			f.chain = f.chain[:len(f.chain)-1]
+			if err != nil {
+				return nil, err
+			}
+
+			terms = nested
+		case "a", "mx":
+			networks, err := f.networks(m)
+			if err != nil {
+				return nil, err
+			}
+
+			terms = networkMechanisms(networks, Pass)
+		default:
+			terms = []Mechanism{m}
+		}
+
+		for _, t := range terms {
+			if t.origin == nil {
+				t.origin = origin
+			}
+
+			p, ok := mechanismPrefix(t)
+
+			switch {
+			case f.union:
+				if m.Result == Pass {
+					t.Result = Pass
+					mechanisms = append(mechanisms, t)
+				}
+			case m.Result != Pass && ok:
+				ex.prefixes = append(ex.prefixes, p)
+			case m.Result != Pass:
+				ex.opaque = true
+			case ex.opaque || !ok && len(ex.prefixes) > 0:
+				return nil, ErrNotFlattenable
+			case !ok:
+				t.Result = Pass
+				mechanisms = append(mechanisms, t)
+			default:
+				for _, rest := range subtractPrefixes(p, ex.prefixes) {
+					n := prefixMechanism(rest, Pass)
+					n.origin = t.origin
+					mechanisms = append(mechanisms, n)
+				}
+			}
+		}
+
+		if hasAll {
+			break
+		}
+	}
+
+	// A redirect is only followed when the record has no all mechanism.
+	if redirect := spf.Redirect(); redirect != "" {
+		f.lookups++
+
+		if !hasAll {
+			target, err := f.record(redirect)
+			if err != nil {
+				return nil, err
+			}
+
+			f.chain = append(f.chain, "redirect="+redirect)
+			nested, err := f.passTerms(target, ex)
+			f.chain = f.chain[:len(f.chain)-1]
+			if err != nil {
+				return nil, err
+			}
+
+			mechanisms = append(mechanisms, nested...)
+		}
+	}
+
+	return mechanisms, nil
+}
+
+// origin returns the Origin of the terms the mechanism m of the record of
+// domain flattens to.
+func (f *flattener) origin(m Mechanism, domain string) *Origin {
+	return &Origin{Mechanism: m.SPFString(), Domain: domain, Chain: append([]string(nil), f.chain...)}
+}
+
+// networks resolves the networks covered by an a or mx mechanism. Lookup
+// errors are returned so a partially resolved record is never produced.
+func (f *flattener) networks(m Mechanism) ([]*net.IPNet, error) {
+	r := f.checker.resolver()
+
+	hosts := []string{m.Domain}
+	if m.Name == "mx" {
+		mxs, err := r.LookupMX(f.ctx, m.Domain)
+		if err != nil && !isNotFound(err) {
+			return nil, ErrFailedLookup
+		}
+		f.observe(m.Domain, "MX")
+
+		if len(mxs) > f.checker.mxLimit() {
+			return nil, ErrTooManyMX
+		}
+
+		hosts = hosts[:0]
+		for _, mx := range mxs {
+			hosts = append(hosts, mx.Host)
+		}
+	}
+
+	var networks []*net.IPNet
+	for _, host := range hosts {
+		var err error
+		networks, _, _, err = hostNetworks(f.ctx, r, host, m.Prefix, true, true, networks)
+		if err != nil {
+			return nil, err
+		}
+		f.observe(host, "A")
+		f.observe(host, "AAAA")
+	}
+
+	return networks, nil
+}
+
+// networkMechanisms returns an ip4 or ip6 mechanism for each network.
+func networkMechanisms(networks []*net.IPNet, r Result) []Mechanism {
+	var mechanisms []Mechanism
+
+	for _, network := range networks {
+		m := Mechanism{Name: "ip6", Result: r, Domain: network.IP.String()}
+		if network.IP.To4() != nil {
+			m.Name = "ip4"
+		}
+
+		ones, bits := network.Mask.Size()
+		if ones != bits {
+			m.Prefix = strconv.Itoa(ones)
+		}
+
+		mechanisms = append(mechanisms, m)
+	}
+
+	return mechanisms
+}
+
+// dedupeMechanisms removes repeated mechanisms, keeping the first occurrence.
+func dedupeMechanisms(mechanisms []Mechanism) []Mechanism {
+	var unique []Mechanism
+	seen := make(map[string]bool)
+
+	for _, m := range mechanisms {
+		key := m.SPFString()
+		if m.Name == "all" || !seen[key] {
+			seen[key] = true
+			unique = append(unique, m)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,300 @@
+package spf
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const flattenZone = `
+$ORIGIN example.org.
+$TTL 3600
+@          TXT  "v=spf1 mx include:_spf.vendor.example -ip4:192.0.2.66 redirect=_rest.example.org"
+@          MX   10 mail
+mail   300 A    192.0.2.25
+mail       AAAA 2001:db8::25
+_rest      TXT  "v=spf1 a:relay.example.org/28 ptr ~all"
+relay      A    192.0.2.130
+_spf.vendor.example.  120 TXT "v=spf1 ip4:198.51.100.0/24 -ip4:203.0.113.1 include:_net.vendor.example -all"
+_net.vendor.example.      TXT "v=spf1 ip6:2001:db8:1::/48 ip4:198.51.100.0/24 ?all"
+loop.example.             TXT "v=spf1 include:loop2.example -all"
+loop2.example.            TXT "v=spf1 include:loop.example -all"
+`
+
+func TestFlatten(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(flattenZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Resolver: z}
+
+	before := time.Now()
+	flat, err := c.Flatten(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "v=spf1 ip4:192.0.2.25 ip6:2001:db8::25 ip4:198.51.100.0/24 ip6:2001:db8:1::/48 -ip4:192.0.2.66 ip4:192.0.2.128/28 ptr:_rest.example.org ~all"
+	if flat.SPFString() != expected {
+		t.Error("Expected", expected, "got", flat.SPFString())
+	}
+
+	if flat.TTL != 120*time.Second {
+		t.Error("Expected TTL", 120*time.Second, "got", flat.TTL)
+	}
+
+	if flat.RefreshAfter.Before(before.Add(flat.TTL)) || flat.Stale() {
+		t.Error("Unexpected RefreshAfter", flat.RefreshAfter)
+	}
+
+	z.Add(ZoneRecord{Name: "mail.example.org", Type: "A", Data: "192.0.2.26"})
+	if err := flat.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(flat.SPFString(), "ip4:192.0.2.26 ") {
+		t.Error("Expected refreshed record got", flat.SPFString())
+	}
+
+	if _, err := c.Flatten(context.Background(), "loop.example"); err != ErrIncludeLoop {
+		t.Error("Expected", ErrIncludeLoop, "got", err)
+	}
+}
+
+const nestedZone = `
+$ORIGIN example.
+open           TXT "v=spf1 include:_open.open.example ip4:198.51.100.1 -all"
+_open.open     TXT "v=spf1 -ip4:203.0.113.0/24 ~ip6:2001:db8::/32 +all ip4:198.51.100.0/24"
+excluded       TXT "v=spf1 include:_spf.excluded.example ~include:_spf.excluded.example -all"
+_spf.excluded  TXT "v=spf1 -include:_bad.excluded.example ip4:192.0.2.0/24 ?ip6:2001:db8::1 ip6:2001:db8::/48 redirect=_rest.excluded.example"
+_bad.excluded  TXT "v=spf1 ip4:192.0.2.64/26 a:bad.excluded.example"
+bad.excluded   A   192.0.2.5
+_rest.excluded TXT "v=spf1 -ip4:198.51.100.0/25 ip4:198.51.100.0/24 -all ip4:203.0.113.0/24"
+closed         TXT "v=spf1 include:_spf.closed.example ip4:192.0.2.0/24 ~all"
+_spf.closed    TXT "v=spf1 ip4:198.51.100.0/24 ?all ip4:192.0.2.0/24"
+opaque         TXT "v=spf1 include:_spf.opaque.example -all"
+_spf.opaque    TXT "v=spf1 -exists:%{i}.bl.example ip4:192.0.2.0/24"
+`
+
+func TestFlattenEquivalent(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(nestedZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Resolver: z}
+	ips := []string{"192.0.2.1", "192.0.2.5", "192.0.2.65", "198.51.100.1", "198.51.100.200", "203.0.113.1", "2001:db8::1", "2001:db8::2", "2001:db8:1::1", "2001:db9::1"}
+
+	for _, domain := range []string{"open.example", "excluded.example", "closed.example"} {
+		flat, err := c.Flatten(context.Background(), domain)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		flatChecker := &Checker{Source: MapSource{domain: flat.SPFString()}}
+		for _, ip := range ips {
+			t.Log("Analyzing", domain, ip, flat.SPFString())
+
+			expected, _ := c.SPFTest(context.Background(), ip, "info@"+domain)
+			actual, _ := flatChecker.SPFTest(context.Background(), ip, "info@"+domain)
+			if actual != expected {
+				t.Error("Expected", expected, "got", actual)
+			}
+		}
+	}
+
+	if _, err := c.Flatten(context.Background(), "opaque.example"); err != ErrNotFlattenable {
+		t.Error("Expected", ErrNotFlattenable, "got", err)
+	}
+}
+
+func TestFlattener(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(flattenZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan *Flattened, 10)
+	f := &Flattener{
+		Checker:  &Checker{Resolver: z},
+		Domain:   "example.org",
+		Interval: 5 * time.Millisecond,
+		OnChange: func(old, new *Flattened) { changes <- new },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- f.Run(ctx) }()
+
+	first := <-changes
+	if first.SPFString() != f.Current().SPFString() {
+		t.Error("Expected current record", first.SPFString(), "got", f.Current().SPFString())
+	}
+
+	z.Add(ZoneRecord{Name: "relay.example.org", Type: "A", Data: "203.0.113.64"})
+
+	select {
+	case second := <-changes:
+		if !strings.Contains(second.SPFString(), "ip4:203.0.113.64/28") {
+			t.Error("Expected changed record got", second.SPFString())
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected change notification")
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Error("Expected", context.Canceled, "got", err)
+	}
+
+	if len(changes) != 0 {
+		t.Error("Unexpected change notifications", len(changes))
+	}
+}
+
+func TestSameTerms(t *testing.T) {
+	base := "v=spf1 -ip4:192.0.2.1 ip4:192.0.2.0/24 ip6:2001:db8::/32 ~all"
+
+	tests := []struct {
+		record string
+		same   bool
+	}{
+		{"v=spf1 -ip4:192.0.2.1 ip6:2001:db8::/32 ip4:192.0.2.0/24 ~all", true},
+		{"v=spf1 ip4:192.0.2.0/24 -ip4:192.0.2.1 ip6:2001:db8::/32 ~all", false},
+		{"v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 -ip4:192.0.2.1 ~all", false},
+		{"v=spf1 -ip4:192.0.2.1 ip4:192.0.2.0/24 ~all ip6:2001:db8::/32", false},
+	}
+
+	a, _ := NewSPF("example.com", base, 0)
+	for _, test := range tests {
+		t.Log("Analyzing", test.record)
+
+		b, err := NewSPF("example.com", test.record, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if same := sameTerms(a.Mechanisms, b.Mechanisms); same != test.same {
+			t.Error("Expected", test.same, "got", same)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	var flat Flattened
+	flat.Domain = "example.org"
+	flat.Version = "spf1"
+
+	for i := 0; i < 40; i++ {
+		m, _ := NewMechanism(fmt.Sprintf("ip4:192.0.2.%d", i), flat.Domain)
+		flat.Mechanisms = append(flat.Mechanisms, m)
+	}
+
+	for _, term := range []string{"-ip4:198.51.100.1", "ip6:2001:db8::/32", "~all"} {
+		m, _ := NewMechanism(term, flat.Domain)
+		flat.Mechanisms = append(flat.Mechanisms, m)
+	}
+
+	records, err := flat.Split(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "v=spf1 include:_spf1.example.org include:_spf2.example.org include:_spf3.example.org include:_spf4.example.org -ip4:198.51.100.1 include:_spf5.example.org ~all"
+	if records[0].Domain != "example.org" || records[0].Text != expected {
+		t.Error("Expected", expected, "got", records[0].Text)
+	}
+
+	if len(records) != 6 {
+		t.Fatal("Expected 6 records got", len(records))
+	}
+
+	z := NewZone()
+	for _, r := range records {
+		if len(r.Text) > 200 {
+			t.Error("Record", r.Domain, "is longer than 200:", len(r.Text))
+		}
+		z.Add(ZoneRecord{Name: r.Domain, Type: "TXT", Data: r.Text})
+	}
+
+	c := &Checker{Resolver: z}
+	tests := []spftest{
+		spftest{"192.0.2.0", "info@example.org", Pass},
+		spftest{"192.0.2.39", "info@example.org", Pass},
+		spftest{"2001:db8::1", "info@example.org", Pass},
+		spftest{"198.51.100.1", "info@example.org", Fail},
+		spftest{"203.0.113.1", "info@example.org", SoftFail},
+	}
+
+	for _, expected := range tests {
+		actual, _ := c.SPFTest(context.Background(), expected.server, expected.email)
+
+		if actual != expected.result {
+			t.Error("For", expected.server, "at", expected.email, "Expected", expected.result, "got", actual)
+		}
+	}
+
+	records, _ = flat.Split(0)
+	if len(records) != 4 {
+		t.Error("Expected 4 records got", len(records))
+	}
+
+	if _, err := flat.Split(20); err != ErrRecordTooLong {
+		t.Error("Expected", ErrRecordTooLong, "got", err)
+	}
+}
+
+func TestNetworks(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(flattenZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Resolver: z}
+
+	networks, err := c.Networks(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[192.0.2.25/32 192.0.2.128/28 198.51.100.0/24 2001:db8::25/128 2001:db8:1::/48]"
+	if fmt.Sprint(networks) != expected {
+		t.Error("Expected", expected, "got", networks)
+	}
+
+	s, _ := c.NewSPF(context.Background(), "example.org", "v=spf1 ip4:192.0.2.0/24 +all", 0)
+	networks, _ = s.Networks(context.Background())
+
+	expected = "[0.0.0.0/0 ::/0]"
+	if fmt.Sprint(networks) != expected {
+		t.Error("Expected", expected, "got", networks)
+	}
+
+	// Records that cannot be flattened exactly still report their networks.
+	z, err = ParseZone(strings.NewReader(nestedZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = &Checker{Resolver: z}
+
+	networks, err = c.Networks(context.Background(), "opaque.example")
+	expected = "[192.0.2.0/24]"
+	if err != nil || fmt.Sprint(networks) != expected {
+		t.Error("Expected", expected, "got", networks, err)
+	}
+
+	if _, err := c.NewReport(context.Background(), "opaque.example"); err != nil {
+		t.Error("Expected", nil, "got", err)
+	}
+
+	if _, err := c.Score(context.Background(), "opaque.example"); err != nil {
+		t.Error("Expected", nil, "got", err)
+	}
+
+	if status := (&Monitor{Checker: c}).Check(context.Background(), "opaque.example"); status.Error != "" {
+		t.Error("Expected no error got", status.Error)
+	}
+}
@@ -0,0 +1,179 @@
+package spf
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// MinRefreshInterval is the shortest time a Flattener without an
+	// Interval waits between two flattening runs, regardless of the TTLs of
+	// the resolved data.
+	MinRefreshInterval = time.Minute
+)
+
+// Flattener is a long-running component that keeps the flattened record of a
+// domain up to date. It re-resolves the include tree whenever the previous
+// result may be stale and calls OnChange when the effective record changed.
+type Flattener struct {
+	// Checker performs the lookups. If nil, DefaultChecker is used.
+	Checker *Checker
+
+	// Domain is the domain whose record is flattened.
+	Domain string
+
+	// Interval caps the time between two flattening runs and is also the
+	// delay before retrying a failed run. If zero, runs are scheduled at
+	// the RefreshAfter time of the previous result, but no more often than
+	// MinRefreshInterval.
+	Interval time.Duration
+
+	// OnChange is called with the previous and the new result whenever the
+	// flattened record changed. The previous result is nil on the first
+	// successful run.
+	OnChange func(old, new *Flattened)
+
+	// OnError is called when a flattening run fails.
+	OnError func(err error)
+
+	// OnEvent is called with a RecordChanged event whenever the flattened
+	// record changed, except on the first run, and with a
+	// LookupLimitExceeded event when the published record starts requiring
+	// more than MaxCount lookups.
+	OnEvent func(event Event)
+
+	mu      sync.Mutex
+	current *Flattened
+}
+
+// Current returns the most recent flattened record, or nil if no run has
+// succeeded yet.
+func (f *Flattener) Current() *Flattened {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.current
+}
+
+// Run flattens the record until the context is cancelled and returns the
+// context's error.
+func (f *Flattener) Run(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		timer.Reset(f.update(ctx))
+	}
+}
+
+// update performs a single flattening run and returns the delay before the
+// next one.
+func (f *Flattener) update(ctx context.Context) time.Duration {
+	c := f.Checker
+	if c == nil {
+		c = DefaultChecker
+	}
+
+	flat, err := c.Flatten(ctx, f.Domain)
+	if err != nil {
+		if f.OnError != nil && ctx.Err() == nil {
+			f.OnError(err)
+		}
+
+		if f.Interval > 0 {
+			return f.Interval
+		}
+		return MinRefreshInterval
+	}
+
+	f.mu.Lock()
+	old := f.current
+	f.current = flat
+	f.mu.Unlock()
+
+	changed := old == nil || !sameTerms(old.Mechanisms, flat.Mechanisms)
+	if f.OnChange != nil && changed {
+		f.OnChange(old, flat)
+	}
+
+	if f.OnEvent != nil {
+		f.events(old, flat, changed)
+	}
+
+	wait := time.Until(flat.RefreshAfter)
+	switch {
+	case f.Interval > 0 && (wait > f.Interval || wait <= 0):
+		wait = f.Interval
+	case f.Interval == 0 && wait < MinRefreshInterval:
+		wait = MinRefreshInterval
+	}
+
+	return wait
+}
+
+// events reports the events of a flattening run to OnEvent.
+func (f *Flattener) events(old, flat *Flattened, changed bool) {
+	now := time.Now()
+
+	if old != nil && changed {
+		diff := DiffRecords(old.Raw, flat.Raw)
+		f.OnEvent(Event{
+			Type:    RecordChanged,
+			Domain:  f.Domain,
+			Time:    now,
+			Old:     old.Raw,
+			New:     flat.Raw,
+			Added:   diff.Added,
+			Removed: diff.Removed,
+		})
+	}
+
+	if flat.Lookups > MaxCount && (old == nil || old.Lookups <= MaxCount) {
+		f.OnEvent(Event{Type: LookupLimitExceeded, Domain: f.Domain, Time: now, Lookups: flat.Lookups})
+	}
+}
+
+// sameTerms reports whether both lists contain the same terms in an order
+// giving the same results, see termStrings.
+func sameTerms(a, b []Mechanism) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	as := termStrings(a)
+	bs := termStrings(b)
+	for i := range as {
+		if as[i] != bs[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// termStrings returns the SPF representations of the mechanisms, sorted
+// within each run of consecutive mechanisms sharing a qualifier. Only the
+// order of mechanisms with different qualifiers changes the results.
+func termStrings(mechanisms []Mechanism) []string {
+	terms := make([]string, len(mechanisms))
+	start := 0
+	for i, m := range mechanisms {
+		terms[i] = m.SPFString()
+
+		if i > 0 && m.Result != mechanisms[i-1].Result {
+			sort.Strings(terms[start:i])
+			start = i
+		}
+	}
+	sort.Strings(terms[start:])
+
+	return terms
+}
@@ -0,0 +1,188 @@
+package spf
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidHeader = errors.New("Invalid header field.")
+)
+
+// ParseResult returns the Result for its textual form as used in
+// Received-SPF and Authentication-Results header fields, e.g. "softfail".
+// The comparison is case-insensitive and the legacy forms "error" and
+// "unknown" map to TempError and PermError.
+func ParseResult(s string) (Result, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "pass":
+		return Pass, nil
+	case "fail", "hardfail":
+		return Fail, nil
+	case "softfail":
+		return SoftFail, nil
+	case "neutral":
+		return Neutral, nil
+	case "none":
+		return None, nil
+	case "temperror", "error":
+		return TempError, nil
+	case "permerror", "unknown":
+		return PermError, nil
+	}
+
+	return "", ErrInvalidResult
+}
+
+// ReceivedSPFHeader is a parsed Received-SPF header field as defined in RFC
+// 7208 section 9.1. Params holds every key-value pair of the field, keyed by
+// lower-cased name; the well-known ones are also available as fields.
+type ReceivedSPFHeader struct {
+	Result       Result
+	Comment      string
+	ClientIP     string
+	EnvelopeFrom string
+	Helo         string
+	Receiver     string
+	Identity     string
+	Mechanism    string
+	Problem      string
+	Params       map[string]string
+}
+
+// ParseReceivedSPF parses the value of a Received-SPF header field. The
+// "Received-SPF:" field name may be included. Folded lines are accepted.
+func ParseReceivedSPF(header string) (*ReceivedSPFHeader, error) {
+	value := unfold(header)
+	if i := strings.Index(value, ":"); i != -1 && strings.EqualFold(strings.TrimSpace(value[:i]), "Received-SPF") {
+		value = value[i+1:]
+	}
+	value = strings.TrimSpace(value)
+
+	end := strings.IndexAny(value, " \t(;")
+	if end == -1 {
+		end = len(value)
+	}
+
+	result, err := ParseResult(value[:end])
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+
+	h := &ReceivedSPFHeader{Result: result, Params: make(map[string]string)}
+	rest := strings.TrimSpace(value[end:])
+
+	if strings.HasPrefix(rest, "(") {
+		comment, n, err := readComment(rest)
+		if err != nil {
+			return nil, err
+		}
+		h.Comment = comment
+		rest = rest[n:]
+	}
+
+	params, err := parseParams(rest)
+	if err != nil {
+		return nil, err
+	}
+	h.Params = params
+
+	h.ClientIP = params["client-ip"]
+	h.EnvelopeFrom = params["envelope-from"]
+	h.Helo = params["helo"]
+	h.Receiver = params["receiver"]
+	h.Identity = params["identity"]
+	h.Mechanism = params["mechanism"]
+	h.Problem = params["problem"]
+
+	return h, nil
+}
+
+// unfold joins folded header lines.
+func unfold(header string) string {
+	header = strings.ReplaceAll(header, "\r\n", "\n")
+	header = strings.ReplaceAll(header, "\n\t", " ")
+	header = strings.ReplaceAll(header, "\n ", " ")
+
+	return strings.TrimSpace(header)
+}
+
+// readComment reads the possibly nested comment at the start of s and
+// returns its content and the number of bytes consumed.
+func readComment(s string) (string, int, error) {
+	depth := 0
+	escaped := false
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == '(':
+			depth++
+		case s[i] == ')':
+			depth--
+			if depth == 0 {
+				return s[1:i], i + 1, nil
+			}
+		}
+	}
+
+	return "", 0, ErrInvalidHeader
+}
+
+// parseParams parses "key=value;" pairs. Values may be quoted strings and
+// comments between pairs are ignored.
+func parseParams(s string) (map[string]string, error) {
+	params := make(map[string]string)
+
+	for {
+		s = strings.TrimLeft(s, " \t;")
+		for strings.HasPrefix(s, "(") {
+			_, n, err := readComment(s)
+			if err != nil {
+				return nil, err
+			}
+			s = strings.TrimLeft(s[n:], " \t;")
+		}
+
+		if s == "" {
+			return params, nil
+		}
+
+		eq := strings.Index(s, "=")
+		if eq <= 0 {
+			return nil, ErrInvalidHeader
+		}
+
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value string
+		if strings.HasPrefix(s, "\"") {
+			var buf strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				buf.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, ErrInvalidHeader
+			}
+			value = buf.String()
+			s = s[i+1:]
+		} else {
+			end := strings.IndexAny(s, "; \t(")
+			if end == -1 {
+				end = len(s)
+			}
+			value = s[:end]
+			s = s[end:]
+		}
+
+		params[key] = value
+	}
+}
@@ -0,0 +1,47 @@
+package spf
+
+import (
+	"testing"
+)
+
+func TestParseReceivedSPF(t *testing.T) {
+	header := "Received-SPF: Pass (mybox.example.org: domain of\r\n" +
+		"\tmyname@example.com designates 192.0.2.1 as permitted sender)\r\n" +
+		"\treceiver=mybox.example.org; client-ip=192.0.2.1;\r\n" +
+		"\tenvelope-from=\"myname@example.com\"; helo=foo.example.com;\r\n" +
+		"\tx-vendor=abc (vendor extension)"
+
+	h, err := ParseReceivedSPF(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Result != Pass || h.ClientIP != "192.0.2.1" || h.EnvelopeFrom != "myname@example.com" ||
+		h.Helo != "foo.example.com" || h.Receiver != "mybox.example.org" || h.Params["x-vendor"] != "abc" {
+		t.Error("Unexpected header", h)
+	}
+
+	expected := "mybox.example.org: domain of myname@example.com designates 192.0.2.1 as permitted sender"
+	if h.Comment != expected {
+		t.Error("Expected", expected, "got", h.Comment)
+	}
+
+	generated := ReceivedSPF(SoftFail, "192.0.2.1", "info@example.com", "mx.example.org")
+	h, err = ParseReceivedSPF(generated)
+	if err != nil || h.Result != SoftFail || h.EnvelopeFrom != "info@example.com" || h.Receiver != "mx.example.org" {
+		t.Error("Unexpected header", h, err)
+	}
+
+	invalid := []string{
+		"Received-SPF: maybe",
+		"Received-SPF: pass (unterminated",
+		"Received-SPF: pass client-ip",
+		"Received-SPF: pass envelope-from=\"open",
+	}
+
+	for _, header := range invalid {
+		if _, err := ParseReceivedSPF(header); err != ErrInvalidHeader {
+			t.Error("For", header, "Expected", ErrInvalidHeader, "got", err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package spf
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HealthReport is the analysis of the SPF record of a domain, rendered by
+// WriteHTML as a self-contained HTML page or by WriteJSON as a JSON document.
+type HealthReport struct {
+	Report
+
+	// Tree is the record of the domain and the records it includes or
+	// redirects to.
+	Tree *IncludeNode `json:"tree"`
+
+	// OverLimit is set when the record requires more than MaxCount
+	// lookups.
+	OverLimit bool `json:"over_limit"`
+
+	// Score rates the record, see Checker.Score. It is nil if the record
+	// could not be rated.
+	Score *Scorecard `json:"score,omitempty"`
+
+	Generated time.Time `json:"generated"`
+}
+
+// IncludeNode is a record in the include tree of a HealthReport. Via is the
+// term referencing the record, empty for the top level record, and Provider
+// the known mail provider it belongs to, see IdentifyProvider.
+type IncludeNode struct {
+	Domain   string         `json:"domain"`
+	Via      string         `json:"via,omitempty"`
+	Provider string         `json:"provider,omitempty"`
+	Record   string         `json:"record,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Children []*IncludeNode `json:"children,omitempty"`
+}
+
+// NewHealthReport analyzes the record of the domain using the DefaultChecker.
+func NewHealthReport(ctx context.Context, domain string) (*HealthReport, error) {
+	return DefaultChecker.NewHealthReport(ctx, domain)
+}
+
+// NewHealthReport analyzes the record of the domain. Records of the include
+// tree that cannot be fetched or parsed are reported in their node instead of
+// failing the report.
+func (c *Checker) NewHealthReport(ctx context.Context, domain string) (*HealthReport, error) {
+	report, err := c.NewReport(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	health := &HealthReport{Report: *report, OverLimit: report.Lookups > MaxCount, Generated: time.Now()}
+	health.Tree = c.includeTree(ctx, domain, "", map[string]bool{})
+	health.Score, _ = c.Score(ctx, domain)
+
+	return health, nil
+}
+
+// includeTree fetches the record of the domain and, recursively, of the
+// records it references. Records already on the chain are not fetched again.
+func (c *Checker) includeTree(ctx context.Context, domain, via string, visited map[string]bool) *IncludeNode {
+	node := &IncludeNode{Domain: domain, Via: via}
+
+	spf, _, err := c.NewSPFLenient(ctx, domain, "", 0)
+	if err != nil {
+		node.Error = err.Error()
+		return node
+	}
+	node.Record = spf.Raw
+
+	visited[canonicalName(domain)] = true
+	defer delete(visited, canonicalName(domain))
+
+	for _, m := range spf.terms() {
+		if m.Name != "include" && m.Name != "redirect" {
+			continue
+		}
+
+		child := &IncludeNode{Domain: m.Domain, Via: m.SPFString()}
+		switch {
+		case visited[canonicalName(m.Domain)]:
+			child.Error = ErrIncludeLoop.Error()
+		case hasMacro(m.Domain):
+			// The target depends on the client.
+		default:
+			child = c.includeTree(ctx, m.Domain, child.Via, visited)
+		}
+		child.Provider = IdentifyProvider(m)
+
+		node.Children = append(node.Children, child)
+	}
+
+	return node
+}
+
+// WriteJSON writes the report as an indented JSON document.
+func (r *HealthReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r)
+}
+
+// WriteHTML writes the report as an HTML page without external resources.
+func (r *HealthReport) WriteHTML(w io.Writer) error {
+	return healthTemplate.Execute(w, r)
+}
+
+// HealthHandler returns a handler serving the HealthReport of the domain
+// given by the domain query parameter, as HTML or, with format=json, JSON.
+func (c *Checker) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		domain := r.URL.Query().Get("domain")
+		if domain == "" {
+			http.Error(w, "missing domain", http.StatusBadRequest)
+			return
+		}
+
+		report, err := c.NewHealthReport(r.Context(), domain)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			report.WriteJSON(w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		report.WriteHTML(w)
+	})
+}
+
+var healthTemplate = template.Must(template.New("health").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>SPF report for {{.Domain}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+code, pre { font-family: monospace; background: #f4f4f4; padding: 0.1em 0.3em; }
+pre { padding: 0.5em; white-space: pre-wrap; word-break: break-all; }
+ul.tree, ul.tree ul { list-style: none; padding-left: 1.2em; border-left: 1px solid #ccc; }
+.error { color: #b00; }
+.warning { color: #a60; }
+</style>
+</head>
+<body>
+<h1>SPF report for {{.Domain}}</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}</p>
+<h2>Record</h2>
+<pre>{{.Record}}</pre>
+<p>{{.Lookups}} DNS lookups{{if .OverLimit}} <span class="error">(over the limit)</span>{{end}}</p>
+{{- with .Score}}
+<h2>Score: {{.Score}}/100</h2>
+<table>
+{{- range .Findings}}
+<tr><td>{{.Name}}</td><td>{{.Points}}/{{.Weight}}</td><td>{{.Detail}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Warnings}}
+<h2>Warnings</h2>
+<ul>
+{{- range .Warnings}}
+<li class="warning">{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+<h2>Include tree</h2>
+<ul class="tree">{{template "node" .Tree}}</ul>
+<h2>Authorized networks</h2>
+<ul>
+{{- range .Networks}}
+<li><code>{{.}}</code></li>
+{{- else}}
+<li>None</li>
+{{- end}}
+</ul>
+</body>
+</html>
+{{define "node"}}
+<li>{{if .Via}}<code>{{.Via}}</code>: {{end}}<strong>{{.Domain}}</strong>{{with .Provider}} ({{.}}){{end}}
+{{- if .Record}} <code>{{.Record}}</code>{{end}}
+{{- if .Error}} <span class="error">{{.Error}}</span>{{end}}
+{{- if .Children}}
+<ul>
+{{- range .Children}}{{template "node" .}}{{end}}
+</ul>
+{{- end}}
+</li>
+{{- end}}
+`))
@@ -0,0 +1,102 @@
+package spf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewHealthReport(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(flattenZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Resolver: z}
+
+	report, err := c.NewHealthReport(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree := report.Tree
+	if tree.Domain != "example.org" || len(tree.Children) != 2 {
+		t.Fatal("Unexpected tree", tree)
+	}
+
+	vendor := tree.Children[0]
+	if vendor.Via != "include:_spf.vendor.example" || len(vendor.Children) != 1 || vendor.Children[0].Domain != "_net.vendor.example" {
+		t.Error("Unexpected include node", vendor)
+	}
+
+	if tree.Children[1].Via != "redirect=_rest.example.org" {
+		t.Error("Expected redirect=_rest.example.org got", tree.Children[1].Via)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"domain", "record", "networks", "lookups", "tree", "over_limit"} {
+		if _, ok := decoded[key]; !ok {
+			t.Error("Expected", key, "in", buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := report.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	html := buf.String()
+	for _, s := range []string{"<title>SPF report for example.org</title>", "<code>198.51.100.0/24</code>", "_net.vendor.example"} {
+		if !strings.Contains(html, s) {
+			t.Error("Expected", s, "in", html)
+		}
+	}
+}
+
+func TestIncludeTreeLoop(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(flattenZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Resolver: z}
+	tree := c.includeTree(context.Background(), "loop.example", "", map[string]bool{})
+
+	if len(tree.Children) != 1 || len(tree.Children[0].Children) != 1 {
+		t.Fatal("Unexpected tree", tree)
+	}
+
+	if loop := tree.Children[0].Children[0]; loop.Error != ErrIncludeLoop.Error() {
+		t.Error("Expected", ErrIncludeLoop, "got", loop.Error)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	c := &Checker{Source: MapSource{"example.com": "v=spf1 ip4:192.0.2.0/24 <script> -all"}}
+
+	rec := httptest.NewRecorder()
+	c.HealthHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/?domain=example.com", nil))
+
+	if strings.Contains(rec.Body.String(), "<script>") {
+		t.Error("Expected the record to be escaped")
+	}
+
+	rec = httptest.NewRecorder()
+	c.HealthHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/?domain=example.com&format=json", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Error("Expected application/json got", ct)
+	}
+}
@@ -0,0 +1,95 @@
+package spf
+
+import (
+	"net/netip"
+)
+
+// MechanismEvent describes a term of a record being evaluated, passed to the
+// BeforeMechanism and AfterMechanism hooks of a Checker.
+type MechanismEvent struct {
+	// Mechanism is the term as written in the record, with its macros
+	// unexpanded. A redirect modifier is passed as a mechanism named
+	// "redirect".
+	Mechanism Mechanism
+
+	// Domain is the domain of the record holding the term and Depth the
+	// number of records being evaluated, 1 for the record of the checked
+	// domain.
+	Domain string
+	Depth  int
+
+	Addr   netip.Addr
+	Sender string
+	Helo   string
+
+	// Matched reports whether the term determines the result of the
+	// record, which is then Result: either the term matched or its
+	// evaluation failed with TempError or PermError. Otherwise the
+	// evaluation moves on to the next term and Result is None. Both are
+	// the tentative outcome of the term when AfterMechanism is called.
+	Result  Result
+	Matched bool
+}
+
+// hooked reports whether the Checker has a BeforeMechanism or AfterMechanism
+// hook.
+func (c *Checker) hooked() bool {
+	return c.BeforeMechanism != nil || c.AfterMechanism != nil
+}
+
+// mechanismEvent returns the event of the term m of the record, nil if the
+// Checker has no hooks.
+func (e *evaluation) mechanismEvent(s *SPF, m *Mechanism) *MechanismEvent {
+	if !e.checker.hooked() {
+		return nil
+	}
+
+	return &MechanismEvent{
+		Mechanism: *m,
+		Domain:    s.Domain,
+		Depth:     e.depth,
+		Addr:      e.addr,
+		Sender:    e.sender,
+		Helo:      e.helo,
+	}
+}
+
+// before calls the BeforeMechanism hook, returning true with the outcome it
+// set if it decided the outcome of the term.
+func (e *evaluation) before(ev *MechanismEvent) (bool, Result, error) {
+	if ev == nil || e.checker.BeforeMechanism == nil || !e.checker.BeforeMechanism(e.ctx, ev) {
+		return false, None, nil
+	}
+
+	result, err := ev.outcome(nil)
+
+	return true, result, err
+}
+
+// after calls the AfterMechanism hook with the outcome of the term and
+// returns the outcome the hook left.
+func (e *evaluation) after(ev *MechanismEvent, result Result, err error) (Result, error) {
+	if ev == nil || e.checker.AfterMechanism == nil {
+		return result, err
+	}
+
+	ev.Result, ev.Matched = result, err == nil
+	e.checker.AfterMechanism(e.ctx, ev)
+
+	return ev.outcome(err)
+}
+
+// outcome returns the outcome of the term set in the event, as returned by
+// Mechanism.evaluate: a nil error for a term that matched, else err, or
+// ErrNoMatch when err is nil.
+func (ev *MechanismEvent) outcome(err error) (Result, error) {
+	if ev.Matched {
+		return ev.Result, nil
+	}
+
+	if err == nil {
+		err = ErrNoMatch
+	}
+
+	return ev.Result, err
+}
@@ -0,0 +1,75 @@
+package spf
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMechanismHooks(t *testing.T) {
+	source := MapSource{
+		"example.com":      "v=spf1 include:_spf.example.com ip4:198.51.100.0/24 -all",
+		"_spf.example.com": "v=spf1 ip4:192.0.2.0/24 -all",
+	}
+
+	var seen []string
+	c := &Checker{Source: source}
+	c.AfterMechanism = func(ctx context.Context, ev *MechanismEvent) {
+		seen = append(seen, strings.Join([]string{ev.Domain, ev.Mechanism.SPFString(), string(ev.Result)}, " "))
+
+		// Experiment: soften the hard fail of the top level record.
+		if ev.Depth == 1 && ev.Mechanism.Name == "all" && ev.Result == Fail {
+			ev.Result = SoftFail
+		}
+	}
+
+	tests := []struct {
+		ip     string
+		result Result
+		seen   int
+	}{
+		{"192.0.2.1", Pass, 2},
+		{"198.51.100.1", Pass, 4},
+		{"203.0.113.1", SoftFail, 5},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.ip)
+
+		seen = nil
+		result, _ := c.SPFTest(context.Background(), test.ip, "info@example.com")
+		if result != test.result || len(seen) != test.seen {
+			t.Error("Expected", test.result, test.seen, "got", result, seen)
+		}
+	}
+
+	if seen[0] != "_spf.example.com ip4:192.0.2.0/24 None" || seen[2] != "example.com include:_spf.example.com None" {
+		t.Error("Unexpected events", seen)
+	}
+
+	// Overrides: decide the include without fetching its record.
+	c.AfterMechanism = nil
+	c.BeforeMechanism = func(ctx context.Context, ev *MechanismEvent) bool {
+		if ev.Mechanism.Name != "include" {
+			return false
+		}
+
+		ev.Result, ev.Matched = Pass, ev.Addr.String() == "203.0.113.1"
+		return true
+	}
+
+	for ip, expected := range map[string]Result{"203.0.113.1": Pass, "192.0.2.1": Fail} {
+		t.Log("Analyzing", ip)
+
+		cr := c.Check(context.Background(), Request{IP: ip, Sender: "info@example.com"})
+		if cr.Result != expected {
+			t.Error("Expected", expected, "got", cr.Result)
+		}
+	}
+
+	s, _ := NewSPF("example.com", "v=spf1 ip4:192.0.2.1 -all", 0)
+	s.checker = c
+	if s.Test("192.0.2.1") != Pass {
+		t.Error("Expected Pass with hooks on a literal record")
+	}
+}
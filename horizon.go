@@ -0,0 +1,133 @@
+package spf
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// View is a named view of split-horizon DNS, e.g. "internal" or "external",
+// and the Resolver answering from it.
+type View struct {
+	Name     string
+	Resolver Resolver
+}
+
+// ViewResult is the outcome of a check against a single view.
+type ViewResult struct {
+	View string
+	CheckResult
+
+	// Records holds the record of every domain on the include tree of the
+	// sender's domain, as published in the view.
+	Records map[string]string
+}
+
+// Divergence is a domain whose record differs between views.
+type Divergence struct {
+	Domain string
+
+	// Records holds the record of the domain in each view, in the order
+	// of the views, empty when the view publishes none.
+	Records []string
+}
+
+// HorizonReport compares the checks of a request against several views.
+type HorizonReport struct {
+	Views       []ViewResult
+	Divergences []Divergence
+}
+
+// Diverges reports whether the views gave different results or publish
+// different records.
+func (r HorizonReport) Diverges() bool {
+	if len(r.Divergences) > 0 {
+		return true
+	}
+
+	for _, v := range r.Views {
+		if v.Result != r.Views[0].Result {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CompareViews checks the request against each view and reports where the
+// views diverge. The lookups of each check are made with the Resolver of the
+// view. Records are fetched from the view too unless the Checker's Source
+// does not use DNS, in which case only the other lookups differ.
+func (c *Checker) CompareViews(ctx context.Context, req Request, views ...View) HorizonReport {
+	var report HorizonReport
+
+	for _, view := range views {
+		report.Views = append(report.Views, c.checkView(ctx, req, view))
+	}
+
+	domains := map[string]bool{}
+	for _, v := range report.Views {
+		for domain := range v.Records {
+			domains[domain] = true
+		}
+	}
+
+	var names []string
+	for domain := range domains {
+		names = append(names, domain)
+	}
+	sort.Strings(names)
+
+	for _, domain := range names {
+		d := Divergence{Domain: domain}
+		diverges := false
+		for _, v := range report.Views {
+			d.Records = append(d.Records, v.Records[domain])
+			diverges = diverges || d.Records[len(d.Records)-1] != d.Records[0]
+		}
+
+		if diverges {
+			report.Divergences = append(report.Divergences, d)
+		}
+	}
+
+	return report
+}
+
+// checkView checks the request against a single view and collects the
+// records of the include tree.
+func (c *Checker) checkView(ctx context.Context, req Request, view View) ViewResult {
+	vr := ViewResult{View: view.Name, Records: map[string]string{}}
+
+	vc := *c
+	vc.Resolver = view.Resolver
+
+	source := c.Source
+	switch s := c.Source.(type) {
+	case nil:
+		source = DNSSource{Resolver: vc.resolver()}
+	case DNSSource:
+		source = DNSSource{Resolver: vc.resolver(), SenderID: s.SenderID}
+	}
+
+	var mu sync.Mutex
+	vc.Source = RecordSourceFunc(func(ctx context.Context, domain string) (string, error) {
+		record, err := source.Record(ctx, domain)
+		if err == nil {
+			mu.Lock()
+			vr.Records[canonicalName(domain)] = record
+			mu.Unlock()
+		}
+		return record, err
+	})
+
+	vr.CheckResult = vc.Check(ctx, req)
+
+	if spf, err := vc.NewSPF(ctx, vr.Domain, "", 0); err == nil {
+		spf.WalkTree(ctx, func(domain string, m *Mechanism) error {
+			return nil
+		})
+	}
+
+	return vr
+}
@@ -0,0 +1,52 @@
+package spf
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCompareViews(t *testing.T) {
+	external, err := ParseZone(strings.NewReader(`
+$ORIGIN example.com.
+@      IN TXT "v=spf1 include:_spf.example.com -all"
+_spf   IN TXT "v=spf1 ip4:203.0.113.0/24 -all"
+`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	internal, err := ParseZone(strings.NewReader(`
+$ORIGIN example.com.
+@      IN TXT "v=spf1 include:_spf.example.com -all"
+_spf   IN TXT "v=spf1 ip4:203.0.113.0/24 ip4:10.0.0.0/8 -all"
+`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{}
+	views := []View{View{"internal", internal}, View{"external", external}}
+
+	report := c.CompareViews(context.Background(), Request{IP: "10.0.0.1", Sender: "info@example.com"}, views...)
+	if !report.Diverges() {
+		t.Error("Expected views to diverge")
+	}
+
+	if report.Views[0].Result != Pass || report.Views[1].Result != Fail {
+		t.Error("Expected Pass and Fail got", report.Views[0].Result, report.Views[1].Result)
+	}
+
+	if len(report.Divergences) != 1 || report.Divergences[0].Domain != "_spf.example.com" {
+		t.Fatal("Expected _spf.example.com to diverge got", report.Divergences)
+	}
+
+	if report.Divergences[0].Records[1] != "v=spf1 ip4:203.0.113.0/24 -all" {
+		t.Error("Expected external record got", report.Divergences[0].Records[1])
+	}
+
+	report = c.CompareViews(context.Background(), Request{IP: "203.0.113.1", Sender: "info@example.com"}, views[1], views[1])
+	if report.Diverges() {
+		t.Error("Expected identical views not to diverge got", report.Divergences)
+	}
+}
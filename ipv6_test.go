@@ -0,0 +1,184 @@
+package spf
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+type ip6test struct {
+	mechanism string
+	ip        string
+	matches   bool
+}
+
+func TestIPv6Matching(t *testing.T) {
+	tests := []ip6test{
+		ip6test{"ip6:2001:db8::1", "2001:db8::1", true},
+		ip6test{"ip6:2001:db8::1", "2001:db8::2", false},
+		ip6test{"ip6:2001:db8::1", "2001:0db8:0000:0000:0000:0000:0000:0001", true},
+		ip6test{"ip6:2001:0DB8:0:0::1", "2001:db8::1", true},
+		ip6test{"ip6:2001:db8::/32", "2001:db8:ffff::1", true},
+		ip6test{"ip6:2001:db8::/32", "2001:db9::1", false},
+		ip6test{"ip6:2001:db8::/128", "2001:db8::1", false},
+		ip6test{"ip6:::/0", "2001:db8::1", true},
+		ip6test{"ip6:::/0", "192.0.2.1", false},
+		ip6test{"ip6:1080::8:800:68.0.3.1", "1080::8:800:4400:301", true},
+		ip6test{"ip6:::ffff:192.0.2.1", "192.0.2.1", false},
+		ip6test{"ip4:192.0.2.1", "::ffff:192.0.2.1", true},
+		ip6test{"ip4:192.0.2.0/24", "::ffff:192.0.2.77", true},
+		ip6test{"ip4:192.0.2.1", "2001:db8::1", false},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.mechanism, test.ip)
+
+		m, err := NewMechanism(test.mechanism, "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = m.Evaluate(test.ip, 0)
+		if (err == nil) != test.matches {
+			t.Error("For", test.ip, "Expected match", test.matches, "got", err)
+		}
+	}
+}
+
+func TestIPv6Valid(t *testing.T) {
+	tests := []struct {
+		mechanism string
+		valid     bool
+	}{
+		{"ip6:2001:db8::1", true},
+		{"ip6:2001:db8::/48", true},
+		{"ip6:fe80::1%eth0", false},
+		{"ip6:fe80::1%25eth0", false},
+		{"ip6:2001:db8:::1", false},
+		{"ip6:2001:db8::g", false},
+	}
+
+	for _, test := range tests {
+		m, _ := NewMechanism(test.mechanism, "example.com")
+		if m.Valid() != test.valid {
+			t.Error("For", test.mechanism, "Expected", test.valid, "got", m.Valid())
+		}
+	}
+}
+
+func TestIPv6SPFString(t *testing.T) {
+	tests := []spfstr{
+		spfstr{"v=spf1 ip6:2001:DB8:0:0:0:0:0:1 -all", "v=spf1 ip6:2001:db8::1 -all"},
+		spfstr{"v=spf1 ip6:2001:0db8:0000::/48 -all", "v=spf1 ip6:2001:db8::/48 -all"},
+		spfstr{"v=spf1 -ip6:2001:db8::1:0:0:1 ~all", "v=spf1 -ip6:2001:db8::1:0:0:1 ~all"},
+		spfstr{"v=spf1 ip6:2001:db8:0:0:1:0:0:1 ~all", "v=spf1 ip6:2001:db8::1:0:0:1 ~all"},
+	}
+
+	for _, test := range tests {
+		s, err := NewSPF("example.com", test.raw, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.SPFString() != test.expected {
+			t.Error("Expected", test.expected, "got", s.SPFString())
+		}
+	}
+}
+
+func TestAddressFamily(t *testing.T) {
+	tests := []struct {
+		record string
+		err    error
+	}{
+		{"v=spf1 ip4:2001:db8::1 -all", ErrAddressFamily},
+		{"v=spf1 ip4:::ffff:192.0.2.1 -all", ErrAddressFamily},
+		{"v=spf1 ip6:192.0.2.1 -all", ErrAddressFamily},
+		{"v=spf1 ip6:::ffff:192.0.2.1 -all", nil},
+		{"v=spf1 ip4:192.0.2.1 ip6:2001:db8::1 -all", nil},
+		{"v=spf1 ip4:192.0.2.256 -all", ErrInvalidMechanism},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.record)
+
+		if _, err := NewSPF("example.com", test.record, 0); err != test.err {
+			t.Error("Expected", test.err, "got", err)
+		}
+	}
+
+	c := &Checker{Source: MapSource{"example.com": "v=spf1 ip4:2001:db8::1 -all"}}
+	if result, err := c.SPFTest(context.Background(), "2001:db8::1", "info@example.com"); result != PermError || err != ErrAddressFamily {
+		t.Error("Expected", PermError, ErrAddressFamily, "got", result, err)
+	}
+}
+
+func TestDualCIDR(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "dual.example", Type: "TXT", Data: "v=spf1 a/24//64 mx//48 -all"},
+		ZoneRecord{Name: "dual.example", Type: "A", Data: "192.0.2.10"},
+		ZoneRecord{Name: "dual.example", Type: "AAAA", Data: "2001:db8:1::10"},
+		ZoneRecord{Name: "dual.example", Type: "MX", Data: "10 mx.dual.example"},
+		ZoneRecord{Name: "mx.dual.example", Type: "AAAA", Data: "2001:db8:2::25"},
+		ZoneRecord{Name: "v4.example", Type: "TXT", Data: "v=spf1 a:dual.example/24 -all"},
+	)
+
+	c := &Checker{Resolver: z}
+
+	tests := []spftest{
+		spftest{"192.0.2.99", "info@dual.example", Pass},
+		spftest{"192.0.3.1", "info@dual.example", Fail},
+		spftest{"2001:db8:1::ffff", "info@dual.example", Pass},
+		spftest{"2001:db8:1:1::1", "info@dual.example", Fail},
+		spftest{"2001:db8:2:ff::1", "info@dual.example", Pass},
+		spftest{"192.0.2.99", "info@v4.example", Pass},
+		spftest{"2001:db8:1::ffff", "info@v4.example", Fail},
+		spftest{"2001:db8:1::10", "info@v4.example", Pass},
+	}
+
+	for _, expected := range tests {
+		t.Log("Analyzing", expected.server, expected.email)
+
+		result, err := c.SPFTest(context.Background(), expected.server, expected.email)
+		if result != expected.result {
+			t.Error("Expected", expected.result, "got", result, err)
+		}
+	}
+}
+
+// barrierResolver answers the address lookups of a host only once both the A
+// and AAAA queries were made, failing if they are not concurrent.
+type barrierResolver struct {
+	Resolver
+	arrived chan struct{}
+}
+
+func (r *barrierResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	select {
+	case r.arrived <- struct{}{}:
+	case <-r.arrived:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return r.Resolver.LookupIP(ctx, network, host)
+}
+
+func TestConcurrentLookups(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 a -all"},
+		ZoneRecord{Name: "example.com", Type: "A", Data: "192.0.2.10"},
+		ZoneRecord{Name: "example.com", Type: "AAAA", Data: "2001:db8::10"},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c := &Checker{Resolver: &barrierResolver{Resolver: z, arrived: make(chan struct{})}, DualLookups: true}
+	if result, err := c.SPFTest(ctx, "2001:db8::10", "info@example.com"); result != Pass {
+		t.Error("Expected", Pass, "got", result, err)
+	}
+}
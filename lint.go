@@ -0,0 +1,88 @@
+package spf
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrWholeInternet   = errors.New("Prefix /0 covers every address.")
+	ErrRedirectIgnored = errors.New("Redirect is never used by a record with an all mechanism.")
+	ErrNoTerminal      = errors.New("Record without an all mechanism or redirect defaults to Neutral, end it with -all or ~all.")
+)
+
+// Lint returns warnings for terms of the record that are valid but most
+// likely mistakes.
+func (s *SPF) Lint() []Warning {
+	var warnings []Warning
+	var all bool
+
+	for _, m := range s.Mechanisms {
+		if m.Result != Fail && zeroPrefix(m) {
+			warnings = append(warnings, Warning{Term: m.SPFString(), Err: ErrWholeInternet})
+		}
+
+		all = all || m.Name == "all"
+	}
+
+	// RFC 7208 section 6.1: the redirect only applies when no all
+	// mechanism is present.
+	if m := s.modifier("redirect"); all && m != nil {
+		warnings = append(warnings, Warning{Term: m.SPFString(), Err: ErrRedirectIgnored})
+	}
+
+	// RFC 7208 section 4.7: clients covered by no mechanism get Neutral,
+	// which owners rarely intend.
+	if !all && s.modifier("redirect") == nil {
+		warnings = append(warnings, Warning{Term: s.SPFString(), Err: ErrNoTerminal})
+	}
+
+	return warnings
+}
+
+// StripIgnoredRedirect removes the redirect modifiers of a record that also
+// has an all mechanism, as they are never used. Each removed modifier is
+// reported as a warning.
+func StripIgnoredRedirect(record string) (string, []Warning) {
+	var warnings []Warning
+
+	fields := strings.Fields(record)
+
+	all := false
+	for _, f := range fields {
+		all = all || strings.EqualFold(strings.TrimLeft(f, "+-~?"), "all")
+	}
+
+	if !all {
+		return record, nil
+	}
+
+	kept := fields[:0]
+	for _, f := range fields {
+		if len(f) > len("redirect=") && strings.EqualFold(f[:len("redirect=")], "redirect=") {
+			warnings = append(warnings, Warning{Term: f, Err: ErrRedirectIgnored})
+			continue
+		}
+
+		kept = append(kept, f)
+	}
+
+	if len(warnings) == 0 {
+		return record, nil
+	}
+
+	return strings.Join(kept, " "), warnings
+}
+
+// zeroPrefix reports whether the mechanism uses a /0 prefix length.
+func zeroPrefix(m Mechanism) bool {
+	switch m.Name {
+	case "ip4", "ip6":
+		return m.Prefix == "0"
+	case "a", "mx":
+		ip4, ip6 := splitCIDR(m.Prefix)
+		return ip4 == "0" || ip6 == "0"
+	}
+
+	return false
+}
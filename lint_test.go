@@ -0,0 +1,137 @@
+package spf
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPrefixBounds(t *testing.T) {
+	tests := []struct {
+		record string
+		err    error
+	}{
+		{"v=spf1 ip4:192.0.2.0/24 -all", nil},
+		{"v=spf1 ip4:192.0.2.0/32 ip6:2001:db8::/128 -all", nil},
+		{"v=spf1 ip4:192.0.2.0/0 ip6:::/0 -all", nil},
+		{"v=spf1 ip4:192.0.2.1/99 -all", ErrInvalidPrefix},
+		{"v=spf1 ip4:192.0.2.1/33 -all", ErrInvalidPrefix},
+		{"v=spf1 ip6:2001:db8::/129 -all", ErrInvalidPrefix},
+		{"v=spf1 ip4:192.0.2.1/024 -all", ErrInvalidPrefix},
+		{"v=spf1 ip4:192.0.2.1/-1 -all", ErrInvalidPrefix},
+		{"v=spf1 a/24 mx/24//64 a//64 -all", nil},
+		{"v=spf1 a/33 -all", ErrInvalidPrefix},
+		{"v=spf1 mx/24//129 -all", ErrInvalidPrefix},
+		{"v=spf1 mx/24// -all", ErrInvalidPrefix},
+		{"v=spf1 include:example.net/24 -all", ErrInvalidPrefix},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.record)
+
+		if _, err := NewSPF("example.com", test.record, 0); err != test.err {
+			t.Error("Expected", test.err, "got", err)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	s, err := NewSPF("example.com", "v=spf1 ip4:192.0.2.0/24 ip4:0.0.0.0/0 -ip6:::/0 a//0 ~all", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warnings := s.Lint()
+	if len(warnings) != 2 {
+		t.Fatal("Expected 2 warnings got", warnings)
+	}
+
+	for i, term := range []string{"ip4:0.0.0.0/0", "a:example.com//0"} {
+		if warnings[i].Term != term || warnings[i].Err != ErrWholeInternet {
+			t.Error("Expected", term, ErrWholeInternet, "got", warnings[i])
+		}
+	}
+}
+
+func TestLintIgnoredRedirect(t *testing.T) {
+	tests := []struct {
+		record   string
+		warnings int
+	}{
+		{"v=spf1 mx -all redirect=_spf.example.com", 1},
+		{"v=spf1 mx redirect=_spf.example.com", 0},
+		{"v=spf1 mx ?all", 0},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.record)
+
+		s, err := NewSPF("example.com", test.record, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		warnings := s.Lint()
+		if len(warnings) != test.warnings {
+			t.Fatal("Expected", test.warnings, "warnings got", warnings)
+		}
+
+		if len(warnings) > 0 && (warnings[0].Term != "redirect=_spf.example.com" || warnings[0].Err != ErrRedirectIgnored) {
+			t.Error("Unexpected warning", warnings[0])
+		}
+	}
+}
+
+func TestStripIgnoredRedirect(t *testing.T) {
+	record, warnings := StripIgnoredRedirect("v=spf1 mx REDIRECT=_spf.example.com ~all")
+	if record != "v=spf1 mx ~all" || len(warnings) != 1 {
+		t.Error("Expected v=spf1 mx ~all got", record, warnings)
+	}
+
+	record, warnings = StripIgnoredRedirect("v=spf1 mx  redirect=_spf.example.com")
+	if record != "v=spf1 mx  redirect=_spf.example.com" || warnings != nil {
+		t.Error("Expected the record unchanged got", record, warnings)
+	}
+
+	c := &Checker{Normalize: true, StripIgnoredRedirect: true}
+	s, warnings, err := c.Parse(context.Background(), "example.com", "v=spf1 -all redirect=_spf.example.com", 0, Strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Mechanisms) != 1 || len(s.Modifiers) != 0 || len(warnings) != 1 || warnings[0].Err != ErrRedirectIgnored {
+		t.Error("Expected the redirect stripped got", s.Modifiers, warnings)
+	}
+}
+
+func TestLintNoTerminal(t *testing.T) {
+	tests := []struct {
+		record string
+		err    error
+	}{
+		{"v=spf1 ip4:192.0.2.0/24", ErrNoTerminal},
+		{"v=spf1", ErrNoTerminal},
+		{"v=spf1 mx exp=explain.example.com", ErrNoTerminal},
+		{"v=spf1 mx ?all", nil},
+		{"v=spf1 mx redirect=_spf.example.com", nil},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.record)
+
+		s, err := NewSPF("example.com", test.record, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var found error
+		for _, w := range s.Lint() {
+			if w.Err == ErrNoTerminal {
+				found = w.Err
+			}
+		}
+
+		if found != test.err {
+			t.Error("Expected", test.err, "got", found)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package spf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidMacro = errors.New("Invalid macro in SPF string.")
+)
+
+// macroContext holds the values macros expand to.
+type macroContext struct {
+	sender string // the full sender identity, e.g. user@example.com
+	domain string // the domain of the record being evaluated
+	ip     net.IP // the client IP address
+	helo   string // the HELO/EHLO domain
+
+	// explain enables the c, r and t macros of explanation strings, r
+	// expanding to receiver.
+	explain  bool
+	receiver string
+}
+
+// hasMacro reports whether the domain-spec contains macros.
+func hasMacro(spec string) bool {
+	return strings.Contains(spec, "%")
+}
+
+// localPart returns the local part of the sender, "postmaster" if empty.
+func (mc macroContext) localPart() string {
+	if i := strings.LastIndex(mc.sender, "@"); i > 0 {
+		return mc.sender[:i]
+	}
+
+	return "postmaster"
+}
+
+// senderDomain returns the domain of the sender.
+func (mc macroContext) senderDomain() string {
+	if i := strings.LastIndex(mc.sender, "@"); i != -1 {
+		return mc.sender[i+1:]
+	}
+
+	return mc.sender
+}
+
+// expand expands the macros of the domain-spec as defined in RFC 7208
+// section 7. Expanded domain names longer than 253 characters are shortened
+// by removing labels from the left.
+func (mc macroContext) expand(spec string) (string, error) {
+	expanded, err := mc.expandString(spec)
+	if err != nil {
+		return "", err
+	}
+
+	for len(expanded) > 253 {
+		dot := strings.IndexByte(expanded, '.')
+		if dot == -1 {
+			return "", ErrInvalidMacro
+		}
+		expanded = expanded[dot+1:]
+	}
+
+	return expanded, nil
+}
+
+// expandString expands the macros of the macro-string, e.g. an explanation.
+func (mc macroContext) expandString(spec string) (string, error) {
+	var buf strings.Builder
+
+	for i := 0; i < len(spec); i++ {
+		if spec[i] != '%' {
+			buf.WriteByte(spec[i])
+			continue
+		}
+
+		i++
+		if i == len(spec) {
+			return "", ErrInvalidMacro
+		}
+
+		switch spec[i] {
+		case '%':
+			buf.WriteByte('%')
+		case '_':
+			buf.WriteByte(' ')
+		case '-':
+			buf.WriteString("%20")
+		case '{':
+			end := strings.IndexByte(spec[i:], '}')
+			if end == -1 {
+				return "", ErrInvalidMacro
+			}
+
+			value, err := mc.macro(spec[i+1 : i+end])
+			if err != nil {
+				return "", err
+			}
+
+			buf.WriteString(value)
+			i += end
+		default:
+			return "", ErrInvalidMacro
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// macro expands the body of a single %{...} macro: a letter, an optional
+// number of parts to keep, an optional "r" to reverse and optional
+// delimiters.
+func (mc macroContext) macro(body string) (string, error) {
+	if body == "" {
+		return "", ErrInvalidMacro
+	}
+
+	letter := body[0]
+	rest := body[1:]
+
+	var value string
+	switch letter | 0x20 {
+	case 's':
+		value = mc.sender
+	case 'l':
+		value = mc.localPart()
+	case 'o':
+		value = mc.senderDomain()
+	case 'd':
+		value = mc.domain
+	case 'i':
+		value = mc.ipString()
+	case 'p':
+		// Validating the client's domain name requires extra lookups and
+		// is discouraged by the RFC.
+		value = "unknown"
+	case 'v':
+		value = "in-addr"
+		if mc.ip != nil && mc.ip.To4() == nil {
+			value = "ip6"
+		}
+	case 'h':
+		value = mc.helo
+	case 'c', 'r', 't':
+		// RFC 7208 section 7.3: only explanations use these.
+		if !mc.explain {
+			return "", ErrInvalidMacro
+		}
+
+		switch letter | 0x20 {
+		case 'c':
+			if mc.ip != nil {
+				value = mc.ip.String()
+			}
+		case 'r':
+			value = mc.receiver
+			if value == "" {
+				value = "unknown"
+			}
+		case 't':
+			value = strconv.FormatInt(time.Now().Unix(), 10)
+		}
+	default:
+		return "", ErrInvalidMacro
+	}
+
+	digits := 0
+	for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+
+	keep := 0
+	if digits > 0 {
+		n, err := strconv.Atoi(rest[:digits])
+		if err != nil || n == 0 || n > 128 {
+			return "", ErrInvalidMacro
+		}
+		keep = n
+	}
+	rest = rest[digits:]
+
+	reverse := false
+	if len(rest) > 0 && (rest[0] == 'r' || rest[0] == 'R') {
+		reverse = true
+		rest = rest[1:]
+	}
+
+	delimiters := "."
+	if rest != "" {
+		if strings.Trim(rest, ".-+,/_=") != "" {
+			return "", ErrInvalidMacro
+		}
+		delimiters = rest
+	}
+
+	parts := strings.FieldsFunc(value, func(r rune) bool {
+		return strings.ContainsRune(delimiters, r)
+	})
+
+	if reverse {
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+	}
+
+	if keep > 0 && keep < len(parts) {
+		parts = parts[len(parts)-keep:]
+	}
+
+	value = strings.Join(parts, ".")
+
+	// Uppercase macro letters are URL escaped.
+	if letter >= 'A' && letter <= 'Z' {
+		value = url.QueryEscape(value)
+		value = strings.ReplaceAll(value, "+", "%20")
+	}
+
+	return value, nil
+}
+
+// ipString returns the client IP in dotted form, nibbles for IPv6.
+func (mc macroContext) ipString() string {
+	if mc.ip == nil {
+		return ""
+	}
+
+	if v4 := mc.ip.To4(); v4 != nil {
+		return v4.String()
+	}
+
+	nibbles := make([]string, 0, 32)
+	for _, b := range mc.ip.To16() {
+		nibbles = append(nibbles, fmt.Sprintf("%x", b>>4), fmt.Sprintf("%x", b&0x0f))
+	}
+
+	return strings.Join(nibbles, ".")
+}
@@ -0,0 +1,150 @@
+package spf
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+type macrotest struct {
+	spec     string
+	expected string
+}
+
+func TestExpandMacros(t *testing.T) {
+	mc := macroContext{
+		sender: "strong-bad@email.example.com",
+		domain: "email.example.com",
+		ip:     net.ParseIP("192.0.2.3"),
+	}
+
+	tests := []macrotest{
+		macrotest{"%{s}", "strong-bad@email.example.com"},
+		macrotest{"%{o}", "email.example.com"},
+		macrotest{"%{d}", "email.example.com"},
+		macrotest{"%{d4}", "email.example.com"},
+		macrotest{"%{d3}", "email.example.com"},
+		macrotest{"%{d2}", "example.com"},
+		macrotest{"%{d1}", "com"},
+		macrotest{"%{dr}", "com.example.email"},
+		macrotest{"%{d2r}", "example.email"},
+		macrotest{"%{l}", "strong-bad"},
+		macrotest{"%{l-}", "strong.bad"},
+		macrotest{"%{lr}", "strong-bad"},
+		macrotest{"%{lr-}", "bad.strong"},
+		macrotest{"%{l1r-}", "strong"},
+		macrotest{"%{ir}.%{v}._spf.%{d2}", "3.2.0.192.in-addr._spf.example.com"},
+		macrotest{"%{lr-}.lp._spf.%{d2}", "bad.strong.lp._spf.example.com"},
+		macrotest{"%{lr-}.lp.%{ir}.%{v}._spf.%{d2}", "bad.strong.lp.3.2.0.192.in-addr._spf.example.com"},
+		macrotest{"%{ir}.%{v}.%{l1r-}.lp._spf.%{d2}", "3.2.0.192.in-addr.strong.lp._spf.example.com"},
+		macrotest{"%{d2}.trusted-domains.example.net", "example.com.trusted-domains.example.net"},
+		macrotest{"%%%_%-", "% %20"},
+	}
+
+	for _, tcase := range tests {
+		actual, err := mc.expand(tcase.spec)
+		if err != nil || actual != tcase.expected {
+			t.Error("For", tcase.spec, "Expected", tcase.expected, "got", actual, err)
+		}
+	}
+
+	mc.ip = net.ParseIP("2001:db8::cb01")
+	expected := "1.0.b.c.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6._spf.example.com"
+	if actual, _ := mc.expand("%{ir}.%{v}._spf.%{d2}"); actual != expected {
+		t.Error("Expected", expected, "got", actual)
+	}
+
+	for _, spec := range []string{"%", "%{", "%{x}", "%{d0}", "%{d2!}", "%a"} {
+		if _, err := mc.expand(spec); err != ErrInvalidMacro {
+			t.Error("For", spec, "Expected", ErrInvalidMacro, "got", err)
+		}
+	}
+}
+
+func TestMacroTargets(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 include:_spf.%{o} a:%{l}.hosts.%{d} mx:mx.%{d} -all"},
+		ZoneRecord{Name: "_spf.example.com", Type: "TXT", Data: "v=spf1 ip4:198.51.100.0/24 a:%{d}.relay.example -all"},
+		ZoneRecord{Name: "_spf.example.com.relay.example", Type: "A", Data: "203.0.113.5"},
+		ZoneRecord{Name: "alice.hosts.example.com", Type: "A", Data: "192.0.2.10"},
+		ZoneRecord{Name: "mx.example.com", Type: "MX", Data: "10 mail.example.com."},
+		ZoneRecord{Name: "mail.example.com", Type: "A", Data: "192.0.2.25"},
+	)
+
+	c := &Checker{Resolver: z}
+
+	tests := []spftest{
+		spftest{"192.0.2.10", "alice@example.com", Pass},
+		spftest{"192.0.2.25", "bob@example.com", Pass},
+		spftest{"198.51.100.5", "bob@example.com", Pass},
+		spftest{"203.0.113.5", "bob@example.com", Pass},
+		spftest{"192.0.2.10", "bob@example.com", Fail},
+	}
+
+	for _, expected := range tests {
+		trace, _ := c.Trace(context.Background(), expected.server, expected.email)
+
+		if trace.Result != expected.result {
+			t.Error("For", expected.server, "at", expected.email, "Expected", expected.result, "got", trace.Result)
+			t.Log(trace)
+		}
+	}
+}
+
+func TestExists(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 exists:%{ir}.%{l1r-}.%{d}.allow.example -all"},
+		ZoneRecord{Name: "1.2.0.192.alice.example.com.allow.example", Type: "A", Data: "127.0.0.2"},
+		ZoneRecord{Name: "2.2.0.192.bob.example.com.allow.example", Type: "AAAA", Data: "2001:db8::2"},
+	)
+
+	c := &Checker{Resolver: z}
+
+	tests := []spftest{
+		spftest{"192.0.2.1", "alice@example.com", Pass},
+		spftest{"192.0.2.1", "bob@example.com", Fail},
+		spftest{"192.0.2.2", "bob@example.com", Fail},
+	}
+
+	for _, expected := range tests {
+		actual, _ := c.SPFTest(context.Background(), expected.server, expected.email)
+
+		if actual != expected.result {
+			t.Error("For", expected.server, "at", expected.email, "Expected", expected.result, "got", actual)
+		}
+	}
+}
+
+func TestIdentityMacros(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 exists:%{l}.%{o}.%{h}.allow.example -all"},
+		ZoneRecord{Name: "mail.example.com", Type: "TXT", Data: "v=spf1 exists:%{l}.%{o}.%{h}.allow.example -all"},
+		ZoneRecord{Name: "alice.example.com.mail.example.com.allow.example", Type: "A", Data: "127.0.0.2"},
+		ZoneRecord{Name: "postmaster.mail.example.com.mail.example.com.allow.example", Type: "A", Data: "127.0.0.2"},
+	)
+
+	c := &Checker{Resolver: z}
+
+	tests := []struct {
+		sender string
+		helo   string
+		result Result
+	}{
+		{"alice@example.com", "mail.example.com", Pass},
+		{"bob@example.com", "mail.example.com", Fail},
+		{"alice@example.com", "other.example.com", Fail},
+		{"", "mail.example.com", Pass},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.sender, test.helo)
+
+		cr := c.Check(context.Background(), Request{IP: "192.0.2.1", Sender: test.sender, Helo: test.helo})
+		if cr.Result != test.result {
+			t.Error("Expected", test.result, "got", cr.Result, cr.Err)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package spf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// Delivery is a message seen in mail logs or Received header fields: the
+// address of the SMTP client, its HELO name and the MAIL FROM address, empty
+// for the null sender.
+type Delivery struct {
+	Addr   netip.Addr
+	Sender string
+	Helo   string
+}
+
+// domain returns the domain whose record the delivery is checked against,
+// the HELO name for the null sender, RFC 7208 section 2.4.
+func (d Delivery) domain() string {
+	if i := strings.LastIndex(d.Sender, "@"); i != -1 {
+		return strings.ToLower(strings.TrimSuffix(d.Sender[i+1:], "."))
+	}
+
+	if d.Sender == "" {
+		return strings.ToLower(strings.TrimSuffix(d.Helo, "."))
+	}
+
+	return ""
+}
+
+// ParseReceived parses the value of a Received header field added by a
+// receiving MTA, such as:
+//
+//	from mail.example.com (mail.example.com [192.0.2.1]) by mx.example.net
+//	    (envelope-from <info@example.com>) with ESMTP id 4F2A5; ...
+//
+// The "Received:" field name may be included. The client address is the
+// first bracketed address of the from clause, the sender the envelope-from
+// comment if there is one. ErrInvalidHeader is returned if the field names
+// no client address.
+func ParseReceived(header string) (Delivery, error) {
+	var d Delivery
+
+	value := unfold(header)
+	if i := strings.Index(value, ":"); i != -1 && strings.EqualFold(strings.TrimSpace(value[:i]), "Received") {
+		value = value[i+1:]
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], "from") {
+		return d, ErrInvalidHeader
+	}
+	d.Helo = fields[1]
+
+	from := value
+	if i := strings.Index(from, " by "); i != -1 {
+		from = from[:i]
+	}
+
+	addr, ok := bracketedAddr(from)
+	if !ok {
+		return d, ErrInvalidHeader
+	}
+	d.Addr = addr
+
+	if i := strings.Index(strings.ToLower(value), "envelope-from "); i != -1 {
+		d.Sender = angleAddr(value[i+len("envelope-from "):])
+	}
+
+	return d, nil
+}
+
+// ReadMailLog reads the deliveries logged by an MTA, recognizing the
+// formats of Postfix, whose client= and from= lines are joined by queue ID,
+// Sendmail and Exim. Other lines are skipped.
+func ReadMailLog(r io.Reader) ([]Delivery, error) {
+	var deliveries []Delivery
+	clients := make(map[string]Delivery)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.Contains(line, " <= "):
+			// Exim: <= sender H=name (helo) [addr]
+			if d, ok := eximDelivery(line); ok {
+				deliveries = append(deliveries, d)
+			}
+		case strings.Contains(line, "from=<") && strings.Contains(line, "relay="):
+			// Sendmail: from=<sender>, ..., relay=name [addr]
+			d := Delivery{Sender: angleAddr(line[strings.Index(line, "from=<")+len("from="):])}
+			if addr, ok := bracketedAddr(line[strings.Index(line, "relay="):]); ok {
+				d.Addr = addr
+				deliveries = append(deliveries, d)
+			}
+		case strings.Contains(line, ": client="):
+			// Postfix smtpd: QUEUEID: client=name[addr]
+			id := queueID(line, ": client=")
+			if addr, ok := bracketedAddr(line[strings.Index(line, ": client="):]); ok && id != "" {
+				clients[id] = Delivery{Addr: addr}
+			}
+		case strings.Contains(line, ": from=<"):
+			// Postfix qmgr: QUEUEID: from=<sender>, size=...
+			id := queueID(line, ": from=<")
+			if d, ok := clients[id]; ok {
+				d.Sender = angleAddr(line[strings.Index(line, ": from=<")+len(": from="):])
+				deliveries = append(deliveries, d)
+				delete(clients, id)
+			}
+		}
+	}
+
+	return deliveries, scanner.Err()
+}
+
+// eximDelivery parses an Exim message arrival line.
+func eximDelivery(line string) (Delivery, bool) {
+	var d Delivery
+
+	fields := strings.Fields(line[strings.Index(line, " <= ")+len(" <= "):])
+	if len(fields) == 0 {
+		return d, false
+	}
+
+	if fields[0] != "<>" {
+		d.Sender = fields[0]
+	}
+
+	for i, f := range fields {
+		if !strings.HasPrefix(f, "H=") {
+			continue
+		}
+
+		d.Helo = strings.TrimPrefix(f, "H=")
+		if i+1 < len(fields) && strings.HasPrefix(fields[i+1], "(") {
+			d.Helo = strings.Trim(fields[i+1], "()")
+		}
+
+		addr, ok := bracketedAddr(strings.Join(fields[i:], " "))
+		d.Addr = addr
+
+		return d, ok
+	}
+
+	return d, false
+}
+
+// queueID returns the Postfix queue ID preceding the marker in the line.
+func queueID(line, marker string) string {
+	prefix := line[:strings.Index(line, marker)]
+
+	return prefix[strings.LastIndexByte(prefix, ' ')+1:]
+}
+
+// bracketedAddr returns the first address between square brackets in s, in
+// the "[192.0.2.1]" or "[IPv6:2001:db8::1]" forms.
+func bracketedAddr(s string) (netip.Addr, bool) {
+	for {
+		start := strings.IndexByte(s, '[')
+		if start == -1 {
+			return netip.Addr{}, false
+		}
+
+		end := strings.IndexByte(s[start:], ']')
+		if end == -1 {
+			return netip.Addr{}, false
+		}
+
+		text := s[start+1 : start+end]
+		if len(text) > 5 && strings.EqualFold(text[:5], "IPv6:") {
+			text = text[5:]
+		}
+
+		if addr, err := netip.ParseAddr(text); err == nil {
+			return addr.Unmap(), true
+		}
+
+		s = s[start+end:]
+	}
+}
+
+// angleAddr returns the address between the angle brackets starting s,
+// empty for the null sender or a malformed address.
+func angleAddr(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "<") {
+		return ""
+	}
+
+	end := strings.IndexByte(s, '>')
+	if end == -1 {
+		return ""
+	}
+
+	return s[1:end]
+}
+
+// GapReport cross-checks deliveries against the published records of their
+// sender domains, with the Suggestion for each domain by decreasing volume.
+// Err holds why a domain could not be checked, its Suggestion then only
+// holds the Domain and Total volume.
+type GapReport struct {
+	Domains []DomainGap
+}
+
+// DomainGap is the outcome of the cross-check for a sender domain.
+type DomainGap struct {
+	*Suggestion
+	Err error
+}
+
+// AnalyzeDeliveries cross-checks the deliveries using the DefaultChecker.
+// See Checker.AnalyzeDeliveries.
+func AnalyzeDeliveries(ctx context.Context, deliveries []Delivery, minVolume int) *GapReport {
+	return DefaultChecker.AnalyzeDeliveries(ctx, deliveries, minVolume)
+}
+
+// AnalyzeDeliveries groups the deliveries by the domain of their sender, or
+// HELO name for the null sender, and calls Suggest for each domain with one
+// message per delivery. Deliveries without a domain are skipped.
+func (c *Checker) AnalyzeDeliveries(ctx context.Context, deliveries []Delivery, minVolume int) *GapReport {
+	observed := make(map[string][]Observation)
+	for _, d := range deliveries {
+		if domain := d.domain(); domain != "" && d.Addr.IsValid() {
+			observed[domain] = append(observed[domain], Observation{Addr: d.Addr, Volume: 1})
+		}
+	}
+
+	report := &GapReport{}
+	for domain, observations := range observed {
+		s, err := c.Suggest(ctx, domain, observations, minVolume)
+		if err != nil {
+			s = &Suggestion{Domain: domain, Total: len(observations)}
+		}
+
+		report.Domains = append(report.Domains, DomainGap{Suggestion: s, Err: err})
+	}
+
+	sort.Slice(report.Domains, func(i, j int) bool {
+		a, b := report.Domains[i], report.Domains[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Domain < b.Domain
+	})
+
+	return report
+}
+
+// Return a GapReport as text, one line per domain followed by its uncovered
+// senders and the suggested record when it differs from the published one.
+func (r *GapReport) String() string {
+	var buf bytes.Buffer
+
+	for _, d := range r.Domains {
+		if d.Err != nil {
+			buf.WriteString(fmt.Sprintf("%s: %d messages: %s\n", d.Domain, d.Total, d.Err))
+			continue
+		}
+
+		buf.WriteString(fmt.Sprintf("%s: %d of %d messages covered\n", d.Domain, d.Covered, d.Total))
+		for _, gap := range d.Uncovered {
+			buf.WriteString(fmt.Sprintf("  %s: %d (%s)\n", gap.Addr, gap.Volume, gap.Result))
+		}
+
+		if suggested := d.Record.SPFString(); suggested != d.Published {
+			buf.WriteString(fmt.Sprintf("  suggested: %s\n", suggested))
+		}
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,107 @@
+package spf
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestParseReceived(t *testing.T) {
+	tests := []struct {
+		header   string
+		delivery Delivery
+		err      error
+	}{
+		{
+			"Received: from mail.example.com (mail.example.com [192.0.2.1])\r\n\tby mx.example.net (envelope-from <info@example.com>) with ESMTP id 4F2A5",
+			Delivery{netip.MustParseAddr("192.0.2.1"), "info@example.com", "mail.example.com"},
+			nil,
+		},
+		{
+			"from helo.example.org ([IPv6:2001:db8::25]) by mx.example.net [198.51.100.1] with ESMTPS",
+			Delivery{netip.MustParseAddr("2001:db8::25"), "", "helo.example.org"},
+			nil,
+		},
+		{"from localhost by mx.example.net [198.51.100.1]", Delivery{}, ErrInvalidHeader},
+		{"by mx.example.net with LMTP", Delivery{}, ErrInvalidHeader},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.header)
+
+		d, err := ParseReceived(test.header)
+		if err != test.err {
+			t.Error("Expected", test.err, "got", err)
+		}
+
+		if err == nil && d != test.delivery {
+			t.Error("Expected", test.delivery, "got", d)
+		}
+	}
+}
+
+func TestReadMailLog(t *testing.T) {
+	log := `Oct 14 10:00:00 mx postfix/smtpd[1234]: 4F2A51234: client=mail.example.com[192.0.2.1]
+Oct 14 10:00:00 mx postfix/cleanup[1235]: 4F2A51234: message-id=<1@example.com>
+Oct 14 10:00:01 mx postfix/qmgr[99]: 4F2A51234: from=<info@example.com>, size=1234, nrcpt=1 (queue active)
+Oct 14 10:00:02 mx postfix/qmgr[99]: 5B3C6: from=<root@mx.example.net>, size=300, nrcpt=1 (queue active)
+Oct 14 10:00:03 mx sm-mta[42]: x9EA0003: from=<news@example.org>, size=10, class=0, nrcpts=1, proto=ESMTP, daemon=MTA, relay=out.example.org [198.51.100.7]
+2026-10-14 10:00:04 1tA2b3-0001-XX <= <> H=bounce.example.net (helo.example.net) [2001:db8::9] P=esmtp S=900
+2026-10-14 10:00:05 1tA2b4-0001-XX <= alerts@example.com H=[203.0.113.5] P=esmtp S=100
+unrelated line`
+
+	deliveries, err := ReadMailLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Delivery{
+		{netip.MustParseAddr("192.0.2.1"), "info@example.com", ""},
+		{netip.MustParseAddr("198.51.100.7"), "news@example.org", ""},
+		{netip.MustParseAddr("2001:db8::9"), "", "helo.example.net"},
+		{netip.MustParseAddr("203.0.113.5"), "alerts@example.com", "[203.0.113.5]"},
+	}
+
+	if len(deliveries) != len(expected) {
+		t.Fatal("Expected", expected, "got", deliveries)
+	}
+
+	for i, d := range deliveries {
+		if d != expected[i] {
+			t.Error("Expected", expected[i], "got", d)
+		}
+	}
+}
+
+func TestAnalyzeDeliveries(t *testing.T) {
+	c := &Checker{Source: MapSource{
+		"example.com": "v=spf1 ip4:192.0.2.0/24 -all",
+		"example.org": "v=spf1 include:missing.example -all",
+	}}
+
+	deliveries := []Delivery{
+		{netip.MustParseAddr("192.0.2.1"), "info@example.com", ""},
+		{netip.MustParseAddr("192.0.2.2"), "info@Example.COM", ""},
+		{netip.MustParseAddr("203.0.113.5"), "alerts@example.com", ""},
+		{netip.MustParseAddr("198.51.100.7"), "news@example.org", ""},
+		{netip.MustParseAddr("2001:db8::9"), "", "helo.example.net"},
+		{netip.MustParseAddr("2001:db8::10"), "postmaster", ""},
+	}
+
+	report := c.AnalyzeDeliveries(context.Background(), deliveries, 0)
+
+	expected := `example.com: 2 of 3 messages covered
+  203.0.113.5: 1 (Fail)
+  suggested: v=spf1 ip4:192.0.2.0/24 ip4:203.0.113.5 -all
+example.org: 0 of 1 messages covered
+  198.51.100.7: 1 (PermError)
+helo.example.net: 0 of 1 messages covered
+  2001:db8::9: 1 (None)
+  suggested: v=spf1 ip6:2001:db8::9 ~all
+`
+
+	if report.String() != expected {
+		t.Error("Expected", expected, "got", report.String())
+	}
+}
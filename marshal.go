@@ -0,0 +1,57 @@
+package spf
+
+import (
+	"context"
+)
+
+// MarshalText implements encoding.TextMarshaler using the canonical SPF
+// string form of the record.
+func (s SPF) MarshalText() ([]byte, error) {
+	return []byte(s.SPFString()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. The text must be a valid
+// SPF record. Mechanisms without a domain default to the Domain already set
+// on s, if any.
+func (s *SPF) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		return ErrInvalidSPF
+	}
+
+	spf, err := DefaultChecker.NewSPF(context.Background(), s.Domain, string(text), 0)
+	if err != nil {
+		return err
+	}
+
+	*s = spf
+
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler using the form the mechanism
+// takes in a TXT record.
+func (m Mechanism) MarshalText() ([]byte, error) {
+	return []byte(m.SPFString()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. The text must be a valid
+// mechanism. When it does not define a domain, the Domain already set on m
+// is kept.
+func (m *Mechanism) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		return ErrInvalidMechanism
+	}
+
+	mechanism, err := NewMechanism(string(text), m.Domain)
+	if err != nil {
+		return err
+	}
+
+	if !mechanism.Valid() {
+		return ErrInvalidMechanism
+	}
+
+	*m = mechanism
+
+	return nil
+}
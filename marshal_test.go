@@ -0,0 +1,51 @@
+package spf
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type marshalConfig struct {
+	Record    SPF       `json:"record"`
+	Mechanism Mechanism `json:"mechanism"`
+}
+
+func TestMarshalText(t *testing.T) {
+	data := `{"record":"v=spf1 ip4:192.0.2.0/24 -ip4:192.0.2.1 include:_spf.example.com ~all","mechanism":"-ip6:2001:db8::/32"}`
+
+	var config marshalConfig
+	if err := json.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Mechanism.Name != "ip6" || config.Mechanism.Result != Fail || config.Mechanism.Prefix != "32" {
+		t.Error("Unexpected mechanism", config.Mechanism.String())
+	}
+
+	if len(config.Record.Mechanisms) != 4 {
+		t.Error("Expected 4 mechanisms got", len(config.Record.Mechanisms))
+	}
+
+	out, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != data {
+		t.Error("Expected", data, "got", string(out))
+	}
+
+	invalid := []string{
+		`{"record":""}`,
+		`{"record":"include:example.com"}`,
+		`{"mechanism":"foo:bar"}`,
+		`{"mechanism":"ip4:"}`,
+	}
+
+	for _, tcase := range invalid {
+		if err := json.Unmarshal([]byte(tcase), &config); err == nil {
+			t.Log("Analyzing", tcase)
+			t.Error("Expected error got nil")
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrNotPrecompilable = errors.New("Record requires lookups for each client.")
+)
+
+// Matcher matches clients against an SPF record whose DNS data is resolved
+// ahead of time, when the Matcher is built and whenever it is refreshed.
+// Match performs no lookups, which separates the slow resolve phase from the
+// per-message phase of a mail filter. A Matcher is safe for concurrent use,
+// including while it is being refreshed.
+type Matcher struct {
+	// OnError is called by Run when refreshing the Matcher fails. The
+	// previous state is kept in use.
+	OnError func(err error)
+
+	checker *Checker
+	spf     SPF
+	fetch   bool
+
+	state atomic.Value // *matcherState
+}
+
+// matcherState is the resolved form of the record, replaced as a whole on
+// each refresh.
+type matcherState struct {
+	flat  *Flattened
+	terms []matcherTerm
+}
+
+// matcherTerm is a single network, or the all mechanism when all is set,
+// and the flattened term it was compiled from.
+type matcherTerm struct {
+	network   netip.Prefix
+	all       bool
+	result    Result
+	mechanism Mechanism
+}
+
+// NewMatcher builds a Matcher for the SPF record of the domain using the
+// DefaultChecker.
+func NewMatcher(ctx context.Context, domain string) (*Matcher, error) {
+	return DefaultChecker.NewMatcher(ctx, domain)
+}
+
+// NewMatcher builds a Matcher for the SPF record of the domain. The record is
+// fetched again on each refresh.
+func (c *Checker) NewMatcher(ctx context.Context, domain string) (*Matcher, error) {
+	m := &Matcher{checker: c, spf: SPF{Domain: domain}, fetch: true}
+
+	if err := m.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Compile builds a Matcher for the given record, resolving the include,
+// redirect, a and mx terms once. Records with ptr or exists terms, whose
+// lookups depend on the client, return ErrNotPrecompilable.
+func (c *Checker) Compile(ctx context.Context, spf SPF) (*Matcher, error) {
+	m := &Matcher{checker: c, spf: spf}
+
+	if err := m.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Match returns the result of the record for the client address, None if
+// the address is invalid like SPFTest.
+func (m *Matcher) Match(ip string) Result {
+	addr, err := parseIP(ip)
+	if err != nil {
+		return None
+	}
+
+	if t := m.match(addr); t != nil {
+		return t.result
+	}
+
+	return Neutral
+}
+
+// WhichMechanism returns the term of the flattened record matching the
+// client address, whose Origin tells the published term and include chain
+// it comes from. It returns false when the address is invalid or no term
+// matches and the result is Neutral.
+func (m *Matcher) WhichMechanism(ip string) (Mechanism, bool) {
+	addr, err := parseIP(ip)
+	if err != nil {
+		return Mechanism{}, false
+	}
+
+	if t := m.match(addr); t != nil {
+		return t.mechanism, true
+	}
+
+	return Mechanism{}, false
+}
+
+// match returns the first term matching the client address.
+func (m *Matcher) match(addr netip.Addr) *matcherTerm {
+	addr = addr.Unmap()
+
+	terms := m.load().terms
+	for i := range terms {
+		if terms[i].all || terms[i].network.Contains(addr) {
+			return &terms[i]
+		}
+	}
+
+	return nil
+}
+
+// Record returns the flattened record the Matcher currently matches against.
+func (m *Matcher) Record() *Flattened {
+	return m.load().flat
+}
+
+func (m *Matcher) load() *matcherState {
+	return m.state.Load().(*matcherState)
+}
+
+// Refresh resolves the record again and atomically replaces the state used
+// by Match. On error the previous state is kept.
+func (m *Matcher) Refresh(ctx context.Context) error {
+	var flat *Flattened
+	var err error
+
+	if m.fetch {
+		flat, err = m.checker.Flatten(ctx, m.spf.Domain)
+	} else {
+		f := flattener{ctx: ctx, checker: m.checker, visited: make(map[string]bool)}
+		flat, err = f.result(m.spf)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	state, err := compileMatcher(flat)
+	if err != nil {
+		return err
+	}
+
+	m.state.Store(state)
+
+	return nil
+}
+
+// Run refreshes the Matcher whenever its record may be stale, but no more
+// often than MinRefreshInterval, until the context is cancelled. It returns
+// the context's error.
+func (m *Matcher) Run(ctx context.Context) error {
+	timer := time.NewTimer(m.wait())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if err := m.Refresh(ctx); err != nil && m.OnError != nil && ctx.Err() == nil {
+			m.OnError(err)
+		}
+
+		timer.Reset(m.wait())
+	}
+}
+
+// wait returns the delay before the next refresh.
+func (m *Matcher) wait() time.Duration {
+	wait := time.Until(m.Record().RefreshAfter)
+	if wait < MinRefreshInterval {
+		wait = MinRefreshInterval
+	}
+
+	return wait
+}
+
+// compileMatcher converts the terms of the flattened record into networks.
+func compileMatcher(flat *Flattened) (*matcherState, error) {
+	state := &matcherState{flat: flat}
+
+	for _, mech := range flat.Mechanisms {
+		t := matcherTerm{result: mech.Result, mechanism: mech}
+
+		switch mech.Name {
+		case "all":
+			t.all = true
+		case "ip4", "ip6":
+			// Like in an evaluation, a network that cannot match any
+			// client is skipped.
+			network, ok := ipMechanismNetwork(&mech)
+			if !ok {
+				continue
+			}
+			t.network = network
+		default:
+			return nil, ErrNotPrecompilable
+		}
+
+		state.terms = append(state.terms, t)
+
+		// Terms after all are never reached.
+		if t.all {
+			break
+		}
+	}
+
+	return state, nil
+}
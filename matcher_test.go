@@ -0,0 +1,168 @@
+package spf
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 -ip4:192.0.2.66 include:_spf.example.com mx ~all"},
+		ZoneRecord{Name: "_spf.example.com", Type: "TXT", Data: "v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 -all"},
+		ZoneRecord{Name: "example.com", Type: "MX", Data: "10 mx.example.com"},
+		ZoneRecord{Name: "mx.example.com", Type: "A", Data: "198.51.100.25"},
+	)
+
+	r := &countingResolver{Resolver: z}
+	c := &Checker{Resolver: r}
+
+	m, err := c.NewMatcher(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lookups := len(r.count)
+
+	tests := []spftest{
+		spftest{"192.0.2.1", "", Pass},
+		spftest{"192.0.2.66", "", Fail},
+		spftest{"::ffff:192.0.2.1", "", Pass},
+		spftest{"2001:db8::1", "", Pass},
+		spftest{"198.51.100.25", "", Pass},
+		spftest{"203.0.113.1", "", SoftFail},
+		spftest{"bogus", "", None},
+	}
+
+	for _, expected := range tests {
+		t.Log("Analyzing", expected.server)
+
+		result := m.Match(expected.server)
+		if result != expected.result {
+			t.Error("Expected", expected.result, "got", result)
+		}
+	}
+
+	if len(r.count) != lookups {
+		t.Error("Expected no lookups from Match got", len(r.count)-lookups)
+	}
+
+	mech, ok := m.WhichMechanism("192.0.2.1")
+	if !ok || mech.SPFString() != "ip4:192.0.2.0/24" || mech.Origin() == nil || mech.Origin().Domain != "_spf.example.com" {
+		t.Error("Expected ip4:192.0.2.0/24 from _spf.example.com got", mech.SPFString(), mech.Origin())
+	}
+
+	mech, ok = m.WhichMechanism("198.51.100.25")
+	if !ok || mech.Origin().Mechanism != "mx:example.com" {
+		t.Error("Expected a term from mx got", mech.Origin())
+	}
+}
+
+func TestMatcherRefresh(t *testing.T) {
+	source := MapSource{"example.com": "v=spf1 ip4:192.0.2.0/24 -all"}
+	c := &Checker{Source: source}
+	ctx := context.Background()
+
+	m, err := c.NewMatcher(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := m.Match("198.51.100.1"); result != Fail {
+		t.Error("Expected", Fail, "got", result)
+	}
+
+	source["example.com"] = "v=spf1 ip4:198.51.100.0/24 -all"
+	if err := m.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := m.Match("198.51.100.1"); result != Pass {
+		t.Error("Expected", Pass, "got", result)
+	}
+
+	// A failed refresh keeps the previous state.
+	source["example.com"] = "v=spf1 ip4:198.51.100.0/24 include:nowhere.example.com -all"
+	if err := m.Refresh(ctx); err == nil {
+		t.Error("Expected an error got", err)
+	}
+
+	if result := m.Match("198.51.100.1"); result != Pass {
+		t.Error("Expected", Pass, "got", result)
+	}
+}
+
+func TestMatcherAgrees(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(nestedZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Resolver: z}
+	ips := []string{"192.0.2.1", "192.0.2.5", "192.0.2.65", "198.51.100.1", "198.51.100.200", "203.0.113.1", "::ffff:203.0.113.1", "2001:db8::1", "2001:db8::2", "2001:db9::1", "bogus", ""}
+
+	for _, domain := range []string{"open.example", "excluded.example", "closed.example"} {
+		m, err := c.NewMatcher(context.Background(), domain)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, ip := range ips {
+			t.Log("Analyzing", domain, ip)
+
+			expected, _ := c.SPFTest(context.Background(), ip, "info@"+domain)
+			if actual := m.Match(ip); actual != expected {
+				t.Error("Expected", expected, "got", actual)
+			}
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	c := &Checker{Source: MapSource{}}
+
+	tests := []struct {
+		record string
+		err    error
+	}{
+		{"v=spf1 ip4:192.0.2.0/24 -all", nil},
+		{"v=spf1 ip6:2001:db8::/32 ?all", nil},
+		{"v=spf1 ptr -all", ErrNotPrecompilable},
+		{"v=spf1 exists:%{i}.example.com -all", ErrNotPrecompilable},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.record)
+
+		s, err := c.NewSPF(context.Background(), "example.com", test.record, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.Compile(context.Background(), s)
+		if err != test.err {
+			t.Error("Expected", test.err, "got", err)
+		}
+	}
+}
+
+func TestMatcherAllocations(t *testing.T) {
+	s, err := NewSPF("example.com", "v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 -all", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := DefaultChecker.Compile(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		m.Match("2001:db8::1")
+	})
+
+	if allocs != 0 {
+		t.Error("Expected no allocations got", allocs)
+	}
+}
@@ -0,0 +1,115 @@
+package spf
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// MatcherCache shares compiled Matchers between the domains publishing the
+// same record, as is common with the boilerplate records of email service
+// providers. Matchers are keyed by a hash of the record text, and of the
+// domain too when the record resolves differently for each domain, e.g.
+// through a bare a or mx term or a macro. The zero value is ready to use. A
+// MatcherCache must not be copied after first use.
+type MatcherCache struct {
+	// Checker fetches and compiles the records. If nil, DefaultChecker is
+	// used.
+	Checker *Checker
+
+	mu       sync.Mutex
+	matchers map[[sha256.Size]byte]*Matcher
+	group    singleflight.Group
+}
+
+func (mc *MatcherCache) checker() *Checker {
+	if mc.Checker == nil {
+		return DefaultChecker
+	}
+
+	return mc.Checker
+}
+
+// Matcher returns the Matcher for the record of the domain, compiling it if
+// no domain with the same record was seen yet or the cached Matcher is stale.
+// Matchers shared between domains report the domain they were first
+// compiled for in their Record.
+func (mc *MatcherCache) Matcher(ctx context.Context, domain string) (*Matcher, error) {
+	c := mc.checker()
+
+	spf, err := c.NewSPF(ctx, domain, "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	key := matcherKey(spf)
+
+	mc.mu.Lock()
+	m := mc.matchers[key]
+	mc.mu.Unlock()
+
+	if m != nil && !m.Record().Stale() {
+		return m, nil
+	}
+
+	v, err := share(ctx, &mc.group, string(key[:]), func(ctx context.Context) (interface{}, error) {
+		m, err := c.Compile(ctx, spf)
+		if err != nil {
+			return nil, err
+		}
+
+		mc.mu.Lock()
+		if mc.matchers == nil {
+			mc.matchers = make(map[[sha256.Size]byte]*Matcher)
+		}
+		mc.matchers[key] = m
+		mc.mu.Unlock()
+
+		return m, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*Matcher), nil
+}
+
+// Len returns the number of distinct compiled Matchers.
+func (mc *MatcherCache) Len() int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	return len(mc.matchers)
+}
+
+// Flush removes all Matchers from the cache.
+func (mc *MatcherCache) Flush() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.matchers = nil
+}
+
+// matcherKey hashes the record text, adding the domain if the record
+// depends on it.
+func matcherKey(spf SPF) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(spf.Raw))
+
+	for _, m := range spf.terms() {
+		implicit := m.implicit && (m.Name == "a" || m.Name == "mx" || m.Name == "ptr")
+		if implicit || hasMacro(m.Domain) {
+			h.Write([]byte{0})
+			h.Write([]byte(canonicalName(spf.Domain)))
+			break
+		}
+	}
+
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+
+	return key
+}
@@ -0,0 +1,124 @@
+package spf
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMatcherCache(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "one.example", Type: "TXT", Data: "v=spf1 include:_spf.esp.example -all"},
+		ZoneRecord{Name: "two.example", Type: "TXT", Data: "v=spf1 include:_spf.esp.example -all"},
+		ZoneRecord{Name: "three.example", Type: "TXT", Data: "v=spf1 mx -all"},
+		ZoneRecord{Name: "four.example", Type: "TXT", Data: "v=spf1 mx -all"},
+		ZoneRecord{Name: "_spf.esp.example", Type: "TXT", Data: "v=spf1 ip4:192.0.2.0/24 -all"},
+		ZoneRecord{Name: "three.example", Type: "MX", Data: "10 mx.three.example"},
+		ZoneRecord{Name: "mx.three.example", Type: "A", Data: "198.51.100.3"},
+		ZoneRecord{Name: "four.example", Type: "MX", Data: "10 mx.four.example"},
+		ZoneRecord{Name: "mx.four.example", Type: "A", Data: "198.51.100.4"},
+	)
+
+	mc := &MatcherCache{Checker: &Checker{Resolver: z}}
+	ctx := context.Background()
+
+	one, err := mc.Matcher(ctx, "one.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	two, err := mc.Matcher(ctx, "two.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if one != two {
+		t.Error("Expected identical records to share a Matcher")
+	}
+
+	if result := two.Match("192.0.2.1"); result != Pass {
+		t.Error("Expected", Pass, "got", result)
+	}
+
+	// A bare mx term resolves differently for each domain.
+	three, err := mc.Matcher(ctx, "three.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	four, err := mc.Matcher(ctx, "four.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if three == four {
+		t.Error("Expected domain dependent records not to share a Matcher")
+	}
+
+	if result := four.Match("198.51.100.4"); result != Pass {
+		t.Error("Expected", Pass, "got", result)
+	}
+
+	if mc.Len() != 3 {
+		t.Error("Expected 3 got", mc.Len())
+	}
+
+	mc.Flush()
+	if mc.Len() != 0 {
+		t.Error("Expected 0 got", mc.Len())
+	}
+}
+
+func TestMatcherCacheCancel(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+
+	source := RecordSourceFunc(func(ctx context.Context, domain string) (string, error) {
+		if domain != "_spf.esp.example" {
+			return "v=spf1 include:_spf.esp.example -all", nil
+		}
+
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return "v=spf1 ip4:192.0.2.0/24 -all", ctx.Err()
+	})
+
+	mc := &MatcherCache{Checker: &Checker{Source: source}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := mc.Matcher(ctx, "one.example")
+		first <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	second := make(chan *Matcher)
+	go func() {
+		m, err := mc.Matcher(context.Background(), "two.example")
+		if err != nil {
+			t.Error("Expected", nil, "got", err)
+		}
+		second <- m
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-first; err != context.Canceled {
+		t.Error("Expected", context.Canceled, "got", err)
+	}
+
+	close(release)
+
+	if m := <-second; m == nil || m.Match("192.0.2.1") != Pass {
+		t.Error("Expected a Matcher passing 192.0.2.1")
+	}
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Error("Expected a single compilation got", n)
+	}
+}
@@ -2,9 +2,11 @@ package spf
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net"
+	"net/netip"
 	"strings"
 )
 
@@ -21,7 +23,9 @@ const (
 )
 
 var (
-	ErrNoMatch = errors.New("Client was not covered by the mechanism.")
+	ErrNoMatch       = errors.New("Client was not covered by the mechanism.")
+	ErrAddressFamily = errors.New("Address family does not match mechanism.")
+	ErrInvalidPrefix = errors.New("Invalid CIDR prefix length.")
 )
 
 // Mechanism represents a single mechanism in an SPF record.
@@ -31,6 +35,15 @@ type Mechanism struct {
 	Prefix string
 	Result Result
 	Count  int
+
+	// implicit is set when the record did not give the domain, which
+	// defaulted to the domain of the record. plus is set when the Pass
+	// qualifier was spelled out. Both only affect SPFString.
+	implicit bool
+	plus     bool
+
+	// origin is set on the terms of flattened records, see Origin.
+	origin *Origin
 }
 
 // Return a Mechanism as a string
@@ -71,61 +84,154 @@ func (m *Mechanism) ResultTag() string {
 // SPFString return a string representation of a mechanism, suitable for using
 // in a TXT record.
 func (m *Mechanism) SPFString() string {
-	var buf bytes.Buffer
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	m.writeSPF(buf, false)
+
+	return buf.String()
+}
 
+// writeSPF writes the mechanism as SPFString does, leaving out the domain if
+// omitDomain is set.
+func (m *Mechanism) writeSPF(buf *bytes.Buffer, omitDomain bool) {
 	tag := m.ResultTag()
 
-	switch m.Name {
-	case "redirect":
-		buf.WriteString(fmt.Sprintf("%s=%s", m.Name, m.Domain))
-	case "all":
-		buf.WriteString(fmt.Sprintf("%s%s", tag, m.Name))
+	switch {
+	case isModifier(m.Name):
+		buf.WriteString(m.Name)
+		buf.WriteByte('=')
+		buf.WriteString(m.Domain)
+	case m.Name == "all":
+		if tag != "+" || m.plus {
+			buf.WriteString(tag)
+		}
+
+		buf.WriteString(m.Name)
 	default:
-		if tag != "+" {
+		if tag != "+" || m.plus {
 			buf.WriteString(tag)
 		}
 
 		buf.WriteString(m.Name)
 
-		if len(m.Domain) != 0 {
-			buf.WriteString(fmt.Sprintf(":%s", m.Domain))
+		switch {
+		case m.Name == "ip6":
+			buf.WriteByte(':')
+			if addr, err := netip.ParseAddr(m.Domain); err == nil {
+				buf.Write(addr.AppendTo(buf.AvailableBuffer()))
+			} else {
+				buf.WriteString(m.Domain)
+			}
+		case len(m.Domain) != 0 && !omitDomain:
+			buf.WriteByte(':')
+			buf.WriteString(m.Domain)
 		}
 
 		if len(m.Prefix) != 0 {
-			buf.WriteString(fmt.Sprintf("/%s", m.Prefix))
+			buf.WriteByte('/')
+			buf.WriteString(m.Prefix)
 		}
 	}
-
-	return buf.String()
 }
 
 // Ensure the mechanism is valid
 func (m *Mechanism) Valid() bool {
-	var hasResult bool
-	var hasName bool
-	var isIP bool
+	return m.validate() == nil
+}
 
+// validate returns why the mechanism is not valid, nil if it is.
+func (m *Mechanism) validate() error {
 	switch m.Result {
 	case Pass, Fail, SoftFail, Neutral:
-		hasResult = true
 	default:
-		hasResult = false
+		return ErrInvalidMechanism
 	}
 
 	switch m.Name {
-	case "all", "a", "mx", "ip4", "ip6", "exists", "include", "ptr", "redirect":
-		hasName = true
+	case "all", "exists", "include", "ptr", "redirect":
+		if m.Prefix != "" {
+			return ErrInvalidPrefix
+		}
+
+		// Unlike a, mx and ptr, these terms have no default domain,
+		// RFC 7208 sections 5.2, 5.7 and 6.1.
+		if m.Name != "all" && m.Name != "ptr" && (m.implicit || m.Domain == "") {
+			return ErrEmptyDomain
+		}
+	case "a", "mx":
+		// The prefix may hold both lengths of a dual-cidr-length, e.g.
+		// "24//64", or only the IPv6 one, "/64".
+		ip4, ip6 := splitCIDR(m.Prefix)
+		if ip4 != "" && !validPrefixLength(ip4, 32) {
+			return ErrInvalidPrefix
+		}
+
+		dual := strings.HasPrefix(m.Prefix, "/") || strings.Contains(m.Prefix, "//")
+		if dual && !validPrefixLength(ip6, 128) {
+			return ErrInvalidPrefix
+		}
+	case "ip4", "ip6":
+		if net.ParseIP(m.Domain) == nil {
+			return ErrInvalidMechanism
+		}
+
+		// ip4 requires dotted quad IPv4 text and ip6 IPv6 text, including
+		// IPv4-mapped addresses.
+		addr, err := netip.ParseAddr(m.Domain)
+		if err != nil || (m.Name == "ip4") != addr.Is4() {
+			return ErrAddressFamily
+		}
+
+		if m.Prefix != "" && !validPrefixLength(m.Prefix, addr.BitLen()) {
+			return ErrInvalidPrefix
+		}
 	default:
-		hasName = false
+		return ErrUnknownMechanism
+	}
+
+	return nil
+}
+
+// Unknown reports whether the name of the mechanism is not one defined by
+// RFC 7208. NewSPF keeps unknown mechanisms, which result in a PermError
+// when an evaluation reaches them, while lenient parsing skips them.
+func (m *Mechanism) Unknown() bool {
+	switch m.Name {
+	case "all", "a", "mx", "ip4", "ip6", "exists", "include", "ptr", "redirect":
+		return false
 	}
 
-	isIP = true
-	if m.Name == "ip4" || m.Name == "ip6" {
-		valid := net.ParseIP(m.Domain)
-		isIP = (valid != nil)
+	return true
+}
+
+// splitCIDR returns the IPv4 and IPv6 prefix lengths of the prefix of an a
+// or mx mechanism.
+func splitCIDR(prefix string) (ip4, ip6 string) {
+	if strings.HasPrefix(prefix, "/") {
+		return "", prefix[1:]
 	}
 
-	return hasResult && hasName && isIP
+	ip4, ip6, _ = strings.Cut(prefix, "//")
+	return ip4, ip6
+}
+
+// validPrefixLength reports whether the text is a decimal prefix length of
+// at most max bits, without leading zeros.
+func validPrefixLength(text string, max int) bool {
+	if text == "" || len(text) > 3 || (len(text) > 1 && text[0] == '0') {
+		return false
+	}
+
+	n := 0
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return false
+		}
+		n = n*10 + int(c-'0')
+	}
+
+	return n <= max
 }
 
 // Evaluate determines if the given IP address is covered by the mechanism.
@@ -133,68 +239,137 @@ func (m *Mechanism) Valid() bool {
 // If the IP is not covered an error is returned. The caller must check for
 // the error to determine if the result is valid.
 func (m *Mechanism) Evaluate(ip string, count int) (Result, error) {
+	addr, err := parseIP(ip)
+	if err != nil {
+		return None, err
+	}
+
+	return m.EvaluateAddr(addr, count)
+}
+
+// EvaluateAddr is like Evaluate for a parsed client address. ErrInvalidIP is
+// returned for the zero netip.Addr.
+func (m *Mechanism) EvaluateAddr(addr netip.Addr, count int) (Result, error) {
+	if !addr.IsValid() {
+		return None, ErrInvalidIP
+	}
+
+	return m.evaluate(newEvaluation(context.Background(), DefaultChecker, addr), count)
+}
 
-	parsedIP := net.ParseIP(ip)
+func (m *Mechanism) evaluate(e *evaluation, count int) (Result, error) {
+	ctx, c := e.ctx, e.checker
+
+	// Macros in the domain-spec are expanded before any lookup. ip4 and ip6
+	// mechanisms hold addresses, not domain-specs.
+	if m.Name != "ip4" && m.Name != "ip6" && hasMacro(m.Domain) {
+		domain, err := e.macros().expand(m.Domain)
+		if err != nil {
+			return e.fail(PermError, err), nil
+		}
+
+		expanded := *m
+		expanded.Domain = domain
+		m = &expanded
+	}
 
 	switch m.Name {
 	case "all":
 		return m.Result, nil
 	case "exists":
-		_, err := net.LookupHost(m.Domain)
-		if err == nil {
+		// Only an A query is made and any answer matches, whatever the
+		// address returned.
+		e.budget.Exists++
+		ips, err := c.resolver().LookupIP(ctx, "ip4", m.Domain)
+		e.note("A %s: %s", m.Domain, joinIPs(ips))
+		if err == nil && len(ips) > 0 {
 			return m.Result, nil
 		}
+
+		if lookupFailed(err) {
+			return e.lookupError()
+		}
 	case "redirect":
-		spf, err := NewSPF(m.Domain, "", count)
+		if e.visited[canonicalName(m.Domain)] {
+			return e.fail(PermError, ErrIncludeLoop), nil
+		}
 
-		// There is no clear definition of what to do with errors on a
-		// redirected domain. Trying to make wise choices here.
+		e.budget.Redirect++
+		spf, err := c.NewSPF(ctx, m.Domain, "", count)
+
+		// RFC 7208 section 6.1: a target without a record, or one that
+		// cannot be evaluated, is a PermError and a failed lookup a
+		// TempError. Otherwise the result of the target is the result.
 		switch err {
+		case nil:
 		case ErrFailedLookup:
-			return TempError, nil
+			return e.fail(TempError, err), nil
 		default:
-			return PermError, nil
+			return e.fail(PermError, err), nil
 		}
 
-		return spf.Test(ip), nil
+		return spf.evaluate(e), nil
 	case "include":
-		spf, err := NewSPF(m.Domain, "", count)
+		// Including a domain already on the chain of includes would recurse
+		// forever.
+		if e.visited[canonicalName(m.Domain)] {
+			return e.fail(PermError, ErrIncludeLoop), nil
+		}
 
-		// If there is no SPF record for the included domain or if we have too
-		// many mechanisms that require DNS lookups it is considered a
-		// PermError. Any other error is ok to ignore.
-		if err == ErrNoRecord || err == ErrMaxCount {
+		e.budget.Include++
+		spf, err := c.NewSPF(ctx, m.Domain, "", count)
+
+		// RFC 7208 section 5.2: a target without a record, or one that
+		// cannot be evaluated, is a PermError and a failed lookup a
+		// TempError.
+		switch err {
+		case nil:
+		case ErrFailedLookup:
+			return e.fail(TempError, err), nil
+		default:
+			return e.fail(PermError, err), nil
+		}
+
+		// The include only matches when the target passes, the other
+		// results of the target, Fail, SoftFail and Neutral, move on to the
+		// next mechanism. Errors are the result of the including record.
+		switch result := spf.evaluate(e); result {
+		case Pass:
+			return m.Result, nil
+		case TempError:
+			return TempError, nil
+		case PermError, None:
 			return PermError, nil
 		}
+	case "a", "mx":
+		found, err := testNetworks(e, m)
+		if err == ErrTooManyMX {
+			return e.fail(PermError, err), nil
+		}
 
-		// The include statment is meant to be used as an if-pass or on-pass
-		// statement. Meaning if we get a result other than Pass or PermError,
-		// it is ok to ignore it and move on to the other mechanisms.
-		result := spf.Test(ip)
-		if result == Pass || result == PermError {
-			return result, nil
+		if err != nil {
+			return e.lookupError()
 		}
-	case "a":
-		networks := aNetworks(m)
-		if ipInNetworks(parsedIP, networks) {
+
+		if found {
 			return m.Result, nil
 		}
-	case "mx":
-		networks := mxNetworks(m)
-		if ipInNetworks(parsedIP, networks) {
+	case "ptr":
+		if testPTR(e, m) {
 			return m.Result, nil
 		}
-	case "ptr":
-		if testPTR(m, ip) {
+	case "ip4", "ip6":
+		if ipMechanismContains(m, e.addr) {
 			return m.Result, nil
 		}
 	default:
-		network, err := networkCIDR(m.Domain, m.Prefix)
-		if err == nil {
-			if network.Contains(parsedIP) {
-				return m.Result, nil
-			}
+		if ext, ok := c.extension(m.Name); ok {
+			return e.evaluateExtension(ext, m)
 		}
+
+		// RFC 7208 section 5: evaluating an unknown mechanism is a
+		// PermError, whatever the terms that follow.
+		return e.fail(PermError, ErrUnknownMechanism), nil
 	}
 
 	return None, ErrNoMatch
@@ -207,6 +382,10 @@ func NewMechanism(str, domain string) (Mechanism, error) {
 	var m Mechanism
 	var err error
 
+	if str == "" {
+		return m, ErrInvalidMechanism
+	}
+
 	switch string(str[0]) {
 	case "-":
 		m, err = parseMechanism(Fail, str[1:], domain)
@@ -214,6 +393,7 @@ func NewMechanism(str, domain string) (Mechanism, error) {
 		m, err = parseMechanism(SoftFail, str[1:], domain)
 	case "+":
 		m, err = parseMechanism(Pass, str[1:], domain)
+		m.plus = true
 	case "?":
 		m, err = parseMechanism(Neutral, str[1:], domain)
 	default:
@@ -223,59 +403,117 @@ func NewMechanism(str, domain string) (Mechanism, error) {
 	return m, err
 }
 
+// parseMechanism parses an unqualified term following the RFC 7208 grammar:
+//
+//	mechanism = name [ ":" domain-spec ] [ "/" cidr-length ]
+//	modifier  = name "=" macro-string
+//
+// Names are case-insensitive. The domain-spec ends at the first "/" outside
+// of a macro, so delimiters like in "%{l/}" are not mistaken for a
+// cidr-length, and the value of a modifier is taken whole.
 func parseMechanism(r Result, str, domain string) (Mechanism, error) {
 	var m Mechanism
-	var n string
-	var d string
-	var p string
 
-	ci := strings.Index(str, ":")
-	pi := strings.Index(str, "/")
-	ei := strings.Index(str, "=")
+	end := 0
+	for end < len(str) && isNameChar(str[end], end == 0) {
+		end++
+	}
 
-	switch {
-	case ei != -1:
-		n = str[:ei]
-		d = str[ei+1:]
+	if end == 0 {
+		return m, ErrInvalidMechanism
+	}
 
-		// Domain should not be empty
-		if d == "" {
+	n := strings.ToLower(str[:end])
+	d := domain
+	p := ""
+	explicit := false
+	rest := str[end:]
+
+	if strings.HasPrefix(rest, "=") {
+		// Modifier, the value should not be empty. Only redirect is
+		// parsed as a term, records hold their modifiers apart, see
+		// parseModifier.
+		d, rest = rest[1:], ""
+		if d == "" || n != "redirect" {
 			return m, ErrInvalidMechanism
 		}
-	case ci != -1 && pi != -1 && ci < pi: // name:domain/prefix
-		n = str[:ci]
-		d = str[ci+1 : pi]
-		p = str[pi+1:]
+		explicit = true
+	}
 
-		// Domain and prefix should not be empty
-		if d == "" || p == "" {
-			return m, ErrInvalidMechanism
-		}
-	case ci != -1: // name:domain
-		n = str[:ci]
-		d = str[ci+1:]
+	if strings.HasPrefix(rest, ":") {
 		// Domain should not be empty
+		d, rest = splitDomainSpec(rest[1:])
 		if d == "" {
 			return m, ErrInvalidMechanism
 		}
-	case pi != -1: // name/prefix
-		n = str[:pi]
-		d = domain
-		p = str[pi+1:]
+		explicit = true
+	}
 
-		// Prefix should not be empty
+	if strings.HasPrefix(rest, "/") {
+		// Prefix should not be empty and holds one or two lengths
+		p, rest = rest[1:], ""
 		if p == "" {
 			return m, ErrInvalidMechanism
 		}
-	default: // name
-		n = str
-		d = domain
+
+		if strings.Trim(p, "0123456789/") != "" {
+			return m, ErrInvalidPrefix
+		}
+	}
+
+	if rest != "" {
+		return m, ErrInvalidMechanism
 	}
 
 	m.Result = r
 	m.Domain = d
 	m.Name = n
 	m.Prefix = p
+	m.implicit = !explicit
+
+	// Domain-specs given in the record must follow the RFC grammar.
+	switch n {
+	case "a", "mx", "ptr", "include", "exists", "redirect":
+		if explicit {
+			if err := validDomainSpec(d); err != nil {
+				return m, err
+			}
+		}
+	}
 
 	return m, nil
 }
+
+// isNameChar reports whether c may appear in a mechanism or modifier name,
+// which starts with a letter.
+func isNameChar(c byte, first bool) bool {
+	switch {
+	case c|0x20 >= 'a' && c|0x20 <= 'z':
+		return true
+	case first:
+		return false
+	}
+
+	return c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.'
+}
+
+// splitDomainSpec splits the domain-spec from the cidr-length following it,
+// skipping over macros.
+func splitDomainSpec(str string) (spec, rest string) {
+	for i := 0; i < len(str); i++ {
+		switch str[i] {
+		case '%':
+			if i+1 < len(str) && str[i+1] == '{' {
+				if end := strings.IndexByte(str[i:], '}'); end != -1 {
+					i += end
+					continue
+				}
+			}
+			i++
+		case '/':
+			return str[:i], str[i:]
+		}
+	}
+
+	return str, ""
+}
@@ -73,3 +73,75 @@ func TestNewMechanism(t *testing.T) {
 		}
 	}
 }
+
+func TestParseMechanismGrammar(t *testing.T) {
+	tests := []mechtest{
+		mechtest{"exists:%{l=}.example.com", "exists", "%{l=}.example.com", "", Pass},
+		mechtest{"-exists:%{l/}.%{d}", "exists", "%{l/}.%{d}", "", Fail},
+		mechtest{"a:%{l/}.example.com/24", "a", "%{l/}.example.com", "24", Pass},
+		mechtest{"mx:mail.example.com/24//64", "mx", "mail.example.com", "24//64", Pass},
+		mechtest{"a//64", "a", domain, "/64", Pass},
+		mechtest{"redirect=%{d}._spf.example.com", "redirect", "%{d}._spf.example.com", "", Pass},
+		mechtest{"MX:Mail.example.com", "mx", "Mail.example.com", "", Pass},
+		mechtest{"~Include:example.com", "include", "example.com", "", SoftFail},
+		mechtest{"ip6:2001:db8::/32", "ip6", "2001:db8::", "32", Pass},
+	}
+
+	for _, expected := range tests {
+		t.Log("Analyzing", expected.raw)
+
+		actual, err := NewMechanism(expected.raw, domain)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if actual.Name != expected.name || actual.Domain != expected.domain || actual.Prefix != expected.prefix || actual.Result != expected.result {
+			t.Error("Expected", expected, "got", actual)
+		}
+	}
+
+	for _, term := range []string{"4ip:192.0.2.1", "a:example.com:80", "a/24x", ""} {
+		if _, err := NewMechanism(term, domain); err == nil {
+			t.Error("For", term, "Expected error got nil")
+		}
+	}
+}
+
+func TestMechanismDomainSpec(t *testing.T) {
+	tests := []struct {
+		term string
+		err  error
+	}{
+		{"exists:example.com", nil},
+		{"exists:example.com/24", ErrInvalidPrefix},
+		{"exists", ErrEmptyDomain},
+		{"include", ErrEmptyDomain},
+		{"-include", ErrEmptyDomain},
+		{"redirect", ErrEmptyDomain},
+		{"ptr", nil},
+		{"all", nil},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.term)
+
+		m, err := NewMechanism(test.term, "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := m.validate(); err != test.err || m.Valid() != (test.err == nil) {
+			t.Error("Expected", test.err, "got", err)
+		}
+	}
+
+	_, warnings, err := ParseLenient("example.com", "v=spf1 exists:%{i}.example.com/24 include -all")
+	if err != nil || len(warnings) != 2 || warnings[0].Err != ErrInvalidPrefix || warnings[1].Err != ErrEmptyDomain {
+		t.Error("Expected two warnings got", warnings, err)
+	}
+
+	if _, err := NewSPF("example.com", "v=spf1 exists -all", 0); err != ErrEmptyDomain {
+		t.Error("Expected", ErrEmptyDomain, "got", err)
+	}
+}
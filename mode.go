@@ -0,0 +1,142 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ParseMode controls how closely records must follow the RFC 7208 grammar.
+type ParseMode int
+
+const (
+	// Strict rejects every record the RFC requires a PermError for.
+	Strict ParseMode = iota
+
+	// Lenient skips invalid terms and repairs common mistakes found in
+	// published records, reporting both as warnings.
+	Lenient
+)
+
+var (
+	ErrDuplicateModifier   = errors.New("Modifier appears more than once in SPF string.")
+	ErrQualifiedModifier   = errors.New("Modifier must not have a qualifier.")
+	ErrSpacedDelimiter     = errors.New("Whitespace around term delimiter.")
+	ErrTrailingDot         = errors.New("Trailing dot in domain name.")
+	ErrIrregularWhitespace = errors.New("Irregular whitespace in SPF string.")
+)
+
+// Return a ParseMode as a string.
+func (m ParseMode) String() string {
+	if m == Lenient {
+		return "lenient"
+	}
+
+	return "strict"
+}
+
+// Parse parses the record of the domain in the given mode using the
+// DefaultChecker. See Checker.Parse.
+func Parse(domain, record string, mode ParseMode) (SPF, []Warning, error) {
+	return DefaultChecker.Parse(context.Background(), domain, record, 0, mode)
+}
+
+// Parse creates a new SPF record for the given domain like NewSPF does, in
+// the given mode. In Strict mode the first problem is returned as the error
+// and only the repairs made when the Checker normalizes records are returned
+// as warnings. In Lenient mode, which always normalizes records, the
+// problems, including the repairs made, are returned as warnings.
+func (c *Checker) Parse(ctx context.Context, domain, record string, count int, mode ParseMode) (SPF, []Warning, error) {
+	return c.parse(ctx, domain, record, count, mode)
+}
+
+// Normalize rewrites the record with its terms separated by single spaces,
+// without leading or trailing whitespace, and with terms split around their
+// delimiter rejoined, e.g. "ip4: 192.0.2.1" becomes "ip4:192.0.2.1". Each
+// repair is reported as a warning.
+func Normalize(record string) (string, []Warning) {
+	var warnings []Warning
+
+	fields := strings.Fields(record)
+	if strings.Join(fields, " ") != record {
+		warnings = append(warnings, Warning{Term: record, Err: ErrIrregularWhitespace})
+	}
+
+	fields, joined := joinDelimiters(fields)
+	warnings = append(warnings, joined...)
+
+	return strings.Join(fields, " "), warnings
+}
+
+// isTerm reports whether the field starts with a known mechanism or modifier
+// name, i.e. whether it is not the continuation of the previous term.
+func isTerm(field string) bool {
+	name := strings.TrimLeft(field, "+-~?")
+	if i := strings.IndexAny(name, ":/="); i != -1 {
+		name = name[:i]
+	}
+
+	switch strings.ToLower(name) {
+	case "all", "a", "mx", "ip4", "ip6", "exists", "include", "ptr", "redirect", "exp":
+		return true
+	}
+
+	return strings.HasPrefix(field, "v=")
+}
+
+// isIP6 reports whether the term is an ip6 mechanism, whose value itself
+// contains colons.
+func isIP6(term string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimLeft(term, "+-~?")), "ip6")
+}
+
+// joinDelimiters rejoins terms split by whitespace around their ":" or "="
+// delimiter, e.g. "include: example.com". A dangling delimiter is only
+// joined with a field that cannot be a term on its own.
+func joinDelimiters(fields []string) ([]string, []Warning) {
+	var joined []string
+	var warnings []Warning
+
+	for i := 0; i < len(fields); i++ {
+		term := fields[i]
+		original := term
+
+		for i+1 < len(fields) {
+			next := fields[i+1]
+			open := strings.HasSuffix(term, ":") || strings.HasSuffix(term, "=")
+			if !open && !strings.HasPrefix(next, ":") && !strings.HasPrefix(next, "=") {
+				break
+			}
+			if open && (isTerm(next) || (strings.ContainsAny(next, ":=") && !isIP6(term))) {
+				break
+			}
+
+			term += next
+			original += " " + next
+			i++
+		}
+
+		if term != original {
+			warnings = append(warnings, Warning{Term: original, Err: ErrSpacedDelimiter})
+		}
+
+		joined = append(joined, term)
+	}
+
+	return joined, warnings
+}
+
+// trimTrailingDot removes the trailing dot of a fully qualified domain-spec.
+func trimTrailingDot(m *Mechanism) bool {
+	switch m.Name {
+	case "ip4", "ip6", "all":
+		return false
+	}
+
+	if len(m.Domain) > 1 && strings.HasSuffix(m.Domain, ".") {
+		m.Domain = strings.TrimSuffix(m.Domain, ".")
+		return true
+	}
+
+	return false
+}
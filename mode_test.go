@@ -0,0 +1,108 @@
+package spf
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	record := "v=spf1 ip4: 192.0.2.1 include :_spf.example.net. ip6 : 2001:db8::/32 include: exists:%{i}.example.net -all"
+
+	s, warnings, err := Parse("example.com", record, Lenient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "v=spf1 ip4:192.0.2.1 include:_spf.example.net ip6:2001:db8::/32 exists:%{i}.example.net -all"
+	if s.SPFString() != expected {
+		t.Error("Expected", expected, "got", s.SPFString())
+	}
+
+	expectedWarnings := []Warning{
+		Warning{"ip4: 192.0.2.1", ErrSpacedDelimiter},
+		Warning{"include :_spf.example.net.", ErrSpacedDelimiter},
+		Warning{"ip6 : 2001:db8::/32", ErrSpacedDelimiter},
+		Warning{"include:_spf.example.net.", ErrTrailingDot},
+		Warning{"include:", ErrEmptyDomain},
+	}
+
+	if len(warnings) != len(expectedWarnings) {
+		t.Fatal("Expected", expectedWarnings, "got", warnings)
+	}
+
+	for i, w := range warnings {
+		if w != expectedWarnings[i] {
+			t.Error("Expected", expectedWarnings[i], "got", w)
+		}
+	}
+
+	if _, _, err := Parse("example.com", record, Strict); err == nil {
+		t.Error("Expected error got nil")
+	}
+}
+
+func TestParseModeStrict(t *testing.T) {
+	tests := []struct {
+		record string
+		err    error
+	}{
+		{"v=spf10 -all", ErrInvalidSPF},
+		{"v=spf1 redirect=a.example redirect=b.example", ErrDuplicateModifier},
+		{"v=spf1 -redirect=a.example", ErrQualifiedModifier},
+		{"v=spf1 include:_spf.example.net. -all", nil},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.record)
+
+		_, warnings, err := Parse("example.com", test.record, Strict)
+		if err != test.err {
+			t.Error("Expected", test.err, "got", err)
+		}
+
+		if warnings != nil {
+			t.Error("Expected no warnings got", warnings)
+		}
+	}
+
+	s, warnings, err := Parse("example.com", "v=spf1 redirect=a.example redirect=b.example", Lenient)
+	if err != nil || len(warnings) != 1 || len(s.Modifiers) != 1 {
+		t.Error("Expected a single redirect and warning got", s.Modifiers, warnings, err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []spfstr{
+		spfstr{" v=spf1\tip4:192.0.2.1  -all ", "v=spf1 ip4:192.0.2.1 -all"},
+		spfstr{"v=spf1 ip4: 192.0.2.1 include: _spf.example.com -all", "v=spf1 ip4:192.0.2.1 include:_spf.example.com -all"},
+		spfstr{"v=spf1 mx -all", "v=spf1 mx -all"},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.raw)
+
+		normalized, warnings := Normalize(test.raw)
+		if normalized != test.expected {
+			t.Error("Expected", test.expected, "got", normalized)
+		}
+
+		if (normalized == test.raw) != (len(warnings) == 0) {
+			t.Error("Expected warnings for every repair got", warnings)
+		}
+	}
+
+	record := "v=spf1  ip4: 192.0.2.1 -all"
+	if _, err := NewSPF("example.com", record, 0); err == nil {
+		t.Error("Expected error got nil")
+	}
+
+	c := &Checker{Normalize: true}
+	s, warnings, err := c.Parse(context.Background(), "example.com", record, 0, Strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Mechanisms) != 2 || len(warnings) != 2 || s.Raw != record {
+		t.Error("Expected 2 mechanisms and 2 warnings got", s.Mechanisms, warnings)
+	}
+}
@@ -0,0 +1,193 @@
+package spf
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrModifierNotFound = errors.New("Modifier not found in SPF record.")
+
+// Modifier represents a name=value term of an SPF record, RFC 7208 section
+// 6. Unlike mechanisms, modifiers do not depend on their position in the
+// record: redirect is only followed once no mechanism matched and exp names
+// the explanation of a Fail result. Other modifiers are kept with their
+// macro-string Value as written, but do not affect the evaluation.
+type Modifier struct {
+	Name  string
+	Value string
+}
+
+// isModifier reports whether the term name is a modifier defined by RFC
+// 7208 rather than a mechanism. A record holds at most one of each.
+func isModifier(name string) bool {
+	return name == "redirect" || name == "exp"
+}
+
+// SPFString returns the modifier as it appears in a TXT record.
+func (m *Modifier) SPFString() string {
+	return m.Name + "=" + m.Value
+}
+
+// term returns the modifier in the form of the mechanisms passed to a
+// WalkFunc and recorded in traces.
+func (m *Modifier) term() Mechanism {
+	return Mechanism{Name: m.Name, Domain: m.Value, Result: Pass}
+}
+
+// parseModifier parses a term following the RFC 7208 grammar:
+//
+//	modifier = name "=" macro-string
+//
+// ok is false if the term is not a modifier. Names are case-insensitive and
+// the values of redirect and exp must be domain-specs. A qualified modifier
+// is returned with ErrQualifiedModifier.
+func parseModifier(term string) (m Modifier, ok bool, err error) {
+	str := term
+	if str != "" && strings.IndexByte("+-~?", str[0]) != -1 {
+		str = str[1:]
+	}
+
+	end := 0
+	for end < len(str) && isNameChar(str[end], end == 0) {
+		end++
+	}
+
+	if end == 0 || end == len(str) || str[end] != '=' {
+		return m, false, nil
+	}
+
+	m.Name = strings.ToLower(str[:end])
+	m.Value = str[end+1:]
+
+	// Mechanism names followed by "=" are invalid mechanisms, not unknown
+	// modifiers.
+	probe := Mechanism{Name: m.Name}
+	if !probe.Unknown() && m.Name != "redirect" {
+		return m, false, nil
+	}
+
+	if isModifier(m.Name) {
+		if m.Value == "" {
+			return m, true, ErrInvalidMechanism
+		}
+
+		if err := validDomainSpec(m.Value); err != nil {
+			return m, true, err
+		}
+	}
+
+	if len(str) != len(term) {
+		return m, true, ErrQualifiedModifier
+	}
+
+	return m, true, nil
+}
+
+// modifier returns the modifier of the record with the name, nil if there is
+// none.
+func (s *SPF) modifier(name string) *Modifier {
+	for i := range s.Modifiers {
+		if s.Modifiers[i].Name == name {
+			return &s.Modifiers[i]
+		}
+	}
+
+	return nil
+}
+
+// Redirect returns the domain-spec of the redirect modifier of the record,
+// empty if it has none.
+func (s *SPF) Redirect() string {
+	if m := s.modifier("redirect"); m != nil {
+		return m.Value
+	}
+
+	return ""
+}
+
+// Exp returns the domain-spec of the exp modifier of the record, empty if it
+// has none. Check evaluates it into CheckResult.Explanation.
+func (s *SPF) Exp() string {
+	if m := s.modifier("exp"); m != nil {
+		return m.Value
+	}
+
+	return ""
+}
+
+// terms returns the mechanisms of the record followed by its redirect, if
+// any, in the order they are evaluated.
+func (s *SPF) terms() []Mechanism {
+	m := s.modifier("redirect")
+	if m == nil {
+		return s.Mechanisms
+	}
+
+	terms := make([]Mechanism, len(s.Mechanisms), len(s.Mechanisms)+1)
+	copy(terms, s.Mechanisms)
+
+	return append(terms, m.term())
+}
+
+// SetModifier sets the modifier of the record with the name of m, replacing
+// the first one already present, if any, or adding it after the others. The
+// value of a redirect or exp must be a valid domain-spec and a new redirect
+// must not take the record over the lookup limit.
+func (s *SPF) SetModifier(m Modifier) error {
+	parsed, ok, err := parseModifier(m.Name + "=" + m.Value)
+	if !ok || strings.ContainsAny(m.Value, " \t") {
+		return ErrInvalidMechanism
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if existing := s.modifier(parsed.Name); existing != nil {
+		existing.Value = parsed.Value
+		s.Raw = s.SPFString()
+		return nil
+	}
+
+	cost := 0
+	if parsed.Name == "redirect" {
+		cost = 1
+	}
+
+	if s.Count+cost >= MaxCount {
+		return ErrMaxCount
+	}
+
+	s.Modifiers = append(s.Modifiers, parsed)
+	s.Count += cost
+	s.Raw = s.SPFString()
+
+	return nil
+}
+
+// RemoveModifier removes the modifier with the name from the record, the
+// first one if an unknown modifier appears more than once. Like
+// RemoveMechanism, it returns ErrNoTerminal instead of removing the redirect
+// of a record without an all mechanism.
+func (s *SPF) RemoveModifier(name string) error {
+	name = strings.ToLower(name)
+	for i := range s.Modifiers {
+		if s.Modifiers[i].Name != name {
+			continue
+		}
+
+		if name == "redirect" {
+			if s.indexOf(Mechanism{Name: "all"}) == -1 {
+				return ErrNoTerminal
+			}
+			s.Count--
+		}
+
+		s.Modifiers = append(s.Modifiers[:i], s.Modifiers[i+1:]...)
+		s.Raw = s.SPFString()
+
+		return nil
+	}
+
+	return ErrModifierNotFound
+}
@@ -0,0 +1,156 @@
+package spf
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseModifiers(t *testing.T) {
+	s, err := NewSPF("example.com", "v=spf1 exp=explain.%{d} REDIRECT=_spf.example.com -ip4:192.0.2.1 vendor=x", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Mechanisms) != 1 || len(s.Modifiers) != 3 || s.Modifiers[2] != (Modifier{"vendor", "x"}) {
+		t.Error("Expected one mechanism and three modifiers got", s.Mechanisms, s.Modifiers)
+	}
+
+	if s.Redirect() != "_spf.example.com" || s.Exp() != "explain.%{d}" || s.Count != 1 {
+		t.Error("Expected the redirect and exp got", s.Redirect(), s.Exp(), s.Count)
+	}
+
+	expected := "v=spf1 -ip4:192.0.2.1 exp=explain.%{d} redirect=_spf.example.com vendor=x"
+	if s.SPFString() != expected {
+		t.Error("Expected", expected, "got", s.SPFString())
+	}
+
+	tests := []struct {
+		record string
+		err    error
+	}{
+		{"v=spf1 exp=a.example exp=b.example", ErrDuplicateModifier},
+		{"v=spf1 redirect:a.example redirect=b.example", ErrDuplicateModifier},
+		{"v=spf1 ~exp=a.example", ErrQualifiedModifier},
+		{"v=spf1 exp=", ErrInvalidMechanism},
+		{"v=spf1 exp=a..example", ErrInvalidDomain},
+		{"v=spf1 a=example.com", ErrInvalidMechanism},
+		{"v=spf1 x-vendor.tag= -all", nil},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.record)
+
+		if _, err := NewSPF("example.com", test.record, 0); err != test.err {
+			t.Error("Expected", test.err, "got", err)
+		}
+	}
+}
+
+func TestRedirectPosition(t *testing.T) {
+	c := &Checker{Source: MapSource{
+		"example.com":      "v=spf1 redirect=_spf.example.com ip4:192.0.2.1",
+		"_spf.example.com": "v=spf1 -all",
+	}}
+
+	tests := []spftest{
+		spftest{"192.0.2.1", "info@example.com", Pass},
+		spftest{"192.0.2.2", "info@example.com", Fail},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.server)
+
+		result, _ := c.SPFTest(context.Background(), test.server, test.email)
+		if result != test.result {
+			t.Error("Expected", test.result, "got", result)
+		}
+	}
+
+	s, err := c.NewSPF(context.Background(), "example.com", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := s.Test("192.0.2.2"); r != Fail {
+		t.Error("Expected", Fail, "got", r)
+	}
+}
+
+func TestMutateModifiers(t *testing.T) {
+	s, err := NewSPF("example.com", "v=spf1 mx -all", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetModifier(Modifier{Name: "redirect", Value: "_spf.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetModifier(Modifier{Name: "exp", Value: "explain.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetModifier(Modifier{Name: "redirect", Value: "_rest.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "v=spf1 mx -all redirect=_rest.example.com exp=explain.example.com"
+	if s.Raw != expected || s.Count != 2 {
+		t.Error("Expected", expected, "got", s.Raw, s.Count)
+	}
+
+	if err := s.SetModifier(Modifier{Name: "redirect", Value: "a..example"}); err != ErrInvalidDomain {
+		t.Error("Expected", ErrInvalidDomain, "got", err)
+	}
+
+	if err := s.AddMechanism(mustMechanism(t, "redirect=_spf.example.com")); err != ErrInvalidMechanism {
+		t.Error("Expected", ErrInvalidMechanism, "got", err)
+	}
+
+	if err := s.RemoveModifier("redirect"); err != nil {
+		t.Error(err)
+	}
+
+	if err := s.RemoveModifier("redirect"); err != ErrModifierNotFound {
+		t.Error("Expected", ErrModifierNotFound, "got", err)
+	}
+
+	expected = "v=spf1 mx -all exp=explain.example.com"
+	if s.Raw != expected || s.Count != 1 {
+		t.Error("Expected", expected, "got", s.Raw, s.Count)
+	}
+}
+
+func TestUnknownModifiers(t *testing.T) {
+	record := "v=spf1 x-vendor=%{d}.tag ip4:192.0.2.0/24 x-vendor=2 -all v2=%{ir}"
+	s, err := NewSPF("example.com", record, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AddMechanism(mustMechanism(t, "include:_spf.vendor.example")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetModifier(Modifier{Name: "x-vendor", Value: "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetModifier(Modifier{Name: "note", Value: "a b"}); err != ErrInvalidMechanism {
+		t.Error("Expected", ErrInvalidMechanism, "got", err)
+	}
+
+	expected := "v=spf1 ip4:192.0.2.0/24 include:_spf.vendor.example -all x-vendor=1 x-vendor=2 v2=%{ir}"
+	if s.SPFString() != expected {
+		t.Error("Expected", expected, "got", s.SPFString())
+	}
+
+	parsed, err := NewSPF("example.com", s.SPFString(), 0)
+	if err != nil || parsed.SPFString() != expected {
+		t.Error("Expected", expected, "got", parsed.SPFString(), err)
+	}
+
+	if r := parsed.Test("192.0.2.1"); r != Pass {
+		t.Error("Expected", Pass, "got", r)
+	}
+}
@@ -0,0 +1,262 @@
+package spf
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DomainStatus is the state of a domain supervised by a Monitor, as of its
+// last check. Malformed is set when the domain publishes a record that is
+// not a valid SPF record, or several records; Record then holds the
+// published text, if single.
+type DomainStatus struct {
+	Domain      string    `json:"domain"`
+	Record      string    `json:"record"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Lookups     int       `json:"lookups"`
+	OverLimit   bool      `json:"over_limit"`
+	Warnings    []string  `json:"warnings,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Malformed   bool      `json:"malformed,omitempty"`
+	Checked     time.Time `json:"checked"`
+	Changed     time.Time `json:"changed"`
+}
+
+// Monitor supervises the SPF records of a set of domains for continuous
+// hygiene monitoring. Each domain is checked periodically: its record is
+// watched for changes, audited for problems and its DNS lookups are counted
+// against MaxCount. The current status of all domains is served as JSON by
+// ServeHTTP, so a Monitor can be mounted on an HTTP server.
+type Monitor struct {
+	// Checker performs the lookups. If nil, DefaultChecker is used.
+	Checker *Checker
+
+	// Domains are the domains supervised by Run.
+	Domains []string
+
+	// Interval is the time between two checks of a domain. It is raised to
+	// the TTL of the domain's TXT records when the Resolver reports a
+	// longer one, see Checker.Watch. If zero, the TTL is used, but no less
+	// than MinRefreshInterval.
+	Interval time.Duration
+
+	// OnChange is called when the record of a domain changed since the
+	// previous check.
+	OnChange func(change Change)
+
+	// OnStatus is called with the status of a domain after each check.
+	OnStatus func(status DomainStatus)
+
+	// OnEvent is called with a RecordChanged or RecordDisappeared event
+	// along with OnChange, with a RecordMalformed event when a domain
+	// starts publishing a malformed record, and with a LookupLimitExceeded
+	// event when a record starts requiring more than MaxCount lookups. Only
+	// a domain publishing no record at all has its record disappear.
+	OnEvent func(event Event)
+
+	mu     sync.Mutex
+	status map[string]DomainStatus
+}
+
+func (m *Monitor) checker() *Checker {
+	if m.Checker == nil {
+		return DefaultChecker
+	}
+
+	return m.Checker
+}
+
+// Run checks the domains until the context is cancelled and returns the
+// context's error.
+func (m *Monitor) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+
+	for _, domain := range m.Domains {
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			m.supervise(ctx, domain)
+		}(domain)
+	}
+
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// supervise checks the domain until the context is cancelled.
+func (m *Monitor) supervise(ctx context.Context, domain string) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		m.Check(ctx, domain)
+
+		timer.Reset(m.checker().pollInterval(ctx, domain, m.Interval))
+	}
+}
+
+// Check checks the domain once, updating and returning its status. The
+// domain does not need to be one of Domains.
+func (m *Monitor) Check(ctx context.Context, domain string) DomainStatus {
+	status := m.audit(ctx, domain)
+
+	m.mu.Lock()
+	previous, seen := m.status[domain]
+
+	// A failed lookup tells nothing about the record, nor do several
+	// records, the previous one is assumed unchanged.
+	if status.Error != "" && status.Error != ErrNoRecord.Error() && status.Record == "" && seen {
+		status.Record = previous.Record
+		status.Fingerprint = previous.Fingerprint
+	}
+
+	// Nor did a record that could never be fetched change.
+	known := previous.Record != "" || previous.Error == "" || previous.Error == ErrNoRecord.Error()
+
+	changed := seen && known && status.Record != previous.Record
+	if changed || !seen {
+		status.Changed = status.Checked
+	} else {
+		status.Changed = previous.Changed
+	}
+
+	if m.status == nil {
+		m.status = make(map[string]DomainStatus)
+	}
+	m.status[domain] = status
+	m.mu.Unlock()
+
+	if changed {
+		change := Change{
+			Domain: domain,
+			Old:    previous.Record,
+			New:    status.Record,
+			Diff:   DiffRecords(previous.Record, status.Record),
+			Time:   status.Checked,
+		}
+
+		if m.OnChange != nil {
+			m.OnChange(change)
+		}
+
+		if m.OnEvent != nil {
+			event := changeEvent(change)
+			if status.Malformed {
+				event.Type = RecordMalformed
+			}
+			m.OnEvent(event)
+		}
+	} else if status.Malformed && !previous.Malformed && m.OnEvent != nil {
+		m.OnEvent(Event{Type: RecordMalformed, Domain: domain, Time: status.Checked, New: status.Record})
+	}
+
+	if status.OverLimit && !previous.OverLimit && m.OnEvent != nil {
+		m.OnEvent(Event{Type: LookupLimitExceeded, Domain: domain, Time: status.Checked, Lookups: status.Lookups})
+	}
+
+	if m.OnStatus != nil {
+		m.OnStatus(status)
+	}
+
+	return status
+}
+
+// audit fetches, lints and resolves the record of the domain.
+func (m *Monitor) audit(ctx context.Context, domain string) DomainStatus {
+	status := DomainStatus{Domain: domain, Checked: time.Now()}
+
+	spf, warnings, err := m.checker().NewSPFLenient(ctx, domain, "", 0)
+	switch err {
+	case nil:
+	case ErrInvalidSPF:
+		status.Record, status.Error, status.Malformed = spf.Raw, err.Error(), true
+		return status
+	case ErrMultipleRecords:
+		status.Error, status.Malformed = err.Error(), true
+		return status
+	default:
+		status.Error = err.Error()
+		return status
+	}
+
+	status.Record = spf.Raw
+	status.Fingerprint = spf.Fingerprint()
+
+	for _, w := range append(warnings, spf.Lint()...) {
+		status.Warnings = append(status.Warnings, w.String())
+	}
+
+	_, lookups, err := spf.networks(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	status.Lookups = lookups
+	status.OverLimit = lookups > MaxCount
+
+	return status
+}
+
+// Status returns the status of the domain. ok is false if the domain was not
+// checked yet.
+func (m *Monitor) Status(domain string) (status DomainStatus, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status, ok = m.status[domain]
+	return status, ok
+}
+
+// Statuses returns the status of every checked domain, sorted by domain.
+func (m *Monitor) Statuses() []DomainStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]DomainStatus, 0, len(m.status))
+	for _, status := range m.status {
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Domain < statuses[j].Domain
+	})
+
+	return statuses
+}
+
+// ServeHTTP writes the status of every checked domain as a JSON array, or
+// the status of a single domain as a JSON object when the request has a
+// domain query parameter.
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var v interface{} = m.Statuses()
+
+	if domain := r.URL.Query().Get("domain"); domain != "" {
+		status, ok := m.Status(domain)
+		if !ok {
+			http.Error(w, "domain not monitored", http.StatusNotFound)
+			return
+		}
+		v = status
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,131 @@
+package spf
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMonitorCheck(t *testing.T) {
+	source := MapSource{
+		"example.com":      "v=spf1 include:_spf.esp.example ip4:192.0.2.0/24 -all",
+		"_spf.esp.example": "v=spf1 a mx exists:%{i}.esp.example -all",
+	}
+
+	var changes []Change
+	m := &Monitor{
+		Checker:  &Checker{Source: source, Resolver: NewZone()},
+		OnChange: func(change Change) { changes = append(changes, change) },
+	}
+	ctx := context.Background()
+
+	status := m.Check(ctx, "example.com")
+	if status.Error != "" {
+		t.Fatal(status.Error)
+	}
+
+	if status.Lookups != 4 {
+		t.Error("Expected 4 got", status.Lookups)
+	}
+
+	if status.OverLimit || status.Changed != status.Checked {
+		t.Error("Unexpected status", status)
+	}
+
+	m.Check(ctx, "example.com")
+	if len(changes) != 0 {
+		t.Error("Expected no change got", changes)
+	}
+
+	source["example.com"] = "v=spf1 ip4:192.0.2.0/24 -all"
+	status = m.Check(ctx, "example.com")
+	if len(changes) != 1 || len(changes[0].Diff.Removed) != 1 {
+		t.Fatal("Expected one change got", changes)
+	}
+
+	if status.Lookups != 0 || status.Changed != status.Checked {
+		t.Error("Unexpected status", status)
+	}
+
+	// A disappearing record is a change.
+	delete(source, "example.com")
+	status = m.Check(ctx, "example.com")
+	if len(changes) != 2 || changes[1].New != "" {
+		t.Error("Expected the record to disappear got", changes)
+	}
+
+	if status.Error != ErrNoRecord.Error() {
+		t.Error("Expected", ErrNoRecord, "got", status.Error)
+	}
+}
+
+func TestMonitorServeHTTP(t *testing.T) {
+	m := &Monitor{Checker: &Checker{Source: MapSource{
+		"one.example": "v=spf1 -all",
+		"two.example": "v=spf1 ip4:192.0.2.1 -all",
+	}}}
+
+	m.Check(context.Background(), "two.example")
+	m.Check(context.Background(), "one.example")
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	var statuses []DomainStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(statuses) != 2 || statuses[0].Domain != "one.example" {
+		t.Error("Unexpected statuses", statuses)
+	}
+
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/?domain=two.example", nil))
+
+	var status DomainStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Record != "v=spf1 ip4:192.0.2.1 -all" {
+		t.Error("Expected v=spf1 ip4:192.0.2.1 -all got", status.Record)
+	}
+
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/?domain=three.example", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Error("Expected", http.StatusNotFound, "got", rec.Code)
+	}
+}
+
+func TestMonitorMalformed(t *testing.T) {
+	source := MapSource{"example.com": "v=spf1 ip4:192.0.2.0/24 -all"}
+
+	var events []EventType
+	m := &Monitor{
+		Checker: &Checker{Source: source},
+		OnEvent: func(event Event) { events = append(events, event.Type) },
+	}
+	ctx := context.Background()
+
+	m.Check(ctx, "example.com")
+
+	source["example.com"] = "v=spf1ip4:192.0.2.0/24 -all"
+	status := m.Check(ctx, "example.com")
+	if !status.Malformed || status.Record != source["example.com"] || status.Error != ErrInvalidSPF.Error() {
+		t.Error("Unexpected status", status)
+	}
+
+	m.Check(ctx, "example.com")
+
+	delete(source, "example.com")
+	m.Check(ctx, "example.com")
+
+	expected := []EventType{RecordMalformed, RecordDisappeared}
+	if len(events) != len(expected) || events[0] != expected[0] || events[1] != expected[1] {
+		t.Error("Expected", expected, "got", events)
+	}
+}
@@ -0,0 +1,134 @@
+package spf
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrDuplicateMechanism = errors.New("Mechanism already present in SPF record.")
+	ErrMechanismNotFound  = errors.New("Mechanism not found in SPF record.")
+)
+
+// lookupCost returns the number of DNS lookups the term counts towards the
+// lookup limit.
+func lookupCost(m Mechanism) int {
+	switch m.Name {
+	case "include", "redirect", "exists", "a", "mx", "ptr":
+		return 1
+	}
+
+	return 0
+}
+
+// sameTerm reports whether both terms are the same regardless of their
+// qualifier.
+func sameTerm(a, b Mechanism) bool {
+	if a.Name != b.Name {
+		return false
+	}
+
+	// A record holds at most one all mechanism.
+	if a.Name == "all" {
+		return true
+	}
+
+	return strings.EqualFold(a.Domain, b.Domain) && a.Prefix == b.Prefix
+}
+
+// indexOf returns the index of the term in the record, or -1.
+func (s *SPF) indexOf(m Mechanism) int {
+	for i, existing := range s.Mechanisms {
+		if sameTerm(existing, m) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// AddMechanism adds a mechanism to the record. Mechanisms are inserted
+// before the all mechanism, so the record keeps its meaning. The mechanism
+// must be valid, not already present with any qualifier and must not take
+// the record over the lookup limit. Modifiers are set with SetModifier.
+func (s *SPF) AddMechanism(m Mechanism) error {
+	if !m.Valid() || isModifier(m.Name) {
+		return ErrInvalidMechanism
+	}
+
+	if s.indexOf(m) != -1 {
+		return ErrDuplicateMechanism
+	}
+
+	if s.Count+lookupCost(m) >= MaxCount {
+		return ErrMaxCount
+	}
+
+	pos := len(s.Mechanisms)
+	for i, existing := range s.Mechanisms {
+		if existing.Name == "all" && m.Name != "all" {
+			pos = i
+			break
+		}
+	}
+
+	s.Mechanisms = append(s.Mechanisms, Mechanism{})
+	copy(s.Mechanisms[pos+1:], s.Mechanisms[pos:])
+	s.Mechanisms[pos] = m
+
+	s.Count += lookupCost(m)
+	s.Raw = s.SPFString()
+
+	return nil
+}
+
+// RemoveMechanism removes the mechanism from the record. The mechanism is
+// matched on its name, domain and prefix, regardless of its qualifier. The
+// all mechanism of a record without a redirect is not removed, as the record
+// would default to Neutral, and ErrNoTerminal is returned.
+func (s *SPF) RemoveMechanism(m Mechanism) error {
+	i := s.indexOf(m)
+	if i == -1 {
+		return ErrMechanismNotFound
+	}
+
+	if s.Mechanisms[i].Name == "all" && s.modifier("redirect") == nil {
+		return ErrNoTerminal
+	}
+
+	s.Count -= lookupCost(s.Mechanisms[i])
+	s.Mechanisms = append(s.Mechanisms[:i], s.Mechanisms[i+1:]...)
+	s.Raw = s.SPFString()
+
+	return nil
+}
+
+// ReplaceMechanism replaces the old mechanism, matched like RemoveMechanism
+// does, by the new one at the same position. Replacing a mechanism by a
+// modifier is not allowed, nor the all mechanism by another one, as it would
+// change the meaning of the record.
+func (s *SPF) ReplaceMechanism(old, new Mechanism) error {
+	i := s.indexOf(old)
+	if i == -1 {
+		return ErrMechanismNotFound
+	}
+
+	if !new.Valid() || isModifier(new.Name) || (new.Name == "all") != (s.Mechanisms[i].Name == "all") {
+		return ErrInvalidMechanism
+	}
+
+	if j := s.indexOf(new); j != -1 && j != i {
+		return ErrDuplicateMechanism
+	}
+
+	count := s.Count - lookupCost(s.Mechanisms[i]) + lookupCost(new)
+	if count >= MaxCount {
+		return ErrMaxCount
+	}
+
+	s.Mechanisms[i] = new
+	s.Count = count
+	s.Raw = s.SPFString()
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package spf
+
+import (
+	"testing"
+)
+
+func mustMechanism(t *testing.T, term string) Mechanism {
+	m, err := NewMechanism(term, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func TestMutateMechanisms(t *testing.T) {
+	s, err := NewSPF("example.com", "v=spf1 ip4:192.0.2.0/24 -all redirect=_spf.example.com", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AddMechanism(mustMechanism(t, "include:_spf.vendor.example")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "v=spf1 ip4:192.0.2.0/24 include:_spf.vendor.example -all redirect=_spf.example.com"
+	if s.Raw != expected || s.Count != 2 {
+		t.Error("Expected", expected, "got", s.Raw, s.Count)
+	}
+
+	if err := s.AddMechanism(mustMechanism(t, "~ip4:192.0.2.0/24")); err != ErrDuplicateMechanism {
+		t.Error("Expected", ErrDuplicateMechanism, "got", err)
+	}
+
+	if err := s.AddMechanism(mustMechanism(t, "~all")); err != ErrDuplicateMechanism {
+		t.Error("Expected", ErrDuplicateMechanism, "got", err)
+	}
+
+	if err := s.AddMechanism(Mechanism{Name: "ip4", Domain: "300.0.0.1", Result: Pass}); err != ErrInvalidMechanism {
+		t.Error("Expected", ErrInvalidMechanism, "got", err)
+	}
+
+	if err := s.ReplaceMechanism(mustMechanism(t, "all"), mustMechanism(t, "~all")); err != nil {
+		t.Error(err)
+	}
+
+	if err := s.ReplaceMechanism(mustMechanism(t, "all"), mustMechanism(t, "mx")); err != ErrInvalidMechanism {
+		t.Error("Expected", ErrInvalidMechanism, "got", err)
+	}
+
+	if err := s.RemoveMechanism(mustMechanism(t, "ip4:192.0.2.0/24")); err != nil {
+		t.Error(err)
+	}
+
+	if err := s.RemoveMechanism(mustMechanism(t, "ip4:192.0.2.0/24")); err != ErrMechanismNotFound {
+		t.Error("Expected", ErrMechanismNotFound, "got", err)
+	}
+
+	expected = "v=spf1 include:_spf.vendor.example ~all redirect=_spf.example.com"
+	if s.SPFString() != expected || s.Raw != expected {
+		t.Error("Expected", expected, "got", s.SPFString())
+	}
+
+	for i := 0; i < 7; i++ {
+		s.AddMechanism(mustMechanism(t, "a:host"+string(rune('a'+i))+".example.com"))
+	}
+
+	if err := s.AddMechanism(mustMechanism(t, "mx")); err != ErrMaxCount {
+		t.Error("Expected", ErrMaxCount, "got", err)
+	}
+}
+
+func TestMutateTerminal(t *testing.T) {
+	s, err := NewSPF("example.com", "v=spf1 ip4:192.0.2.0/24 -all", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RemoveMechanism(mustMechanism(t, "all")); err != ErrNoTerminal {
+		t.Error("Expected", ErrNoTerminal, "got", err)
+	}
+
+	if err := s.SetModifier(Modifier{Name: "redirect", Value: "_spf.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RemoveMechanism(mustMechanism(t, "all")); err != nil {
+		t.Error("Expected", nil, "got", err)
+	}
+
+	if err := s.RemoveModifier("redirect"); err != ErrNoTerminal {
+		t.Error("Expected", ErrNoTerminal, "got", err)
+	}
+
+	expected := "v=spf1 ip4:192.0.2.0/24 redirect=_spf.example.com"
+	if s.Raw != expected {
+		t.Error("Expected", expected, "got", s.Raw)
+	}
+}
@@ -1,26 +1,43 @@
 package spf
 
 import (
-	"fmt"
+	"context"
 	"net"
+	"net/netip"
+	"strconv"
 	"strings"
+	"sync"
 )
 
-func networkCIDR(ip, prefix string) (*net.IPNet, error) {
-	if prefix == "" {
-		ip := net.ParseIP(ip)
+// ipMechanismContains reports whether the network of the ip4 or ip6
+// mechanism contains the client address, which must be unmapped. Without a
+// prefix the mechanism covers a single address, /32 for ip4 and /128 for
+// ip6. Addresses with a zone never match. It does not allocate.
+func ipMechanismContains(m *Mechanism, client netip.Addr) bool {
+	network, ok := ipMechanismNetwork(m)
 
-		if ip.To4() != nil {
-			prefix = "32"
-		} else {
-			prefix = "128"
+	return ok && network.Contains(client)
+}
+
+// ipMechanismNetwork returns the network of an ip4 or ip6 mechanism. ok is
+// false if the mechanism cannot match any client.
+func ipMechanismNetwork(m *Mechanism) (network netip.Prefix, ok bool) {
+	addr, err := netip.ParseAddr(m.Domain)
+	if err != nil || addr.Zone() != "" || (m.Name == "ip4") != addr.Is4() {
+		return network, false
+	}
+
+	bits := addr.BitLen()
+	if m.Prefix != "" {
+		bits, err = strconv.Atoi(m.Prefix)
+		if err != nil {
+			return network, false
 		}
 	}
 
-	cidrStr := fmt.Sprintf("%s/%s", ip, prefix)
+	network, err = addr.Prefix(bits)
 
-	_, network, err := net.ParseCIDR(cidrStr)
-	return network, err
+	return network, err == nil
 }
 
 func ipInNetworks(ip net.IP, networks []*net.IPNet) bool {
@@ -33,50 +50,251 @@ func ipInNetworks(ip net.IP, networks []*net.IPNet) bool {
 	return false
 }
 
-func buildNetworks(ips []string, prefix string) []*net.IPNet {
+// networkPool holds the network slices built by the a and mx mechanisms, so
+// that filters evaluating many messages reuse them instead of allocating new
+// ones for each evaluation.
+var networkPool = sync.Pool{
+	New: func() interface{} {
+		networks := make([]*net.IPNet, 0, 8)
+		return &networks
+	},
+}
+
+// testNetworks reports whether the client is covered by the networks of an a
+// or mx mechanism. ErrFailedLookup is returned if a lookup failed for another
+// reason than the name not existing, ErrTooManyMX if the domain of an mx
+// mechanism has more MX records than allowed.
+func testNetworks(e *evaluation, m *Mechanism) (bool, error) {
+	p := networkPool.Get().(*[]*net.IPNet)
+
 	var networks []*net.IPNet
+	var err error
+	if m.Name == "mx" {
+		networks, err = mxNetworks(e, m, (*p)[:0])
+	} else {
+		networks, err = aNetworks(e, m, (*p)[:0])
+	}
+
+	found := err == nil && ipInNetworks(e.parsedIP, networks)
+
+	// Drop the networks so the pool does not keep them alive.
+	for i := range networks {
+		networks[i] = nil
+	}
+	*p = networks[:0]
+	networkPool.Put(p)
+
+	return found, err
+}
+
+// hostNetworks appends to dst the networks of the A and AAAA records of
+// host, using the IPv4 and IPv6 prefix lengths of the dual-cidr-length prefix
+// of an a or mx mechanism, RFC 7208 section 5.6. Only the A records are
+// looked up if aaaa is false, only the AAAA ones if a is. The addresses are
+// returned too, for tracing. ErrFailedLookup is returned if a lookup failed
+// for another reason than the name not existing.
+func hostNetworks(ctx context.Context, r Resolver, host, prefix string, a, aaaa bool, dst []*net.IPNet) (networks []*net.IPNet, v4, v6 []net.IP, err error) {
+	ip4, ip6 := splitCIDR(prefix)
+
+	switch {
+	case a && aaaa:
+		v4, v6, err = lookupBoth(ctx, r, host)
+	case a:
+		v4, err = r.LookupIP(ctx, "ip4", host)
+	case aaaa:
+		v6, err = r.LookupIP(ctx, "ip6", host)
+	}
+
+	if lookupFailed(err) {
+		return dst, v4, v6, ErrFailedLookup
+	}
+
+	dst = appendNetworks(dst, v4, ip4, net.IPv4len*8)
+	dst = appendNetworks(dst, v6, ip6, net.IPv6len*8)
+
+	return dst, v4, v6, nil
+}
+
+// lookupBoth looks up the A and AAAA records of host concurrently, so a slow
+// server answering one of the queries does not delay the other. Both share
+// the deadline of ctx and the AAAA query is canceled if the A query fails.
+// The error returned is the one of the first query failing for another
+// reason than the name not existing.
+func lookupBoth(ctx context.Context, r Resolver, host string) (v4, v6 []net.IP, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var err6 error
+	done := make(chan struct{})
+	go func() {
+		v6, err6 = r.LookupIP(ctx, "ip6", host)
+		close(done)
+	}()
+
+	v4, err = r.LookupIP(ctx, "ip4", host)
+	if lookupFailed(err) {
+		cancel()
+	}
+	<-done
+
+	if lookupFailed(err) {
+		return v4, v6, err
+	}
+
+	return v4, v6, err6
+}
+
+// appendNetworks appends to dst the networks of the addresses of a family,
+// of bits bits, with the prefix length. The full length is used when the
+// prefix is empty. Addresses of the other family are skipped.
+func appendNetworks(dst []*net.IPNet, ips []net.IP, prefix string, bits int) []*net.IPNet {
+	ones := bits
+	if prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n < 0 || n > bits {
+			return dst
+		}
+		ones = n
+	}
+	mask := net.CIDRMask(ones, bits)
 
 	for _, ip := range ips {
-		network, err := networkCIDR(ip, prefix)
-		if err == nil {
-			networks = append(networks, network)
+		if bits == net.IPv4len*8 {
+			ip = ip.To4()
+		} else if ip.To4() == nil {
+			ip = ip.To16()
+		} else {
+			ip = nil
+		}
+
+		if ip != nil {
+			dst = append(dst, &net.IPNet{IP: ip.Mask(mask), Mask: mask})
 		}
 	}
 
-	return networks
+	return dst
 }
 
-func aNetworks(m *Mechanism) []*net.IPNet {
-	ips, _ := net.LookupHost(m.Domain)
+func aNetworks(e *evaluation, m *Mechanism, dst []*net.IPNet) ([]*net.IPNet, error) {
+	a, aaaa := e.families()
+	e.budget.A += e.queries()
+	dst, v4, v6, err := hostNetworks(e.ctx, e.checker.resolver(), m.Domain, m.Prefix, a, aaaa, dst)
+	if e.term != nil {
+		e.note("A %s: %s", m.Domain, joinIPs(v4, v6))
+	}
 
-	return buildNetworks(ips, m.Prefix)
+	return dst, err
 }
 
-func mxNetworks(m *Mechanism) []*net.IPNet {
-	var networks []*net.IPNet
+func mxNetworks(e *evaluation, m *Mechanism, dst []*net.IPNet) ([]*net.IPNet, error) {
+	r := e.checker.resolver()
+	e.budget.MX++
+	mxs, err := r.LookupMX(e.ctx, m.Domain)
+	if lookupFailed(err) {
+		e.note("MX %s:", m.Domain)
+		return dst, ErrFailedLookup
+	}
 
-	mxs, _ := net.LookupMX(m.Domain)
+	if len(mxs) > e.checker.mxLimit() {
+		e.note("MX %s: %d records", m.Domain, len(mxs))
+		return dst, ErrTooManyMX
+	}
 
+	a, aaaa := e.families()
 	for _, mx := range mxs {
-		ips, _ := net.LookupHost(mx.Host)
-		networks = append(networks, buildNetworks(ips, m.Prefix)...)
+		e.budget.MXHosts += e.queries()
+
+		var v4, v6 []net.IP
+		dst, v4, v6, err = hostNetworks(e.ctx, r, mx.Host, m.Prefix, a, aaaa, dst)
+		if e.term != nil {
+			e.note("MX %s: %s %s", m.Domain, mx.Host, joinIPs(v4, v6))
+		}
+
+		if err != nil {
+			return dst, err
+		}
+	}
+
+	if len(mxs) == 0 {
+		e.note("MX %s:", m.Domain)
 	}
 
-	return networks
+	return dst, nil
 }
 
-func testPTR(m *Mechanism, ip string) bool {
-	names, err := net.LookupAddr(ip)
+// lookupFailed reports whether err is a DNS failure rather than the name not
+// existing, which RFC 7208 section 5 treats as no match.
+func lookupFailed(err error) bool {
+	return err != nil && !isNotFound(err)
+}
+
+// testPTR reports whether a validated name of the client is the domain of
+// the ptr mechanism or one of its subdomains, RFC 7208 section 5.5. A name is
+// validated when it has an address of the family of the client that is the
+// client. Unlike the other mechanisms, a failed lookup is no match. Only the
+// first names, up to the Checker's PTRLimit, are considered.
+func testPTR(e *evaluation, m *Mechanism) bool {
+	e.budget.PTR++
+	names, err := e.checker.resolver().LookupAddr(e.ctx, e.ip)
+	e.note("PTR %s: %s", e.ip, strings.Join(names, " "))
 
 	if err != nil {
 		return false
 	}
 
+	if limit := e.checker.ptrLimit(); len(names) > limit {
+		names = names[:limit]
+	}
+
+	domain := canonicalName(m.Domain)
 	for _, name := range names {
-		if strings.HasSuffix(name, m.Domain) {
+		name = canonicalName(name)
+		if name != domain && !strings.HasSuffix(name, "."+domain) {
+			continue
+		}
+
+		if validName(e, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validName reports whether the name of the client resolves back to the
+// client. A failed lookup does not validate the name.
+func validName(e *evaluation, name string) bool {
+	network := "ip4"
+	if e.addr.Is6() {
+		network = "ip6"
+	}
+
+	ips, err := e.checker.resolver().LookupIP(e.ctx, network, name)
+	if e.term != nil {
+		e.note("A %s: %s", name, joinIPs(ips))
+	}
+
+	if err != nil {
+		return false
+	}
+
+	for _, ip := range ips {
+		if addr, ok := netip.AddrFromSlice(ip); ok && addr.Unmap() == e.addr {
 			return true
 		}
 	}
 
 	return false
 }
+
+// joinIPs joins the addresses of the lists with spaces.
+func joinIPs(lists ...[]net.IP) string {
+	var strs []string
+	for _, ips := range lists {
+		for _, ip := range ips {
+			strs = append(strs, ip.String())
+		}
+	}
+
+	return strings.Join(strs, " ")
+}
@@ -0,0 +1,68 @@
+package spf
+
+import (
+	"context"
+	"net/netip"
+)
+
+// Networks fully resolves the record, following includes and redirects and
+// resolving a and mx mechanisms, and returns every network authorized by a
+// Pass mechanism. A +all mechanism authorizes 0.0.0.0/0 and ::/0. Terms that
+// cannot be resolved ahead of time, like ptr and exists, and exclusions by
+// non-Pass mechanisms are not reflected in the result.
+func (s *SPF) Networks(ctx context.Context) ([]netip.Prefix, error) {
+	prefixes, _, err := s.networks(ctx)
+
+	return prefixes, err
+}
+
+// networks returns the authorized networks and the number of DNS lookups
+// the record requires.
+func (s *SPF) networks(ctx context.Context) ([]netip.Prefix, int, error) {
+	c := s.checker
+	if c == nil {
+		c = DefaultChecker
+	}
+
+	f := flattener{ctx: ctx, checker: c, visited: make(map[string]bool), union: true}
+
+	mechanisms, err := f.flatten(*s, Pass, true)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var prefixes []netip.Prefix
+	for _, m := range mechanisms {
+		if m.Result != Pass {
+			continue
+		}
+
+		if m.Name == "all" {
+			prefixes = append(prefixes, netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0"))
+			continue
+		}
+
+		if p, ok := mechanismPrefix(m); ok {
+			prefixes = append(prefixes, p)
+		}
+	}
+
+	return AggregatePrefixes(prefixes), f.lookups, nil
+}
+
+// Networks returns the networks authorized by the SPF record of the domain
+// using the DefaultChecker. See SPF.Networks.
+func Networks(ctx context.Context, domain string) ([]netip.Prefix, error) {
+	return DefaultChecker.Networks(ctx, domain)
+}
+
+// Networks returns the networks authorized by the SPF record of the domain.
+// See SPF.Networks.
+func (c *Checker) Networks(ctx context.Context, domain string) ([]netip.Prefix, error) {
+	spf, err := c.NewSPF(ctx, domain, "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return spf.Networks(ctx)
+}
@@ -0,0 +1,165 @@
+package spf
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrNoParkedRecord    = errors.New("Non-sending domain publishes no SPF record, publish v=spf1 -all.")
+	ErrAuthorizesSenders = errors.New("Term of a non-sending domain authorizes senders.")
+	ErrNotRestrictive    = errors.New("Record of a non-sending domain does not end with -all.")
+)
+
+// CommonSubdomains are subdomains often used, or abused, as sender domains,
+// worth locking down along with a non-sending domain.
+var CommonSubdomains = []string{"www", "mail", "smtp", "email", "news", "info"}
+
+// NonSendingResult is the verification of a domain that sends no mail.
+// Records holds the SPF and Sender ID records it publishes and Warnings the
+// problems found, none for a domain publishing ParkedRecord alone.
+type NonSendingResult struct {
+	Domain   string
+	Records  []string
+	Warnings []Warning
+}
+
+// OK reports whether the domain is locked down.
+func (r NonSendingResult) OK() bool {
+	return len(r.Warnings) == 0
+}
+
+// VerifyNonSending verifies a non-sending domain using the DefaultChecker.
+// See Checker.VerifyNonSending.
+func VerifyNonSending(ctx context.Context, domain string, subdomains ...string) []NonSendingResult {
+	return DefaultChecker.VerifyNonSending(ctx, domain, subdomains...)
+}
+
+// VerifyNonSending verifies that the domain, a parked domain for instance,
+// and each of the subdomains, relative to it or fully qualified, e.g.
+// CommonSubdomains, publish a single restrictive "v=spf1 -all" record and
+// no conflicting v=spf1 or Sender ID record authorizing senders. A result is
+// returned per domain, the domain first. With a Source other than DNS only
+// the record it provides is verified.
+func (c *Checker) VerifyNonSending(ctx context.Context, domain string, subdomains ...string) []NonSendingResult {
+	results := []NonSendingResult{c.verifyNonSending(ctx, canonicalName(domain))}
+
+	for _, name := range subdomains {
+		sub, err := subdomainOf(canonicalName(domain), name)
+		if err != nil {
+			results = append(results, NonSendingResult{Domain: name, Warnings: []Warning{{Term: name, Err: err}}})
+			continue
+		}
+
+		results = append(results, c.verifyNonSending(ctx, sub))
+	}
+
+	return results
+}
+
+// verifyNonSending verifies a single domain.
+func (c *Checker) verifyNonSending(ctx context.Context, domain string) NonSendingResult {
+	result := NonSendingResult{Domain: domain}
+
+	records, err := c.policyRecords(ctx, domain)
+	if err != nil {
+		result.Warnings = append(result.Warnings, Warning{Term: domain, Err: err})
+		return result
+	}
+	result.Records = records
+
+	var spf int
+	for _, record := range records {
+		if IsSenderID(record) {
+			converted, err := ConvertSenderID(record)
+			if err == ErrNoMfromScope {
+				continue
+			}
+			if err != nil {
+				result.Warnings = append(result.Warnings, Warning{Term: record, Err: err})
+				continue
+			}
+
+			result.Warnings = append(result.Warnings, restrictiveWarnings(domain, record, converted)...)
+			continue
+		}
+
+		spf++
+		if spf > 1 {
+			result.Warnings = append(result.Warnings, Warning{Term: record, Err: ErrMultipleRecords})
+		}
+
+		result.Warnings = append(result.Warnings, restrictiveWarnings(domain, record, record)...)
+	}
+
+	if spf == 0 {
+		result.Warnings = append(result.Warnings, Warning{Term: domain, Err: ErrNoParkedRecord})
+	}
+
+	return result
+}
+
+// policyRecords returns the v=spf1 and Sender ID records of the domain.
+func (c *Checker) policyRecords(ctx context.Context, domain string) ([]string, error) {
+	switch c.Source.(type) {
+	case nil, DNSSource:
+	default:
+		record, err := c.source().Record(ctx, domain)
+		if err != nil || record == "" {
+			return nil, err
+		}
+		return []string{record}, nil
+	}
+
+	txts, err := c.resolver().LookupTXT(ctx, domain)
+	if isNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, ErrFailedLookup
+	}
+
+	var records []string
+	for _, txt := range txts {
+		if IsSenderID(txt) || isSPFRecord(txt) {
+			records = append(records, txt)
+		}
+	}
+
+	return records, nil
+}
+
+// restrictiveWarnings returns the problems of a record of a non-sending
+// domain, given as published and as v=spf1 record: the terms before -all
+// not qualified with -, and a redirect.
+func restrictiveWarnings(domain, published, record string) []Warning {
+	spf, err := NewSPF(domain, record, 0)
+	if err != nil {
+		return []Warning{{Term: published, Err: err}}
+	}
+
+	var warnings []Warning
+	var all, hasAll bool
+
+	for _, m := range spf.Mechanisms {
+		if m.Name == "all" {
+			all, hasAll = m.Result == Fail, true
+			break
+		}
+
+		if m.Result != Fail {
+			warnings = append(warnings, Warning{Term: m.SPFString(), Err: ErrAuthorizesSenders})
+		}
+	}
+
+	if m := spf.modifier("redirect"); m != nil && !hasAll {
+		warnings = append(warnings, Warning{Term: m.SPFString(), Err: ErrAuthorizesSenders})
+	}
+
+	if !all {
+		warnings = append(warnings, Warning{Term: published, Err: ErrNotRestrictive})
+	}
+
+	return warnings
+}
@@ -0,0 +1,60 @@
+package spf
+
+import (
+	"context"
+	"testing"
+)
+
+func TestVerifyNonSending(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "parked.example", Type: "TXT", Data: "v=spf1 -all"},
+		ZoneRecord{Name: "parked.example", Type: "TXT", Data: "google-site-verification=abc"},
+		ZoneRecord{Name: "www.parked.example", Type: "TXT", Data: "v=spf1 -all"},
+		ZoneRecord{Name: "www.parked.example", Type: "TXT", Data: "spf2.0/mfrom include:_spf.example.net -all"},
+		ZoneRecord{Name: "mail.parked.example", Type: "TXT", Data: "v=spf1 -all"},
+		ZoneRecord{Name: "mail.parked.example", Type: "TXT", Data: "v=spf1 a -all"},
+		ZoneRecord{Name: "news.parked.example", Type: "TXT", Data: "v=spf1 redirect=example.net"},
+		ZoneRecord{Name: "info.parked.example", Type: "TXT", Data: "v=spf1 ~all"},
+		ZoneRecord{Name: "pra.parked.example", Type: "TXT", Data: "v=spf1 -all"},
+		ZoneRecord{Name: "pra.parked.example", Type: "TXT", Data: "spf2.0/pra +all"},
+	)
+
+	c := &Checker{Resolver: z}
+	results := c.VerifyNonSending(context.Background(), "parked.example", "www", "mail.parked.example", "news", "info", "smtp", "pra")
+
+	expected := [][]error{
+		nil,
+		{ErrAuthorizesSenders},
+		{ErrMultipleRecords, ErrAuthorizesSenders},
+		{ErrAuthorizesSenders, ErrNotRestrictive},
+		{ErrNotRestrictive},
+		{ErrNoParkedRecord},
+		nil,
+	}
+
+	if len(results) != len(expected) {
+		t.Fatal("Expected", len(expected), "got", results)
+	}
+
+	for i, r := range results {
+		t.Log("Analyzing", r.Domain, r.Warnings)
+
+		if len(r.Warnings) != len(expected[i]) || r.OK() != (expected[i] == nil) {
+			t.Error("Expected", expected[i], "got", r.Warnings)
+			continue
+		}
+
+		for j, w := range r.Warnings {
+			if w.Err != expected[i][j] {
+				t.Error("Expected", expected[i][j], "got", w.Err)
+			}
+		}
+	}
+
+	// Other sources only provide the record.
+	c = &Checker{Source: MapSource{"parked.example": "v=spf1 -all"}}
+	if r := c.VerifyNonSending(context.Background(), "parked.example", "example.com."); !r[0].OK() || r[1].Domain != "example.com.parked.example" || r[1].OK() {
+		t.Error("Unexpected results", r)
+	}
+}
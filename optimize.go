@@ -0,0 +1,171 @@
+package spf
+
+import (
+	"net/netip"
+	"sort"
+	"strconv"
+)
+
+// AggregatePrefixes returns the smallest set of prefixes covering exactly the
+// same addresses as the given prefixes. Overlapping prefixes are dropped and
+// adjacent ones are merged, e.g. two /25s into a /24.
+func AggregatePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	sorted := make([]netip.Prefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		if p.IsValid() {
+			sorted = append(sorted, p.Masked())
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if c := sorted[i].Addr().Compare(sorted[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return sorted[i].Bits() < sorted[j].Bits()
+	})
+
+	var stack []netip.Prefix
+	for _, p := range sorted {
+		if n := len(stack); n > 0 && stack[n-1].Contains(p.Addr()) && stack[n-1].Bits() <= p.Bits() {
+			continue
+		}
+
+		stack = append(stack, p)
+
+		for n := len(stack); n >= 2 && siblings(stack[n-2], stack[n-1]); n = len(stack) {
+			parent := netip.PrefixFrom(stack[n-1].Addr(), stack[n-1].Bits()-1).Masked()
+			stack = append(stack[:n-2], parent)
+		}
+	}
+
+	return stack
+}
+
+// siblings reports whether a and b are the two halves of the same prefix.
+func siblings(a, b netip.Prefix) bool {
+	if a == b || a.Bits() != b.Bits() || a.Bits() == 0 || a.Addr().Is4() != b.Addr().Is4() {
+		return false
+	}
+
+	pa := netip.PrefixFrom(a.Addr(), a.Bits()-1).Masked()
+	pb := netip.PrefixFrom(b.Addr(), b.Bits()-1).Masked()
+
+	return pa == pb
+}
+
+// subtractPrefixes returns the prefixes covering the addresses of p outside of
+// every excluded prefix.
+func subtractPrefixes(p netip.Prefix, excluded []netip.Prefix) []netip.Prefix {
+	p = p.Masked()
+
+	for _, x := range excluded {
+		if x.Addr().Is4() != p.Addr().Is4() {
+			continue
+		}
+
+		if x.Bits() <= p.Bits() && x.Contains(p.Addr()) {
+			return nil
+		}
+
+		if x.Bits() > p.Bits() && p.Contains(x.Addr()) {
+			lower := netip.PrefixFrom(p.Addr(), p.Bits()+1)
+
+			b := p.Addr().AsSlice()
+			b[p.Bits()/8] |= 0x80 >> (p.Bits() % 8)
+			addr, _ := netip.AddrFromSlice(b)
+			upper := netip.PrefixFrom(addr, p.Bits()+1)
+
+			return append(subtractPrefixes(lower, excluded), subtractPrefixes(upper, excluded)...)
+		}
+	}
+
+	return []netip.Prefix{p}
+}
+
+// Optimize shrinks the record by aggregating its ip4 and ip6 mechanisms.
+// Only runs of consecutive ip4 and ip6 mechanisms sharing the same qualifier
+// are merged, so the result of evaluating the record never changes.
+func (s *SPF) Optimize() {
+	s.Mechanisms = OptimizeMechanisms(s.Mechanisms)
+}
+
+// OptimizeMechanisms returns the mechanisms with every run of consecutive ip4
+// and ip6 mechanisms sharing a qualifier replaced by the minimal set of
+// mechanisms covering the same networks.
+func OptimizeMechanisms(mechanisms []Mechanism) []Mechanism {
+	var optimized []Mechanism
+	var run []netip.Prefix
+	var qualifier Result
+
+	flush := func() {
+		for _, p := range AggregatePrefixes(run) {
+			optimized = append(optimized, prefixMechanism(p, qualifier))
+		}
+		run = run[:0]
+	}
+
+	for _, m := range mechanisms {
+		p, ok := mechanismPrefix(m)
+		if ok && (len(run) == 0 || m.Result == qualifier) {
+			qualifier = m.Result
+			run = append(run, p)
+			continue
+		}
+
+		flush()
+
+		if ok {
+			qualifier = m.Result
+			run = append(run, p)
+			continue
+		}
+
+		optimized = append(optimized, m)
+	}
+
+	flush()
+
+	return optimized
+}
+
+// mechanismPrefix returns the network of an ip4 or ip6 mechanism.
+func mechanismPrefix(m Mechanism) (netip.Prefix, bool) {
+	if m.Name != "ip4" && m.Name != "ip6" {
+		return netip.Prefix{}, false
+	}
+
+	addr, err := netip.ParseAddr(m.Domain)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+
+	bits := addr.BitLen()
+	if m.Prefix != "" {
+		bits, err = strconv.Atoi(m.Prefix)
+		if err != nil {
+			return netip.Prefix{}, false
+		}
+	}
+
+	p, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+
+	return p, true
+}
+
+// prefixMechanism returns the ip4 or ip6 mechanism matching the network.
+func prefixMechanism(p netip.Prefix, r Result) Mechanism {
+	m := Mechanism{Name: "ip6", Domain: p.Addr().String(), Result: r}
+	if p.Addr().Is4() {
+		m.Name = "ip4"
+	}
+
+	if p.Bits() != p.Addr().BitLen() {
+		m.Prefix = strconv.Itoa(p.Bits())
+	}
+
+	return m
+}
@@ -0,0 +1,74 @@
+package spf
+
+import (
+	"net/netip"
+	"testing"
+)
+
+type aggtest struct {
+	prefixes []string
+	expected []string
+}
+
+func TestAggregatePrefixes(t *testing.T) {
+	tests := []aggtest{
+		aggtest{[]string{"192.0.2.0/25", "192.0.2.128/25"}, []string{"192.0.2.0/24"}},
+		aggtest{[]string{"192.0.2.0/24", "192.0.2.77/32"}, []string{"192.0.2.0/24"}},
+		aggtest{[]string{"192.0.2.1/32", "192.0.2.2/32"}, []string{"192.0.2.1/32", "192.0.2.2/32"}},
+		aggtest{
+			[]string{"10.0.0.0/26", "10.0.0.128/25", "10.0.0.64/26", "2001:db8::/33", "2001:db8:8000::/33"},
+			[]string{"10.0.0.0/24", "2001:db8::/32"},
+		},
+		aggtest{[]string{"192.0.2.5/24"}, []string{"192.0.2.0/24"}},
+	}
+
+	for _, tcase := range tests {
+		var prefixes []netip.Prefix
+		for _, p := range tcase.prefixes {
+			prefixes = append(prefixes, netip.MustParsePrefix(p))
+		}
+
+		actual := AggregatePrefixes(prefixes)
+		if len(actual) != len(tcase.expected) {
+			t.Error("Expected", tcase.expected, "got", actual)
+			continue
+		}
+
+		for i := range actual {
+			if actual[i].String() != tcase.expected[i] {
+				t.Error("Expected", tcase.expected, "got", actual)
+				break
+			}
+		}
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []spfstr{
+		spfstr{
+			"v=spf1 ip4:192.0.2.0/25 ip4:192.0.2.128/25 ip4:192.0.2.4 -all",
+			"v=spf1 ip4:192.0.2.0/24 -all",
+		},
+		spfstr{
+			"v=spf1 ip4:192.0.2.0/25 -ip4:192.0.2.200 ip4:192.0.2.128/25 mx ip6:2001:db8::1 ip6:2001:db8::/64 ~all",
+			"v=spf1 ip4:192.0.2.0/25 -ip4:192.0.2.200 ip4:192.0.2.128/25 mx ip6:2001:db8::/64 ~all",
+		},
+		spfstr{
+			"v=spf1 -ip4:192.0.2.0/25 -ip4:192.0.2.128/25 +all",
+			"v=spf1 -ip4:192.0.2.0/24 +all",
+		},
+	}
+
+	for _, tcase := range tests {
+		s, err := NewSPF("domain", tcase.raw, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s.Optimize()
+		if r := s.SPFString(); r != tcase.expected {
+			t.Log("Analyzing", tcase.raw)
+			t.Error("Expected", tcase.expected, "got", r)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package spf
+
+import (
+	"context"
+)
+
+// OrgFallback is the record of the organizational domain of a domain
+// publishing no SPF record, see Checker.OrgFallback.
+type OrgFallback struct {
+	Domain string
+	Record string
+
+	// Result is the result the record of the organizational domain gives
+	// for the check, None if it publishes no record, and Err the cause of
+	// a TempError or PermError.
+	Result Result
+	Err    error
+}
+
+// orgFallback evaluates the record of the organizational domain of the
+// domain of e, which published no record. It returns nil when the domain is
+// its own organizational domain.
+func (c *Checker) orgFallback(ctx context.Context, e *evaluation) *OrgFallback {
+	org := OrganizationalDomain(e.domain)
+	if org == canonicalName(e.domain) {
+		return nil
+	}
+
+	fallback := &OrgFallback{Domain: org}
+
+	record, err := c.source().Record(ctx, org)
+	if err != nil {
+		fallback.Result, fallback.Err = TempError, err
+		return fallback
+	}
+	fallback.Record = record
+
+	oe := newEvaluation(ctx, c, e.addr)
+	oe.helo = e.helo
+	oe.sender = e.sender
+	oe.domain = org
+	fallback.Result = c.checkRecord(oe, record)
+	fallback.Err = oe.err
+
+	return fallback
+}
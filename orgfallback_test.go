@@ -0,0 +1,53 @@
+package spf
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOrgFallback(t *testing.T) {
+	c := &Checker{
+		Source: MapSource{
+			"example.co.uk":     "v=spf1 ip4:192.0.2.0/24 -all",
+			"sub.example.co.uk": "",
+		},
+		OrgFallback: true,
+	}
+
+	tests := []struct {
+		ip     string
+		sender string
+		org    string
+		result Result
+	}{
+		{"192.0.2.1", "info@mail.example.co.uk", "example.co.uk", Pass},
+		{"203.0.113.1", "info@sub.example.co.uk", "example.co.uk", Fail},
+		{"192.0.2.1", "info@example.co.uk", "", ""},
+		{"192.0.2.1", "info@mail.example.net", "example.net", None},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.ip, test.sender)
+
+		cr := c.Check(context.Background(), Request{IP: test.ip, Sender: test.sender})
+
+		var org string
+		var result Result
+		if cr.Org != nil {
+			org, result = cr.Org.Domain, cr.Org.Result
+		}
+
+		if org != test.org || result != test.result {
+			t.Error("Expected", test.org, test.result, "got", org, result)
+		}
+
+		if test.org != "" && cr.Result != None {
+			t.Error("Expected", None, "got", cr.Result)
+		}
+	}
+
+	c.OrgFallback = false
+	if cr := c.Check(context.Background(), Request{IP: "192.0.2.1", Sender: "info@mail.example.co.uk"}); cr.Org != nil {
+		t.Error("Expected", nil, "got", cr.Org)
+	}
+}
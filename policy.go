@@ -0,0 +1,193 @@
+package spf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Action is the SMTP behaviour recommended for a result.
+type Action string
+
+const (
+	Accept  Action = "Accept"  // accept the message
+	Prepend Action = "Prepend" // accept the message and prepend a Received-SPF header
+	Reject  Action = "Reject"  // reject the message with a 5xx reply
+	Defer   Action = "Defer"   // temporarily reject the message with a 4xx reply
+)
+
+// Policy is the local policy used to map a Result to an SMTP action. The zero
+// value accepts every message, prepending a Received-SPF header when the
+// result is not Pass.
+type Policy struct {
+	RejectFail      bool
+	RejectSoftFail  bool
+	RejectPermError bool
+	DeferTempError  bool
+
+	// RejectNone rejects mail from domains publishing no SPF record.
+	RejectNone bool
+
+	// RejectNeutral rejects Neutral results of a ?-qualified mechanism,
+	// such as ?all, and RejectNoMatch those of a record none of whose
+	// mechanisms matched, see CheckResult.NoMatch. Decide cannot tell them
+	// apart and only applies RejectNeutral.
+	RejectNeutral bool
+	RejectNoMatch bool
+}
+
+// DefaultPolicy rejects Fail results and defers TempError results as RFC
+// 7208 section 8 suggests.
+var DefaultPolicy = Policy{RejectFail: true, DeferTempError: true}
+
+// Decision is the recommended SMTP behaviour for an SPF result. Code and
+// EnhancedCode hold the reply codes, as defined in RFC 7372, for Reject and
+// Defer actions.
+type Decision struct {
+	Result       Result
+	Action       Action
+	Code         int
+	EnhancedCode string
+	Text         string
+}
+
+// Reply returns the SMTP reply line for Reject and Defer decisions, or an
+// empty string for others.
+func (d Decision) Reply() string {
+	if d.Code == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d %s %s", d.Code, d.EnhancedCode, d.Text)
+}
+
+// Decide maps the result to the recommended SMTP behaviour. The explanation,
+// typically from the record's exp= modifier, is included in the reply text
+// of rejected Fail results, without its control and non-ASCII characters.
+func (p Policy) Decide(result Result, explanation string) Decision {
+	return p.decide(result, false, explanation)
+}
+
+// DecideCheck is like Decide but tells the Neutral result of a record
+// without matching mechanism from the one of a ?-qualified mechanism. An
+// empty explanation defaults to the one of the check.
+func (p Policy) DecideCheck(cr CheckResult, explanation string) Decision {
+	if explanation == "" {
+		explanation = cr.Explanation
+	}
+
+	return p.decide(cr.Result, cr.NoMatch, explanation)
+}
+
+// sanitize removes the control and non-ASCII characters of an explanation
+// or header value, which could inject lines into the SMTP reply or message.
+func sanitize(explanation string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, explanation)
+}
+
+func (p Policy) decide(result Result, noMatch bool, explanation string) Decision {
+	d := Decision{Result: result, Action: Prepend}
+
+	switch result {
+	case Pass:
+		d.Action = Accept
+	case Fail:
+		if p.RejectFail {
+			d.Action = Reject
+			d.Code = 550
+			d.EnhancedCode = "5.7.23"
+			d.Text = "SPF validation failed"
+			if explanation = strings.TrimSpace(sanitize(explanation)); explanation != "" {
+				d.Text = fmt.Sprintf("%s: %s", d.Text, explanation)
+			}
+		}
+	case SoftFail:
+		if p.RejectSoftFail {
+			d.Action = Reject
+			d.Code = 550
+			d.EnhancedCode = "5.7.23"
+			d.Text = "SPF validation soft failed"
+		}
+	case Neutral:
+		if (noMatch && p.RejectNoMatch) || (!noMatch && p.RejectNeutral) {
+			d.Action = Reject
+			d.Code = 550
+			d.EnhancedCode = "5.7.23"
+			d.Text = "SPF validation neutral"
+		}
+	case None:
+		if p.RejectNone {
+			d.Action = Reject
+			d.Code = 550
+			d.EnhancedCode = "5.7.23"
+			d.Text = "No SPF record"
+		}
+	case TempError:
+		if p.DeferTempError {
+			d.Action = Defer
+			d.Code = 451
+			d.EnhancedCode = "4.7.24"
+			d.Text = "SPF validation temporary error"
+		}
+	case PermError:
+		if p.RejectPermError {
+			d.Action = Reject
+			d.Code = 550
+			d.EnhancedCode = "5.7.24"
+			d.Text = "SPF validation permanent error"
+		}
+	}
+
+	return d
+}
+
+// ReceivedSPF returns a Received-SPF header field, as defined in RFC 7208
+// section 9.1, recording the result for the client ip and sender. The
+// receiver is the host name of the receiving MTA. Control and non-ASCII
+// characters of the values are removed so they cannot add header lines.
+func ReceivedSPF(result Result, ip, sender, receiver string) string {
+	var buf bytes.Buffer
+
+	ip, sender, receiver = sanitize(ip), sanitize(sender), sanitize(receiver)
+
+	domain := sender
+	if i := strings.LastIndex(sender, "@"); i != -1 {
+		domain = sender[i+1:]
+	}
+
+	var comment string
+	switch result {
+	case Pass:
+		comment = fmt.Sprintf("domain of %s designates %s as permitted sender", sender, ip)
+	case Fail:
+		comment = fmt.Sprintf("domain of %s does not designate %s as permitted sender", sender, ip)
+	case SoftFail:
+		comment = fmt.Sprintf("domain of transitioning %s does not designate %s as permitted sender", sender, ip)
+	case Neutral:
+		comment = fmt.Sprintf("%s is neither permitted nor denied by domain of %s", ip, domain)
+	case None:
+		comment = fmt.Sprintf("domain of %s does not designate permitted sender hosts", sender)
+	case TempError:
+		comment = fmt.Sprintf("error in processing during lookup of %s", domain)
+	case PermError:
+		comment = fmt.Sprintf("permanent error in processing domain of %s", domain)
+	}
+
+	buf.WriteString(fmt.Sprintf("Received-SPF: %s", strings.ToLower(string(result))))
+	if receiver != "" {
+		buf.WriteString(fmt.Sprintf(" (%s: %s)", receiver, comment))
+	} else {
+		buf.WriteString(fmt.Sprintf(" (%s)", comment))
+	}
+	buf.WriteString(fmt.Sprintf(" client-ip=%s; envelope-from=\"%s\";", ip, sender))
+	if receiver != "" {
+		buf.WriteString(fmt.Sprintf(" receiver=%s;", receiver))
+	}
+
+	return buf.String()
+}
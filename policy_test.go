@@ -0,0 +1,133 @@
+package spf
+
+import (
+	"context"
+	"testing"
+)
+
+type decisiontest struct {
+	policy   Policy
+	result   Result
+	action   Action
+	expected string
+}
+
+func TestDecide(t *testing.T) {
+	strict := Policy{RejectFail: true, RejectSoftFail: true, RejectPermError: true, DeferTempError: true}
+
+	tests := []decisiontest{
+		decisiontest{DefaultPolicy, Pass, Accept, ""},
+		decisiontest{DefaultPolicy, Fail, Reject, "550 5.7.23 SPF validation failed: not authorized"},
+		decisiontest{DefaultPolicy, SoftFail, Prepend, ""},
+		decisiontest{DefaultPolicy, Neutral, Prepend, ""},
+		decisiontest{DefaultPolicy, None, Prepend, ""},
+		decisiontest{DefaultPolicy, TempError, Defer, "451 4.7.24 SPF validation temporary error"},
+		decisiontest{DefaultPolicy, PermError, Prepend, ""},
+		decisiontest{strict, SoftFail, Reject, "550 5.7.23 SPF validation soft failed"},
+		decisiontest{strict, PermError, Reject, "550 5.7.24 SPF validation permanent error"},
+		decisiontest{Policy{}, Fail, Prepend, ""},
+		decisiontest{Policy{RejectNone: true}, None, Reject, "550 5.7.23 No SPF record"},
+		decisiontest{Policy{RejectNeutral: true}, Neutral, Reject, "550 5.7.23 SPF validation neutral"},
+		decisiontest{Policy{RejectNoMatch: true}, Neutral, Prepend, ""},
+	}
+
+	for _, tcase := range tests {
+		d := tcase.policy.Decide(tcase.result, "not authorized")
+
+		if d.Action != tcase.action || d.Reply() != tcase.expected {
+			t.Error("For", tcase.result, "Expected", tcase.action, tcase.expected, "got", d.Action, d.Reply())
+		}
+	}
+}
+
+func TestDecideCheck(t *testing.T) {
+	c := &Checker{Source: MapSource{
+		"default.example":  "v=spf1 ip4:192.0.2.1",
+		"explicit.example": "v=spf1 ip4:192.0.2.1 ?all",
+		"redirect.example": "v=spf1 redirect=default.example",
+		"include.example":  "v=spf1 include:default.example ?all",
+	}}
+	policy := Policy{RejectNone: true, RejectNoMatch: true}
+
+	tests := map[string]Action{
+		"default.example":  Reject,
+		"explicit.example": Prepend,
+		"redirect.example": Reject,
+		"include.example":  Prepend,
+		"none.example":     Reject,
+	}
+
+	for domain, action := range tests {
+		t.Log("Analyzing", domain)
+
+		cr := c.Check(context.Background(), Request{IP: "203.0.113.1", Sender: "info@" + domain})
+		if d := policy.DecideCheck(cr, ""); d.Action != action {
+			t.Error("Expected", action, "got", d.Action, cr)
+		}
+	}
+}
+
+func TestReceivedSPF(t *testing.T) {
+	expected := `Received-SPF: softfail (mx.example.org: domain of transitioning info@example.com does not designate 192.0.2.1 as permitted sender) client-ip=192.0.2.1; envelope-from="info@example.com"; receiver=mx.example.org;`
+
+	if h := ReceivedSPF(SoftFail, "192.0.2.1", "info@example.com", "mx.example.org"); h != expected {
+		t.Error("Expected", expected, "got", h)
+	}
+
+	expected = `Received-SPF: fail (mx.example.org: domain of info@example.comX-Injected: yes does not designate 192.0.2.1 as permitted sender) client-ip=192.0.2.1; envelope-from="info@example.comX-Injected: yes"; receiver=mx.example.org;`
+
+	if h := ReceivedSPF(Fail, "192.0.2.1\n", "info@example.com\r\nX-Injected: yes", "mx.example.org\r\n"); h != expected {
+		t.Error("Expected", expected, "got", h)
+	}
+}
+
+func TestExplanation(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 ip4:192.0.2.0/24 include:_inc.example.com -all exp=explain._spf.%{d}"},
+		ZoneRecord{Name: "explain._spf.example.com", Type: "TXT", Data: "%{i} is not one of %{d}'s designated mail servers, see %{r}"},
+		ZoneRecord{Name: "_inc.example.com", Type: "TXT", Data: "v=spf1 -ip4:198.51.100.0/24 exp=inc.example.com"},
+		ZoneRecord{Name: "inc.example.com", Type: "TXT", Data: "Not explained by includes"},
+		ZoneRecord{Name: "redirect.example.com", Type: "TXT", Data: "v=spf1 redirect=example.com exp=inc.example.com"},
+		ZoneRecord{Name: "missing.example.com", Type: "TXT", Data: "v=spf1 -all exp=nx.example.com"},
+		ZoneRecord{Name: "twice.example.com", Type: "TXT", Data: "v=spf1 -all exp=texts.example.com"},
+		ZoneRecord{Name: "texts.example.com", Type: "TXT", Data: "First"},
+		ZoneRecord{Name: "texts.example.com", Type: "TXT", Data: "Second"},
+		ZoneRecord{Name: "macro.example.com", Type: "TXT", Data: "v=spf1 -all exp=_exp.macro.example.com"},
+		ZoneRecord{Name: "_exp.macro.example.com", Type: "TXT", Data: "Unknown %{z} macro"},
+		ZoneRecord{Name: "inject.example.com", Type: "TXT", Data: "v=spf1 -all exp=_exp.inject.example.com"},
+		ZoneRecord{Name: "_exp.inject.example.com", Type: "TXT", Data: "Denied\r\n250 OK é%{c}"},
+	)
+
+	c := &Checker{Resolver: z}
+
+	tests := []struct {
+		ip          string
+		domain      string
+		explanation string
+	}{
+		{"203.0.113.1", "example.com", "203.0.113.1 is not one of example.com's designated mail servers, see mx.example.org"},
+		{"198.51.100.1", "example.com", "198.51.100.1 is not one of example.com's designated mail servers, see mx.example.org"},
+		{"192.0.2.1", "example.com", ""},
+		{"203.0.113.1", "redirect.example.com", "203.0.113.1 is not one of example.com's designated mail servers, see mx.example.org"},
+		{"203.0.113.1", "missing.example.com", ""},
+		{"203.0.113.1", "twice.example.com", ""},
+		{"203.0.113.1", "macro.example.com", ""},
+		{"2001:db8::1", "inject.example.com", "Denied\r\n250 OK é2001:db8::1"},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.ip, test.domain)
+
+		cr := c.Check(context.Background(), Request{IP: test.ip, Sender: "info@" + test.domain, Receiver: "mx.example.org"})
+		if cr.Explanation != test.explanation {
+			t.Error("Expected", test.explanation, "got", cr.Explanation)
+		}
+	}
+
+	cr := c.Check(context.Background(), Request{IP: "2001:db8::1", Sender: "info@inject.example.com"})
+	expected := "550 5.7.23 SPF validation failed: Denied250 OK 2001:db8::1"
+	if reply := DefaultPolicy.DecideCheck(cr, "").Reply(); reply != expected {
+		t.Error("Expected", expected, "got", reply)
+	}
+}
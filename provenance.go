@@ -0,0 +1,138 @@
+package spf
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/netip"
+	"time"
+)
+
+// Origin is where a term of a flattened record comes from.
+type Origin struct {
+	// Mechanism is the term of the published record that resolved to the
+	// flattened term, e.g. "mx" or "a:mail.example.com", or the term
+	// itself when it was kept as is.
+	Mechanism string `json:"mechanism"`
+
+	// Domain is the domain of the record holding Mechanism.
+	Domain string `json:"domain"`
+
+	// Chain lists the include and redirect terms followed from the top
+	// level record to reach that record, outermost first. It is empty for
+	// the terms of the top level record.
+	Chain []string `json:"chain,omitempty"`
+}
+
+// Origin returns where a term of a Flattened record or a Matcher comes from,
+// nil for the terms of records that were not flattened.
+func (m *Mechanism) Origin() *Origin {
+	return m.origin
+}
+
+// NetworkOrigin is a network authorized by a record and the Origin of the
+// term authorizing it.
+type NetworkOrigin struct {
+	Prefix netip.Prefix
+	Origin Origin
+}
+
+// Provenance fully resolves the record like Networks and returns the networks
+// authorized by each Pass term with its Origin, in record order. Unlike those
+// returned by Networks, the networks are not aggregated, so networks
+// authorized through several terms are listed once for each.
+func (s *SPF) Provenance(ctx context.Context) ([]NetworkOrigin, error) {
+	c := s.checker
+	if c == nil {
+		c = DefaultChecker
+	}
+
+	f := flattener{ctx: ctx, checker: c, visited: make(map[string]bool), union: true}
+
+	mechanisms, err := f.flatten(*s, Pass, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []NetworkOrigin
+	for _, m := range mechanisms {
+		if m.Result != Pass {
+			continue
+		}
+
+		var prefixes []netip.Prefix
+		if m.Name == "all" {
+			prefixes = []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0")}
+		} else if p, ok := mechanismPrefix(m); ok {
+			prefixes = []netip.Prefix{p}
+		}
+
+		for _, p := range prefixes {
+			networks = append(networks, NetworkOrigin{Prefix: p, Origin: *m.origin})
+		}
+	}
+
+	return networks, nil
+}
+
+// Provenance returns the networks authorized by the SPF record of the domain
+// with their origin. See SPF.Provenance.
+func (c *Checker) Provenance(ctx context.Context, domain string) ([]NetworkOrigin, error) {
+	spf, err := c.NewSPF(ctx, domain, "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return spf.Provenance(ctx)
+}
+
+// FlattenReport documents a Flattened record, mapping each of its terms back
+// to the published term it comes from so the record can be maintained
+// without retracing the includes by hand. It is meant to be stored next to
+// the published flattened record, see WriteJSON.
+type FlattenReport struct {
+	Domain    string        `json:"domain"`
+	Record    string        `json:"record"`
+	Lookups   int           `json:"lookups"`
+	TTL       time.Duration `json:"ttl"`
+	Generated time.Time     `json:"generated"`
+	Terms     []TermOrigin  `json:"terms"`
+}
+
+// TermOrigin is a term of a flattened record and its Origin.
+type TermOrigin struct {
+	Term string `json:"term"`
+	Origin
+}
+
+// Report returns the FlattenReport of the record. Lookups is the number of
+// lookups the published record requires.
+func (f *Flattened) Report() *FlattenReport {
+	report := &FlattenReport{
+		Domain:    f.Domain,
+		Record:    f.SPFString(),
+		Lookups:   f.Lookups,
+		TTL:       f.TTL,
+		Generated: time.Now(),
+		Terms:     []TermOrigin{},
+	}
+
+	for _, m := range f.Mechanisms {
+		term := TermOrigin{Term: m.SPFString()}
+		if m.origin != nil {
+			term.Origin = *m.origin
+		}
+
+		report.Terms = append(report.Terms, term)
+	}
+
+	return report
+}
+
+// WriteJSON writes the report as an indented JSON document.
+func (r *FlattenReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r)
+}
@@ -0,0 +1,99 @@
+package spf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestProvenance(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(flattenZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Resolver: z}
+
+	networks, err := c.Provenance(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		prefix    string
+		mechanism string
+		domain    string
+		chain     string
+	}{
+		{"192.0.2.25/32", "mx:example.org", "example.org", ""},
+		{"2001:db8::25/128", "mx:example.org", "example.org", ""},
+		{"198.51.100.0/24", "ip4:198.51.100.0/24", "_spf.vendor.example", "include:_spf.vendor.example"},
+		{"2001:db8:1::/48", "ip6:2001:db8:1::/48", "_net.vendor.example", "include:_spf.vendor.example include:_net.vendor.example"},
+		{"192.0.2.128/28", "a:relay.example.org/28", "_rest.example.org", "redirect=_rest.example.org"},
+	}
+
+	if len(networks) != len(tests) {
+		t.Fatal("Expected", len(tests), "networks got", networks)
+	}
+
+	for i, test := range tests {
+		n := networks[i]
+		t.Log("Analyzing", test.prefix)
+
+		if n.Prefix.String() != test.prefix || n.Origin.Mechanism != test.mechanism || n.Origin.Domain != test.domain || strings.Join(n.Origin.Chain, " ") != test.chain {
+			t.Error("Expected", test, "got", n)
+		}
+	}
+}
+
+func TestFlattenReport(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(flattenZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Resolver: z}
+
+	flat, err := c.Flatten(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := flat.Report().WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var report FlattenReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Record != flat.SPFString() || report.Lookups != flat.Lookups || len(report.Terms) != len(flat.Mechanisms) {
+		t.Fatal("Unexpected report", buf.String())
+	}
+
+	tests := []TermOrigin{
+		{"ip4:192.0.2.25", Origin{"mx:example.org", "example.org", nil}},
+		{"ip6:2001:db8:1::/48", Origin{"ip6:2001:db8:1::/48", "_net.vendor.example", []string{"include:_spf.vendor.example", "include:_net.vendor.example"}}},
+		{"ptr:_rest.example.org", Origin{"ptr:_rest.example.org", "_rest.example.org", []string{"redirect=_rest.example.org"}}},
+		{"~all", Origin{"~all", "_rest.example.org", []string{"redirect=_rest.example.org"}}},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.Term)
+
+		found := false
+		for _, term := range report.Terms {
+			if term.Term == test.Term {
+				found = term.Mechanism == test.Mechanism && term.Domain == test.Domain && strings.Join(term.Chain, " ") == strings.Join(test.Chain, " ")
+			}
+		}
+
+		if !found {
+			t.Error("Expected", test, "got", report.Terms)
+		}
+	}
+}
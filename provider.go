@@ -0,0 +1,140 @@
+package spf
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+var (
+	ErrInvalidProvider = errors.New("Provider without a name, includes or networks.")
+)
+
+// Provider is a well-known mail provider, identified by the records its
+// customers include or by the networks it sends from. An include matches
+// when its domain is one of Includes or a subdomain of one. An ip4 or ip6
+// mechanism matches when its network is within one of Networks.
+type Provider struct {
+	Name     string         `json:"name"`
+	Includes []string       `json:"includes,omitempty"`
+	Networks []netip.Prefix `json:"networks,omitempty"`
+}
+
+// KnownProviders is the dataset used to identify providers, taken from the
+// records the providers published at the time of writing. The networks of
+// providers change; replace the dataset, e.g. with LoadProviders, to keep
+// it current. It must not be modified while records are being analyzed.
+var KnownProviders = []Provider{
+	{
+		Name:     "Google Workspace",
+		Includes: []string{"_spf.google.com", "_netblocks.google.com", "_netblocks2.google.com", "_netblocks3.google.com"},
+		Networks: prefixes("35.190.247.0/24", "64.233.160.0/19", "66.102.0.0/20", "66.249.80.0/20", "72.14.192.0/18",
+			"74.125.0.0/16", "108.177.8.0/21", "173.194.0.0/16", "209.85.128.0/17", "216.58.192.0/19",
+			"2001:4860:4000::/36", "2404:6800:4000::/36", "2607:f8b0:4000::/36", "2800:3f0:4000::/36", "2a00:1450:4000::/36"),
+	},
+	{
+		Name:     "Microsoft 365",
+		Includes: []string{"spf.protection.outlook.com"},
+		Networks: prefixes("40.92.0.0/15", "40.107.0.0/16", "52.100.0.0/14", "104.47.0.0/17",
+			"2a01:111:f400::/48", "2a01:111:f403::/49"),
+	},
+	{
+		Name:     "SendGrid",
+		Includes: []string{"sendgrid.net"},
+		Networks: prefixes("149.72.0.0/16", "159.183.0.0/16", "167.89.0.0/17", "168.245.0.0/17", "198.37.144.0/20", "208.117.48.0/20"),
+	},
+	{
+		Name:     "Mailgun",
+		Includes: []string{"mailgun.org"},
+		Networks: prefixes("69.72.32.0/20", "141.193.32.0/23", "159.135.224.0/20", "161.38.192.0/20", "166.78.68.0/22", "198.61.254.0/23", "209.61.151.0/24"),
+	},
+	{
+		Name:     "Amazon SES",
+		Includes: []string{"amazonses.com"},
+		Networks: prefixes("23.249.208.0/20", "23.251.224.0/19", "54.240.0.0/18", "69.169.224.0/20", "76.223.128.0/19", "199.127.232.0/22", "199.255.192.0/22"),
+	},
+}
+
+func prefixes(cidrs ...string) []netip.Prefix {
+	var p []netip.Prefix
+	for _, cidr := range cidrs {
+		p = append(p, netip.MustParsePrefix(cidr))
+	}
+
+	return p
+}
+
+// LoadProviders reads a provider dataset, a JSON array of objects with the
+// name, includes and networks keys, e.g. to replace KnownProviders.
+func LoadProviders(r io.Reader) ([]Provider, error) {
+	var providers []Provider
+	if err := json.NewDecoder(r).Decode(&providers); err != nil {
+		return nil, err
+	}
+
+	for _, p := range providers {
+		if p.Name == "" || len(p.Includes)+len(p.Networks) == 0 {
+			return nil, ErrInvalidProvider
+		}
+	}
+
+	return providers, nil
+}
+
+// IdentifyProvider returns the name of the known provider of an include or
+// redirect target, or of the networks of an ip4 or ip6 mechanism, and the
+// empty string if the term matches none of KnownProviders.
+func IdentifyProvider(m Mechanism) string {
+	switch m.Name {
+	case "include", "redirect":
+		domain := canonicalName(m.Domain)
+		for _, p := range KnownProviders {
+			for _, inc := range p.Includes {
+				inc = canonicalName(inc)
+				if domain == inc || strings.HasSuffix(domain, "."+inc) {
+					return p.Name
+				}
+			}
+		}
+	case "ip4", "ip6":
+		prefix, ok := mechanismPrefix(m)
+		if !ok {
+			return ""
+		}
+
+		for _, p := range KnownProviders {
+			for _, network := range p.Networks {
+				if network.Bits() <= prefix.Bits() && network.Contains(prefix.Addr()) {
+					return p.Name
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// providerNote returns the provider of the term, in parentheses after a
+// space, or the empty string.
+func providerNote(m Mechanism) string {
+	if name := IdentifyProvider(m); name != "" {
+		return " (" + name + ")"
+	}
+
+	return ""
+}
+
+// Providers maps the terms of the record identified by IdentifyProvider to
+// the names of their providers.
+func (s *SPF) Providers() map[string]string {
+	providers := make(map[string]string)
+	for _, m := range s.terms() {
+		if name := IdentifyProvider(m); name != "" {
+			providers[m.SPFString()] = name
+		}
+	}
+
+	return providers
+}
@@ -0,0 +1,74 @@
+package spf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIdentifyProvider(t *testing.T) {
+	tests := []struct {
+		term     string
+		provider string
+	}{
+		{"include:_spf.google.com", "Google Workspace"},
+		{"include:SPF.Protection.Outlook.com.", "Microsoft 365"},
+		{"include:u1234567.wl.sendgrid.net", "SendGrid"},
+		{"include:mailgun.org", "Mailgun"},
+		{"include:amazonses.com", "Amazon SES"},
+		{"include:notamazonses.com", ""},
+		{"ip4:209.85.220.0/24", "Google Workspace"},
+		{"ip4:209.85.0.0/16", ""},
+		{"ip6:2a01:111:f400::1", "Microsoft 365"},
+		{"a:mail.google.com", ""},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.term)
+
+		m, err := NewMechanism(test.term, "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if provider := IdentifyProvider(m); provider != test.provider {
+			t.Error("Expected", test.provider, "got", provider)
+		}
+	}
+
+	s, err := NewSPF("example.com", "v=spf1 include:_spf.google.com ip4:192.0.2.1 redirect=spf.protection.outlook.com", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	providers := s.Providers()
+	if len(providers) != 2 || providers["redirect=spf.protection.outlook.com"] != "Microsoft 365" {
+		t.Error("Expected two providers got", providers)
+	}
+
+	if !strings.Contains(s.String(), "include:_spf.google.com - Pass (Google Workspace)") {
+		t.Error("Expected an annotated include got", s.String())
+	}
+}
+
+func TestLoadProviders(t *testing.T) {
+	providers, err := LoadProviders(strings.NewReader(`[{"name": "Example Mail", "includes": ["spf.mail.example"], "networks": ["192.0.2.0/24"]}]`))
+	if err != nil || len(providers) != 1 || providers[0].Networks[0].Bits() != 24 {
+		t.Fatal("Expected one provider got", providers, err)
+	}
+
+	saved := KnownProviders
+	defer func() { KnownProviders = saved }()
+	KnownProviders = providers
+
+	if provider := IdentifyProvider(Mechanism{Name: "ip4", Domain: "192.0.2.10"}); provider != "Example Mail" {
+		t.Error("Expected Example Mail got", provider)
+	}
+
+	for _, text := range []string{`[{"name": "Empty"}]`, `[{"name": "Bad", "networks": ["192.0.2.0/33"]}]`} {
+		t.Log("Analyzing", text)
+
+		if _, err := LoadProviders(strings.NewReader(text)); err == nil {
+			t.Error("Expected an error got nil")
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// maxRateZones is the number of zone limiters kept by a
+	// RateLimitedResolver before the idle ones are dropped.
+	maxRateZones = 4096
+)
+
+var (
+	ErrRateLimited = errors.New("DNS query rate limit would exceed the deadline.")
+)
+
+// RateLimitedResolver wraps a Resolver to limit the rate of the queries it
+// sends, overall and to each zone, with token buckets. Queries wait for a
+// token, up to the context deadline, so crawlers and busy servers stay polite
+// towards authoritative servers and public resolvers. The zone of a query is
+// the organizational domain of its name, see OrganizationalDomain; reverse
+// lookups share the zone of the in-addr.arpa or ip6.arpa tree. A
+// RateLimitedResolver must not be copied after first use.
+type RateLimitedResolver struct {
+	// Resolver performs the lookups. If nil, net.DefaultResolver is used.
+	Resolver Resolver
+
+	// QPS is the rate of all queries, in queries per second, and Burst
+	// the number of queries that may be sent at once. A zero QPS does not
+	// limit the rate. Burst defaults to QPS, rounded up.
+	QPS   float64
+	Burst int
+
+	// ZoneQPS and ZoneBurst limit the queries for names of each zone
+	// likewise.
+	ZoneQPS   float64
+	ZoneBurst int
+
+	once   sync.Once
+	global *rate.Limiter
+
+	mu    sync.Mutex
+	zones map[string]*rate.Limiter
+}
+
+func (r *RateLimitedResolver) resolver() Resolver {
+	if r.Resolver == nil {
+		return net.DefaultResolver
+	}
+
+	return r.Resolver
+}
+
+// newLimiter returns the limiter of the rate, nil for a zero rate.
+func newLimiter(qps float64, burst int) *rate.Limiter {
+	if qps <= 0 {
+		return nil
+	}
+
+	if burst <= 0 {
+		burst = int(math.Ceil(qps))
+	}
+
+	return rate.NewLimiter(rate.Limit(qps), burst)
+}
+
+// zone returns the limiter of the zone of name, nil if zones are not
+// limited.
+func (r *RateLimitedResolver) zone(name string) *rate.Limiter {
+	if r.ZoneQPS <= 0 {
+		return nil
+	}
+
+	zone := rateZone(name)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if l, ok := r.zones[zone]; ok {
+		return l
+	}
+
+	if r.zones == nil {
+		r.zones = make(map[string]*rate.Limiter)
+	}
+
+	// Limiters with a full bucket have been idle long enough to be
+	// recreated without letting any query through early.
+	if len(r.zones) >= maxRateZones {
+		for z, l := range r.zones {
+			if l.Tokens() >= float64(l.Burst()) {
+				delete(r.zones, z)
+			}
+		}
+	}
+
+	l := newLimiter(r.ZoneQPS, r.ZoneBurst)
+	r.zones[zone] = l
+
+	return l
+}
+
+// rateZone returns the zone of a query for name.
+func rateZone(name string) string {
+	name = canonicalName(name)
+	for _, tree := range []string{"in-addr.arpa", "ip6.arpa"} {
+		if name == tree || strings.HasSuffix(name, "."+tree) {
+			return tree
+		}
+	}
+
+	return OrganizationalDomain(name)
+}
+
+// wait waits for the tokens of a query for name. It returns the context's
+// error if it is cancelled, or ErrRateLimited if the wait would exceed its
+// deadline.
+func (r *RateLimitedResolver) wait(ctx context.Context, name string) error {
+	r.once.Do(func() {
+		r.global = newLimiter(r.QPS, r.Burst)
+	})
+
+	for _, l := range []*rate.Limiter{r.zone(name), r.global} {
+		if l == nil {
+			continue
+		}
+
+		if err := l.Wait(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return ErrRateLimited
+		}
+	}
+
+	return nil
+}
+
+// LookupTXT looks up the TXT records for name.
+func (r *RateLimitedResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if err := r.wait(ctx, name); err != nil {
+		return nil, err
+	}
+
+	return r.resolver().LookupTXT(ctx, name)
+}
+
+// LookupHost looks up the addresses of host.
+func (r *RateLimitedResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if err := r.wait(ctx, host); err != nil {
+		return nil, err
+	}
+
+	return r.resolver().LookupHost(ctx, host)
+}
+
+// LookupMX looks up the MX records for name.
+func (r *RateLimitedResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if err := r.wait(ctx, name); err != nil {
+		return nil, err
+	}
+
+	return r.resolver().LookupMX(ctx, name)
+}
+
+// LookupAddr looks up the names of addr.
+func (r *RateLimitedResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	name, err := reverseName(addr)
+	if err != nil {
+		name = addr
+	}
+
+	if err := r.wait(ctx, name); err != nil {
+		return nil, err
+	}
+
+	return r.resolver().LookupAddr(ctx, addr)
+}
+
+// LookupIP looks up the addresses of host for the given network.
+func (r *RateLimitedResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	if err := r.wait(ctx, host); err != nil {
+		return nil, err
+	}
+
+	return r.resolver().LookupIP(ctx, network, host)
+}
+
+// LookupTTL forwards to the wrapped Resolver if it implements TTLResolver.
+func (r *RateLimitedResolver) LookupTTL(ctx context.Context, name, rtype string) (time.Duration, error) {
+	if tr, ok := r.resolver().(TTLResolver); ok {
+		if err := r.wait(ctx, name); err != nil {
+			return 0, err
+		}
+
+		return tr.LookupTTL(ctx, name, rtype)
+	}
+
+	return 0, ErrNoTTL
+}
@@ -0,0 +1,52 @@
+package spf
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedResolver(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 -all"},
+		ZoneRecord{Name: "mail.example.com", Type: "A", Data: "192.0.2.1"},
+		ZoneRecord{Name: "example.org", Type: "TXT", Data: "v=spf1 -all"},
+	)
+
+	r := &RateLimitedResolver{Resolver: z, QPS: 1000, ZoneQPS: 1, ZoneBurst: 2}
+	ctx := context.Background()
+
+	// The burst of example.com is used up by the first two queries, a
+	// third one would have to wait a second.
+	for _, name := range []string{"example.com", "mail.example.com"} {
+		t.Log("Analyzing", name)
+
+		if _, err := r.LookupHost(ctx, name); err != nil && !isNotFound(err) {
+			t.Error("Expected no rate limiting got", err)
+		}
+	}
+
+	short, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := r.LookupTXT(short, "example.com"); err != ErrRateLimited {
+		t.Error("Expected", ErrRateLimited, "got", err)
+	}
+
+	if txt, err := r.LookupTXT(short, "example.org"); err != nil || len(txt) != 1 {
+		t.Error("Expected the record of another zone got", txt, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if _, err := r.LookupTXT(cancelled, "example.com"); err != context.Canceled {
+		t.Error("Expected", context.Canceled, "got", err)
+	}
+
+	c := &Checker{Resolver: &RateLimitedResolver{Resolver: z, QPS: 1}}
+	if result, _ := c.SPFTest(ctx, "192.0.2.1", "info@example.com"); result != Fail {
+		t.Error("Expected Fail got", result)
+	}
+}
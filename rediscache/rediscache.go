@@ -0,0 +1,47 @@
+// Package rediscache provides a Redis backed spf.Cache, letting a fleet of
+// mail filters share SPF resolution state.
+package rediscache
+
+import (
+	"context"
+	"time"
+
+	"github.com/asggo/spf"
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache is an spf.Cache storing values in Redis. Expiry is left to Redis.
+type Cache struct {
+	// Client is the Redis client, e.g. a *redis.Client or a
+	// *redis.ClusterClient.
+	Client redis.Cmdable
+
+	// Prefix is prepended to every key, separating the cache from other
+	// data stored in the same database.
+	Prefix string
+}
+
+// New returns a Cache using the client, with keys prefixed by "spf:".
+func New(client redis.Cmdable) *Cache {
+	return &Cache{Client: client, Prefix: "spf:"}
+}
+
+// Get returns the value of the key, spf.ErrCacheMiss if not set or expired.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.Client.Get(ctx, c.Prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, spf.ErrCacheMiss
+	}
+
+	return value, err
+}
+
+// Set stores the value of the key for the ttl. Values with a ttl of zero or
+// less, which Redis would keep forever, are not stored.
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return c.Client.Set(ctx, c.Prefix+key, value, ttl).Err()
+}
@@ -0,0 +1,48 @@
+package rediscache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/asggo/spf"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCache(t *testing.T) {
+	s := miniredis.RunT(t)
+	c := New(redis.NewClient(&redis.Options{Addr: s.Addr()}))
+	ctx := context.Background()
+
+	if _, err := c.Get(ctx, "TXT example.com"); err != spf.ErrCacheMiss {
+		t.Error("Expected", spf.ErrCacheMiss, "got", err)
+	}
+
+	c.Set(ctx, "TXT example.com", []byte("value"), time.Hour)
+	c.Set(ctx, "TXT expiring.example.com", []byte("value"), time.Minute)
+	c.Set(ctx, "TXT zero.example.com", []byte("value"), 0)
+
+	value, err := c.Get(ctx, "TXT example.com")
+	if err != nil || string(value) != "value" {
+		t.Error("Expected value got", string(value), err)
+	}
+
+	if !s.Exists("spf:TXT example.com") {
+		t.Error("Expected the key to be prefixed")
+	}
+
+	if s.Exists("spf:TXT zero.example.com") {
+		t.Error("Expected a zero TTL value not to be stored")
+	}
+
+	s.FastForward(2 * time.Minute)
+
+	if _, err := c.Get(ctx, "TXT expiring.example.com"); err != spf.ErrCacheMiss {
+		t.Error("Expected", spf.ErrCacheMiss, "got", err)
+	}
+
+	if _, err := c.Get(ctx, "TXT example.com"); err != nil {
+		t.Error("Expected", nil, "got", err)
+	}
+}
@@ -0,0 +1,101 @@
+package spf
+
+import (
+	"sort"
+)
+
+// specificity returns the number of host bits of the network of an ip4 or
+// ip6 mechanism, the narrowest networks being evaluated first.
+func specificity(m Mechanism) int {
+	p, _ := mechanismPrefix(m)
+
+	return p.Addr().BitLen() - p.Bits()
+}
+
+// reorderable reports whether the term may be moved within a run of such
+// terms: a pass ip4 or ip6 mechanism, which needs no lookup and cannot fail.
+// Every other term is a barrier. A term needing a lookup, such as include,
+// a, mx, exists or an unknown mechanism, may end the evaluation with
+// TempError or PermError, so moving a network before it could change a
+// client's result.
+func reorderable(m Mechanism) bool {
+	_, ok := mechanismPrefix(m)
+
+	return ok && m.Result == Pass
+}
+
+// SortBySpecificity reorders the pass mechanisms of the record from the most
+// specific to the least specific, so the most likely matches are evaluated
+// first. See SortMechanisms.
+func (s *SPF) SortBySpecificity() {
+	s.Mechanisms = SortMechanisms(s.Mechanisms)
+}
+
+// SortMechanisms returns the mechanisms with every run of consecutive pass
+// ip4 and ip6 mechanisms sorted by specificity, from the narrowest network
+// to the widest. The other mechanisms and modifiers never move, so a client
+// gets the same result from both records.
+func SortMechanisms(mechanisms []Mechanism) []Mechanism {
+	sorted := make([]Mechanism, len(mechanisms))
+	copy(sorted, mechanisms)
+
+	for start := 0; start < len(sorted); {
+		if !reorderable(sorted[start]) {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(sorted) && reorderable(sorted[end]) {
+			end++
+		}
+
+		run := sorted[start:end]
+		sort.SliceStable(run, func(i, j int) bool {
+			return specificity(run[i]) < specificity(run[j])
+		})
+
+		start = end
+	}
+
+	return sorted
+}
+
+// EquivalentOrder reports whether b only differs from a by the order of
+// mechanisms within runs of consecutive pass ip4 and ip6 mechanisms, i.e.
+// whether reordering a into b keeps the result of every client, like
+// SortMechanisms does.
+func EquivalentOrder(a, b []Mechanism) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for start := 0; start < len(a); {
+		if !reorderable(a[start]) {
+			if a[start].SPFString() != b[start].SPFString() {
+				return false
+			}
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(a) && reorderable(a[end]) {
+			end++
+		}
+
+		for _, m := range b[start:end] {
+			if !reorderable(m) {
+				return false
+			}
+		}
+
+		if !sameTerms(a[start:end], b[start:end]) {
+			return false
+		}
+
+		start = end
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+package spf
+
+import (
+	"testing"
+)
+
+func TestSortBySpecificity(t *testing.T) {
+	tests := []spfstr{
+		spfstr{
+			"v=spf1 include:_spf.example.com mx ip4:192.0.2.0/24 ip4:192.0.2.1 -all",
+			"v=spf1 include:_spf.example.com mx ip4:192.0.2.1 ip4:192.0.2.0/24 -all",
+		},
+		spfstr{
+			"v=spf1 a ip6:2001:db8::/32 -ip4:192.0.2.1 exists:%{i}.example.com ip4:198.51.100.0/24 ~all",
+			"v=spf1 a ip6:2001:db8::/32 -ip4:192.0.2.1 exists:%{i}.example.com ip4:198.51.100.0/24 ~all",
+		},
+		spfstr{
+			"v=spf1 ip6:2001:db8::/32 ip4:198.51.100.0/24 ip4:192.0.2.1 a ip4:203.0.113.0/28 ip4:203.0.113.1 -all",
+			"v=spf1 ip4:192.0.2.1 ip4:198.51.100.0/24 ip6:2001:db8::/32 a ip4:203.0.113.1 ip4:203.0.113.0/28 -all",
+		},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.raw)
+
+		s, err := NewSPF("example.com", test.raw, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		original := s.Mechanisms
+		s.SortBySpecificity()
+
+		if s.SPFString() != test.expected {
+			t.Error("Expected", test.expected, "got", s.SPFString())
+		}
+
+		if !EquivalentOrder(original, s.Mechanisms) {
+			t.Error("Expected equivalent order for", s.SPFString())
+		}
+	}
+}
+
+func TestEquivalentOrder(t *testing.T) {
+	a, _ := NewSPF("example.com", "v=spf1 mx -ip4:192.0.2.1 ip4:192.0.2.0/24 -all", 0)
+	b, _ := NewSPF("example.com", "v=spf1 -ip4:192.0.2.1 mx ip4:192.0.2.0/24 -all", 0)
+	c, _ := NewSPF("example.com", "v=spf1 mx -ip4:192.0.2.1 ip4:192.0.2.0/24 ~all", 0)
+
+	if EquivalentOrder(a.Mechanisms, b.Mechanisms) {
+		t.Error("Expected moving a qualified mechanism to be unsafe")
+	}
+
+	if EquivalentOrder(a.Mechanisms, c.Mechanisms) {
+		t.Error("Expected a different all to be unsafe")
+	}
+
+	// A lookup failing before the network is reached ends the evaluation.
+	d, _ := NewSPF("example.com", "v=spf1 include:broken.example ip4:192.0.2.1 -all", 0)
+	e, _ := NewSPF("example.com", "v=spf1 ip4:192.0.2.1 include:broken.example -all", 0)
+
+	if EquivalentOrder(d.Mechanisms, e.Mechanisms) {
+		t.Error("Expected moving a network before an include to be unsafe")
+	}
+
+	if !EquivalentOrder(a.Mechanisms, a.Mechanisms) {
+		t.Error("Expected a record to be equivalent to itself")
+	}
+}
@@ -0,0 +1,72 @@
+package spf
+
+import (
+	"context"
+)
+
+// Report summarizes the SPF record of a domain. Its field names and layout
+// are stable so serialized reports, in JSON or YAML, can be compared over
+// time to detect configuration drift.
+type Report struct {
+	Domain     string   `json:"domain" yaml:"domain"`
+	Record     string   `json:"record" yaml:"record"`
+	Mechanisms []string `json:"mechanisms" yaml:"mechanisms"`
+	Networks   []string `json:"networks" yaml:"networks"`
+	Lookups    int      `json:"lookups" yaml:"lookups"`
+	Warnings   []string `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+
+	// Providers maps the terms of Mechanisms belonging to a known mail
+	// provider to its name, see IdentifyProvider.
+	Providers map[string]string `json:"providers,omitempty" yaml:"providers,omitempty"`
+}
+
+// NewReport builds a Report for the domain using the DefaultChecker.
+func NewReport(ctx context.Context, domain string) (*Report, error) {
+	return DefaultChecker.NewReport(ctx, domain)
+}
+
+// NewReport builds a Report for the domain. The record is parsed leniently so
+// problems are listed as warnings. Networks lists the networks authorized by
+// the fully resolved record and Lookups the number of DNS lookups needed to
+// resolve it.
+func (c *Checker) NewReport(ctx context.Context, domain string) (*Report, error) {
+	spf, warnings, err := c.NewSPFLenient(ctx, domain, "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes, lookups, err := spf.networks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Domain:     domain,
+		Record:     spf.Raw,
+		Mechanisms: []string{},
+		Networks:   []string{},
+		Lookups:    lookups,
+	}
+
+	for _, m := range spf.Mechanisms {
+		report.Mechanisms = append(report.Mechanisms, m.SPFString())
+	}
+
+	for _, m := range spf.Modifiers {
+		report.Mechanisms = append(report.Mechanisms, m.SPFString())
+	}
+
+	if providers := spf.Providers(); len(providers) > 0 {
+		report.Providers = providers
+	}
+
+	for _, p := range prefixes {
+		report.Networks = append(report.Networks, p.String())
+	}
+
+	for _, w := range warnings {
+		report.Warnings = append(report.Warnings, w.String())
+	}
+
+	return report, nil
+}
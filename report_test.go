@@ -0,0 +1,40 @@
+package spf
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewReport(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(flattenZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z.Add(ZoneRecord{Name: "bad.example", Type: "TXT", Data: "v=spf1 ip4:192.0.2.1 bogus -all"})
+	c := &Checker{Resolver: z}
+
+	report, err := c.NewReport(context.Background(), "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, _ := json.Marshal(report)
+	expected := `{"domain":"example.org","record":"v=spf1 mx include:_spf.vendor.example -ip4:192.0.2.66 redirect=_rest.example.org",` +
+		`"mechanisms":["mx:example.org","include:_spf.vendor.example","-ip4:192.0.2.66","redirect=_rest.example.org"],` +
+		`"networks":["192.0.2.25/32","192.0.2.128/28","198.51.100.0/24","2001:db8::25/128","2001:db8:1::/48"],"lookups":6}`
+	if string(out) != expected {
+		t.Error("Expected", expected, "got", string(out))
+	}
+
+	report, err = c.NewReport(context.Background(), "bad.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Warnings) != 1 || report.Warnings[0] != "bogus: "+ErrUnknownMechanism.Error() {
+		t.Error("Unexpected warnings", report.Warnings)
+	}
+}
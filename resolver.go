@@ -0,0 +1,26 @@
+package spf
+
+import (
+	"context"
+	"net"
+)
+
+// Resolver performs the DNS lookups needed to evaluate an SPF record. The
+// methods match those of *net.Resolver, which satisfies the interface.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+// isNotFound reports whether err is a DNS error indicating the name does not
+// exist.
+func isNotFound(err error) bool {
+	if dnsErr, ok := err.(*net.DNSError); ok {
+		return dnsErr.IsNotFound
+	}
+
+	return false
+}
@@ -0,0 +1,92 @@
+package spf
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// randomTerm returns a random valid term in its normalized form.
+func randomTerm(r *rand.Rand) string {
+	qualifiers := []string{"", "+", "-", "~", "?"}
+	domains := []string{"example.net", "_spf.example.org", "%{i}._ip.%{d}", "%{l1r+-}.users.example.com", "mail.example.com."}
+	q := qualifiers[r.Intn(len(qualifiers))]
+	d := domains[r.Intn(len(domains))]
+
+	switch r.Intn(8) {
+	case 0:
+		return q + "ip4:192.0.2." + []string{"1", "0/24", "128/25"}[r.Intn(3)]
+	case 1:
+		return q + "ip6:" + []string{"2001:db8::1", "2001:db8::/32", "::/0"}[r.Intn(3)]
+	case 2:
+		return q + "a" + []string{"", ":" + d}[r.Intn(2)] + []string{"", "/24", "//64", "/24//64"}[r.Intn(4)]
+	case 3:
+		return q + "mx" + []string{"", ":" + d}[r.Intn(2)] + []string{"", "/28"}[r.Intn(2)]
+	case 4:
+		return q + "ptr" + []string{"", ":" + d}[r.Intn(2)]
+	case 5:
+		return q + "include:" + d
+	case 6:
+		return q + "exists:" + d
+	}
+
+	return q + "all"
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(7208))
+
+	for i := 0; i < 1000; i++ {
+		terms := []string{"v=spf1"}
+		for n := r.Intn(8); n > 0; n-- {
+			terms = append(terms, randomTerm(r))
+		}
+
+		// redirect may appear anywhere in the record and is written after
+		// the mechanisms.
+		expected := strings.Join(terms, " ")
+		if r.Intn(3) == 0 {
+			at := 1 + r.Intn(len(terms))
+			terms = append(terms[:at], append([]string{"redirect=_spf.example.org"}, terms[at:]...)...)
+			expected += " redirect=_spf.example.org"
+		}
+
+		record := strings.Join(terms, " ")
+
+		s, err := NewSPF("example.com", record, 0)
+		if err == ErrMaxCount || err == ErrIncludeLoop {
+			continue
+		}
+
+		if err != nil {
+			t.Error("For", record, "got", err)
+			continue
+		}
+
+		if s.SPFString() != expected {
+			t.Error("Expected", expected, "got", s.SPFString())
+		}
+	}
+}
+
+func TestRoundTripNormalization(t *testing.T) {
+	tests := []spfstr{
+		spfstr{"v=spf1  MX\tInclude:_spf.example.org  -ALL ", "v=spf1 mx include:_spf.example.org -all"},
+		spfstr{"v=spf1 redirect:_spf.example.org", "v=spf1 redirect=_spf.example.org"},
+		spfstr{"v=spf1 ip6:2001:DB8:0::1 ~all", "v=spf1 ip6:2001:db8::1 ~all"},
+		spfstr{"v=spf1 a:example.com a mx:EXAMPLE.com -all", "v=spf1 a:example.com a mx:EXAMPLE.com -all"},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.raw)
+
+		s, err := NewSPF("example.com", test.raw, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.SPFString() != test.expected {
+			t.Error("Expected", test.expected, "got", s.SPFString())
+		}
+	}
+}
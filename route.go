@@ -0,0 +1,94 @@
+package spf
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// Route sends the lookups for the names under Suffix, e.g. "corp.example",
+// to Resolver.
+type Route struct {
+	Suffix   string
+	Resolver Resolver
+}
+
+// RoutingResolver sends each lookup to the Resolver of the route with the
+// longest suffix matching the looked up name, or to Default when no route
+// matches. Reverse lookups are routed on the in-addr.arpa or ip6.arpa name
+// of the address, e.g. "10.in-addr.arpa" for 10.0.0.0/8.
+type RoutingResolver struct {
+	Routes []Route
+
+	// Default performs the lookups matching no route. If nil,
+	// net.DefaultResolver is used.
+	Default Resolver
+}
+
+// route returns the Resolver for the name.
+func (r *RoutingResolver) route(name string) Resolver {
+	name = canonicalName(name)
+
+	var best Resolver
+	longest := -1
+	for _, route := range r.Routes {
+		suffix := canonicalName(route.Suffix)
+		if name != suffix && !strings.HasSuffix(name, "."+suffix) {
+			continue
+		}
+
+		if len(suffix) > longest {
+			best, longest = route.Resolver, len(suffix)
+		}
+	}
+
+	if best != nil {
+		return best
+	}
+
+	if r.Default != nil {
+		return r.Default
+	}
+
+	return net.DefaultResolver
+}
+
+// LookupTXT looks up the TXT records for name.
+func (r *RoutingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return r.route(name).LookupTXT(ctx, name)
+}
+
+// LookupHost looks up the addresses of host.
+func (r *RoutingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return r.route(host).LookupHost(ctx, host)
+}
+
+// LookupMX looks up the MX records for name.
+func (r *RoutingResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return r.route(name).LookupMX(ctx, name)
+}
+
+// LookupAddr looks up the names of addr.
+func (r *RoutingResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	name, err := reverseName(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.route(name).LookupAddr(ctx, addr)
+}
+
+// LookupIP looks up the addresses of host for the given network.
+func (r *RoutingResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	return r.route(host).LookupIP(ctx, network, host)
+}
+
+// LookupTTL forwards to the routed Resolver if it implements TTLResolver.
+func (r *RoutingResolver) LookupTTL(ctx context.Context, name, rtype string) (time.Duration, error) {
+	if tr, ok := r.route(name).(TTLResolver); ok {
+		return tr.LookupTTL(ctx, name, rtype)
+	}
+
+	return 0, ErrNoTTL
+}
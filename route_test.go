@@ -0,0 +1,59 @@
+package spf
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	public, err := ParseZone(strings.NewReader(`
+$ORIGIN example.com.
+@          IN TXT "v=spf1 include:_spf.corp.example.com -all"
+_spf.corp  IN TXT "v=spf1 ip4:203.0.113.1 -all"
+`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	internal, err := ParseZone(strings.NewReader(`
+$ORIGIN corp.example.com.
+_spf       IN TXT "v=spf1 a:relay.corp.example.com -all"
+relay      IN A   10.0.0.25
+25.0.0.10.in-addr.arpa. IN PTR relay.corp.example.com.
+`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{
+		Resolver: public,
+		Routes: []Route{
+			Route{Suffix: "corp.example.com", Resolver: internal},
+			Route{Suffix: "10.in-addr.arpa", Resolver: internal},
+		},
+	}
+
+	tests := []spftest{
+		spftest{"10.0.0.25", "info@example.com", Pass},
+		spftest{"203.0.113.1", "info@example.com", Fail},
+	}
+
+	for _, expected := range tests {
+		actual, _ := c.SPFTest(context.Background(), expected.server, expected.email)
+
+		if actual != expected.result {
+			t.Error("For", expected.server, "at", expected.email, "Expected", expected.result, "got", actual)
+		}
+	}
+
+	names, err := c.resolver().LookupAddr(context.Background(), "10.0.0.25")
+	if err != nil || len(names) != 1 {
+		t.Error("Expected reverse lookup to be routed got", names, err)
+	}
+
+	r := &RoutingResolver{Routes: c.Routes, Default: public}
+	if r.route("notcorp.example.com") != public || r.route("CORP.example.com.") != internal {
+		t.Error("Expected routes to match on label boundaries")
+	}
+}
@@ -0,0 +1,295 @@
+package spf
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+var (
+	ErrInvalidRule = errors.New("Invalid local policy rule.")
+)
+
+// LocalRule replaces the result of a check matching all its conditions by
+// Then, e.g. to soften the failures of a partner whose forwarders are known
+// to break SPF, or to pass clients of the internal network. Conditions left
+// empty match every check.
+type LocalRule struct {
+	// Result matches the result of the check.
+	Result Result `json:"result,omitempty"`
+
+	// Domain matches the domain whose record was evaluated. A domain
+	// starting with a dot, e.g. ".partner.example", matches its subdomains,
+	// any other only itself.
+	Domain string `json:"domain,omitempty"`
+
+	// Networks matches clients within any of the prefixes.
+	Networks []netip.Prefix `json:"client,omitempty"`
+
+	Then Result `json:"then"`
+}
+
+// Return a LocalRule as a string, in the syntax read by ParseRules, e.g.
+// "if result==Fail and domain ends with .partner.example then SoftFail".
+func (r LocalRule) String() string {
+	var conditions []string
+
+	if r.Result != "" {
+		conditions = append(conditions, "result=="+string(r.Result))
+	}
+
+	if strings.HasPrefix(r.Domain, ".") {
+		conditions = append(conditions, "domain ends with "+r.Domain)
+	} else if r.Domain != "" {
+		conditions = append(conditions, "domain=="+r.Domain)
+	}
+
+	if len(r.Networks) > 0 {
+		prefixes := make([]string, len(r.Networks))
+		for i, p := range r.Networks {
+			prefixes[i] = p.String()
+		}
+		conditions = append(conditions, "client in "+strings.Join(prefixes, ","))
+	}
+
+	if len(conditions) == 0 {
+		return "then " + string(r.Then)
+	}
+
+	return fmt.Sprintf("if %s then %s", strings.Join(conditions, " and "), r.Then)
+}
+
+// Matches reports whether the result of a check for the client addr meets the
+// conditions of the rule.
+func (r LocalRule) Matches(cr CheckResult, addr netip.Addr) bool {
+	if r.Result != "" && r.Result != cr.Result {
+		return false
+	}
+
+	if r.Domain != "" {
+		domain, want := canonicalName(cr.Domain), canonicalName(r.Domain)
+		if strings.HasPrefix(r.Domain, ".") {
+			if !strings.HasSuffix(domain, "."+strings.TrimPrefix(want, ".")) {
+				return false
+			}
+		} else if domain != want {
+			return false
+		}
+	}
+
+	if len(r.Networks) > 0 {
+		addr = addr.Unmap()
+		for _, p := range r.Networks {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// applyRules replaces the result by that of the first rule matching it. The
+// result being replaced is kept in DirectResult unless an earlier override
+// already set it.
+func (r *CheckResult) applyRules(rules []LocalRule, addr netip.Addr) {
+	for _, rule := range rules {
+		if !rule.Matches(*r, addr) {
+			continue
+		}
+
+		if r.Override == "" {
+			r.DirectResult = r.Result
+		}
+
+		r.Result = rule.Then
+		r.Override = "local"
+		r.NoMatch = r.NoMatch && r.Result == Neutral
+
+		return
+	}
+}
+
+// ParseRules reads local policy rules from r, one per line, in the form
+//
+//	if <condition> [and <condition>...] then <result>
+//
+// where a condition is one of "result==<result>", "domain==<domain>",
+// "domain ends with <suffix>" and "client in <prefix>[,<prefix>...]". Results
+// are parsed with ParseResult. Empty lines and lines starting with # are
+// ignored.
+func ParseRules(r io.Reader) ([]LocalRule, error) {
+	var rules []LocalRule
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := parseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// parseRule parses a single rule of ParseRules.
+func parseRule(line string) (LocalRule, error) {
+	var rule LocalRule
+
+	tokens := strings.Fields(strings.ReplaceAll(line, "==", " == "))
+	if len(tokens) < 2 || !strings.EqualFold(tokens[0], "if") {
+		return rule, ErrInvalidRule
+	}
+
+	tokens = tokens[1:]
+	for {
+		n, err := rule.parseCondition(tokens)
+		if err != nil {
+			return rule, err
+		}
+		tokens = tokens[n:]
+
+		if len(tokens) == 0 {
+			return rule, ErrInvalidRule
+		}
+
+		keyword := strings.ToLower(tokens[0])
+		tokens = tokens[1:]
+
+		if keyword == "then" {
+			break
+		}
+
+		if keyword != "and" {
+			return rule, ErrInvalidRule
+		}
+	}
+
+	if len(tokens) != 1 {
+		return rule, ErrInvalidRule
+	}
+
+	then, err := ParseResult(tokens[0])
+	if err != nil {
+		return rule, err
+	}
+	rule.Then = then
+
+	return rule, nil
+}
+
+// parseCondition sets the condition at the start of tokens and returns the
+// number of tokens it spans.
+func (r *LocalRule) parseCondition(tokens []string) (int, error) {
+	if len(tokens) < 3 {
+		return 0, ErrInvalidRule
+	}
+
+	subject, op := strings.ToLower(tokens[0]), strings.ToLower(tokens[1])
+
+	switch {
+	case subject == "result" && op == "==" && r.Result == "":
+		result, err := ParseResult(tokens[2])
+		if err != nil {
+			return 0, err
+		}
+		r.Result = result
+		return 3, nil
+	case subject == "domain" && op == "==" && r.Domain == "":
+		r.Domain = strings.TrimPrefix(canonicalName(tokens[2]), ".")
+		return 3, nil
+	case subject == "domain" && op == "ends" && r.Domain == "":
+		if len(tokens) < 4 || !strings.EqualFold(tokens[2], "with") {
+			return 0, ErrInvalidRule
+		}
+		r.Domain = "." + strings.TrimPrefix(canonicalName(tokens[3]), ".")
+		return 4, nil
+	case subject == "client" && op == "in":
+		for _, s := range strings.Split(tokens[2], ",") {
+			p, err := parseRulePrefix(s)
+			if err != nil {
+				return 0, err
+			}
+			r.Networks = append(r.Networks, p)
+		}
+		return 3, nil
+	}
+
+	return 0, ErrInvalidRule
+}
+
+// parseRulePrefix parses a prefix, or a single address as a full-length
+// prefix.
+func parseRulePrefix(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, ErrInvalidRule
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, ErrInvalidRule
+	}
+
+	return p.Masked(), nil
+}
+
+// ParseRulesJSON reads a JSON array of LocalRules from r.
+func ParseRulesJSON(r io.Reader) ([]LocalRule, error) {
+	var rules []LocalRule
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, err
+	}
+
+	for i := range rules {
+		then, err := ParseResult(string(rules[i].Then))
+		if err != nil {
+			return nil, err
+		}
+		rules[i].Then = then
+
+		if rules[i].Result != "" {
+			if rules[i].Result, err = ParseResult(string(rules[i].Result)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return rules, nil
+}
+
+// LoadRules reads local policy rules from the file at path. Files ending in
+// ".json" are read with ParseRulesJSON, all others with ParseRules.
+func LoadRules(path string) ([]LocalRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.HasSuffix(path, ".json") {
+		return ParseRulesJSON(f)
+	}
+
+	return ParseRules(f)
+}
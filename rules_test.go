@@ -0,0 +1,125 @@
+package spf
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+const localRules = `
+# Forwarders of partners break SPF.
+if result==Fail and domain ends with .partner.example then SoftFail
+if client in 10.0.0.0/8,192.168.1.1 then pass
+`
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(localRules))
+	if err != nil {
+		t.Fatal("Expected", nil, "got", err)
+	}
+
+	expected := []string{
+		"if result==Fail and domain ends with .partner.example then SoftFail",
+		"if client in 10.0.0.0/8,192.168.1.1/32 then Pass",
+	}
+
+	if len(rules) != len(expected) {
+		t.Fatal("Expected", len(expected), "got", rules)
+	}
+
+	for i, rule := range rules {
+		if rule.String() != expected[i] {
+			t.Error("Expected", expected[i], "got", rule.String())
+		}
+	}
+
+	invalid := []string{
+		"result==Fail then Pass",
+		"if result==Fail then",
+		"if result==Bad then Pass",
+		"if result==Fail or client in 10.0.0.0/8 then Pass",
+		"if client in 10.0.0.0/33 then Pass",
+		"if domain ends example.com then Pass",
+		"if result==Fail and result==SoftFail then Pass",
+	}
+
+	for _, line := range invalid {
+		t.Log("Analyzing", line)
+
+		if _, err := ParseRules(strings.NewReader(line)); err == nil {
+			t.Error("Expected an error, got", nil)
+		}
+	}
+}
+
+func TestParseRulesJSON(t *testing.T) {
+	data := `[{"result": "fail", "domain": "example.com", "then": "neutral"}, {"client": ["2001:db8::/32"], "then": "Pass"}]`
+
+	rules, err := ParseRulesJSON(strings.NewReader(data))
+	if err != nil {
+		t.Fatal("Expected", nil, "got", err)
+	}
+
+	if len(rules) != 2 || rules[0].String() != "if result==Fail and domain==example.com then Neutral" || rules[1].String() != "if client in 2001:db8::/32 then Pass" {
+		t.Error("Unexpected rules", rules)
+	}
+
+	if _, err := ParseRulesJSON(strings.NewReader(`[{"then": "maybe"}]`)); err != ErrInvalidResult {
+		t.Error("Expected", ErrInvalidResult, "got", err)
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(localRules))
+	if err != nil {
+		t.Fatal("Expected", nil, "got", err)
+	}
+
+	c := &Checker{Source: MapSource{
+		"mail.partner.example": "v=spf1 ip4:192.0.2.0/24 -all",
+		"partner.example":      "v=spf1 ip4:192.0.2.0/24 -all",
+		"example.com":          "v=spf1 ip4:192.0.2.0/24 ?all",
+	}}
+	c.Rules = rules
+
+	tests := []struct {
+		ip       string
+		sender   string
+		result   Result
+		override string
+	}{
+		{"192.0.2.1", "info@mail.partner.example", Pass, ""},
+		{"203.0.113.1", "info@mail.partner.example", SoftFail, "local"},
+		{"203.0.113.1", "info@partner.example", Fail, ""},
+		{"10.1.2.3", "info@example.com", Pass, "local"},
+		{"::ffff:192.168.1.1", "info@example.com", Pass, "local"},
+		{"192.168.1.2", "info@example.com", Neutral, ""},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.ip, test.sender)
+
+		cr := c.Check(context.Background(), Request{IP: test.ip, Sender: test.sender})
+		if cr.Result != test.result || cr.Override != test.override {
+			t.Error("Expected", test.result, test.override, "got", cr.Result, cr.Override)
+		}
+
+		if cr.Override != "" && cr.DirectResult == cr.Result {
+			t.Error("Expected the direct result, got", cr.DirectResult)
+		}
+	}
+}
+
+func TestLocalRuleMatches(t *testing.T) {
+	rule := LocalRule{Domain: "Example.COM.", Then: Pass}
+	addr := netip.MustParseAddr("192.0.2.1")
+
+	if !rule.Matches(CheckResult{Result: Fail, Domain: "example.com"}, addr) {
+		t.Error("Expected", true, "got", false)
+	}
+
+	if rule.Matches(CheckResult{Result: Fail, Domain: "mail.example.com"}, addr) {
+		t.Error("Expected", false, "got", true)
+	}
+}
@@ -0,0 +1,144 @@
+package spf
+
+import (
+	"context"
+	"strconv"
+)
+
+// Weights of the findings of a Scorecard. They add up to 100.
+const (
+	WeightFailAll    = 30
+	WeightNoPassAll  = 25
+	WeightHeadroom   = 25
+	WeightNoPTR      = 10
+	WeightRecordSize = 10
+)
+
+const (
+	// maxSingleString is the longest record fitting in a single TXT string.
+	maxSingleString = 255
+)
+
+// Finding is a scored aspect of a record. Points is the part of the Weight
+// earned by the record.
+type Finding struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+	Points int    `json:"points"`
+	Detail string `json:"detail"`
+}
+
+// Scorecard rates the SPF posture of a domain from 0 to 100, the sum of the
+// points of its findings, so domains can be ranked and tracked over time.
+type Scorecard struct {
+	Domain   string    `json:"domain"`
+	Score    int       `json:"score"`
+	Findings []Finding `json:"findings"`
+}
+
+// Score rates the record of the domain using the DefaultChecker.
+func Score(ctx context.Context, domain string) (*Scorecard, error) {
+	return DefaultChecker.Score(ctx, domain)
+}
+
+// Score rates the record of the domain. The findings are:
+//
+//   - fail-all: the record ends with -all, half the points for ~all
+//   - no-pass-all: the record does not end with +all
+//   - lookup-headroom: full points up to half of MaxCount lookups, down to
+//     none over MaxCount
+//   - no-ptr: the record does not rely on the ptr mechanism
+//   - record-size: the record fits in a single TXT string, half the points
+//     if it still fits in a UDP answer
+//
+// The all and ptr terms of included records and redirect targets count too.
+func (c *Checker) Score(ctx context.Context, domain string) (*Scorecard, error) {
+	spf, err := c.NewSPF(ctx, domain, "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	f := flattener{ctx: ctx, checker: c, visited: make(map[string]bool), union: true}
+	if _, err := f.flatten(spf, Pass, true); err != nil {
+		return nil, err
+	}
+
+	// The all and ptr terms are taken from the published records, as
+	// flattening resolves them away. The all of the record, or of the
+	// redirect target it ends with, is the one evaluated last. Redirects
+	// of records with an all mechanism are ignored.
+	all, passAll, ptr := Neutral, false, false
+	chain := map[string]bool{canonicalName(spf.Domain): true}
+	withAll := make(map[string]bool)
+
+	err = spf.WalkTree(ctx, func(domain string, m *Mechanism) error {
+		domain = canonicalName(domain)
+
+		switch m.Name {
+		case "all":
+			passAll = passAll || m.Result == Pass
+			if chain[domain] && !withAll[domain] {
+				all = m.Result
+			}
+			withAll[domain] = true
+		case "ptr":
+			ptr = true
+		case "redirect":
+			if withAll[domain] {
+				return SkipInclude
+			}
+			if chain[domain] {
+				chain[canonicalName(m.Domain)] = true
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	card := &Scorecard{Domain: domain}
+
+	failAll := Finding{Name: "fail-all", Weight: WeightFailAll, Detail: "no -all"}
+	switch all {
+	case Fail:
+		failAll.Points, failAll.Detail = WeightFailAll, "-all"
+	case SoftFail:
+		failAll.Points, failAll.Detail = WeightFailAll/2, "~all"
+	}
+
+	noPassAll := Finding{Name: "no-pass-all", Weight: WeightNoPassAll, Points: WeightNoPassAll, Detail: "no +all"}
+	if passAll {
+		noPassAll.Points, noPassAll.Detail = 0, "+all authorizes every host"
+	}
+
+	headroom := Finding{Name: "lookup-headroom", Weight: WeightHeadroom, Detail: strconv.Itoa(f.lookups) + " of " + strconv.Itoa(MaxCount) + " lookups"}
+	headroom.Points = WeightHeadroom * (MaxCount - f.lookups) / (MaxCount - MaxCount/2)
+	switch {
+	case headroom.Points > WeightHeadroom:
+		headroom.Points = WeightHeadroom
+	case headroom.Points < 0:
+		headroom.Points = 0
+	}
+
+	noPTR := Finding{Name: "no-ptr", Weight: WeightNoPTR, Points: WeightNoPTR, Detail: "no ptr"}
+	if ptr {
+		noPTR.Points, noPTR.Detail = 0, "ptr is slow and deprecated"
+	}
+
+	size := Finding{Name: "record-size", Weight: WeightRecordSize, Detail: strconv.Itoa(len(spf.Raw)) + " bytes"}
+	switch {
+	case len(spf.Raw) <= maxSingleString:
+		size.Points = WeightRecordSize
+	case len(spf.Raw) <= MaxRecordLength:
+		size.Points = WeightRecordSize / 2
+	}
+
+	card.Findings = []Finding{failAll, noPassAll, headroom, noPTR, size}
+	for _, finding := range card.Findings {
+		card.Score += finding.Points
+	}
+
+	return card, nil
+}
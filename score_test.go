@@ -0,0 +1,59 @@
+package spf
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	long := "v=spf1 " + strings.Repeat("ip4:192.0.2.1 ", 25) + "-all"
+
+	source := MapSource{
+		"strict.example":       "v=spf1 ip4:192.0.2.0/24 -all",
+		"soft.example":         "v=spf1 ip4:192.0.2.0/24 ~all",
+		"open.example":         "v=spf1 +all",
+		"ptr.example":          "v=spf1 ptr include:_spf.ptr.example",
+		"_spf.ptr.example":     "v=spf1 a a a a a a a ?all",
+		"long.example":         long,
+		"redirect.example":     "v=spf1 redirect=strict.example",
+		"ignored.example":      "v=spf1 ip4:192.0.2.0/24 -all redirect=open.example",
+		"nested.example":       "v=spf1 include:open.example -all",
+		"exclude.example":      "v=spf1 include:_spf.exclude.example -all",
+		"_spf.exclude.example": "v=spf1 -ptr ?exists:%{i}.bl.example ip4:192.0.2.0/24",
+	}
+
+	c := &Checker{Source: source, Resolver: NewZone()}
+
+	tests := []struct {
+		domain string
+		score  int
+	}{
+		{"strict.example", 100},
+		{"soft.example", 85},
+		{"open.example", 45},
+		{"ptr.example", 25 + 5 + 10},
+		{"long.example", 95},
+		{"redirect.example", 100},
+		{"ignored.example", 100},
+		{"nested.example", 75},
+		{"exclude.example", 90},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.domain)
+
+		card, err := c.Score(context.Background(), test.domain)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if card.Score != test.score {
+			t.Error("Expected", test.score, "got", card.Score, card.Findings)
+		}
+	}
+
+	if _, err := c.Score(context.Background(), "none.example"); err != ErrNoRecord {
+		t.Error("Expected", ErrNoRecord, "got", err)
+	}
+}
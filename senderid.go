@@ -0,0 +1,82 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrNoMfromScope = errors.New("Sender ID record does not cover the mfrom scope.")
+)
+
+// senderIDVersion is the version prefix of Sender ID records as defined in
+// RFC 4406, followed by the comma separated list of scopes.
+const senderIDVersion = "spf2.0/"
+
+// IsSenderID reports whether the record is a Sender ID record, e.g.
+// "spf2.0/pra,mfrom ip4:192.0.2.0/24 -all".
+func IsSenderID(record string) bool {
+	return len(record) > len(senderIDVersion) && strings.EqualFold(record[:len(senderIDVersion)], senderIDVersion)
+}
+
+// SenderIDScopes returns the scopes covered by the Sender ID record, e.g.
+// "pra" and "mfrom".
+func SenderIDScopes(record string) ([]string, error) {
+	if !IsSenderID(record) {
+		return nil, ErrInvalidSPF
+	}
+
+	version := strings.Fields(record)[0]
+
+	var scopes []string
+	for _, scope := range strings.Split(version[len(senderIDVersion):], ",") {
+		if scope == "" {
+			return nil, ErrInvalidSPF
+		}
+		scopes = append(scopes, strings.ToLower(scope))
+	}
+
+	return scopes, nil
+}
+
+// ConvertSenderID returns the v=spf1 record equivalent to the mfrom scope of
+// the Sender ID record. Sender ID shares the SPF mechanisms so only the
+// version changes. ErrNoMfromScope is returned for records only covering the
+// pra scope.
+func ConvertSenderID(record string) (string, error) {
+	scopes, err := SenderIDScopes(record)
+	if err != nil {
+		return "", err
+	}
+
+	for _, scope := range scopes {
+		if scope == "mfrom" {
+			terms := strings.Fields(record)[1:]
+			return strings.Join(append([]string{"v=spf1"}, terms...), " "), nil
+		}
+	}
+
+	return "", ErrNoMfromScope
+}
+
+// SenderID returns the first Sender ID record published by the domain, or an
+// empty string if there is none.
+func (c *Checker) SenderID(ctx context.Context, domain string) (string, error) {
+	records, err := c.resolver().LookupTXT(ctx, domain)
+	if isNotFound(err) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", ErrFailedLookup
+	}
+
+	for _, record := range records {
+		if IsSenderID(record) {
+			return record, nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,73 @@
+package spf
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const senderIDZone = `
+$ORIGIN example.org.
+@       IN TXT "spf2.0/pra,mfrom ip4:192.0.2.0/24 include:_spf.example.org -all"
+_spf    IN TXT "v=spf1 ip4:198.51.100.1 -all"
+pra     IN TXT "spf2.0/pra ip4:192.0.2.0/24 -all"
+both    IN TXT "spf2.0/mfrom -all"
+        IN TXT "v=spf1 ip4:192.0.2.1 -all"
+`
+
+func TestConvertSenderID(t *testing.T) {
+	tests := []spfstr{
+		spfstr{"spf2.0/pra,mfrom ip4:192.0.2.0/24 -all", "v=spf1 ip4:192.0.2.0/24 -all"},
+		spfstr{"SPF2.0/MFROM include:_spf.example.com ~all", "v=spf1 include:_spf.example.com ~all"},
+		spfstr{"spf2.0/pra ip4:192.0.2.0/24 -all", ""},
+		spfstr{"v=spf1 -all", ""},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.raw)
+
+		converted, _ := ConvertSenderID(test.raw)
+		if converted != test.expected {
+			t.Error("Expected", test.expected, "got", converted)
+		}
+	}
+
+	scopes, err := SenderIDScopes("spf2.0/pra,mfrom -all")
+	if err != nil || strings.Join(scopes, ",") != "pra,mfrom" {
+		t.Error("Expected pra,mfrom got", scopes, err)
+	}
+
+	if _, err := SenderIDScopes("spf2.0/pra,,mfrom -all"); err != ErrInvalidSPF {
+		t.Error("Expected", ErrInvalidSPF, "got", err)
+	}
+}
+
+func TestSenderIDSource(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(senderIDZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Source: DNSSource{Resolver: z, SenderID: true}, Resolver: z}
+
+	tests := []spftest{
+		spftest{"192.0.2.10", "info@example.org", Pass},
+		spftest{"198.51.100.1", "info@example.org", Pass},
+		spftest{"203.0.113.1", "info@example.org", Fail},
+		spftest{"192.0.2.10", "info@pra.example.org", None},
+		spftest{"192.0.2.1", "info@both.example.org", Pass},
+	}
+
+	for _, expected := range tests {
+		actual, _ := c.SPFTest(context.Background(), expected.server, expected.email)
+
+		if actual != expected.result {
+			t.Error("For", expected.server, "at", expected.email, "Expected", expected.result, "got", actual)
+		}
+	}
+
+	record, err := c.SenderID(context.Background(), "pra.example.org")
+	if err != nil || record != "spf2.0/pra ip4:192.0.2.0/24 -all" {
+		t.Error("Expected pra record got", record, err)
+	}
+}
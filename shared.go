@@ -0,0 +1,145 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	ErrNoTTL = errors.New("Resolver does not report TTLs.")
+)
+
+// SharedResolver wraps a Resolver so that identical lookups issued
+// concurrently, e.g. while evaluating many messages from the same domain at
+// once, share a single query and its answer. Names are compared in canonical
+// form. A caller whose context is done stops waiting without failing the
+// others. A SharedResolver must not be copied after first use.
+type SharedResolver struct {
+	// Resolver performs the lookups. If nil, net.DefaultResolver is used.
+	Resolver Resolver
+
+	group singleflight.Group
+}
+
+func (r *SharedResolver) resolver() Resolver {
+	if r.Resolver == nil {
+		return net.DefaultResolver
+	}
+
+	return r.Resolver
+}
+
+// LookupTXT looks up the TXT records for name.
+func (r *SharedResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	v, err := share(ctx, &r.group, "TXT "+canonicalName(name), func(ctx context.Context) (interface{}, error) {
+		return r.resolver().LookupTXT(ctx, name)
+	})
+
+	txt, _ := v.([]string)
+	return txt, err
+}
+
+// LookupHost looks up the addresses of host.
+func (r *SharedResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	v, err := share(ctx, &r.group, "HOST "+canonicalName(host), func(ctx context.Context) (interface{}, error) {
+		return r.resolver().LookupHost(ctx, host)
+	})
+
+	addrs, _ := v.([]string)
+	return addrs, err
+}
+
+// LookupMX looks up the MX records for name.
+func (r *SharedResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	v, err := share(ctx, &r.group, "MX "+canonicalName(name), func(ctx context.Context) (interface{}, error) {
+		return r.resolver().LookupMX(ctx, name)
+	})
+
+	mxs, _ := v.([]*net.MX)
+	return mxs, err
+}
+
+// LookupAddr looks up the names of addr.
+func (r *SharedResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	v, err := share(ctx, &r.group, "PTR "+canonicalName(addr), func(ctx context.Context) (interface{}, error) {
+		return r.resolver().LookupAddr(ctx, addr)
+	})
+
+	names, _ := v.([]string)
+	return names, err
+}
+
+// LookupIP looks up the addresses of host for the given network.
+func (r *SharedResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	v, err := share(ctx, &r.group, "IP "+network+" "+canonicalName(host), func(ctx context.Context) (interface{}, error) {
+		return r.resolver().LookupIP(ctx, network, host)
+	})
+
+	ips, _ := v.([]net.IP)
+	return ips, err
+}
+
+// LookupTTL forwards to the wrapped Resolver if it implements TTLResolver.
+func (r *SharedResolver) LookupTTL(ctx context.Context, name, rtype string) (time.Duration, error) {
+	if tr, ok := r.resolver().(TTLResolver); ok {
+		return tr.LookupTTL(ctx, name, rtype)
+	}
+
+	return 0, ErrNoTTL
+}
+
+// SharedSource wraps a RecordSource so that concurrent requests for the
+// record of the same domain share a single fetch. A SharedSource must not be
+// copied after first use.
+type SharedSource struct {
+	Source RecordSource
+
+	group singleflight.Group
+}
+
+// Record returns the record of the domain.
+func (s *SharedSource) Record(ctx context.Context, domain string) (string, error) {
+	v, err := share(ctx, &s.group, canonicalName(domain), func(ctx context.Context) (interface{}, error) {
+		return s.Source.Record(ctx, domain)
+	})
+
+	record, _ := v.(string)
+	return record, err
+}
+
+// sharedPanic carries a panic of a shared call to its callers.
+type sharedPanic struct {
+	value interface{}
+}
+
+// share calls fn once for the concurrent calls with the same key and returns
+// its result. fn runs with a context that is not canceled with the one of the
+// caller who started it, so that caller giving up does not fail the others;
+// each caller returns early once its own context is done. A panic of fn is
+// raised again in each caller, where it can be recovered.
+func share(ctx context.Context, group *singleflight.Group, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	shared := context.WithoutCancel(ctx)
+	ch := group.DoChan(key, func() (v interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				v, err = sharedPanic{p}, nil
+			}
+		}()
+
+		return fn(shared)
+	})
+
+	select {
+	case res := <-ch:
+		if p, ok := res.Val.(sharedPanic); ok {
+			panic(p.value)
+		}
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,104 @@
+package spf
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSharedSource(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+
+	source := &SharedSource{Source: RecordSourceFunc(func(ctx context.Context, domain string) (string, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return "v=spf1 ip4:192.0.2.0/24 -all", nil
+	})}
+
+	c := &Checker{Source: source}
+
+	var wg sync.WaitGroup
+	results := make(chan Result, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, _ := c.SPFTest(context.Background(), "192.0.2.1", "info@example.com")
+			results <- result
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for result := range results {
+		if result != Pass {
+			t.Error("Expected", Pass, "got", result)
+		}
+	}
+
+	if n := atomic.LoadInt32(&fetches); n >= 50 {
+		t.Error("Expected shared fetches got", n)
+	}
+}
+
+// blockingResolver answers TXT lookups once released, counting them.
+type blockingResolver struct {
+	Resolver
+	lookups int32
+	release chan struct{}
+}
+
+func (r *blockingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	atomic.AddInt32(&r.lookups, 1)
+	<-r.release
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return []string{"v=spf1 -all"}, nil
+}
+
+func TestSharedResolverCancel(t *testing.T) {
+	blocking := &blockingResolver{Resolver: NewZone(), release: make(chan struct{})}
+	r := &SharedResolver{Resolver: blocking}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := r.LookupTXT(ctx, "Example.COM.")
+		first <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	second := make(chan error)
+	go func() {
+		txt, err := r.LookupTXT(context.Background(), "example.com")
+		if err == nil && len(txt) != 1 {
+			t.Error("Expected a record got", txt)
+		}
+		second <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-first; err != context.Canceled {
+		t.Error("Expected", context.Canceled, "got", err)
+	}
+
+	close(blocking.release)
+
+	if err := <-second; err != nil {
+		t.Error("Expected", nil, "got", err)
+	}
+
+	if n := atomic.LoadInt32(&blocking.lookups); n != 1 {
+		t.Error("Expected a single lookup got", n)
+	}
+}
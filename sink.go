@@ -0,0 +1,120 @@
+package spf
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// EvaluationEvent describes a single check, for ingestion by log collectors
+// and SIEMs such as Elasticsearch or Splunk.
+type EvaluationEvent struct {
+	Time     time.Time `json:"timestamp"`
+	ClientIP string    `json:"client_ip"`
+
+	// Sender and Helo are the identities of the request. Identity is the
+	// one checked, postmaster@Helo when Sender is empty.
+	Sender   string `json:"sender,omitempty"`
+	Helo     string `json:"helo,omitempty"`
+	Identity string `json:"identity,omitempty"`
+
+	Domain    string `json:"domain,omitempty"`
+	Result    Result `json:"result"`
+	Override  string `json:"override,omitempty"`
+	Mechanism string `json:"mechanism,omitempty"`
+	Error     string `json:"error,omitempty"`
+
+	// OrgDomain and OrgResult report the organizational domain fallback
+	// of CheckResult.Org.
+	OrgDomain string `json:"org_domain,omitempty"`
+	OrgResult Result `json:"org_result,omitempty"`
+
+	// Duration is the time the check took. DNS breaks down the queries
+	// made by the terms of the evaluated records and Queries is their
+	// total.
+	Duration time.Duration `json:"duration_ns"`
+	Queries  int           `json:"dns_queries"`
+	DNS      Budget        `json:"dns"`
+}
+
+// newEvaluationEvent returns the event of the check of the request.
+func newEvaluationEvent(start time.Time, req Request, cr CheckResult) EvaluationEvent {
+	event := EvaluationEvent{
+		Time:     start,
+		ClientIP: req.IP,
+		Sender:   req.Sender,
+		Helo:     req.Helo,
+		Identity: req.Sender,
+		Domain:   cr.Domain,
+		Result:   cr.Result,
+		Override: cr.Override,
+		Duration: time.Since(start),
+		Queries:  cr.Budget.Total(),
+		DNS:      cr.Budget,
+	}
+
+	if event.Identity == "" && req.Helo != "" {
+		event.Identity = "postmaster@" + req.Helo
+	}
+
+	if req.Addr.IsValid() {
+		event.ClientIP = req.Addr.String()
+	}
+
+	if cr.Org != nil {
+		event.OrgDomain, event.OrgResult = cr.Org.Domain, cr.Org.Result
+	}
+
+	if cr.Mechanism != nil {
+		event.Mechanism = cr.Mechanism.SPFString()
+	}
+
+	if cr.Err != nil {
+		event.Error = cr.Err.Error()
+	}
+
+	return event
+}
+
+// EventSink receives the EvaluationEvent of each check of a Checker, see
+// Checker.Events. Emit is called synchronously by the check, so sinks writing
+// to slow destinations should buffer or hand the events off to another
+// goroutine. Sinks must be safe for concurrent use.
+type EventSink interface {
+	Emit(event EvaluationEvent)
+}
+
+// SinkFunc adapts a function to the EventSink interface.
+type SinkFunc func(event EvaluationEvent)
+
+// Emit calls f(event).
+func (f SinkFunc) Emit(event EvaluationEvent) {
+	f(event)
+}
+
+// JSONSink is an EventSink writing each event to Writer as a JSON object on
+// its own line, the format expected by most log shippers. A JSONSink must not
+// be copied after first use.
+type JSONSink struct {
+	Writer io.Writer
+
+	// OnError is called when an event could not be written.
+	OnError func(err error)
+
+	mu sync.Mutex
+}
+
+// Emit writes the event.
+func (s *JSONSink) Emit(event EvaluationEvent) {
+	line, err := json.Marshal(event)
+	if err == nil {
+		s.mu.Lock()
+		_, err = s.Writer.Write(append(line, '\n'))
+		s.mu.Unlock()
+	}
+
+	if err != nil && s.OnError != nil {
+		s.OnError(err)
+	}
+}
@@ -0,0 +1,64 @@
+package spf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEvaluationEvents(t *testing.T) {
+	var buf bytes.Buffer
+	source := MapSource{
+		"example.com":      "v=spf1 include:_spf.example.com -all",
+		"_spf.example.com": "v=spf1 ip4:192.0.2.0/24 -all",
+	}
+
+	c := &Checker{Source: source, Events: &JSONSink{Writer: &buf}}
+	ctx := context.Background()
+
+	c.Check(ctx, Request{IP: "192.0.2.1", Sender: "info@example.com"})
+	c.Check(ctx, Request{IP: "bogus", Helo: "example.com"})
+	c.SPFTest(ctx, "203.0.113.1", "info@example.com")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatal("Expected 3 events got", buf.String())
+	}
+
+	tests := []struct {
+		ip        string
+		identity  string
+		result    Result
+		mechanism string
+		queries   int
+		err       bool
+	}{
+		{"192.0.2.1", "info@example.com", Pass, "include:_spf.example.com", 1, false},
+		{"bogus", "postmaster@example.com", None, "", 0, true},
+		{"203.0.113.1", "info@example.com", Fail, "-all", 1, false},
+	}
+
+	for i, test := range tests {
+		t.Log("Analyzing", lines[i])
+
+		var event EvaluationEvent
+		if err := json.Unmarshal([]byte(lines[i]), &event); err != nil {
+			t.Fatal(err)
+		}
+
+		if event.ClientIP != test.ip || event.Identity != test.identity || event.Result != test.result || event.Mechanism != test.mechanism ||
+			event.Queries != test.queries || event.DNS.Include != test.queries || (event.Error != "") != test.err || event.Time.IsZero() {
+			t.Error("Expected", test, "got", event)
+		}
+	}
+
+	var events []EvaluationEvent
+	c.Events = SinkFunc(func(event EvaluationEvent) { events = append(events, event) })
+	c.DryRun(ctx, "192.0.2.1", "info@example.com")
+
+	if len(events) != 0 {
+		t.Error("Expected no events from DryRun got", events)
+	}
+}
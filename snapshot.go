@@ -0,0 +1,108 @@
+package spf
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotEntry is the encoding of a MemoryCache value in a snapshot.
+type snapshotEntry struct {
+	Key     string    `json:"key"`
+	Value   []byte    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+// Save writes the unexpired values of the cache to w, one JSON object per
+// line, so they can be restored with Load, e.g. after a restart. To snapshot
+// the answers of a CachingResolver, set its Cache to a MemoryCache.
+func (c *MemoryCache) Save(w io.Writer) error {
+	now := time.Now()
+	enc := json.NewEncoder(w)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			continue
+		}
+
+		if err := enc.Encode(snapshotEntry{Key: key, Value: entry.value, Expires: entry.expires}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Load adds the values written by Save to the cache. Values keep their
+// original expiry time, so only the remaining part of their TTL is left and
+// values that expired since the snapshot was taken are skipped.
+func (c *MemoryCache) Load(r io.Reader) error {
+	now := time.Now()
+	dec := json.NewDecoder(r)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for {
+		var entry snapshotEntry
+		err := dec.Decode(&entry)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !now.Before(entry.Expires) {
+			continue
+		}
+
+		if c.entries == nil {
+			c.entries = make(map[string]cacheEntry)
+		}
+		c.entries[entry.Key] = cacheEntry{value: entry.Value, expires: entry.Expires}
+	}
+}
+
+// SaveFile saves the cache to the file at path. The file is replaced
+// atomically so a crash while saving never leaves a truncated snapshot.
+func (c *MemoryCache) SaveFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := c.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// LoadFile loads the cache from the file at path. A missing file is not an
+// error, leaving the cache empty on the first start.
+func (c *MemoryCache) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.Load(f)
+}
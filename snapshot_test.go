@@ -0,0 +1,55 @@
+package spf
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "spf.cache")
+
+	c := &MemoryCache{}
+	c.Set(ctx, "TXT example.com", []byte("v=spf1 -all"), time.Hour)
+	c.Set(ctx, "TXT expired.example.com", []byte("v=spf1 -all"), time.Nanosecond)
+
+	time.Sleep(time.Millisecond)
+
+	if err := c.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := &MemoryCache{}
+	if err := restored.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := restored.Get(ctx, "TXT example.com")
+	if err != nil || string(value) != "v=spf1 -all" {
+		t.Error("Expected v=spf1 -all got", string(value), err)
+	}
+
+	if _, err := restored.Get(ctx, "TXT expired.example.com"); err != ErrCacheMiss {
+		t.Error("Expected", ErrCacheMiss, "got", err)
+	}
+
+	if remaining := time.Until(restored.entries["TXT example.com"].expires); remaining > time.Hour || remaining < 59*time.Minute {
+		t.Error("Expected the remaining TTL to be kept got", remaining)
+	}
+
+	if err := restored.LoadFile(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Error("Expected nil got", err)
+	}
+
+	stale := `{"key":"TXT stale.example.com","value":"dj1zcGYxIC1hbGw=","expires":"2001-01-01T00:00:00Z"}`
+	if err := restored.Load(strings.NewReader(stale)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := restored.Get(ctx, "TXT stale.example.com"); err != ErrCacheMiss {
+		t.Error("Expected", ErrCacheMiss, "got", err)
+	}
+}
@@ -0,0 +1,117 @@
+package spf
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// RecordSource provides the raw SPF record text for a domain. Implementations
+// may fetch the record from DNS, a database, a configuration service or a
+// zone file snapshot. Record returns an empty string and a nil error when the
+// domain does not publish an SPF record.
+type RecordSource interface {
+	Record(ctx context.Context, domain string) (string, error)
+}
+
+// RecordSourceFunc adapts an ordinary function to the RecordSource interface.
+type RecordSourceFunc func(ctx context.Context, domain string) (string, error)
+
+// Record calls f(ctx, domain).
+func (f RecordSourceFunc) Record(ctx context.Context, domain string) (string, error) {
+	return f(ctx, domain)
+}
+
+// DNSSource fetches SPF records from the TXT records published for a domain.
+type DNSSource struct {
+	// Resolver performs the TXT lookups. If nil, net.DefaultResolver is
+	// used.
+	Resolver Resolver
+
+	// SenderID makes Record fall back to the Sender ID record of domains
+	// publishing no v=spf1 record. Records covering the mfrom scope are
+	// returned converted to v=spf1, see ConvertSenderID.
+	SenderID bool
+}
+
+// Record returns the TXT record of the domain starting with "v=spf1", see
+// SelectRecord.
+func (s DNSSource) Record(ctx context.Context, domain string) (string, error) {
+	var r Resolver = net.DefaultResolver
+
+	if s.Resolver != nil {
+		r = s.Resolver
+	}
+
+	// A domain that does not exist publishes no record. Any other DNS error
+	// during domain name lookup should result in "TempError".
+	records, err := r.LookupTXT(ctx, domain)
+	if isNotFound(err) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", ErrFailedLookup
+	}
+
+	spfText, err := SelectRecord(records)
+	if err != nil {
+		return "", err
+	}
+
+	if spfText == "" && s.SenderID {
+		for _, record := range records {
+			if converted, err := ConvertSenderID(record); err == nil {
+				spfText = converted
+				break
+			}
+		}
+	}
+
+	return spfText, nil
+}
+
+// SelectRecord returns the SPF record among the TXT records of a domain, the
+// one whose version section is exactly "v=spf1", followed by a space or the
+// end of the record, RFC 7208 section 4.5. An empty string is returned when
+// there is none and ErrMultipleRecords when there are several.
+func SelectRecord(records []string) (string, error) {
+	var spfText string
+	for _, record := range records {
+		if !isSPFRecord(record) {
+			continue
+		}
+
+		if spfText != "" {
+			return "", ErrMultipleRecords
+		}
+		spfText = record
+	}
+
+	return spfText, nil
+}
+
+// isSPFRecord reports whether the TXT record is an SPF record.
+func isSPFRecord(record string) bool {
+	return record == "v=spf1" || strings.HasPrefix(record, "v=spf1 ")
+}
+
+// MapSource serves SPF records from a map of domain names to record text.
+// Domains missing from the map do not publish an SPF record.
+type MapSource map[string]string
+
+// Record returns the record stored for the domain. Domain names are compared
+// case-insensitively.
+func (s MapSource) Record(ctx context.Context, domain string) (string, error) {
+	if record, ok := s[domain]; ok {
+		return record, nil
+	}
+
+	for name, record := range s {
+		if canonicalName(name) == canonicalName(domain) {
+			return record, nil
+		}
+	}
+
+	return "", nil
+}
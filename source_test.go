@@ -0,0 +1,113 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+var testSource = MapSource{
+	"example.com":      "v=spf1 ip4:192.0.2.0/24 include:_spf.example.com -all",
+	"_spf.example.com": "v=spf1 ip4:198.51.100.10 ip6:2001:db8::/32 ~all",
+	"neutral.example":  "v=spf1 ip4:203.0.113.1",
+	"softfail.example": "v=spf1 include:_spf.example.com ~all",
+	"broken.example":   "v=spf1 include:missing.example -all",
+	"invalid.example":  "v=spf1 foo:bar -all",
+	"loop-a.example":   "v=spf1 include:loop-b.example -all",
+	"loop-b.example":   "v=spf1 ip4:192.0.2.1 include:loop-c.example -all",
+	"loop-c.example":   "v=spf1 include:LOOP-A.example ~all",
+	"diamond.example":  "v=spf1 include:softfail.example include:_spf.example.com -all",
+}
+
+func TestRecordSource(t *testing.T) {
+	c := &Checker{Source: testSource}
+
+	tests := []spftest{
+		spftest{"192.0.2.1", "info@example.com", Pass},
+		spftest{"198.51.100.10", "info@example.com", Pass},
+		spftest{"2001:db8::1", "info@example.com", Pass},
+		spftest{"127.0.0.1", "info@example.com", Fail},
+		spftest{"127.0.0.1", "info@softfail.example", SoftFail},
+		spftest{"127.0.0.1", "info@neutral.example", Neutral},
+		spftest{"127.0.0.1", "info@unknown.example", None},
+		spftest{"127.0.0.1", "info@broken.example", PermError},
+		spftest{"127.0.0.1", "info@invalid.example", PermError},
+		spftest{"198.51.100.10", "info@diamond.example", Pass},
+	}
+
+	for _, expected := range tests {
+		actual, _ := c.SPFTest(context.Background(), expected.server, expected.email)
+
+		if actual != expected.result {
+			t.Error("For", expected.server, "at", expected.email, "Expected", expected.result, "got", actual)
+		}
+	}
+}
+
+func TestRecordSourceFunc(t *testing.T) {
+	failure := errors.New("backend unavailable")
+	c := &Checker{Source: RecordSourceFunc(func(ctx context.Context, domain string) (string, error) {
+		return "", failure
+	})}
+
+	result, err := c.SPFTest(context.Background(), "192.0.2.1", "info@example.com")
+	if result != TempError {
+		t.Error("Expected", TempError, "got", result)
+	}
+
+	if err != failure {
+		t.Error("Expected", failure, "got", err)
+	}
+}
+
+func TestIncludeLoop(t *testing.T) {
+	c := &Checker{Source: testSource}
+
+	tests := []spftest{
+		spftest{"127.0.0.1", "info@loop-a.example", PermError},
+		spftest{"127.0.0.1", "info@loop-b.example", PermError},
+		spftest{"127.0.0.1", "info@loop-c.example", PermError},
+	}
+
+	for _, expected := range tests {
+		actual, err := c.SPFTest(context.Background(), expected.server, expected.email)
+
+		if actual != expected.result {
+			t.Error("For", expected.server, "at", expected.email, "Expected", expected.result, "got", actual)
+		}
+
+		if err != ErrIncludeLoop {
+			t.Error("For", expected.email, "Expected", ErrIncludeLoop, "got", err)
+		}
+	}
+}
+
+func TestDNSSourceSelection(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf10 +all"},
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 ip4:192.0.2.0/24 -all"},
+		ZoneRecord{Name: "multiple.example", Type: "TXT", Data: "v=spf1 +all"},
+		ZoneRecord{Name: "multiple.example", Type: "TXT", Data: "v=spf1 -all"},
+	)
+
+	c := &Checker{Source: DNSSource{Resolver: z}}
+
+	tests := []struct {
+		email  string
+		result Result
+		err    error
+	}{
+		{"info@example.com", Fail, nil},
+		{"info@multiple.example", PermError, ErrMultipleRecords},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.email)
+
+		result, err := c.SPFTest(context.Background(), "203.0.113.1", test.email)
+		if result != test.result || err != test.err {
+			t.Error("Expected", test.result, test.err, "got", result, err)
+		}
+	}
+}
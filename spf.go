@@ -1,19 +1,26 @@
 // Package spf can parse an SPF record and determine if a given IP address is
 // allowed to send email based on that record. SPF can handle all of the
-// mechanisms defined at http://www.openspf.org/SPF_Record_Syntax. The redirect
-// mechanism is ignored.
+// mechanisms defined at http://www.openspf.org/SPF_Record_Syntax, and the
+// redirect and exp modifiers.
 package spf
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"net"
-	"strings"
+	"net/netip"
+	"sync"
 )
 
 const (
 	MaxCount = 10
+
+	// MaxMXRecords is the most MX records an mx mechanism may resolve to
+	// and MaxPTRNames the most names of the client a ptr mechanism
+	// validates, RFC 7208 section 4.6.4.
+	MaxMXRecords = 10
+	MaxPTRNames  = 10
 )
 
 var (
@@ -23,144 +30,244 @@ var (
 	ErrIncludeLoop      = errors.New("Include loop detected.")
 	ErrInvalidMechanism = errors.New("Invalid mechanism in SPF string.")
 	ErrMaxCount         = errors.New("Exceeded maximum lookups.")
+	ErrTimeout          = errors.New("Evaluation deadline exceeded.")
+	ErrInvalidIP        = errors.New("Invalid client IP address.")
+	ErrTooManyMX        = errors.New("Exceeded maximum MX records.")
 )
 
 // SPF represents an SPF record for a particular Domain. The SPF record
-// holds all of the Allow, Deny, and Neutral mechanisms.
+// holds all of the Allow, Deny, and Neutral mechanisms, in order, and its
+// modifiers: at most one redirect and one exp, and any unknown modifiers,
+// such as vendor extensions, in the order they appear.
 type SPF struct {
 	Raw        string
 	Domain     string
 	Version    string
 	Mechanisms []Mechanism
+	Modifiers  []Modifier
 	Count      int
+
+	checker *Checker
 }
 
 // Test evaluates each mechanism to determine the result for the client.
 // Mechanisms are evaluated in order until one of them provides a valid
-// result. If no valid results are provided, the default result of "Neutral"
-// is returned.
+// result. If no valid results are provided, the result of the redirect
+// target, or else the default result of "Neutral", is returned. An ip that
+// is not an IP address results in None.
 func (s *SPF) Test(ip string) Result {
-	for _, m := range s.Mechanisms {
-		result, err := m.Evaluate(ip, s.Count)
-		if err == nil {
-			return result
-		}
+	addr, err := parseIP(ip)
+	if err != nil {
+		return None
 	}
 
-	return Neutral
+	return s.TestAddr(addr)
 }
 
-// Return an SPF record as a string.
-func (s *SPF) String() string {
-	var buf bytes.Buffer
-
-	buf.WriteString(fmt.Sprintf("Raw: %s\n", s.Raw))
-	buf.WriteString(fmt.Sprintf("Domain: %s\n", s.Domain))
-	buf.WriteString(fmt.Sprintf("Version: %s\n", s.Version))
-
-	buf.WriteString("Mechanisms:\n")
-	for _, m := range s.Mechanisms {
-		buf.WriteString(fmt.Sprintf("\t%s\n", m.String()))
+// TestAddr is like Test for a parsed client address. A net.IP can be
+// converted with netip.AddrFromSlice.
+func (s *SPF) TestAddr(addr netip.Addr) Result {
+	if !addr.IsValid() {
+		return None
 	}
 
-	return buf.String()
-}
-
-// SPFString returns a formatted SPF object as a string suitable for use in a
-// TXT record.
-func (s *SPF) SPFString() string {
-	var buf bytes.Buffer
+	c := s.checker
+	if c == nil {
+		c = DefaultChecker
+	}
 
-	buf.WriteString(fmt.Sprintf("v=%s", s.Version))
-	for _, m := range s.Mechanisms {
-		buf.WriteString(fmt.Sprintf(" %s", m.SPFString()))
+	// The hooks are called for each term, which the shortcut skips.
+	if !c.hooked() {
+		if r, ok := s.testLiterals(addr); ok {
+			return r
+		}
 	}
 
-	return buf.String()
+	return s.evaluate(newEvaluation(context.Background(), c, addr))
 }
 
-func getSPFRecord(domain string) (string, error) {
-	var spfText string
+// testLiterals evaluates records made only of ip4, ip6 and all mechanisms
+// without allocating, as no lookup nor evaluation state is needed. ok is
+// false for any other record, including those with a redirect.
+func (s *SPF) testLiterals(addr netip.Addr) (r Result, ok bool) {
+	if s.modifier("redirect") != nil {
+		return None, false
+	}
 
-	// DNS errors during domain name lookup should result in "TempError".
-	records, err := net.LookupTXT(domain)
-	if err != nil {
-		return "", ErrFailedLookup
+	for i := range s.Mechanisms {
+		switch s.Mechanisms[i].Name {
+		case "ip4", "ip6", "all":
+		default:
+			return None, false
+		}
 	}
 
-	// Find the SPF record among the TXT records for the domain.
-	for _, record := range records {
-		if strings.HasPrefix(record, "v=spf1") {
-			spfText = record
-			break
+	addr = addr.Unmap()
+
+	for i := range s.Mechanisms {
+		m := &s.Mechanisms[i]
+		if m.Name == "all" || ipMechanismContains(m, addr) {
+			return m.Result, true
 		}
 	}
 
-	return spfText, nil
+	return Neutral, true
 }
 
-// Create a new SPF record for the given domain using the provided string. If
-// the provided string is not valid an error is returned.
-func NewSPF(domain, record string, count int) (SPF, error) {
-	var spf SPF
+func (s *SPF) evaluate(e *evaluation) Result {
+	e.enter(s.Domain)
+	defer e.leave(s.Domain)
+
+	current := e.current
+	e.current = s.Domain
+	e.depth++
+	defer func() {
+		e.current = current
+		e.depth--
+	}()
+
+	node := e.begin(s)
+	defer e.end(node)
+
+	// RFC 7208 section 6.1: the redirect is evaluated once no mechanism
+	// matched, wherever it appears in the record.
+	terms := s.extensionTerms(e.checker)
+	for i := range terms {
+		m := &terms[i]
+
+		// Give up once the deadline is (about to be) exceeded, leaving the
+		// remaining mechanisms pending in the trace.
+		if e.expired() {
+			e.pending(node, terms[i:])
+			return e.result(node, e.fail(TempError, ErrTimeout))
+		}
 
-	if record == "" {
-		spfText, err := getSPFRecord(domain)
-		if err != nil {
-			return spf, err
+		term := e.beginTerm(node, m)
+		ev := e.mechanismEvent(s, m)
+
+		decided, result, err := e.before(ev)
+		if !decided {
+			result, err = m.evaluate(e, s.Count)
 		}
 
-		if spfText == "" {
-			return spf, ErrNoRecord
+		// A mechanism that did not match while the deadline passed may
+		// not have completed its lookups.
+		if err != nil && e.expired() {
+			e.pending(node, terms[i+1:])
+			e.endTerm(term, TempError, false)
+			if term != nil {
+				term.Pending = true
+			}
+			return e.result(node, e.fail(TempError, ErrTimeout))
 		}
 
-		record = spfText
+		result, err = e.after(ev, result, err)
+		e.endTerm(term, result, err == nil)
+
+		if err == nil {
+			// The result of a redirect is the result of its target,
+			// which may be a default Neutral.
+			if m.Name != "redirect" {
+				e.noMatch = false
+				if result == Fail {
+					e.failed = s
+				}
+			}
+			if e.depth == 1 {
+				e.matched = m
+			}
+			return e.result(node, result)
+		}
 	}
 
-	spf.Count = count
-	spf.Raw = record
-	spf.Domain = domain
+	// RFC 7208 section 4.7: a record without a matching mechanism results
+	// in Neutral.
+	e.noMatch = true
+
+	return e.result(node, Neutral)
+}
+
+// Return an SPF record as a string.
+func (s *SPF) String() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("Raw: %s\n", s.Raw))
+	buf.WriteString(fmt.Sprintf("Domain: %s\n", s.Domain))
+	buf.WriteString(fmt.Sprintf("Version: %s\n", s.Version))
 
-	if !strings.HasPrefix(record, "v=spf1") {
-		return spf, ErrInvalidSPF
+	buf.WriteString("Mechanisms:\n")
+	for _, m := range s.Mechanisms {
+		buf.WriteString(fmt.Sprintf("\t%s%s\n", m.String(), providerNote(m)))
 	}
 
-	for _, f := range strings.Fields(record) {
-		switch {
-		case strings.HasPrefix(f, "v="):
-			spf.Version = f[2:]
-		default:
-			mechanism, err := NewMechanism(f, domain)
+	if len(s.Modifiers) > 0 {
+		buf.WriteString("Modifiers:\n")
+		for _, m := range s.Modifiers {
+			buf.WriteString(fmt.Sprintf("\t%s%s\n", m.SPFString(), providerNote(m.term())))
+		}
+	}
 
-			if err != nil {
-				return spf, err
-			}
+	return buf.String()
+}
 
-			if !mechanism.Valid() {
-				return spf, ErrInvalidMechanism
-			}
+// SPFString returns a formatted SPF object as a string suitable for use in a
+// TXT record. For a parsed record the mechanisms keep their order and
+// spelling, with these normalizations: terms are separated by single spaces,
+// names are lowercase, ip6 addresses take their RFC 5952 form and modifiers
+// use "=" and follow the mechanisms, as RFC 7208 section 6 recommends.
+func (s *SPF) SPFString() string {
+	buf := getBuffer()
+	defer putBuffer(buf)
 
-			switch mechanism.Name {
-			case "include":
-				spf.Count = spf.Count + 1
-				if mechanism.Domain == domain {
-					return spf, ErrIncludeLoop
-				}
-			case "redirect", "exists", "a", "mx", "ptr":
-				spf.Count = spf.Count + 1
-			default:
-				// No action
-			}
+	buf.Grow(len(s.Raw))
+	buf.WriteString("v=")
+	buf.WriteString(s.Version)
 
-			spf.Mechanisms = append(spf.Mechanisms, mechanism)
-		}
+	for i := range s.Mechanisms {
+		m := &s.Mechanisms[i]
+
+		// Mechanisms defaulting to the record's domain are written without.
+		buf.WriteByte(' ')
+		m.writeSPF(buf, m.implicit && sameName(m.Domain, s.Domain))
 	}
 
-	if spf.Count >= MaxCount {
-		return spf, ErrMaxCount
+	for i := range s.Modifiers {
+		buf.WriteByte(' ')
+		buf.WriteString(s.Modifiers[i].Name)
+		buf.WriteByte('=')
+		buf.WriteString(s.Modifiers[i].Value)
 	}
 
-	return spf, nil
+	return buf.String()
+}
+
+// bufferPool holds the buffers records are rendered into, so that rendering
+// many records does not allocate a buffer for each of them.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+
+	return buf
+}
+
+// putBuffer returns the buffer to the pool unless it grew too large to keep.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= 64<<10 {
+		bufferPool.Put(buf)
+	}
+}
+
+// Create a new SPF record for the given domain using the provided string. If
+// the provided string is not valid an error is returned. When the provided
+// string is empty the record is fetched using the DefaultChecker.
+func NewSPF(domain, record string, count int) (SPF, error) {
+	return DefaultChecker.NewSPF(context.Background(), domain, record, count)
 }
 
 /*
@@ -172,31 +279,10 @@ Exported functions.
 // SPFTest will return one of the following results:
 // Pass, Fail, SoftFail, Neutral, None, TempError, or PermError
 func SPFTest(ip, email string) (Result, error) {
-	var domain string
-
-	// Get domain name from email address.
-	if strings.Contains(email, "@") {
-		parts := strings.Split(email, "@")
-		domain = parts[1]
-	} else {
-		return None, errors.New("Email address must contain an @ sign.")
-	}
-
-	spfText, err := getSPFRecord(domain)
-	if err != nil {
-		return TempError, err
-	}
-
-	// No SPF record should result in None.
-	if spfText == "" {
-		return None, nil
-	}
-
-	// Create a new SPF struct
-	spf, err := NewSPF(domain, spfText, 0)
-	if err != nil {
-		return PermError, err
-	}
+	return DefaultChecker.SPFTest(context.Background(), ip, email)
+}
 
-	return spf.Test(ip), nil
+// SPFTestAddr is like SPFTest for a parsed client address.
+func SPFTestAddr(addr netip.Addr, email string) (Result, error) {
+	return DefaultChecker.SPFTestAddr(context.Background(), addr, email)
 }
@@ -1,6 +1,11 @@
 package spf
 
 import (
+	"context"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -25,7 +30,7 @@ type spfstr struct {
 func TestNewSPF(t *testing.T) {
 	errorTests := []spferror{
 		spferror{"google.com", "somestring"},
-		spferror{"google.com", "v=spf1 include:_spf.google.com ~all -none"},
+		spferror{"google.com", "v=spf1 include:_spf.google.com ~all -ip4:none"},
 		spferror{"google.com", "v=spf1 include:google.com"},
 	}
 
@@ -91,3 +96,339 @@ func TestSPFString(t *testing.T) {
 		}
 	}
 }
+
+func TestRedirect(t *testing.T) {
+	records := MapSource{
+		"pass.example":         "v=spf1 redirect=_spf.pass.example",
+		"_spf.pass.example":    "v=spf1 ip4:192.0.2.0/24 -all",
+		"soft.example":         "v=spf1 ip4:198.51.100.1 redirect=_spf.soft.example",
+		"_spf.soft.example":    "v=spf1 ~all",
+		"neutral.example":      "v=spf1 redirect=_spf.neutral.example",
+		"_spf.neutral.example": "v=spf1 ip4:198.51.100.1",
+		"none.example":         "v=spf1 redirect=_spf.none.example",
+		"invalid.example":      "v=spf1 redirect=_spf.invalid.example",
+		"_spf.invalid.example": "v=spf1 bogus -all",
+		"temp.example":         "v=spf1 redirect=_spf.temp.example",
+		"loop.example":         "v=spf1 redirect=loop.example",
+	}
+
+	c := &Checker{Source: RecordSourceFunc(func(ctx context.Context, domain string) (string, error) {
+		if domain == "_spf.temp.example" {
+			return "", ErrFailedLookup
+		}
+		return records.Record(ctx, domain)
+	})}
+
+	tests := []spftest{
+		spftest{"192.0.2.1", "info@pass.example", Pass},
+		spftest{"203.0.113.1", "info@pass.example", Fail},
+		spftest{"198.51.100.1", "info@soft.example", Pass},
+		spftest{"203.0.113.1", "info@soft.example", SoftFail},
+		spftest{"203.0.113.1", "info@neutral.example", Neutral},
+		spftest{"203.0.113.1", "info@none.example", PermError},
+		spftest{"203.0.113.1", "info@invalid.example", PermError},
+		spftest{"203.0.113.1", "info@temp.example", TempError},
+		spftest{"203.0.113.1", "info@loop.example", PermError},
+	}
+
+	for _, expected := range tests {
+		t.Log("Analyzing", expected.email)
+
+		result, _ := c.SPFTest(context.Background(), expected.server, expected.email)
+		if result != expected.result {
+			t.Error("Expected", expected.result, "got", result)
+		}
+	}
+}
+
+func TestInclude(t *testing.T) {
+	records := MapSource{
+		"pass.example":         "v=spf1 include:_spf.pass.example -all",
+		"_spf.pass.example":    "v=spf1 ip4:192.0.2.0/24 -all",
+		"fail.example":         "v=spf1 -include:_spf.pass.example ~all",
+		"soft.example":         "v=spf1 include:_spf.soft.example -all",
+		"_spf.soft.example":    "v=spf1 ~all",
+		"neutral.example":      "v=spf1 include:_spf.neutral.example -all",
+		"_spf.neutral.example": "v=spf1 ip4:198.51.100.1",
+		"none.example":         "v=spf1 include:_spf.none.example -all",
+		"invalid.example":      "v=spf1 include:_spf.invalid.example -all",
+		"_spf.invalid.example": "v=spf1 bogus -all",
+		"temp.example":         "v=spf1 include:_spf.temp.example -all",
+		"nested.example":       "v=spf1 include:temp.example -all",
+		"loop.example":         "v=spf1 include:loop.example -all",
+	}
+
+	c := &Checker{Source: RecordSourceFunc(func(ctx context.Context, domain string) (string, error) {
+		if domain == "_spf.temp.example" {
+			return "", ErrFailedLookup
+		}
+		return records.Record(ctx, domain)
+	})}
+
+	tests := []spftest{
+		spftest{"192.0.2.1", "info@pass.example", Pass},
+		spftest{"203.0.113.1", "info@pass.example", Fail},
+		spftest{"192.0.2.1", "info@fail.example", Fail},
+		spftest{"203.0.113.1", "info@fail.example", SoftFail},
+		spftest{"203.0.113.1", "info@soft.example", Fail},
+		spftest{"203.0.113.1", "info@neutral.example", Fail},
+		spftest{"203.0.113.1", "info@none.example", PermError},
+		spftest{"203.0.113.1", "info@invalid.example", PermError},
+		spftest{"203.0.113.1", "info@temp.example", TempError},
+		spftest{"203.0.113.1", "info@nested.example", TempError},
+		spftest{"203.0.113.1", "info@loop.example", PermError},
+	}
+
+	for _, expected := range tests {
+		t.Log("Analyzing", expected.email)
+
+		result, _ := c.SPFTest(context.Background(), expected.server, expected.email)
+		if result != expected.result {
+			t.Error("Expected", expected.result, "got", result)
+		}
+	}
+}
+
+// failingResolver fails the lookups of names starting with "broken." and of
+// every PTR record.
+type failingResolver struct {
+	Resolver
+}
+
+func brokenName(name string) error {
+	if strings.HasPrefix(name, "broken.") {
+		return &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+	return nil
+}
+
+func (r failingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if err := brokenName(host); err != nil {
+		return nil, err
+	}
+	return r.Resolver.LookupHost(ctx, host)
+}
+
+func (r failingResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	if err := brokenName(host); err != nil {
+		return nil, err
+	}
+	return r.Resolver.LookupIP(ctx, network, host)
+}
+
+func (r failingResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if err := brokenName(name); err != nil {
+		return nil, err
+	}
+	return r.Resolver.LookupMX(ctx, name)
+}
+
+func (r failingResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	return nil, &net.DNSError{Err: "server misbehaving", Name: addr, IsTemporary: true}
+}
+
+func TestLookupErrors(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(`
+$ORIGIN example.
+a        TXT "v=spf1 a:broken.example -all"
+mx       TXT "v=spf1 mx:broken.example -all"
+mxhost   TXT "v=spf1 mx -all"
+         MX  10 broken.example.
+exists   TXT "v=spf1 exists:broken.example -all"
+ptr      TXT "v=spf1 ptr:example.org -all"
+missing  TXT "v=spf1 a:nx.example mx:nx.example exists:nx.example -all"
+`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Resolver: failingResolver{z}}
+
+	tests := []spftest{
+		spftest{"192.0.2.1", "info@a.example", TempError},
+		spftest{"192.0.2.1", "info@mx.example", TempError},
+		spftest{"192.0.2.1", "info@mxhost.example", TempError},
+		spftest{"192.0.2.1", "info@exists.example", TempError},
+		spftest{"192.0.2.1", "info@ptr.example", Fail},
+		spftest{"192.0.2.1", "info@missing.example", Fail},
+	}
+
+	for _, expected := range tests {
+		t.Log("Analyzing", expected.email)
+
+		result, _ := c.SPFTest(context.Background(), expected.server, expected.email)
+		if result != expected.result {
+			t.Error("Expected", expected.result, "got", result)
+		}
+	}
+}
+
+func TestSPFTestAddr(t *testing.T) {
+	c := &Checker{Source: MapSource{"example.com": "v=spf1 ip4:192.0.2.0/24 -all"}}
+	ctx := context.Background()
+
+	addr, _ := netip.AddrFromSlice(net.ParseIP("192.0.2.1"))
+	if result, err := c.SPFTestAddr(ctx, addr, "info@example.com"); result != Pass || err != nil {
+		t.Error("Expected", Pass, "got", result, err)
+	}
+
+	if cr := c.Check(ctx, Request{Addr: netip.MustParseAddr("203.0.113.1"), Sender: "info@example.com"}); cr.Result != Fail {
+		t.Error("Expected", Fail, "got", cr.Result)
+	}
+
+	for _, ip := range []string{"", "192.0.2", "example.com", "2001:db8:::1"} {
+		t.Log("Analyzing", ip)
+
+		if result, err := c.SPFTest(ctx, ip, "info@example.com"); result != None || err != ErrInvalidIP {
+			t.Error("Expected", None, ErrInvalidIP, "got", result, err)
+		}
+
+		if cr := c.Check(ctx, Request{IP: ip, Sender: "info@example.com"}); cr.Result != None || cr.Err != ErrInvalidIP {
+			t.Error("Expected", None, ErrInvalidIP, "got", cr.Result, cr.Err)
+		}
+	}
+
+	if _, err := c.SPFTestAddr(ctx, netip.Addr{}, "info@example.com"); err != ErrInvalidIP {
+		t.Error("Expected", ErrInvalidIP, "got", err)
+	}
+
+	s, err := NewSPF("example.com", "v=spf1 ip6:2001:db8::/32 -all", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := s.TestAddr(netip.MustParseAddr("2001:db8::1")); result != Pass {
+		t.Error("Expected", Pass, "got", result)
+	}
+
+	if result := s.Test("bogus"); result != None {
+		t.Error("Expected", None, "got", result)
+	}
+
+	if _, err := s.Mechanisms[0].Evaluate("bogus", 0); err != ErrInvalidIP {
+		t.Error("Expected", ErrInvalidIP, "got", err)
+	}
+}
+
+func TestLookupLimits(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "mx.example", Type: "TXT", Data: "v=spf1 mx -all"},
+		ZoneRecord{Name: "ptr.example", Type: "TXT", Data: "v=spf1 ptr:ptr.example -all"},
+	)
+
+	// The client has ten names outside of ptr.example before one in it.
+	for i := 1; i <= 10; i++ {
+		host := "mx" + strconv.Itoa(i) + ".mx.example"
+		z.Add(
+			ZoneRecord{Name: "mx.example", Type: "MX", Data: strconv.Itoa(i) + " " + host},
+			ZoneRecord{Name: "1.2.0.192.in-addr.arpa", Type: "PTR", Data: "host" + strconv.Itoa(i) + ".other.example"},
+		)
+	}
+	z.Add(
+		ZoneRecord{Name: "mx10.mx.example", Type: "A", Data: "192.0.2.1"},
+		ZoneRecord{Name: "1.2.0.192.in-addr.arpa", Type: "PTR", Data: "host.ptr.example"},
+	)
+
+	tests := []struct {
+		checker *Checker
+		email   string
+		result  Result
+	}{
+		{&Checker{Resolver: z}, "info@mx.example", Pass},
+		{&Checker{Resolver: z, MXLimit: 5}, "info@mx.example", PermError},
+		{&Checker{Resolver: z}, "info@ptr.example", Fail},
+		{&Checker{Resolver: z, PTRLimit: 20}, "info@ptr.example", Fail},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.email, test.checker.MXLimit, test.checker.PTRLimit)
+
+		result, _ := test.checker.SPFTest(context.Background(), "192.0.2.1", test.email)
+		if result != test.result {
+			t.Error("Expected", test.result, "got", result)
+		}
+	}
+
+	// An eleventh MX record exceeds the RFC limit.
+	z.Add(ZoneRecord{Name: "mx.example", Type: "MX", Data: "11 mx11.mx.example"})
+
+	c := &Checker{Resolver: z, MXLimit: 20}
+	if result, err := c.SPFTest(context.Background(), "192.0.2.1", "info@mx.example"); result != PermError || err != ErrTooManyMX {
+		t.Error("Expected", PermError, ErrTooManyMX, "got", result, err)
+	}
+}
+
+func TestPTR(t *testing.T) {
+	z := NewZone()
+	z.Add(
+		ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 ptr -all"},
+		ZoneRecord{Name: "example.org", Type: "TXT", Data: "v=spf1 ptr:Example.ORG. -all"},
+		ZoneRecord{Name: "example.net", Type: "TXT", Data: "v=spf1 ptr -all"},
+		ZoneRecord{Name: "1.2.0.192.in-addr.arpa", Type: "PTR", Data: "mail.example.com."},
+		ZoneRecord{Name: "mail.example.com", Type: "A", Data: "192.0.2.1"},
+		ZoneRecord{Name: "2.2.0.192.in-addr.arpa", Type: "PTR", Data: "example.org."},
+		ZoneRecord{Name: "example.org", Type: "A", Data: "192.0.2.2"},
+		// The name does not resolve back to the client.
+		ZoneRecord{Name: "3.2.0.192.in-addr.arpa", Type: "PTR", Data: "relay.example.com."},
+		ZoneRecord{Name: "relay.example.com", Type: "A", Data: "192.0.2.30"},
+		// The name is not within the domain.
+		ZoneRecord{Name: "4.2.0.192.in-addr.arpa", Type: "PTR", Data: "mail.badexample.com."},
+		ZoneRecord{Name: "mail.badexample.com", Type: "A", Data: "192.0.2.4"},
+		ZoneRecord{Name: "4.2.0.192.in-addr.arpa", Type: "PTR", Data: "mail.badexample.net."},
+		ZoneRecord{Name: "mail.badexample.net", Type: "A", Data: "192.0.2.4"},
+	)
+
+	tests := []spftest{
+		spftest{"192.0.2.1", "info@example.com", Pass},
+		spftest{"192.0.2.2", "info@example.org", Pass},
+		spftest{"192.0.2.3", "info@example.com", Fail},
+		spftest{"192.0.2.4", "info@example.com", Fail},
+		spftest{"192.0.2.4", "info@example.net", Fail},
+		spftest{"192.0.2.5", "info@example.com", Fail},
+	}
+
+	c := &Checker{Resolver: z}
+	for _, expected := range tests {
+		t.Log("Analyzing", expected.server, expected.email)
+
+		result, _ := c.SPFTest(context.Background(), expected.server, expected.email)
+		if result != expected.result {
+			t.Error("Expected", expected.result, "got", result)
+		}
+	}
+}
+
+func TestUnknownMechanism(t *testing.T) {
+	c := &Checker{Source: MapSource{
+		"example.com":      "v=spf1 ip4:192.0.2.0/24 foo:bar.example -all",
+		"include.example":  "v=spf1 include:example.com -all",
+		"redirect.example": "v=spf1 redirect=example.com",
+	}}
+
+	tests := []spftest{
+		spftest{"192.0.2.1", "info@example.com", Pass},
+		spftest{"203.0.113.1", "info@example.com", PermError},
+		spftest{"203.0.113.1", "info@include.example", PermError},
+		spftest{"203.0.113.1", "info@redirect.example", PermError},
+	}
+
+	for _, expected := range tests {
+		t.Log("Analyzing", expected.server, expected.email)
+
+		result, err := c.SPFTest(context.Background(), expected.server, expected.email)
+		if result != expected.result || (result == PermError && err != ErrUnknownMechanism) {
+			t.Error("Expected", expected.result, "got", result, err)
+		}
+	}
+
+	s, err := NewSPF("example.com", "v=spf1 foo -all", 0)
+	if err != nil || len(s.Mechanisms) != 2 || !s.Mechanisms[0].Unknown() || s.Mechanisms[1].Unknown() {
+		t.Error("Expected foo to be kept as an unknown mechanism got", s.Mechanisms, err)
+	}
+
+	s, warnings, err := ParseLenient("example.com", "v=spf1 foo -all")
+	if err != nil || len(s.Mechanisms) != 1 || len(warnings) != 1 || warnings[0].Err != ErrUnknownMechanism {
+		t.Error("Expected foo to be skipped got", s.Mechanisms, warnings, err)
+	}
+}
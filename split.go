@@ -0,0 +1,124 @@
+package spf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxRecordLength is the recommended maximum length of an SPF record,
+	// keeping the DNS answer within the size of a single UDP packet.
+	MaxRecordLength = 450
+)
+
+var (
+	ErrRecordTooLong = errors.New("SPF record exceeds the maximum length.")
+)
+
+// SplitRecord is a TXT record to publish for Domain.
+type SplitRecord struct {
+	Domain string
+	Text   string
+}
+
+// Split returns the records to publish for the record, typically a flattened
+// one, so that no record is longer than maxLen. If the record is short enough
+// it is returned as the only record. Otherwise runs of consecutive Pass ip4
+// and ip6 terms are moved into _spf1.<domain>, _spf2.<domain>, ...
+// sub-records and replaced by includes of them in the top-level record, which
+// is always the first record returned. A maxLen of zero uses MaxRecordLength.
+func (s *SPF) Split(maxLen int) ([]SplitRecord, error) {
+	if maxLen <= 0 {
+		maxLen = MaxRecordLength
+	}
+
+	record := s.SPFString()
+	if len(record) <= maxLen {
+		return []SplitRecord{{Domain: s.Domain, Text: record}}, nil
+	}
+
+	var records []SplitRecord
+	var top []string
+	var run []string
+	count := s.Count
+
+	flush := func() error {
+		for _, chunk := range chunkTerms(run, maxLen) {
+			domain := fmt.Sprintf("_spf%d.%s", len(records)+1, s.Domain)
+			text := fmt.Sprintf("v=%s %s -all", s.Version, strings.Join(chunk, " "))
+			if len(text) > maxLen {
+				return ErrRecordTooLong
+			}
+
+			records = append(records, SplitRecord{Domain: domain, Text: text})
+			top = append(top, "include:"+domain)
+			count++
+		}
+		run = run[:0]
+
+		return nil
+	}
+
+	for _, m := range s.Mechanisms {
+		if (m.Name == "ip4" || m.Name == "ip6") && m.Result == Pass {
+			run = append(run, m.SPFString())
+			continue
+		}
+
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		top = append(top, m.SPFString())
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	for _, m := range s.Modifiers {
+		top = append(top, m.SPFString())
+	}
+
+	text := fmt.Sprintf("v=%s %s", s.Version, strings.Join(top, " "))
+	if len(text) > maxLen {
+		return nil, ErrRecordTooLong
+	}
+
+	if count >= MaxCount {
+		return nil, ErrMaxCount
+	}
+
+	return append([]SplitRecord{{Domain: s.Domain, Text: text}}, records...), nil
+}
+
+// chunkTerms groups terms so that each group, wrapped in a sub-record, stays
+// within maxLen.
+func chunkTerms(terms []string, maxLen int) [][]string {
+	var chunks [][]string
+	var chunk []string
+
+	// Room left for the terms once "v=spf1 " and " -all" are accounted for.
+	room := maxLen - len("v=spf1  -all")
+	size := 0
+
+	for _, term := range terms {
+		if len(chunk) > 0 && size+1+len(term) > room {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			size = 0
+		}
+
+		if len(chunk) > 0 {
+			size++
+		}
+		size += len(term)
+		chunk = append(chunk, term)
+	}
+
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
@@ -0,0 +1,94 @@
+package spf
+
+import (
+	"database/sql/driver"
+	"errors"
+)
+
+var (
+	ErrInvalidResult = errors.New("Invalid SPF result.")
+	ErrScanType      = errors.New("Cannot scan value, expected a string.")
+)
+
+// Value implements driver.Valuer, storing the record in its SPF string form.
+func (s SPF) Value() (driver.Value, error) {
+	return s.SPFString(), nil
+}
+
+// Scan implements sql.Scanner. A NULL value resets s to the zero SPF.
+func (s *SPF) Scan(src interface{}) error {
+	text, null, err := scanText(src)
+	if err != nil {
+		return err
+	}
+
+	if null {
+		*s = SPF{}
+		return nil
+	}
+
+	return s.UnmarshalText(text)
+}
+
+// Value implements driver.Valuer, storing the mechanism in its SPF string
+// form.
+func (m Mechanism) Value() (driver.Value, error) {
+	return m.SPFString(), nil
+}
+
+// Scan implements sql.Scanner. A NULL value resets m to the zero Mechanism.
+func (m *Mechanism) Scan(src interface{}) error {
+	text, null, err := scanText(src)
+	if err != nil {
+		return err
+	}
+
+	if null {
+		*m = Mechanism{}
+		return nil
+	}
+
+	return m.UnmarshalText(text)
+}
+
+// Value implements driver.Valuer.
+func (r Result) Value() (driver.Value, error) {
+	return string(r), nil
+}
+
+// Scan implements sql.Scanner. Only the defined results are accepted. A NULL
+// value resets r to the empty Result.
+func (r *Result) Scan(src interface{}) error {
+	text, null, err := scanText(src)
+	if err != nil {
+		return err
+	}
+
+	if null {
+		*r = ""
+		return nil
+	}
+
+	switch result := Result(text); result {
+	case Pass, Neutral, Fail, SoftFail, None, TempError, PermError:
+		*r = result
+		return nil
+	}
+
+	return ErrInvalidResult
+}
+
+// scanText converts a database value into text. The boolean is true for
+// NULL values.
+func scanText(src interface{}) ([]byte, bool, error) {
+	switch v := src.(type) {
+	case nil:
+		return nil, true, nil
+	case string:
+		return []byte(v), false, nil
+	case []byte:
+		return v, false, nil
+	}
+
+	return nil, false, ErrScanType
+}
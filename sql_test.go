@@ -0,0 +1,46 @@
+package spf
+
+import (
+	"testing"
+)
+
+func TestScanValue(t *testing.T) {
+	var s SPF
+	if err := s.Scan([]byte("v=spf1 ip4:192.0.2.0/24 -all")); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := s.Value(); v != "v=spf1 ip4:192.0.2.0/24 -all" {
+		t.Error("Expected", "v=spf1 ip4:192.0.2.0/24 -all", "got", v)
+	}
+
+	if err := s.Scan(nil); err != nil || len(s.Mechanisms) != 0 {
+		t.Error("Expected zero SPF got", s.String(), err)
+	}
+
+	var m Mechanism
+	if err := m.Scan("~include:_spf.example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := m.Value(); v != "~include:_spf.example.com" {
+		t.Error("Expected", "~include:_spf.example.com", "got", v)
+	}
+
+	var r Result
+	if err := r.Scan("SoftFail"); err != nil || r != SoftFail {
+		t.Error("Expected", SoftFail, "got", r, err)
+	}
+
+	if v, _ := r.Value(); v != "SoftFail" {
+		t.Error("Expected", "SoftFail", "got", v)
+	}
+
+	if err := r.Scan("Maybe"); err != ErrInvalidResult {
+		t.Error("Expected", ErrInvalidResult, "got", err)
+	}
+
+	if err := r.Scan(42); err != ErrScanType {
+		t.Error("Expected", ErrScanType, "got", err)
+	}
+}
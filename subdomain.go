@@ -0,0 +1,117 @@
+package spf
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrNotSubdomain       = errors.New("Domain is not a subdomain of the record's domain.")
+	ErrDuplicateSubdomain = errors.New("Subdomain planned more than once.")
+)
+
+// ParkedRecord is the record of a domain sending no mail, RFC 7208 section
+// 10.1.2.
+const ParkedRecord = "v=spf1 -all"
+
+// PlanSubdomains returns the records to publish for subdomains of the domain
+// of the record, the apex, so that each sending subdomain is covered and
+// the others are locked down. sending maps the sending subdomains to the
+// inventory of their own senders, as taken by BuildRecord. A subdomain
+// without senders of its own is redirected to the apex; the others
+// authorize their senders, include the apex record and end with its all
+// mechanism, Fail if it has none. The subdomains in parked get ParkedRecord.
+// Subdomains are given relative to the apex, e.g. "mail", or fully qualified
+// under it. The records are returned sorted by domain.
+func (s *SPF) PlanSubdomains(sending map[string][]string, parked []string) ([]SplitRecord, error) {
+	apex := canonicalName(s.Domain)
+
+	planned := make(map[string]bool)
+	var records []SplitRecord
+
+	for name, senders := range sending {
+		domain, err := subdomainOf(apex, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if planned[domain] {
+			return nil, ErrDuplicateSubdomain
+		}
+		planned[domain] = true
+
+		record, err := s.subdomainRecord(domain, senders)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, SplitRecord{Domain: domain, Text: record})
+	}
+
+	for _, name := range parked {
+		domain, err := subdomainOf(apex, name)
+		if err != nil {
+			return nil, err
+		}
+
+		// A sending subdomain must not be locked down.
+		if planned[domain] {
+			return nil, ErrDuplicateSubdomain
+		}
+		planned[domain] = true
+
+		records = append(records, SplitRecord{Domain: domain, Text: ParkedRecord})
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Domain < records[j].Domain
+	})
+
+	return records, nil
+}
+
+// subdomainRecord returns the record of the sending subdomain with the
+// senders of its own.
+func (s *SPF) subdomainRecord(domain string, senders []string) (string, error) {
+	if len(senders) == 0 {
+		return "v=spf1 redirect=" + canonicalName(s.Domain), nil
+	}
+
+	all := Fail
+	for _, m := range s.Mechanisms {
+		if m.Name == "all" {
+			all = m.Result
+		}
+	}
+
+	built, err := BuildRecord(domain, senders, all)
+	if err != nil {
+		return "", err
+	}
+
+	// The include of the apex goes right before the all mechanism.
+	n := len(built.Mechanisms) - 1
+	include := Mechanism{Name: "include", Domain: canonicalName(s.Domain), Result: Pass}
+	built.Mechanisms = append(built.Mechanisms[:n], include, built.Mechanisms[n])
+
+	if built.Count+1+s.Count >= MaxCount {
+		return "", ErrMaxCount
+	}
+
+	return built.SPFString(), nil
+}
+
+// subdomainOf returns the fully qualified name of the subdomain of apex.
+func subdomainOf(apex, name string) (string, error) {
+	name = canonicalName(strings.TrimSpace(name))
+	if name == "" || name == apex {
+		return "", ErrNotSubdomain
+	}
+
+	if strings.HasSuffix(name, "."+apex) {
+		return name, nil
+	}
+
+	return name + "." + apex, nil
+}
@@ -0,0 +1,56 @@
+package spf
+
+import (
+	"testing"
+)
+
+func TestPlanSubdomains(t *testing.T) {
+	apex, _ := NewSPF("example.com", "v=spf1 include:_spf.google.com ~all", 0)
+
+	sending := map[string][]string{
+		"news":             nil,
+		"Mail.Example.COM": {"192.0.2.10", "192.0.2.11", "relay.example.net"},
+	}
+
+	records, err := apex.PlanSubdomains(sending, []string{"www", "static.example.com"})
+	if err != nil {
+		t.Fatal("Expected", nil, "got", err)
+	}
+
+	expected := []SplitRecord{
+		{"mail.example.com", "v=spf1 ip4:192.0.2.10/31 a:relay.example.net include:example.com ~all"},
+		{"news.example.com", "v=spf1 redirect=example.com"},
+		{"static.example.com", ParkedRecord},
+		{"www.example.com", ParkedRecord},
+	}
+
+	if len(records) != len(expected) {
+		t.Fatal("Expected", expected, "got", records)
+	}
+
+	for i, r := range records {
+		t.Log("Analyzing", r.Domain)
+
+		if r != expected[i] {
+			t.Error("Expected", expected[i], "got", r)
+		}
+	}
+
+	tests := []struct {
+		sending map[string][]string
+		parked  []string
+		err     error
+	}{
+		{nil, []string{"example.com"}, ErrNotSubdomain},
+		{map[string][]string{"mail": nil}, []string{"mail.example.com"}, ErrDuplicateSubdomain},
+		{map[string][]string{"mail": {"not a host"}}, nil, ErrInvalidDomain},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.sending, test.parked)
+
+		if _, err := apex.PlanSubdomains(test.sending, test.parked); err != test.err {
+			t.Error("Expected", test.err, "got", err)
+		}
+	}
+}
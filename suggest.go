@@ -0,0 +1,134 @@
+package spf
+
+import (
+	"context"
+	"net/netip"
+	"sort"
+)
+
+// Observation is the number of messages seen from a sending address, e.g.
+// counted from mail logs.
+type Observation struct {
+	Addr   netip.Addr
+	Volume int
+}
+
+// SenderGap is an observed sender the published record does not pass, with
+// the result of its evaluation.
+type SenderGap struct {
+	Observation
+	Result Result
+}
+
+// Suggestion compares observed senders to the published record of a domain.
+type Suggestion struct {
+	Domain string
+
+	// Published is the text of the published record, empty if the domain
+	// has none.
+	Published string
+
+	// Record is the suggested record: the published one with ip4 and ip6
+	// mechanisms added for the uncovered senders, or a new record ending
+	// with ~all if the domain has none.
+	Record SPF
+
+	// Uncovered lists the senders the published record does not pass, by
+	// decreasing volume. Covered and Total are the volumes passed and
+	// observed.
+	Uncovered []SenderGap
+	Covered   int
+	Total     int
+}
+
+// Suggest compares the observations to the SPF record of the domain using
+// the DefaultChecker. See Checker.Suggest.
+func Suggest(ctx context.Context, domain string, observations []Observation, minVolume int) (*Suggestion, error) {
+	return DefaultChecker.Suggest(ctx, domain, observations, minVolume)
+}
+
+// Suggest evaluates the record of the domain for every observed address and
+// suggests a record covering the senders it does not pass. Observations of
+// the same address are merged. Only the uncovered senders with at least
+// minVolume messages are added to the suggested record, and none whose
+// evaluation was a TempError or PermError, which no added mechanism would
+// fix. The added mechanisms are inserted before the
+// all mechanism, and senders the record explicitly excludes, e.g. with
+// -ip4, are not added back.
+func (c *Checker) Suggest(ctx context.Context, domain string, observations []Observation, minVolume int) (*Suggestion, error) {
+	current, err := c.NewSPF(ctx, domain, "", 0)
+	published := err == nil
+	if err != nil && err != ErrNoRecord {
+		return nil, err
+	}
+
+	s := &Suggestion{Domain: domain}
+
+	var prefixes []netip.Prefix
+	for _, o := range mergeObservations(observations) {
+		s.Total += o.Volume
+
+		result := None
+		if published {
+			result = c.check(newEvaluation(ctx, c, o.Addr), "postmaster@"+domain)
+		}
+
+		if result == Pass {
+			s.Covered += o.Volume
+			continue
+		}
+
+		s.Uncovered = append(s.Uncovered, SenderGap{Observation: o, Result: result})
+		if o.Volume >= minVolume && result != TempError && result != PermError {
+			prefixes = append(prefixes, netip.PrefixFrom(o.Addr, o.Addr.BitLen()))
+		}
+	}
+
+	if !published {
+		var inventory []string
+		for _, p := range AggregatePrefixes(prefixes) {
+			inventory = append(inventory, p.String())
+		}
+
+		s.Record, err = BuildRecord(domain, inventory, SoftFail)
+		return s, err
+	}
+
+	s.Published = current.Raw
+	s.Record = current
+	s.Record.Mechanisms = append([]Mechanism(nil), current.Mechanisms...)
+	s.Record.Modifiers = append([]Modifier(nil), current.Modifiers...)
+
+	for _, p := range AggregatePrefixes(prefixes) {
+		if err := s.Record.AddMechanism(prefixMechanism(p, Pass)); err != nil && err != ErrDuplicateMechanism {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// mergeObservations sums the volumes of the observations of each address,
+// skipping invalid ones, and sorts them by decreasing volume.
+func mergeObservations(observations []Observation) []Observation {
+	volumes := make(map[netip.Addr]int)
+	for _, o := range observations {
+		if o.Addr.IsValid() {
+			volumes[o.Addr.Unmap()] += o.Volume
+		}
+	}
+
+	merged := make([]Observation, 0, len(volumes))
+	for addr, volume := range volumes {
+		merged = append(merged, Observation{Addr: addr, Volume: volume})
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Volume != merged[j].Volume {
+			return merged[i].Volume > merged[j].Volume
+		}
+		return merged[i].Addr.Less(merged[j].Addr)
+	})
+
+	return merged
+}
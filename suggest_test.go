@@ -0,0 +1,62 @@
+package spf
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	c := &Checker{Source: MapSource{
+		"example.com": "v=spf1 ip4:192.0.2.0/25 -ip4:198.51.100.66 -all",
+	}}
+
+	observations := []Observation{
+		{netip.MustParseAddr("192.0.2.10"), 500},
+		{netip.MustParseAddr("198.51.100.1"), 40},
+		{netip.MustParseAddr("::ffff:198.51.100.1"), 20},
+		{netip.MustParseAddr("198.51.100.66"), 80},
+		{netip.MustParseAddr("203.0.113.9"), 2},
+		{netip.Addr{}, 1000},
+	}
+
+	s, err := c.Suggest(context.Background(), "example.com", observations, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Total != 642 || s.Covered != 500 {
+		t.Error("Expected 500 of 642 covered got", s.Covered, s.Total)
+	}
+
+	expected := []SenderGap{
+		{Observation{netip.MustParseAddr("198.51.100.66"), 80}, Fail},
+		{Observation{netip.MustParseAddr("198.51.100.1"), 60}, Fail},
+		{Observation{netip.MustParseAddr("203.0.113.9"), 2}, Fail},
+	}
+
+	if len(s.Uncovered) != len(expected) {
+		t.Fatal("Expected", expected, "got", s.Uncovered)
+	}
+
+	for i, gap := range s.Uncovered {
+		if gap != expected[i] {
+			t.Error("Expected", expected[i], "got", gap)
+		}
+	}
+
+	record := "v=spf1 ip4:192.0.2.0/25 -ip4:198.51.100.66 ip4:198.51.100.1 -all"
+	if s.Record.SPFString() != record {
+		t.Error("Expected", record, "got", s.Record.SPFString())
+	}
+
+	s, err = c.Suggest(context.Background(), "example.org", observations, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record = "v=spf1 ip4:192.0.2.10 ip4:198.51.100.1 ip4:198.51.100.66 ip4:203.0.113.9 ~all"
+	if s.Record.SPFString() != record || len(s.Uncovered) != 4 || s.Uncovered[0].Result != None {
+		t.Error("Expected", record, "got", s.Record.SPFString(), s.Uncovered)
+	}
+}
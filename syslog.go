@@ -0,0 +1,197 @@
+package spf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSyslogFacility is the facility of a SyslogSink without one,
+	// mail.
+	DefaultSyslogFacility = 2
+
+	// DefaultSyslogSDID is the SD-ID of the structured data element of a
+	// SyslogSink without one. 32473 is the enterprise number reserved for
+	// documentation by RFC 5612; set an SD-ID of your own in production.
+	DefaultSyslogSDID = "spf@32473"
+)
+
+// SyslogSink is an EventSink sending each event as an RFC 5424 syslog message
+// to Writer, typically a connection to a syslog daemon. The event is carried
+// both as a structured data element and as a readable message. A SyslogSink
+// must not be copied after first use.
+type SyslogSink struct {
+	Writer io.Writer
+
+	// Facility is the syslog facility code, or DefaultSyslogFacility if
+	// zero. The severity of a message depends on the result: informational
+	// for Pass, Neutral and None, notice for Fail and SoftFail and warning
+	// for TempError and PermError.
+	Facility int
+
+	// Hostname and AppName identify the sender. If empty, the host name of
+	// the machine and "spf" are used.
+	Hostname string
+	AppName  string
+
+	// SDID is the SD-ID of the structured data element, or
+	// DefaultSyslogSDID if empty.
+	SDID string
+
+	// OctetCounting frames each message with its length, RFC 6587, as
+	// required by syslog over TCP. Messages sent over UDP are not framed.
+	OctetCounting bool
+
+	// Format returns the MSG part of the message. If nil, the result is
+	// described in the style of Authentication-Results.
+	Format func(event EvaluationEvent) string
+
+	// OnError is called when a message could not be written.
+	OnError func(err error)
+
+	mu sync.Mutex
+}
+
+// DialSyslog connects to the syslog daemon at addr over network, "udp" or
+// "tcp", and returns a SyslogSink writing to it. Messages sent over TCP use
+// octet counting.
+func DialSyslog(network, addr string) (*SyslogSink, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyslogSink{Writer: conn, OctetCounting: strings.HasPrefix(network, "tcp")}, nil
+}
+
+// Emit writes the event.
+func (s *SyslogSink) Emit(event EvaluationEvent) {
+	msg := s.Message(event)
+	if s.OctetCounting {
+		msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+	}
+
+	s.mu.Lock()
+	_, err := s.Writer.Write(msg)
+	s.mu.Unlock()
+
+	if err != nil && s.OnError != nil {
+		s.OnError(err)
+	}
+}
+
+// Message returns the event as an RFC 5424 syslog message, without framing.
+func (s *SyslogSink) Message(event EvaluationEvent) []byte {
+	var buf bytes.Buffer
+
+	facility := s.Facility
+	if facility == 0 {
+		facility = DefaultSyslogFacility
+	}
+
+	hostname := s.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	appName := s.AppName
+	if appName == "" {
+		appName = "spf"
+	}
+
+	sdid := s.SDID
+	if sdid == "" {
+		sdid = DefaultSyslogSDID
+	}
+
+	buf.WriteString(fmt.Sprintf("<%d>1 %s %s %s %d evaluation [%s", facility*8+syslogSeverity(event.Result),
+		event.Time.UTC().Format(time.RFC3339Nano), syslogHeader(hostname), syslogHeader(appName), os.Getpid(), sdid))
+
+	params := []struct{ name, value string }{
+		{"result", string(event.Result)},
+		{"ip", event.ClientIP},
+		{"identity", event.Identity},
+		{"helo", event.Helo},
+		{"domain", event.Domain},
+		{"mechanism", event.Mechanism},
+		{"override", event.Override},
+		{"error", event.Error},
+		{"queries", fmt.Sprint(event.Queries)},
+		{"duration", event.Duration.String()},
+	}
+
+	for _, p := range params {
+		if p.value != "" {
+			buf.WriteString(fmt.Sprintf(" %s=\"%s\"", p.name, sdEscaper.Replace(p.value)))
+		}
+	}
+	buf.WriteString("] ")
+
+	if s.Format != nil {
+		buf.WriteString(s.Format(event))
+	} else {
+		buf.WriteString(eventSummary(event))
+	}
+
+	return buf.Bytes()
+}
+
+// syslogSeverity returns the severity of the messages of a result.
+func syslogSeverity(r Result) int {
+	switch r {
+	case Fail, SoftFail:
+		return 5
+	case TempError, PermError:
+		return 4
+	}
+
+	return 6
+}
+
+// syslogHeader returns the value as a header field, the NILVALUE if empty.
+// Header fields are printable US-ASCII without spaces.
+func syslogHeader(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, value)
+
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+// sdEscaper escapes the characters RFC 5424 section 6.3.3 requires to be
+// escaped in PARAM-VALUEs.
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// eventSummary describes the event in the style of Authentication-Results,
+// e.g. "spf=pass smtp.mailfrom=info@example.com client-ip=192.0.2.1".
+func eventSummary(event EvaluationEvent) string {
+	summary := fmt.Sprintf("spf=%s", strings.ToLower(string(event.Result)))
+
+	switch {
+	case event.Sender != "":
+		summary += " smtp.mailfrom=" + event.Sender
+	case event.Helo != "":
+		summary += " smtp.helo=" + event.Helo
+	}
+
+	summary += " client-ip=" + event.ClientIP
+
+	if event.Error != "" {
+		summary += fmt.Sprintf(" (%s)", event.Error)
+	}
+
+	return summary
+}
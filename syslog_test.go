@@ -0,0 +1,70 @@
+package spf
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := &SyslogSink{Writer: &buf, Hostname: "mx 1", AppName: "spfd", OctetCounting: true}
+
+	event := EvaluationEvent{
+		Time:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		ClientIP: "192.0.2.1",
+		Sender:   "info@example.com",
+		Identity: "info@example.com",
+		Result:   PermError,
+		Error:    `Bad "record]`,
+	}
+	s.Emit(event)
+
+	length, msg, _ := strings.Cut(buf.String(), " ")
+	if length != strconv.Itoa(len(msg)) {
+		t.Error("Expected a frame of", len(msg), "bytes got", length)
+	}
+
+	tests := []string{
+		"<20>1 2024-05-01T12:00:00Z mx1 spfd ",
+		` evaluation [spf@32473 result="PermError" ip="192.0.2.1" identity="info@example.com" error="Bad \"record\]" queries="0" duration="0s"] `,
+		"spf=permerror smtp.mailfrom=info@example.com client-ip=192.0.2.1 (Bad \"record])",
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test)
+
+		if !strings.Contains(msg, test) {
+			t.Error("Expected", test, "got", msg)
+		}
+	}
+}
+
+func TestDialSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	s, err := DialSyslog("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Emit(EvaluationEvent{Time: time.Now(), ClientIP: "192.0.2.1", Result: Pass})
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	packet := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(packet[:n]), "<22>1 ") {
+		t.Error("Expected an unframed mail.info message got", string(packet[:n]))
+	}
+}
@@ -0,0 +1,112 @@
+package spf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"text/template"
+)
+
+// VarType is the type of a template variable.
+type VarType string
+
+const (
+	VarDomain VarType = "domain" // a domain name, e.g. _spf.vendor.example
+	VarIP4    VarType = "ip4"    // an IPv4 address or network
+	VarIP6    VarType = "ip6"    // an IPv6 address or network
+	VarResult VarType = "result" // a qualifier: +, -, ~ or ?
+)
+
+var (
+	ErrInvalidVariable = errors.New("Invalid template variable.")
+)
+
+// Template is an SPF record template such as
+// "v=spf1 include:{{.Vendor}} ip4:{{.Office}} -all". The template uses the
+// text/template syntax. Every variable has a declared type and values are
+// validated against it before the record is rendered.
+type Template struct {
+	Text string
+	Vars map[string]VarType
+
+	tmpl *template.Template
+}
+
+// NewTemplate parses the template text. Vars declares the type of each
+// variable used by the template.
+func NewTemplate(text string, vars map[string]VarType) (*Template, error) {
+	tmpl, err := template.New("spf").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, typ := range vars {
+		switch typ {
+		case VarDomain, VarIP4, VarIP6, VarResult:
+		default:
+			return nil, fmt.Errorf("%s: %v", name, ErrInvalidVariable)
+		}
+	}
+
+	return &Template{Text: text, Vars: vars, tmpl: tmpl}, nil
+}
+
+// Render substitutes the values into the template and parses the result as
+// the SPF record of the domain. Values must be given for every declared
+// variable and must match its type. The rendered record must be valid.
+func (t *Template) Render(domain string, values map[string]string) (SPF, error) {
+	for name, typ := range t.Vars {
+		value, ok := values[name]
+		if !ok || !validVariable(typ, value) {
+			return SPF{}, fmt.Errorf("%s: %v", name, ErrInvalidVariable)
+		}
+	}
+
+	for name := range values {
+		if _, ok := t.Vars[name]; !ok {
+			return SPF{}, fmt.Errorf("%s: %v", name, ErrInvalidVariable)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, values); err != nil {
+		return SPF{}, err
+	}
+
+	// NewSPF would fetch the published record instead of an empty one.
+	record := strings.Join(strings.Fields(buf.String()), " ")
+	if record == "" {
+		return SPF{}, ErrInvalidSPF
+	}
+
+	return NewSPF(domain, record, 0)
+}
+
+// validVariable reports whether value is valid for the variable type.
+func validVariable(typ VarType, value string) bool {
+	switch typ {
+	case VarDomain:
+		return value != "" && !strings.ContainsAny(value, " \t/:=") && strings.Contains(value, ".")
+	case VarIP4, VarIP6:
+		var addr netip.Addr
+
+		if p, err := netip.ParsePrefix(value); err == nil {
+			addr = p.Addr()
+		} else if a, err := netip.ParseAddr(value); err == nil {
+			addr = a
+		} else {
+			return false
+		}
+
+		return addr.Is4() == (typ == VarIP4)
+	case VarResult:
+		switch value {
+		case "+", "-", "~", "?":
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,64 @@
+package spf
+
+import (
+	"testing"
+)
+
+type templatetest struct {
+	values   map[string]string
+	expected string
+	valid    bool
+}
+
+func TestTemplate(t *testing.T) {
+	tmpl, err := NewTemplate("v=spf1 include:{{.Vendor}} ip4:{{.Office}} {{.Default}}all", map[string]VarType{
+		"Vendor":  VarDomain,
+		"Office":  VarIP4,
+		"Default": VarResult,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []templatetest{
+		templatetest{
+			map[string]string{"Vendor": "_spf.vendor.example", "Office": "192.0.2.0/24", "Default": "-"},
+			"v=spf1 include:_spf.vendor.example ip4:192.0.2.0/24 -all",
+			true,
+		},
+		templatetest{map[string]string{"Vendor": "_spf.vendor.example", "Office": "2001:db8::1", "Default": "-"}, "", false},
+		templatetest{map[string]string{"Vendor": "vendor example", "Office": "192.0.2.1", "Default": "~"}, "", false},
+		templatetest{map[string]string{"Vendor": "_spf.vendor.example", "Office": "192.0.2.1"}, "", false},
+		templatetest{map[string]string{"Vendor": "_spf.vendor.example", "Office": "192.0.2.1", "Default": "!"}, "", false},
+		templatetest{map[string]string{"Vendor": "a.example", "Office": "192.0.2.1", "Default": "-", "Extra": "x"}, "", false},
+	}
+
+	for _, tcase := range tests {
+		s, err := tmpl.Render("example.com", tcase.values)
+		if tcase.valid != (err == nil) {
+			t.Error("For", tcase.values, "Expected valid", tcase.valid, "got", err)
+			continue
+		}
+
+		if tcase.valid && s.SPFString() != tcase.expected {
+			t.Error("Expected", tcase.expected, "got", s.SPFString())
+		}
+	}
+
+	if _, err := NewTemplate("v=spf1 {{.Broken", nil); err == nil {
+		t.Error("Expected error got nil")
+	}
+
+	if _, err := NewTemplate("v=spf1 -all", map[string]VarType{"X": "port"}); err == nil {
+		t.Error("Expected error got nil")
+	}
+
+	empty, err := NewTemplate("{{if eq .Default \"-\"}}v=spf1 -all{{end}} \n ", map[string]VarType{"Default": VarResult})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := empty.Render("example.com", map[string]string{"Default": "~"}); err != ErrInvalidSPF {
+		t.Error("Expected", ErrInvalidSPF, "got", err)
+	}
+}
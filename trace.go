@@ -0,0 +1,175 @@
+package spf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Trace records the evaluation of an SPF record: every term checked, the DNS
+// data it looked at and the records evaluated through include and redirect.
+type Trace struct {
+	Domain string
+	Record string
+	Terms  []*TraceTerm
+	Result Result
+
+	parent *TraceTerm
+}
+
+// TraceTerm records the evaluation of a single mechanism. Result is only set
+// when the mechanism matched. Record holds the trace of the included or
+// redirected record, if any. Pending is set for mechanisms that were not, or
+// not completely, evaluated before the deadline of the evaluation expired.
+type TraceTerm struct {
+	Mechanism Mechanism
+	Matched   bool
+	Result    Result
+	Lookups   []string
+	Record    *Trace
+	Pending   bool
+}
+
+// SPFTrace evaluates the SPF record for the email address like SPFTest using
+// the DefaultChecker and returns the trace of the evaluation.
+func SPFTrace(ctx context.Context, ip, email string) (*Trace, error) {
+	return DefaultChecker.Trace(ctx, ip, email)
+}
+
+// Trace evaluates the SPF record for the email address like SPFTest and
+// returns the trace of the evaluation. The final result is held in the
+// Result of the returned Trace. An ip that is not an IP address returns
+// ErrInvalidIP.
+func (c *Checker) Trace(ctx context.Context, ip, email string) (*Trace, error) {
+	addr, err := parseIP(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	e := newEvaluation(ctx, c, addr)
+	e.tracing = true
+
+	result := c.check(e, email)
+	if e.root == nil {
+		e.root = &Trace{Domain: e.domain}
+	}
+	e.root.Result = result
+
+	return e.root, e.err
+}
+
+// Return a Trace as an indented tree.
+func (t *Trace) String() string {
+	var buf bytes.Buffer
+
+	t.write(&buf, "")
+	buf.WriteString(fmt.Sprintf("result: %s\n", t.Result))
+
+	return buf.String()
+}
+
+func (t *Trace) write(buf *bytes.Buffer, indent string) {
+	if t.Record == "" {
+		buf.WriteString(fmt.Sprintf("%s%s: no record\n", indent, t.Domain))
+		return
+	}
+
+	buf.WriteString(fmt.Sprintf("%s%s: %s\n", indent, t.Domain, t.Record))
+
+	for _, term := range t.Terms {
+		status := "no match"
+		switch {
+		case term.Pending:
+			status = "pending"
+		case term.Matched:
+			status = fmt.Sprintf("match (%s)", term.Result)
+		}
+
+		buf.WriteString(fmt.Sprintf("%s  %s: %s\n", indent, term.Mechanism.SPFString(), status))
+
+		for _, lookup := range term.Lookups {
+			buf.WriteString(fmt.Sprintf("%s    %s\n", indent, lookup))
+		}
+
+		if term.Record != nil {
+			term.Record.write(buf, indent+"    ")
+			buf.WriteString(fmt.Sprintf("%s    result: %s\n", indent, term.Record.Result))
+		}
+	}
+}
+
+// begin starts tracing the evaluation of a record.
+func (e *evaluation) begin(s *SPF) *Trace {
+	if !e.tracing {
+		return nil
+	}
+
+	node := &Trace{Domain: s.Domain, Record: s.Raw, parent: e.term}
+	if e.term != nil {
+		e.term.Record = node
+	} else if e.root == nil {
+		e.root = node
+	}
+
+	return node
+}
+
+// end finishes tracing the evaluation of a record.
+func (e *evaluation) end(node *Trace) {
+	if node != nil {
+		e.term = node.parent
+	}
+}
+
+// result records the result of the traced record and returns it.
+func (e *evaluation) result(node *Trace, r Result) Result {
+	if node != nil {
+		node.Result = r
+	}
+
+	return r
+}
+
+// beginTerm starts tracing the evaluation of a mechanism of the record.
+func (e *evaluation) beginTerm(node *Trace, m *Mechanism) *TraceTerm {
+	if node == nil {
+		return nil
+	}
+
+	term := &TraceTerm{Mechanism: *m}
+	node.Terms = append(node.Terms, term)
+	e.term = term
+
+	return term
+}
+
+// pending records the mechanisms left unevaluated as pending.
+func (e *evaluation) pending(node *Trace, mechanisms []Mechanism) {
+	if node == nil {
+		return
+	}
+
+	for _, m := range mechanisms {
+		node.Terms = append(node.Terms, &TraceTerm{Mechanism: m, Pending: true})
+	}
+}
+
+// endTerm records the outcome of a traced mechanism.
+func (e *evaluation) endTerm(term *TraceTerm, r Result, matched bool) {
+	if term == nil {
+		return
+	}
+
+	term.Matched = matched
+	if matched {
+		term.Result = r
+	}
+}
+
+// note records DNS data looked at by the mechanism being traced.
+func (e *evaluation) note(format string, args ...interface{}) {
+	if e.term != nil {
+		e.term.Lookups = append(e.term.Lookups, strings.TrimSpace(fmt.Sprintf(format, args...)))
+	}
+}
@@ -0,0 +1,87 @@
+package spf
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrace(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(flattenZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Resolver: z}
+
+	trace, err := c.Trace(context.Background(), "198.51.100.7", "info@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `example.org: v=spf1 mx include:_spf.vendor.example -ip4:192.0.2.66 redirect=_rest.example.org
+  mx:example.org: no match
+    MX example.org: mail.example.org. 192.0.2.25
+  include:_spf.vendor.example: match (Pass)
+    _spf.vendor.example: v=spf1 ip4:198.51.100.0/24 -ip4:203.0.113.1 include:_net.vendor.example -all
+      ip4:198.51.100.0/24: match (Pass)
+    result: Pass
+result: Pass
+`
+	if trace.String() != expected {
+		t.Error("Expected", expected, "got", trace.String())
+	}
+
+	trace, _ = c.Trace(context.Background(), "198.51.100.7", "info@missing.example")
+	if trace.Result != None || trace.String() != "missing.example: no record\nresult: None\n" {
+		t.Error("Unexpected trace", trace.String())
+	}
+}
+
+// slowResolver cancels the evaluation during the first host lookup.
+type slowResolver struct {
+	Resolver
+	cancel context.CancelFunc
+}
+
+func (r slowResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.cancel()
+	return nil, ctx.Err()
+}
+
+func TestTraceDeadline(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(flattenZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Checker{Resolver: slowResolver{z, cancel}}
+
+	trace, err := c.Trace(ctx, "198.51.100.7", "info@example.org")
+	if err != ErrTimeout || trace.Result != TempError {
+		t.Error("Expected", TempError, ErrTimeout, "got", trace.Result, err)
+	}
+
+	expected := `example.org: v=spf1 mx include:_spf.vendor.example -ip4:192.0.2.66 redirect=_rest.example.org
+  mx:example.org: pending
+    MX example.org:
+  include:_spf.vendor.example: pending
+  -ip4:192.0.2.66: pending
+  redirect=_rest.example.org: pending
+result: TempError
+`
+	if trace.String() != expected {
+		t.Error("Expected", expected, "got", trace.String())
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c = &Checker{Resolver: z, DeadlineMargin: 2 * time.Second}
+	if result, err := c.SPFTest(ctx, "198.51.100.7", "info@example.org"); result != TempError || err != ErrTimeout {
+		t.Error("Expected", TempError, ErrTimeout, "got", result, err)
+	}
+}
@@ -0,0 +1,146 @@
+package spf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DKIMResult is the outcome of the verification of a DKIM signature, as
+// performed by the caller. Result is one of Pass, Fail, Neutral, None,
+// TempError or PermError. Domain and Selector are the d= and s= tags of the
+// signature.
+type DKIMResult struct {
+	Result   Result
+	Domain   string
+	Selector string
+	Reason   string
+}
+
+// Return the result as an Authentication-Results result clause, e.g.
+// "dkim=pass header.d=example.com header.s=mail".
+func (r DKIMResult) String() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("dkim=%s", strings.ToLower(string(r.Result))))
+
+	if r.Reason != "" {
+		buf.WriteString(fmt.Sprintf(" reason=%s", strconv.Quote(r.Reason)))
+	}
+
+	if r.Domain != "" {
+		buf.WriteString(fmt.Sprintf(" header.d=%s", r.Domain))
+	}
+
+	if r.Selector != "" {
+		buf.WriteString(fmt.Sprintf(" header.s=%s", r.Selector))
+	}
+
+	return buf.String()
+}
+
+// Verdict combines the SPF result of a message with the results of its DKIM
+// signatures.
+type Verdict struct {
+	SPF  CheckResult
+	DKIM []DKIMResult
+
+	// Result is Pass when SPF or a DKIM signature passed. Otherwise it is
+	// TempError if one of the checks may pass once retried, Fail if SPF or
+	// a signature failed and None if nothing could be authenticated.
+	Result Result
+
+	// Domains lists the authenticated domains: the domain checked by SPF
+	// if it passed, then the d= domains of the signatures that passed,
+	// lowercase and without duplicates.
+	Domains []string
+
+	// identity is the SPF clause of Authentication-Results.
+	identity AuthResult
+}
+
+// NewVerdict combines the result of the SPF check of the request with the
+// DKIM results.
+func NewVerdict(req Request, spf CheckResult, dkim ...DKIMResult) Verdict {
+	v := Verdict{SPF: spf, DKIM: dkim, Result: None}
+	v.identity = AuthResult{Result: spf.Result, Properties: make(map[string]string)}
+
+	switch {
+	case req.Sender != "":
+		v.identity.Properties["smtp.mailfrom"] = req.Sender
+	case req.Helo != "":
+		v.identity.Properties["smtp.helo"] = req.Helo
+	}
+
+	results := []Result{spf.Result}
+	if spf.Result == Pass {
+		v.addDomain(spf.Domain)
+	}
+
+	for _, r := range dkim {
+		results = append(results, r.Result)
+		if r.Result == Pass {
+			v.addDomain(r.Domain)
+		}
+	}
+
+	for _, r := range results {
+		switch {
+		case r == Pass:
+			v.Result = Pass
+		case r == TempError && v.Result != Pass:
+			v.Result = TempError
+		case (r == Fail || r == SoftFail) && v.Result == None:
+			v.Result = Fail
+		}
+	}
+
+	return v
+}
+
+func (v *Verdict) addDomain(domain string) {
+	domain = canonicalName(domain)
+	if domain == "" {
+		return
+	}
+
+	for _, d := range v.Domains {
+		if d == domain {
+			return
+		}
+	}
+
+	v.Domains = append(v.Domains, domain)
+}
+
+// Aligned reports whether one of the authenticated domains is aligned with
+// the domain of the RFC5322.From header, the condition for a DMARC pass.
+func (v Verdict) Aligned(fromDomain string, mode AlignmentMode) bool {
+	for _, d := range v.Domains {
+		if Aligned(d, fromDomain, mode) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AuthenticationResults returns the value of an Authentication-Results
+// header field, RFC 8601, holding the spf and dkim results, e.g.
+// "mx.example.net; spf=pass smtp.mailfrom=info@example.com; dkim=pass
+// header.d=example.com header.s=mail".
+func (v Verdict) AuthenticationResults(authServID string) string {
+	var buf bytes.Buffer
+
+	buf.WriteString(authServID)
+	buf.WriteString("; ")
+	buf.WriteString(v.identity.String())
+
+	for _, r := range v.DKIM {
+		buf.WriteString("; ")
+		buf.WriteString(r.String())
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,56 @@
+package spf
+
+import (
+	"testing"
+)
+
+func TestNewVerdict(t *testing.T) {
+	req := Request{IP: "192.0.2.1", Sender: "info@mail.example.com"}
+
+	tests := []struct {
+		spf     Result
+		dkim    []DKIMResult
+		result  Result
+		domains int
+	}{
+		{Pass, nil, Pass, 1},
+		{Fail, []DKIMResult{{Result: Pass, Domain: "Example.COM"}}, Pass, 1},
+		{Pass, []DKIMResult{{Result: Pass, Domain: "mail.example.com."}}, Pass, 1},
+		{SoftFail, []DKIMResult{{Result: TempError, Domain: "example.com"}}, TempError, 0},
+		{Neutral, []DKIMResult{{Result: Fail, Domain: "example.com"}}, Fail, 0},
+		{None, nil, None, 0},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.spf, test.dkim)
+
+		v := NewVerdict(req, CheckResult{Result: test.spf, Domain: "mail.example.com"}, test.dkim...)
+		if v.Result != test.result || len(v.Domains) != test.domains {
+			t.Error("Expected", test.result, test.domains, "got", v.Result, v.Domains)
+		}
+	}
+
+	v := NewVerdict(req, CheckResult{Result: SoftFail, Domain: "mail.example.com"},
+		DKIMResult{Result: Pass, Domain: "example.com", Selector: "s1"},
+		DKIMResult{Result: Fail, Domain: "vendor.example", Selector: "k", Reason: "bad signature"})
+
+	if !v.Aligned("example.com", StrictAlignment) || v.Aligned("mail.example.com", StrictAlignment) || !v.Aligned("mail.example.com", RelaxedAlignment) {
+		t.Error("Expected only the DKIM domain authenticated got", v.Domains)
+	}
+
+	expected := `mx.example.net; spf=softfail smtp.mailfrom=info@mail.example.com; dkim=pass header.d=example.com header.s=s1; dkim=fail reason="bad signature" header.d=vendor.example header.s=k`
+	if header := v.AuthenticationResults("mx.example.net"); header != expected {
+		t.Error("Expected", expected, "got", header)
+	}
+
+	results, err := ParseAuthenticationResults(v.AuthenticationResults("mx.example.net"))
+	if err != nil || len(results) != 1 || results[0].Result != SoftFail {
+		t.Error("Expected the spf result back got", results, err)
+	}
+
+	v = NewVerdict(Request{IP: "192.0.2.1", Helo: "mail.example.com"}, CheckResult{Result: Pass, Domain: "mail.example.com"})
+	expected = "mx.example.net; spf=pass smtp.helo=mail.example.com"
+	if header := v.AuthenticationResults("mx.example.net"); header != expected {
+		t.Error("Expected", expected, "got", header)
+	}
+}
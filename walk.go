@@ -0,0 +1,93 @@
+package spf
+
+import (
+	"context"
+	"errors"
+)
+
+// SkipInclude is used as a return value from a WalkFunc to indicate that the
+// record referenced by the include or redirect passed in is not to be
+// walked. It is not returned as an error by any function.
+var SkipInclude = errors.New("skip this include")
+
+// WalkFunc is the type of the function called by WalkTree for each term of
+// the visited records. Domain is the domain of the record holding the term.
+type WalkFunc func(domain string, m *Mechanism) error
+
+// Walk calls fn for each mechanism of the record, in order. Modifiers are
+// skipped. fn receives a copy of the mechanism, so the record cannot be
+// changed through it. Walk stops at the first error returned by fn and
+// returns it.
+func (s *SPF) Walk(fn func(m *Mechanism) error) error {
+	for _, m := range s.Mechanisms {
+		if err := fn(&m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ModifierWalk calls fn for each modifier of the record, such as redirect,
+// like Walk does for mechanisms.
+func (s *SPF) ModifierWalk(fn func(m *Modifier) error) error {
+	for _, m := range s.Modifiers {
+		if err := fn(&m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WalkTree calls fn for each mechanism of the record, then for its redirect
+// modifier, and, depth first, for the terms of the records referenced by its
+// include mechanisms and redirect. The referenced records are fetched with
+// the Checker that created the record. Records already on the current
+// include chain are not walked again. If fn returns SkipInclude for an
+// include or redirect, the referenced record is not walked. Any other error
+// stops the walk and is returned, as are errors fetching the referenced
+// records.
+func (s *SPF) WalkTree(ctx context.Context, fn WalkFunc) error {
+	c := s.checker
+	if c == nil {
+		c = DefaultChecker
+	}
+
+	return s.walkTree(ctx, c, fn, map[string]bool{})
+}
+
+func (s *SPF) walkTree(ctx context.Context, c *Checker, fn WalkFunc, visited map[string]bool) error {
+	visited[canonicalName(s.Domain)] = true
+	defer delete(visited, canonicalName(s.Domain))
+
+	for _, m := range s.terms() {
+		err := fn(s.Domain, &m)
+		if err == SkipInclude {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if m.Name != "include" && m.Name != "redirect" {
+			continue
+		}
+
+		if visited[canonicalName(m.Domain)] || hasMacro(m.Domain) {
+			continue
+		}
+
+		nested, err := c.NewSPF(ctx, m.Domain, "", 0)
+		if err != nil {
+			return err
+		}
+
+		if err := nested.walkTree(ctx, c, fn, visited); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
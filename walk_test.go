@@ -0,0 +1,77 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	c := &Checker{Source: MapSource{
+		"example.com":       "v=spf1 ip4:192.0.2.1 include:_spf.example.com include:skip.example redirect=_rest.example.com",
+		"_spf.example.com":  "v=spf1 a -all",
+		"skip.example":      "v=spf1 mx -all",
+		"_rest.example.com": "v=spf1 include:example.com ~all",
+	}}
+
+	s, err := c.NewSPF(context.Background(), "example.com", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var terms []string
+	s.Walk(func(m *Mechanism) error {
+		terms = append(terms, m.SPFString())
+		m.Name = "changed"
+		return nil
+	})
+
+	if len(terms) != 3 || s.Mechanisms[0].Name != "ip4" {
+		t.Error("Unexpected walk", terms, s.Mechanisms[0].Name)
+	}
+
+	var modifiers []string
+	s.ModifierWalk(func(m *Modifier) error {
+		modifiers = append(modifiers, m.SPFString())
+		return nil
+	})
+
+	if len(modifiers) != 1 || modifiers[0] != "redirect=_rest.example.com" {
+		t.Error("Unexpected modifiers", modifiers)
+	}
+
+	terms = nil
+	err = s.WalkTree(context.Background(), func(domain string, m *Mechanism) error {
+		terms = append(terms, domain+" "+m.SPFString())
+		if m.Domain == "skip.example" {
+			return SkipInclude
+		}
+		return nil
+	})
+
+	expected := []string{
+		"example.com ip4:192.0.2.1",
+		"example.com include:_spf.example.com",
+		"_spf.example.com a:_spf.example.com",
+		"_spf.example.com -all",
+		"example.com include:skip.example",
+		"example.com redirect=_rest.example.com",
+		"_rest.example.com include:example.com",
+		"_rest.example.com ~all",
+	}
+
+	if err != nil || len(terms) != len(expected) {
+		t.Fatal("Expected", expected, "got", terms, err)
+	}
+
+	for i := range terms {
+		if terms[i] != expected[i] {
+			t.Error("Expected", expected[i], "got", terms[i])
+		}
+	}
+
+	stop := errors.New("stop")
+	if err := s.WalkTree(context.Background(), func(string, *Mechanism) error { return stop }); err != stop {
+		t.Error("Expected", stop, "got", err)
+	}
+}
@@ -0,0 +1,56 @@
+package spf
+
+import (
+	"context"
+	"sync"
+)
+
+// warmConcurrency is the number of domains Warm resolves at once.
+const warmConcurrency = 8
+
+// Warm fetches the SPF records of the domains and of every record they
+// include or redirect to, so that the answers are in the cache of the
+// Checker's Resolver or Source, e.g. a CachingResolver, before the first
+// message from these domains is checked. All domains are warmed even when
+// some fail; the first error is returned.
+func (c *Checker) Warm(ctx context.Context, domains []string) error {
+	var wg sync.WaitGroup
+	var once sync.Once
+	var first error
+
+	queue := make(chan string)
+
+	for i := 0; i < warmConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for domain := range queue {
+				if err := c.warm(ctx, domain); err != nil {
+					once.Do(func() { first = err })
+				}
+			}
+		}()
+	}
+
+	for _, domain := range domains {
+		queue <- domain
+	}
+	close(queue)
+
+	wg.Wait()
+
+	return first
+}
+
+// warm fetches the record of the domain and walks its include tree.
+func (c *Checker) warm(ctx context.Context, domain string) error {
+	spf, err := c.NewSPF(ctx, domain, "", 0)
+	if err != nil {
+		return err
+	}
+
+	return spf.WalkTree(ctx, func(domain string, m *Mechanism) error {
+		return nil
+	})
+}
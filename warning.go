@@ -0,0 +1,54 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrEmptyDomain      = errors.New("Mechanism domain is empty.")
+	ErrUnknownMechanism = errors.New("Unknown mechanism in SPF string.")
+)
+
+// Warning describes a problem found in a record parsed in lenient mode. Term
+// is the offending term of the record and Err describes the problem.
+type Warning struct {
+	Term string
+	Err  error
+}
+
+// Return a Warning as a string.
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %v", w.Term, w.Err)
+}
+
+// ParseLenient parses the record of the domain in lenient mode using the
+// DefaultChecker. See Checker.NewSPFLenient.
+func ParseLenient(domain, record string) (SPF, []Warning, error) {
+	return DefaultChecker.parse(context.Background(), domain, record, 0, Lenient)
+}
+
+// termError returns a more specific error than ErrInvalidMechanism for a
+// term that failed to parse or validate.
+func termError(term string, m Mechanism, err error) error {
+	if err != ErrInvalidMechanism {
+		return err
+	}
+
+	name := strings.TrimLeft(term, "+-~?")
+	if i := strings.IndexAny(name, ":/="); i != -1 {
+		if i+1 == len(name) || name[i+1] == '/' {
+			return ErrEmptyDomain
+		}
+		name = name[:i]
+	}
+
+	switch name {
+	case "all", "a", "mx", "ip4", "ip6", "exists", "include", "ptr", "redirect", "exp":
+		return ErrInvalidMechanism
+	}
+
+	return ErrUnknownMechanism
+}
@@ -0,0 +1,45 @@
+package spf
+
+import (
+	"testing"
+)
+
+func TestParseLenient(t *testing.T) {
+	record := "v=spf1 ip4:192.0.2.1 include: foo:bar ip4:300.1.1.1 a:/24 include:example.com mx -all"
+
+	s, warnings, err := ParseLenient("example.com", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "v=spf1 ip4:192.0.2.1 mx -all"
+	if s.SPFString() != expected {
+		t.Error("Expected", expected, "got", s.SPFString())
+	}
+
+	expectedWarnings := []Warning{
+		Warning{"include:", ErrEmptyDomain},
+		Warning{"foo:bar", ErrUnknownMechanism},
+		Warning{"ip4:300.1.1.1", ErrInvalidMechanism},
+		Warning{"a:/24", ErrEmptyDomain},
+		Warning{"include:example.com", ErrIncludeLoop},
+	}
+
+	if len(warnings) != len(expectedWarnings) {
+		t.Fatal("Expected", expectedWarnings, "got", warnings)
+	}
+
+	for i, w := range warnings {
+		if w != expectedWarnings[i] {
+			t.Error("Expected", expectedWarnings[i], "got", w)
+		}
+	}
+
+	if _, err := NewSPF("example.com", record, 0); err == nil {
+		t.Error("Expected error got nil")
+	}
+
+	if _, _, err := ParseLenient("example.com", "spf1 -all"); err != ErrInvalidSPF {
+		t.Error("Expected", ErrInvalidSPF, "got", err)
+	}
+}
@@ -0,0 +1,157 @@
+package spf
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Change is a change of the SPF record of a domain observed by Watch. Old or
+// New is empty when the domain did not, or no longer does, publish a record.
+type Change struct {
+	Domain string
+	Old    string
+	New    string
+	Diff   Diff
+	Time   time.Time
+}
+
+// Diff lists the terms added to and removed from a record.
+type Diff struct {
+	Added   []string
+	Removed []string
+}
+
+// DiffRecords compares the terms of two records. Terms are compared in their
+// normalized spelling, so records differing only in whitespace or case have
+// an empty Diff.
+func DiffRecords(old, new string) Diff {
+	var d Diff
+
+	oldTerms := recordTerms(old)
+	newTerms := recordTerms(new)
+
+	for _, term := range newTerms {
+		if !containsString(oldTerms, term) {
+			d.Added = append(d.Added, term)
+		}
+	}
+
+	for _, term := range oldTerms {
+		if !containsString(newTerms, term) {
+			d.Removed = append(d.Removed, term)
+		}
+	}
+
+	return d
+}
+
+// Empty reports whether no term was added or removed.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// recordTerms returns the terms of the record in their normalized spelling,
+// or as is for terms that do not parse.
+func recordTerms(record string) []string {
+	var terms []string
+
+	for _, f := range strings.Fields(record) {
+		if m, err := NewMechanism(f, ""); err == nil {
+			m.plus = false
+			f = m.SPFString()
+		}
+
+		terms = append(terms, f)
+	}
+
+	return terms
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Watch polls the SPF record of the domain using the DefaultChecker. See
+// Checker.Watch.
+func Watch(ctx context.Context, domain string, interval time.Duration) <-chan Change {
+	return DefaultChecker.Watch(ctx, domain, interval)
+}
+
+// Watch polls the SPF record of the domain and sends a Change whenever the
+// record differs from the one seen at the previous poll. The first poll only
+// records the current record. Polls are spaced by the interval, or by the TTL
+// of the TXT records when the Resolver reports a longer one, as the answer
+// would not change before it expires from caches. With a zero interval the
+// TTL is used, but no less than MinRefreshInterval. Failed lookups are
+// retried at the next poll and never reported as a change. The channel is
+// closed once the context is cancelled.
+func (c *Checker) Watch(ctx context.Context, domain string, interval time.Duration) <-chan Change {
+	changes := make(chan Change)
+
+	go func() {
+		defer close(changes)
+
+		var current string
+		polled := false
+
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+
+			record, err := c.source().Record(ctx, domain)
+			if err == nil {
+				if polled && record != current {
+					change := Change{
+						Domain: domain,
+						Old:    current,
+						New:    record,
+						Diff:   DiffRecords(current, record),
+						Time:   time.Now(),
+					}
+
+					select {
+					case changes <- change:
+					case <-ctx.Done():
+						return
+					}
+				}
+
+				current, polled = record, true
+			}
+
+			timer.Reset(c.pollInterval(ctx, domain, interval))
+		}
+	}()
+
+	return changes
+}
+
+// pollInterval returns the delay before the next poll of the domain.
+func (c *Checker) pollInterval(ctx context.Context, domain string, interval time.Duration) time.Duration {
+	wait := interval
+
+	if r, ok := c.resolver().(TTLResolver); ok {
+		if ttl, err := r.LookupTTL(ctx, domain, "TXT"); err == nil && ttl > wait {
+			wait = ttl
+		}
+	}
+
+	if interval == 0 && wait < MinRefreshInterval {
+		wait = MinRefreshInterval
+	}
+
+	return wait
+}
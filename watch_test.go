@@ -0,0 +1,97 @@
+package spf
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiffRecords(t *testing.T) {
+	d := DiffRecords("v=spf1 MX include:_spf.example.net -all", "v=spf1 +mx ip4:192.0.2.0/24 -all")
+
+	if len(d.Added) != 1 || d.Added[0] != "ip4:192.0.2.0/24" {
+		t.Error("Expected [ip4:192.0.2.0/24] got", d.Added)
+	}
+
+	if len(d.Removed) != 1 || d.Removed[0] != "include:_spf.example.net" {
+		t.Error("Expected [include:_spf.example.net] got", d.Removed)
+	}
+
+	if d := DiffRecords("v=spf1 a -all", "v=spf1  A  -all"); !d.Empty() {
+		t.Error("Expected an empty diff got", d)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	var record atomic.Value
+	record.Store("v=spf1 ip4:192.0.2.0/24 -all")
+
+	c := &Checker{Source: RecordSourceFunc(func(ctx context.Context, domain string) (string, error) {
+		return record.Load().(string), nil
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := c.Watch(ctx, "example.com", time.Millisecond)
+
+	time.Sleep(10 * time.Millisecond)
+	record.Store("v=spf1 ip4:198.51.100.0/24 -all")
+
+	select {
+	case change := <-changes:
+		if change.Old != "v=spf1 ip4:192.0.2.0/24 -all" {
+			t.Error("Expected v=spf1 ip4:192.0.2.0/24 -all got", change.Old)
+		}
+
+		if change.New != "v=spf1 ip4:198.51.100.0/24 -all" {
+			t.Error("Expected v=spf1 ip4:198.51.100.0/24 -all got", change.New)
+		}
+
+		if len(change.Diff.Added) != 1 || len(change.Diff.Removed) != 1 {
+			t.Error("Expected one added and one removed term got", change.Diff)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a change")
+	}
+
+	// A removed record is a change too.
+	record.Store("")
+
+	select {
+	case change := <-changes:
+		if change.New != "" {
+			t.Error("Expected no record got", change.New)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a change")
+	}
+
+	cancel()
+	for range changes {
+	}
+}
+
+func TestPollInterval(t *testing.T) {
+	z := NewZone()
+	z.Add(ZoneRecord{Name: "example.com", Type: "TXT", Data: "v=spf1 -all", TTL: 600})
+
+	c := &Checker{Resolver: z}
+	ctx := context.Background()
+
+	tests := []struct {
+		interval time.Duration
+		wait     time.Duration
+	}{
+		{time.Minute, 10 * time.Minute},
+		{time.Hour, time.Hour},
+		{0, 10 * time.Minute},
+	}
+
+	for _, test := range tests {
+		t.Log("Analyzing", test.interval)
+
+		if wait := c.pollInterval(ctx, "example.com", test.interval); wait != test.wait {
+			t.Error("Expected", test.wait, "got", wait)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package spf
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultWebhookRetries is the number of times a Webhook without
+	// Retries retries a failed delivery.
+	DefaultWebhookRetries = 3
+
+	// DefaultWebhookBackoff is the delay before the first retry of a
+	// Webhook without Backoff. It doubles after each retry.
+	DefaultWebhookBackoff = time.Second
+
+	// SignatureHeader holds the HMAC-SHA256 signature of the body of a
+	// webhook request, as "sha256=" followed by the hex encoded MAC.
+	SignatureHeader = "X-SPF-Signature"
+
+	// EventHeader holds the type of the event posted to a webhook.
+	EventHeader = "X-SPF-Event"
+)
+
+var (
+	ErrWebhookDelivery = errors.New("Webhook delivery failed.")
+)
+
+// EventType identifies the kind of policy event.
+type EventType string
+
+const (
+	RecordChanged       EventType = "record_changed"
+	RecordDisappeared   EventType = "record_disappeared"
+	RecordMalformed     EventType = "record_malformed"
+	LookupLimitExceeded EventType = "lookup_limit_exceeded"
+)
+
+// Event is a policy event reported by a Monitor or a Flattener.
+type Event struct {
+	Type    EventType `json:"type"`
+	Domain  string    `json:"domain"`
+	Time    time.Time `json:"time"`
+	Old     string    `json:"old,omitempty"`
+	New     string    `json:"new,omitempty"`
+	Added   []string  `json:"added,omitempty"`
+	Removed []string  `json:"removed,omitempty"`
+	Lookups int       `json:"lookups,omitempty"`
+}
+
+// changeEvent returns the event for a record change.
+func changeEvent(change Change) Event {
+	event := Event{
+		Type:    RecordChanged,
+		Domain:  change.Domain,
+		Time:    change.Time,
+		Old:     change.Old,
+		New:     change.New,
+		Added:   change.Diff.Added,
+		Removed: change.Diff.Removed,
+	}
+
+	if change.New == "" {
+		event.Type = RecordDisappeared
+	}
+
+	return event
+}
+
+// Webhook posts events as JSON to a set of URLs.
+type Webhook struct {
+	// URLs receive each event.
+	URLs []string
+
+	// Secret signs the body of each request with HMAC-SHA256, see
+	// SignatureHeader. If empty, requests are not signed.
+	Secret []byte
+
+	// Client sends the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Retries is the number of times a failed delivery is retried, or
+	// DefaultWebhookRetries if zero. Deliveries failing with a 4xx status
+	// other than 429 are not retried.
+	Retries int
+
+	// Backoff is the delay before the first retry, or
+	// DefaultWebhookBackoff if zero.
+	Backoff time.Duration
+
+	// OnError is called by Notify when an event could not be delivered to
+	// a URL.
+	OnError func(url string, err error)
+}
+
+// Send posts the event to every URL, retrying failed deliveries. It returns
+// ErrWebhookDelivery if any URL did not accept the event, or the context's
+// error if it was cancelled.
+func (w *Webhook) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var failed error
+	for _, url := range w.URLs {
+		if err := w.deliver(ctx, url, event.Type, body); err != nil {
+			if w.OnError != nil {
+				w.OnError(url, err)
+			}
+			failed = err
+		}
+	}
+
+	return failed
+}
+
+// Notify sends the event in the background, reporting failures to OnError.
+// Its signature fits the OnEvent fields of Monitor and Flattener.
+func (w *Webhook) Notify(event Event) {
+	go w.Send(context.Background(), event)
+}
+
+// deliver posts the body to the URL until it is accepted or the retries are
+// exhausted.
+func (w *Webhook) deliver(ctx context.Context, url string, typ EventType, body []byte) error {
+	retries := w.Retries
+	if retries == 0 {
+		retries = DefaultWebhookRetries
+	}
+
+	backoff := w.Backoff
+	if backoff == 0 {
+		backoff = DefaultWebhookBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, url, typ, body)
+		if err == nil {
+			return nil
+		}
+
+		if !retry || attempt >= retries {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// post makes a single delivery attempt. retry reports whether a failure may
+// be temporary.
+func (w *Webhook) post(ctx context.Context, url string, typ EventType, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(typ))
+	if len(w.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, ErrWebhookDelivery
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, ErrWebhookDelivery
+	}
+
+	return false, ErrWebhookDelivery
+}
+
+// Sign returns the value of the SignatureHeader for the body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports whether the signature, the value of the
+// SignatureHeader of a request, matches the body.
+func VerifySignature(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
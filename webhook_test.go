@@ -0,0 +1,130 @@
+package spf
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookSend(t *testing.T) {
+	secret := []byte("secret")
+
+	var attempts int32
+	var received Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if !VerifySignature(secret, body, r.Header.Get(SignatureHeader)) {
+			t.Error("Expected a valid signature got", r.Header.Get(SignatureHeader))
+		}
+
+		if r.Header.Get(EventHeader) != string(RecordChanged) {
+			t.Error("Expected", RecordChanged, "got", r.Header.Get(EventHeader))
+		}
+
+		json.Unmarshal(body, &received)
+	}))
+	defer srv.Close()
+
+	w := &Webhook{URLs: []string{srv.URL}, Secret: secret, Backoff: time.Millisecond}
+	event := Event{Type: RecordChanged, Domain: "example.com", Old: "v=spf1 -all", New: "v=spf1 mx -all", Added: []string{"mx"}}
+
+	if err := w.Send(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 3 {
+		t.Error("Expected 3 attempts got", attempts)
+	}
+
+	if received.Domain != "example.com" || received.New != "v=spf1 mx -all" {
+		t.Error("Unexpected event", received)
+	}
+}
+
+func TestWebhookNoRetry(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	var failed []string
+	w := &Webhook{
+		URLs:    []string{srv.URL},
+		Backoff: time.Millisecond,
+		OnError: func(url string, err error) { failed = append(failed, url) },
+	}
+
+	if err := w.Send(context.Background(), Event{Type: RecordDisappeared}); err != ErrWebhookDelivery {
+		t.Error("Expected", ErrWebhookDelivery, "got", err)
+	}
+
+	if attempts != 1 {
+		t.Error("Expected 1 attempt got", attempts)
+	}
+
+	if len(failed) != 1 || failed[0] != srv.URL {
+		t.Error("Expected", srv.URL, "got", failed)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"type":"record_changed"}`)
+	signature := Sign([]byte("secret"), body)
+
+	if !strings.HasPrefix(signature, "sha256=") {
+		t.Error("Expected a sha256= prefix got", signature)
+	}
+
+	if VerifySignature([]byte("other"), body, signature) {
+		t.Error("Expected the signature not to match another secret")
+	}
+}
+
+func TestMonitorEvents(t *testing.T) {
+	source := MapSource{"example.com": "v=spf1 -all"}
+
+	var events []Event
+	m := &Monitor{
+		Checker: &Checker{Source: source, Resolver: NewZone()},
+		OnEvent: func(event Event) { events = append(events, event) },
+	}
+	ctx := context.Background()
+
+	m.Check(ctx, "example.com")
+
+	source["example.com"] = "v=spf1 a a:1.example a:2.example a:3.example a:4.example a:5.example a:6.example a:7.example a:8.example include:_spf.example.com -all"
+	source["_spf.example.com"] = "v=spf1 mx a -all"
+	m.Check(ctx, "example.com")
+	m.Check(ctx, "example.com")
+
+	delete(source, "example.com")
+	m.Check(ctx, "example.com")
+
+	expected := []EventType{RecordChanged, LookupLimitExceeded, RecordDisappeared}
+	if len(events) != len(expected) {
+		t.Fatal("Expected", expected, "got", events)
+	}
+
+	for i, event := range events {
+		if event.Type != expected[i] {
+			t.Error("Expected", expected[i], "got", event.Type)
+		}
+	}
+
+	if events[1].Lookups != 12 {
+		t.Error("Expected 12 got", events[1].Lookups)
+	}
+}
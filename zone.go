@@ -0,0 +1,478 @@
+package spf
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultZoneTTL is the TTL given to zone records that do not define
+	// one and are not covered by a $TTL directive.
+	DefaultZoneTTL = 3600
+)
+
+var (
+	ErrInvalidZone = errors.New("Invalid zone data.")
+)
+
+// ZoneRecord is a single resource record held by a Zone. Name is the fully
+// qualified owner name and Data is the record data in presentation format,
+// e.g. "10 mail.example.com." for an MX record. TXT data holds the unquoted,
+// concatenated character-strings.
+type ZoneRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	TTL  uint32 `json:"ttl"`
+	Data string `json:"data"`
+}
+
+// Zone is an in-memory set of DNS records that implements Resolver. It allows
+// SPF records to be evaluated fully offline, for instance in CI or in
+// air-gapped environments. A Zone is safe for concurrent use.
+type Zone struct {
+	mu      sync.RWMutex
+	records map[string][]ZoneRecord
+}
+
+// NewZone returns an empty Zone.
+func NewZone() *Zone {
+	return &Zone{records: make(map[string][]ZoneRecord)}
+}
+
+// Add stores the given records in the zone.
+func (z *Zone) Add(records ...ZoneRecord) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	for _, rr := range records {
+		rr.Type = strings.ToUpper(rr.Type)
+		if rr.TTL == 0 {
+			rr.TTL = DefaultZoneTTL
+		}
+
+		name := canonicalName(rr.Name)
+		z.records[name] = append(z.records[name], rr)
+	}
+}
+
+// Records returns the records of the given type stored for name.
+func (z *Zone) Records(name, rtype string) []ZoneRecord {
+	var found []ZoneRecord
+
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+
+	rtype = strings.ToUpper(rtype)
+	for _, rr := range z.records[canonicalName(name)] {
+		if rr.Type == rtype {
+			found = append(found, rr)
+		}
+	}
+
+	return found
+}
+
+// lookup returns the data of the records of the given type stored for name.
+// A name without any records results in a not found DNS error, mirroring the
+// behaviour of net.Resolver for NXDOMAIN answers.
+func (z *Zone) lookup(name, rtype string) ([]string, error) {
+	var data []string
+
+	z.mu.RLock()
+	_, exists := z.records[canonicalName(name)]
+	z.mu.RUnlock()
+
+	if !exists {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	for _, rr := range z.Records(name, rtype) {
+		data = append(data, rr.Data)
+	}
+
+	return data, nil
+}
+
+// LookupTXT returns the TXT records for name.
+func (z *Zone) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return z.lookup(name, "TXT")
+}
+
+// LookupHost returns the A and AAAA addresses for host.
+func (z *Zone) LookupHost(ctx context.Context, host string) ([]string, error) {
+	v4, err := z.lookup(host, "A")
+	if err != nil {
+		return nil, err
+	}
+
+	v6, _ := z.lookup(host, "AAAA")
+
+	return append(v4, v6...), nil
+}
+
+// LookupIP returns the addresses of host for the network "ip4" (A records),
+// "ip6" (AAAA records) or "ip" (both).
+func (z *Zone) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	var addrs []string
+	var err error
+
+	switch network {
+	case "ip4":
+		addrs, err = z.lookup(host, "A")
+	case "ip6":
+		addrs, err = z.lookup(host, "AAAA")
+	case "ip":
+		addrs, err = z.LookupHost(ctx, host)
+	default:
+		return nil, &net.DNSError{Err: "unsupported network", Name: host}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, net.ParseIP(addr))
+	}
+
+	return ips, nil
+}
+
+// LookupMX returns the MX records for name.
+func (z *Zone) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	var mxs []*net.MX
+
+	data, err := z.lookup(name, "MX")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range data {
+		fields := strings.Fields(d)
+		if len(fields) != 2 {
+			continue
+		}
+
+		pref, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			continue
+		}
+
+		mxs = append(mxs, &net.MX{Host: fields[1], Pref: uint16(pref)})
+	}
+
+	return mxs, nil
+}
+
+// LookupAddr returns the PTR names for the given address.
+func (z *Zone) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	name, err := reverseName(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return z.lookup(name, "PTR")
+}
+
+// LookupTTL returns the lowest TTL of the records of type rtype for name.
+func (z *Zone) LookupTTL(ctx context.Context, name, rtype string) (time.Duration, error) {
+	var ttl uint32
+
+	records := z.Records(name, rtype)
+	if len(records) == 0 {
+		return 0, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	for i, rr := range records {
+		if i == 0 || rr.TTL < ttl {
+			ttl = rr.TTL
+		}
+	}
+
+	return time.Duration(ttl) * time.Second, nil
+}
+
+// ParseZone reads BIND-style zone file data from r. Relative names are
+// qualified with origin unless the data contains an $ORIGIN directive. The
+// A, AAAA, MX, PTR and TXT record types are understood, other types are
+// stored but never returned by lookups.
+func ParseZone(r io.Reader, origin string) (*Zone, error) {
+	z := NewZone()
+	ttl := uint32(DefaultZoneTTL)
+	origin = canonicalName(origin)
+
+	var owner string
+	var entry []string
+	var depth int
+	var indented bool
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+
+		tokens, open, err := zoneTokens(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		if depth == 0 {
+			indented = len(line) > 0 && (line[0] == ' ' || line[0] == '\t')
+		}
+
+		entry = append(entry, tokens...)
+		depth += open
+		if depth > 0 {
+			continue
+		}
+
+		if depth < 0 {
+			return nil, fmt.Errorf("line %d: %v", lineNo, ErrInvalidZone)
+		}
+
+		if len(entry) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(entry[0]) {
+		case "$ORIGIN":
+			if len(entry) != 2 {
+				return nil, fmt.Errorf("line %d: %v", lineNo, ErrInvalidZone)
+			}
+			origin = qualifyName(entry[1], origin)
+		case "$TTL":
+			if len(entry) != 2 {
+				return nil, fmt.Errorf("line %d: %v", lineNo, ErrInvalidZone)
+			}
+			v, err := strconv.ParseUint(entry[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, ErrInvalidZone)
+			}
+			ttl = uint32(v)
+		default:
+			rr, err := parseZoneEntry(entry, indented, owner, origin, ttl)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			owner = rr.Name
+			z.Add(rr)
+		}
+
+		entry = entry[:0]
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if depth != 0 {
+		return nil, ErrInvalidZone
+	}
+
+	return z, nil
+}
+
+// ParseZoneJSON reads a JSON array of ZoneRecords from r.
+func ParseZoneJSON(r io.Reader) (*Zone, error) {
+	var records []ZoneRecord
+
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, err
+	}
+
+	z := NewZone()
+	z.Add(records...)
+
+	return z, nil
+}
+
+// LoadZone reads a zone from the file at path. Files ending in ".json" are
+// read with ParseZoneJSON, all others with ParseZone.
+func LoadZone(path, origin string) (*Zone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.HasSuffix(path, ".json") {
+		return ParseZoneJSON(f)
+	}
+
+	return ParseZone(f, origin)
+}
+
+func parseZoneEntry(entry []string, indented bool, owner, origin string, ttl uint32) (ZoneRecord, error) {
+	var rr ZoneRecord
+
+	if !indented {
+		owner = qualifyName(entry[0], origin)
+		entry = entry[1:]
+	}
+
+	if owner == "" {
+		return rr, ErrInvalidZone
+	}
+
+	rr.Name = owner
+	rr.TTL = ttl
+
+	// The TTL and class are optional and may appear in either order.
+	for len(entry) > 0 {
+		if v, err := strconv.ParseUint(entry[0], 10, 32); err == nil {
+			rr.TTL = uint32(v)
+		} else if strings.EqualFold(entry[0], "IN") {
+			// Only the IN class is supported.
+		} else {
+			break
+		}
+		entry = entry[1:]
+	}
+
+	if len(entry) < 2 {
+		return rr, ErrInvalidZone
+	}
+
+	rr.Type = strings.ToUpper(entry[0])
+	rdata := entry[1:]
+
+	switch rr.Type {
+	case "TXT", "SPF":
+		rr.Data = strings.Join(rdata, "")
+	case "MX":
+		if len(rdata) != 2 {
+			return rr, ErrInvalidZone
+		}
+		rr.Data = rdata[0] + " " + qualifyName(rdata[1], origin) + "."
+	case "PTR", "CNAME", "NS":
+		if len(rdata) != 1 {
+			return rr, ErrInvalidZone
+		}
+		rr.Data = qualifyName(rdata[0], origin) + "."
+	case "A", "AAAA":
+		if len(rdata) != 1 || net.ParseIP(rdata[0]) == nil {
+			return rr, ErrInvalidZone
+		}
+		rr.Data = rdata[0]
+	default:
+		rr.Data = strings.Join(rdata, " ")
+	}
+
+	return rr, nil
+}
+
+// zoneTokens splits a zone file line into tokens. Quoted strings become a
+// single token without the quotes, comments are dropped and parentheses are
+// removed. The returned count is the number of opened minus closed
+// parentheses.
+func zoneTokens(line string) ([]string, int, error) {
+	var tokens []string
+	var buf strings.Builder
+	var quoted, escaped, inToken bool
+	var open int
+
+	flush := func() {
+		if inToken {
+			tokens = append(tokens, buf.String())
+			buf.Reset()
+			inToken = false
+		}
+	}
+
+	for _, ch := range line {
+		switch {
+		case escaped:
+			buf.WriteRune(ch)
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case quoted && ch == '"':
+			quoted = false
+			flush()
+		case quoted:
+			buf.WriteRune(ch)
+		case ch == '"':
+			flush()
+			quoted = true
+			inToken = true
+		case ch == ';':
+			flush()
+			return tokens, open, nil
+		case ch == '(':
+			flush()
+			open++
+		case ch == ')':
+			flush()
+			open--
+		case ch == ' ' || ch == '\t':
+			flush()
+		default:
+			buf.WriteRune(ch)
+			inToken = true
+		}
+	}
+
+	if quoted {
+		return nil, 0, ErrInvalidZone
+	}
+
+	flush()
+
+	return tokens, open, nil
+}
+
+// qualifyName returns name as a canonical fully qualified name, appending
+// origin to relative names.
+func qualifyName(name, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return canonicalName(name)
+	case origin == "":
+		return canonicalName(name)
+	}
+
+	return canonicalName(name + "." + origin)
+}
+
+// canonicalName lower-cases name and strips the trailing dot.
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// sameName reports whether both names are the same once canonical, without
+// allocating.
+func sameName(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
+
+// reverseName returns the in-addr.arpa or ip6.arpa name for addr.
+func reverseName(addr string) (string, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", &net.DNSError{Err: "unrecognized address", Name: addr}
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0]), nil
+	}
+
+	var buf strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		fmt.Fprintf(&buf, "%x.%x.", ip[i]&0x0f, ip[i]>>4)
+	}
+	buf.WriteString("ip6.arpa")
+
+	return buf.String(), nil
+}
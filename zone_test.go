@@ -0,0 +1,111 @@
+package spf
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const testZone = `
+$ORIGIN example.org.
+$TTL 300
+@        IN  TXT  "v=spf1 a mx include:_spf.example.org ip6:2001:db8::/32 " "-all"
+         IN  A    192.0.2.10
+         IN  MX   10 mail
+mail     600 IN A 192.0.2.25
+_spf     TXT  ( "v=spf1 ip4:198.51.100.0/24"   ; vendor range
+                " ~all" )
+other.example.net. IN TXT "v=spf1 ptr:example.org -all"
+25.2.0.192.in-addr.arpa. IN PTR mail.example.org.
+`
+
+func TestParseZone(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(testZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txt, _ := z.LookupTXT(context.Background(), "_spf.example.org")
+	if len(txt) != 1 || txt[0] != "v=spf1 ip4:198.51.100.0/24 ~all" {
+		t.Error("Expected _spf TXT record got", txt)
+	}
+
+	mail := z.Records("mail.example.org", "A")
+	if len(mail) != 1 || mail[0].TTL != 600 {
+		t.Error("Expected one A record with TTL 600 got", mail)
+	}
+
+	mx, _ := z.LookupMX(context.Background(), "example.org")
+	if len(mx) != 1 || mx[0].Host != "mail.example.org." || mx[0].Pref != 10 {
+		t.Error("Expected MX mail.example.org. got", mx)
+	}
+
+	_, err = z.LookupHost(context.Background(), "missing.example.org")
+	if !isNotFound(err) {
+		t.Error("Expected not found error got", err)
+	}
+
+	bad := []string{
+		`@ IN TXT "unterminated`,
+		`@ IN A not-an-ip`,
+		`@ IN MX mail`,
+		`@ TXT ( "v=spf1"`,
+	}
+
+	for _, zone := range bad {
+		if _, err := ParseZone(strings.NewReader(zone), "example.org"); err == nil {
+			t.Log("Analyzing", zone)
+			t.Error("Expected error got nil")
+		}
+	}
+}
+
+func TestZoneResolver(t *testing.T) {
+	z, err := ParseZone(strings.NewReader(testZone), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Resolver: z}
+
+	tests := []spftest{
+		spftest{"192.0.2.10", "info@example.org", Pass},
+		spftest{"192.0.2.25", "info@example.org", Pass},
+		spftest{"198.51.100.7", "info@example.org", Pass},
+		spftest{"2001:db8::25", "info@example.org", Pass},
+		spftest{"203.0.113.1", "info@example.org", Fail},
+		spftest{"203.0.113.1", "info@missing.example.org", None},
+	}
+
+	for _, expected := range tests {
+		actual, _ := c.SPFTest(context.Background(), expected.server, expected.email)
+
+		if actual != expected.result {
+			t.Error("For", expected.server, "at", expected.email, "Expected", expected.result, "got", actual)
+		}
+	}
+}
+
+func TestParseZoneJSON(t *testing.T) {
+	data := `[
+		{"name": "example.com.", "type": "TXT", "data": "v=spf1 a -all"},
+		{"name": "example.com", "type": "a", "ttl": 60, "data": "192.0.2.1"}
+	]`
+
+	z, err := ParseZoneJSON(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checker{Resolver: z}
+
+	result, _ := c.SPFTest(context.Background(), "192.0.2.1", "info@example.com")
+	if result != Pass {
+		t.Error("Expected", Pass, "got", result)
+	}
+
+	a := z.Records("example.com", "A")
+	if len(a) != 1 || a[0].TTL != 60 {
+		t.Error("Expected one A record with TTL 60 got", a)
+	}
+}